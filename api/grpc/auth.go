@@ -3,34 +3,174 @@ package grpc
 import (
 	"context"
 	"fmt"
+	"github.com/awakari/interests/config"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"strings"
 )
 
 const keyGroupId = "x-awakari-group-id"
 const keyUserId = "x-awakari-user-id"
+const keyRoles = "x-awakari-roles"
 
-func getAuthInfo(ctx context.Context) (groupId, userId string, err error) {
-	md, ok := metadata.FromIncomingContext(ctx)
-	if !ok {
+// KeyGateway marks the request metadata of the calls relayed by the REST gateway. Such calls are from the outside of
+// the cluster, so they never get the roles from the metadata nor the anonymous roles.
+const KeyGateway = "x-awakari-gateway"
+
+// Principal is the authenticated caller.
+type Principal struct {
+	GroupId string
+	UserId  string
+	Roles   []string
+}
+
+// Authenticator resolves the caller from the incoming request context.
+// The principal roles may be resolved even when the identity is not, the error is returned in this case.
+// The caller without any credentials gets the configured anonymous roles.
+type Authenticator interface {
+	Authenticate(ctx context.Context) (p Principal, err error)
+}
+
+type authInfo struct {
+	p   Principal
+	err error
+}
+
+type ctxKeyAuthInfo struct{}
+
+type trustedGatewayAuthenticator struct {
+	anonymousRoles []string
+	trustRoles     bool
+}
+
+// NewAuthenticator returns the Authenticator configured by the specified config.
+func NewAuthenticator(cfgAuth config.AuthConfig) (a Authenticator, err error) {
+	switch cfgAuth.TrustedGateway {
+	case true:
+		a = NewTrustedGatewayAuthenticator(cfgAuth.AnonymousRoles, cfgAuth.TrustedGatewayRoles)
+	default:
+		a, err = NewJwtAuthenticator(cfgAuth)
+	}
+	return
+}
+
+// NewTrustedGatewayAuthenticator returns the Authenticator taking the caller identity from the request metadata as is.
+// Should be used only when the callers are authenticated by the gateway in front of the service. The roles are taken
+// from the metadata only when trustRoles is set: the metadata is client controlled, so the gateway should strip the
+// roles of every incoming request and set them for the authenticated callers only.
+func NewTrustedGatewayAuthenticator(anonymousRoles []string, trustRoles bool) Authenticator {
+	return trustedGatewayAuthenticator{
+		anonymousRoles: anonymousRoles,
+		trustRoles:     trustRoles,
+	}
+}
+
+func (tga trustedGatewayAuthenticator) Authenticate(ctx context.Context) (p Principal, err error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	p.GroupId = getMetadataValue(md, keyGroupId)
+	p.UserId = getMetadataValue(md, keyUserId)
+	relayed := isRelayed(md)
+	if roles := getMetadataValue(md, keyRoles); roles != "" && tga.trustRoles && !relayed {
+		p.Roles = splitRoles(roles, ",")
+	}
+	switch {
+	case p.GroupId == "" && p.UserId == "" && relayed:
+	case p.GroupId == "" && p.UserId == "" && len(p.Roles) == 0:
+		p.Roles = tga.anonymousRoles
+	case len(p.Roles) == 0:
+		p.Roles = []string{RoleUser}
+	}
+	switch {
+	case md == nil:
 		err = status.Error(codes.Unauthenticated, "missing request metadata")
+	case p.GroupId == "":
+		err = status.Error(codes.Unauthenticated, fmt.Sprintf("missing value for %s in request metadata", keyGroupId))
+	case p.UserId == "":
+		err = status.Error(codes.Unauthenticated, fmt.Sprintf("missing value for %s in request metadata", keyUserId))
+	}
+	return
+}
+
+// authUnaryInterceptor resolves the caller identity and puts it into the request context.
+// The request is not rejected when the identity can not be resolved: some methods don't require it,
+// the others fail with the authentication error on getAuthInfo.
+func authUnaryInterceptor(a Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(withAuthInfo(ctx, a), req)
 	}
-	if err == nil {
-		groupId = getMetadataValue(md, keyGroupId)
-		if groupId == "" {
-			err = status.Error(codes.Unauthenticated, fmt.Sprintf("missing value for %s in request metadata", keyGroupId))
+}
+
+func authStreamInterceptor(a Authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, authServerStream{
+			ServerStream: ss,
+			ctx:          withAuthInfo(ss.Context(), a),
+		})
+	}
+}
+
+type authServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ass authServerStream) Context() context.Context {
+	return ass.ctx
+}
+
+func withAuthInfo(ctx context.Context, a Authenticator) context.Context {
+	var ai authInfo
+	ai.p, ai.err = a.Authenticate(ctx)
+	return context.WithValue(ctx, ctxKeyAuthInfo{}, ai)
+}
+
+func getAuthInfo(ctx context.Context) (groupId, userId string, err error) {
+	ai, ok := ctx.Value(ctxKeyAuthInfo{}).(authInfo)
+	switch ok {
+	case true:
+		groupId, userId, err = ai.p.GroupId, ai.p.UserId, ai.err
+	default:
+		err = status.Error(codes.Unauthenticated, "missing authentication info")
+	}
+	return
+}
+
+// getActor returns the caller identity for the audit: the service principal may have no group id.
+func getActor(ctx context.Context) (actor string) {
+	if ai, ok := ctx.Value(ctxKeyAuthInfo{}).(authInfo); ok {
+		switch ai.p.GroupId {
+		case "":
+			actor = ai.p.UserId
+		default:
+			actor = ai.p.GroupId + "/" + ai.p.UserId
 		}
 	}
-	if err == nil {
-		userId = getMetadataValue(md, keyUserId)
-		if userId == "" {
-			err = status.Error(codes.Unauthenticated, fmt.Sprintf("missing value for %s in request metadata", keyUserId))
+	return
+}
+
+func getRoles(ctx context.Context) (roles []string) {
+	if ai, ok := ctx.Value(ctxKeyAuthInfo{}).(authInfo); ok {
+		roles = ai.p.Roles
+	}
+	return
+}
+
+func splitRoles(src, sep string) (roles []string) {
+	for _, r := range strings.Split(src, sep) {
+		if r = strings.TrimSpace(r); r != "" {
+			roles = append(roles, r)
 		}
 	}
 	return
 }
 
+// isRelayed returns true when the request is relayed by the REST gateway.
+func isRelayed(md metadata.MD) bool {
+	return len(md.Get(KeyGateway)) > 0
+}
+
 func getMetadataValue(md metadata.MD, k string) (v string) {
 	var vals []string
 	if vals = md.Get(k); len(vals) > 0 {
@@ -0,0 +1,216 @@
+package grpc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/awakari/interests/config"
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"math/big"
+	"os"
+	"strings"
+)
+
+const keyAuthorization = "authorization"
+const prefixBearer = "bearer "
+
+const (
+	jwkKeyTypeOct = "oct"
+	jwkKeyTypeRsa = "RSA"
+)
+
+type jwtAuthenticator struct {
+	keys           map[string]any
+	parser         *jwt.Parser
+	claimGroupId   string
+	claimUserId    string
+	claimRoles     string
+	anonymousRoles []string
+}
+
+// jwk is the subset of the JSON Web Key fields used for the HS256 and RS256 signature verification.
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// NewJwtAuthenticator returns the Authenticator verifying the bearer JWT from the request metadata.
+// The verification keys are loaded from the JSON Web Key Set file. The token's "kid" header selects the key,
+// it may be omitted when the set contains a single key only.
+func NewJwtAuthenticator(cfgAuth config.AuthConfig) (a Authenticator, err error) {
+	var keys map[string]any
+	keys, err = loadKeySet(cfgAuth.Jwt.KeysFile)
+	if err == nil {
+		opts := []jwt.ParserOption{
+			jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg()}),
+		}
+		if cfgAuth.Jwt.Issuer != "" {
+			opts = append(opts, jwt.WithIssuer(cfgAuth.Jwt.Issuer))
+		}
+		if cfgAuth.Jwt.Audience != "" {
+			opts = append(opts, jwt.WithAudience(cfgAuth.Jwt.Audience))
+		}
+		a = jwtAuthenticator{
+			keys:           keys,
+			parser:         jwt.NewParser(opts...),
+			claimGroupId:   cfgAuth.Jwt.ClaimGroupId,
+			claimUserId:    cfgAuth.Jwt.ClaimUserId,
+			claimRoles:     cfgAuth.Jwt.ClaimRoles,
+			anonymousRoles: cfgAuth.AnonymousRoles,
+		}
+	}
+	return
+}
+
+func (ja jwtAuthenticator) Authenticate(ctx context.Context) (p Principal, err error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	tokenStr := getMetadataValue(md, keyAuthorization)
+	switch {
+	case tokenStr == "":
+		if !isRelayed(md) {
+			p.Roles = ja.anonymousRoles
+		}
+		err = status.Error(codes.Unauthenticated, fmt.Sprintf("missing bearer token for %s in request metadata", keyAuthorization))
+	case len(tokenStr) > len(prefixBearer) && strings.EqualFold(tokenStr[:len(prefixBearer)], prefixBearer):
+		tokenStr = tokenStr[len(prefixBearer):]
+	default:
+		err = status.Error(codes.Unauthenticated, fmt.Sprintf("missing bearer token for %s in request metadata", keyAuthorization))
+	}
+	claims := jwt.MapClaims{}
+	if err == nil {
+		_, err = ja.parser.ParseWithClaims(tokenStr, claims, ja.key)
+		if err != nil {
+			err = status.Error(codes.Unauthenticated, fmt.Sprintf("invalid token: %s", err))
+		}
+	}
+	if err == nil {
+		p.GroupId, _ = claims[ja.claimGroupId].(string)
+		p.UserId, _ = claims[ja.claimUserId].(string)
+		p.Roles = claimRoles(claims[ja.claimRoles])
+		if len(p.Roles) == 0 {
+			p.Roles = []string{RoleUser}
+		}
+		switch {
+		case p.GroupId == "":
+			err = status.Error(codes.Unauthenticated, fmt.Sprintf("missing value for %s in token claims", ja.claimGroupId))
+		case p.UserId == "":
+			err = status.Error(codes.Unauthenticated, fmt.Sprintf("missing value for %s in token claims", ja.claimUserId))
+		}
+	}
+	return
+}
+
+// claimRoles accepts both the list of roles and the space separated string, like the "scope" claim.
+func claimRoles(src any) (roles []string) {
+	switch v := src.(type) {
+	case string:
+		roles = splitRoles(v, " ")
+	case []any:
+		for _, r := range v {
+			if s, ok := r.(string); ok && s != "" {
+				roles = append(roles, s)
+			}
+		}
+	}
+	return
+}
+
+func (ja jwtAuthenticator) key(t *jwt.Token) (k any, err error) {
+	kid, _ := t.Header["kid"].(string)
+	var found bool
+	switch {
+	case kid == "" && len(ja.keys) == 1:
+		for _, k = range ja.keys {
+			found = true
+		}
+	default:
+		k, found = ja.keys[kid]
+	}
+	if !found {
+		err = fmt.Errorf("unknown key id: %q", kid)
+	}
+	// prevent the key type confusion, e.g. the RSA public key used as the HMAC secret
+	if err == nil {
+		switch k.(type) {
+		case []byte:
+			if t.Method != jwt.SigningMethodHS256 {
+				err = fmt.Errorf("signing method %s doesn't match the key %q", t.Method.Alg(), kid)
+			}
+		case *rsa.PublicKey:
+			if t.Method != jwt.SigningMethodRS256 {
+				err = fmt.Errorf("signing method %s doesn't match the key %q", t.Method.Alg(), kid)
+			}
+		}
+	}
+	return
+}
+
+func loadKeySet(path string) (keys map[string]any, err error) {
+	if path == "" {
+		err = errors.New("JWT keys file is not configured")
+	}
+	var data []byte
+	if err == nil {
+		data, err = os.ReadFile(path)
+	}
+	var set jwks
+	if err == nil {
+		err = json.Unmarshal(data, &set)
+	}
+	if err == nil {
+		keys = make(map[string]any, len(set.Keys))
+		for _, k := range set.Keys {
+			keys[k.Kid], err = decodeJwk(k)
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err == nil && len(keys) == 0 {
+		err = errors.New("no keys in the set")
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to load the JWT keys from %s: %w", path, err)
+	}
+	return
+}
+
+func decodeJwk(k jwk) (key any, err error) {
+	switch k.Kty {
+	case jwkKeyTypeOct:
+		var secret []byte
+		secret, err = base64.RawURLEncoding.DecodeString(k.K)
+		if err == nil && len(secret) == 0 {
+			err = fmt.Errorf("empty secret for the key %q", k.Kid)
+		}
+		key = secret
+	case jwkKeyTypeRsa:
+		var n, e []byte
+		n, err = base64.RawURLEncoding.DecodeString(k.N)
+		if err == nil {
+			e, err = base64.RawURLEncoding.DecodeString(k.E)
+		}
+		if err == nil {
+			key = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		}
+	default:
+		err = fmt.Errorf("unsupported key type %q for the key %q", k.Kty, k.Kid)
+	}
+	return
+}
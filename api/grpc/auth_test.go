@@ -0,0 +1,286 @@
+package grpc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/awakari/interests/config"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJwtAuthenticator_Authenticate(t *testing.T) {
+	//
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	rsaKeyOther, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	keysFile := filepath.Join(t.TempDir(), "jwks.json")
+	data, err := json.Marshal(jwks{
+		Keys: []jwk{
+			{
+				Kid: "hs0",
+				Kty: jwkKeyTypeOct,
+				K:   base64.RawURLEncoding.EncodeToString(secret),
+			},
+			{
+				Kid: "rs0",
+				Kty: jwkKeyTypeRsa,
+				N:   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+		},
+	})
+	require.Nil(t, err)
+	require.Nil(t, os.WriteFile(keysFile, data, 0600))
+	var cfgAuth config.AuthConfig
+	cfgAuth.Jwt.KeysFile = keysFile
+	cfgAuth.Jwt.Issuer = "issuer0"
+	cfgAuth.Jwt.ClaimGroupId = "groupId"
+	cfgAuth.Jwt.ClaimUserId = "sub"
+	cfgAuth.Jwt.ClaimRoles = "roles"
+	cfgAuth.AnonymousRoles = []string{"anonymous0"}
+	a, err := NewAuthenticator(cfgAuth)
+	require.Nil(t, err)
+	//
+	sign := func(method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		s, err := token.SignedString(key)
+		require.Nil(t, err)
+		return s
+	}
+	claims := jwt.MapClaims{
+		"iss":     "issuer0",
+		"sub":     "user0",
+		"groupId": "group0",
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}
+	cases := map[string]struct {
+		authz   string
+		relayed bool
+		groupId string
+		userId  string
+		roles   []string
+		err     error
+	}{
+		"hs256": {
+			authz:   "Bearer " + sign(jwt.SigningMethodHS256, "hs0", secret, claims),
+			groupId: "group0",
+			userId:  "user0",
+			roles:   []string{RoleUser},
+		},
+		"rs256": {
+			authz:   "bearer " + sign(jwt.SigningMethodRS256, "rs0", rsaKey, claims),
+			groupId: "group0",
+			userId:  "user0",
+			roles:   []string{RoleUser},
+		},
+		"roles list": {
+			authz: "Bearer " + sign(jwt.SigningMethodHS256, "hs0", secret, jwt.MapClaims{
+				"iss":     "issuer0",
+				"sub":     "user0",
+				"groupId": "group0",
+				"roles":   []string{RoleInternal, RoleUser},
+			}),
+			groupId: "group0",
+			userId:  "user0",
+			roles:   []string{RoleInternal, RoleUser},
+		},
+		"service principal": {
+			authz: "Bearer " + sign(jwt.SigningMethodHS256, "hs0", secret, jwt.MapClaims{
+				"iss":   "issuer0",
+				"sub":   "resolver",
+				"roles": RoleInternal,
+			}),
+			userId: "resolver",
+			roles:  []string{RoleInternal},
+			err:    status.Error(codes.Unauthenticated, "missing value for groupId in token claims"),
+		},
+		"missing token": {
+			roles: []string{"anonymous0"},
+			err:   status.Error(codes.Unauthenticated, "missing bearer token for authorization in request metadata"),
+		},
+		"missing token relayed": {
+			relayed: true,
+			err:     status.Error(codes.Unauthenticated, "missing bearer token for authorization in request metadata"),
+		},
+		"not a bearer": {
+			authz: "Basic dXNlcjA6cGFzc3dvcmQ=",
+			err:   status.Error(codes.Unauthenticated, "missing bearer token for authorization in request metadata"),
+		},
+		"unknown key": {
+			authz: "Bearer " + sign(jwt.SigningMethodHS256, "hs1", secret, claims),
+			err:   status.Error(codes.Unauthenticated, "invalid token: token is unverifiable: error while executing keyfunc: unknown key id: \"hs1\""),
+		},
+		"wrong signature": {
+			authz: "Bearer " + sign(jwt.SigningMethodRS256, "rs0", rsaKeyOther, claims),
+			err:   status.Error(codes.Unauthenticated, "invalid token: token signature is invalid: crypto/rsa: verification error"),
+		},
+		"key type mismatch": {
+			authz: "Bearer " + sign(jwt.SigningMethodHS256, "rs0", secret, claims),
+			err:   status.Error(codes.Unauthenticated, "invalid token: token is unverifiable: error while executing keyfunc: signing method HS256 doesn't match the key \"rs0\""),
+		},
+		"expired": {
+			authz: "Bearer " + sign(jwt.SigningMethodHS256, "hs0", secret, jwt.MapClaims{
+				"iss":     "issuer0",
+				"sub":     "user0",
+				"groupId": "group0",
+				"exp":     time.Now().Add(-time.Hour).Unix(),
+			}),
+			err: status.Error(codes.Unauthenticated, "invalid token: token has invalid claims: token is expired"),
+		},
+		"wrong issuer": {
+			authz: "Bearer " + sign(jwt.SigningMethodHS256, "hs0", secret, jwt.MapClaims{
+				"iss":     "issuer1",
+				"sub":     "user0",
+				"groupId": "group0",
+			}),
+			err: status.Error(codes.Unauthenticated, "invalid token: token has invalid claims: token has invalid issuer"),
+		},
+		"missing group": {
+			authz: "Bearer " + sign(jwt.SigningMethodHS256, "hs0", secret, jwt.MapClaims{
+				"iss": "issuer0",
+				"sub": "user0",
+			}),
+			userId: "user0",
+			roles:  []string{RoleUser},
+			err:    status.Error(codes.Unauthenticated, "missing value for groupId in token claims"),
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			md := metadata.MD{}
+			if c.authz != "" {
+				md.Set(keyAuthorization, c.authz)
+			}
+			if c.relayed {
+				md.Set(KeyGateway, "http")
+			}
+			ctx := metadata.NewIncomingContext(context.TODO(), md)
+			p, err := a.Authenticate(ctx)
+			assert.Equal(t, c.groupId, p.GroupId)
+			assert.Equal(t, c.userId, p.UserId)
+			assert.Equal(t, c.roles, p.Roles)
+			assert.ErrorIs(t, err, c.err)
+		})
+	}
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	cases := map[string]struct {
+		trusted  bool
+		keysFile string
+		keys     string
+		err      bool
+	}{
+		"trusted gateway": {
+			trusted: true,
+		},
+		"keys file not set": {
+			err: true,
+		},
+		"keys file missing": {
+			keysFile: "missing.json",
+			err:      true,
+		},
+		"empty key set": {
+			keysFile: "jwks.json",
+			keys:     `{"keys":[]}`,
+			err:      true,
+		},
+		"unsupported key type": {
+			keysFile: "jwks.json",
+			keys:     `{"keys":[{"kid":"ec0","kty":"EC"}]}`,
+			err:      true,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			var cfgAuth config.AuthConfig
+			cfgAuth.TrustedGateway = c.trusted
+			if c.keysFile != "" {
+				cfgAuth.Jwt.KeysFile = filepath.Join(t.TempDir(), c.keysFile)
+			}
+			if c.keys != "" {
+				require.Nil(t, os.WriteFile(cfgAuth.Jwt.KeysFile, []byte(c.keys), 0600))
+			}
+			a, err := NewAuthenticator(cfgAuth)
+			if c.err {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.NotNil(t, a)
+			}
+		})
+	}
+}
+
+func TestTrustedGatewayAuthenticator_Authenticate(t *testing.T) {
+	cases := map[string]struct {
+		trustRoles bool
+		md         []string
+		groupId    string
+		userId     string
+		roles      []string
+		err        error
+	}{
+		"identity": {
+			md:      []string{keyGroupId, "group0", keyUserId, "user0"},
+			groupId: "group0",
+			userId:  "user0",
+			roles:   []string{RoleUser},
+		},
+		"roles are not trusted": {
+			md:      []string{keyGroupId, "group0", keyUserId, "user0", keyRoles, RoleInternal},
+			groupId: "group0",
+			userId:  "user0",
+			roles:   []string{RoleUser},
+		},
+		"anonymous roles are not trusted": {
+			md:    []string{keyRoles, RoleInternal},
+			roles: []string{"anonymous0"},
+			err:   status.Error(codes.Unauthenticated, "missing value for x-awakari-group-id in request metadata"),
+		},
+		"roles are trusted": {
+			trustRoles: true,
+			md:         []string{keyGroupId, "group0", keyUserId, "user0", keyRoles, RoleInternal},
+			groupId:    "group0",
+			userId:     "user0",
+			roles:      []string{RoleInternal},
+		},
+		"trusted roles relayed": {
+			trustRoles: true,
+			md:         []string{keyGroupId, "group0", keyUserId, "user0", keyRoles, RoleInternal, KeyGateway, "http"},
+			groupId:    "group0",
+			userId:     "user0",
+			roles:      []string{RoleUser},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			a := NewTrustedGatewayAuthenticator([]string{"anonymous0"}, c.trustRoles)
+			ctx := metadata.NewIncomingContext(context.TODO(), metadata.Pairs(c.md...))
+			p, err := a.Authenticate(ctx)
+			assert.Equal(t, c.groupId, p.GroupId)
+			assert.Equal(t, c.userId, p.UserId)
+			assert.Equal(t, c.roles, p.Roles)
+			assert.ErrorIs(t, err, c.err)
+		})
+	}
+}
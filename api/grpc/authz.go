@@ -0,0 +1,189 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"os"
+	"slices"
+	"strings"
+)
+
+const (
+	// RoleUser is the end user role.
+	RoleUser = "user"
+	// RoleInternal is the role of the internal service principal.
+	RoleInternal = "internal"
+)
+
+// names of the privileged request flags
+const (
+	flagInternal = "internal"
+	flagAll      = "all"
+)
+
+const prefixServiceMethod = "/awakari.interests.Service/"
+
+// Policy defines the roles allowed to call every Service method and to use every privileged request flag.
+// A flag is identified by the method name and the flag name separated by the dot, e.g. "Read.internal".
+// The method or the flag missing in the policy is not allowed for anybody.
+type Policy struct {
+	Methods map[string][]string `json:"methods"`
+	Flags   map[string][]string `json:"flags"`
+}
+
+// DefaultPolicy allows the end users to manage their interests only, the rest is for the internal services.
+func DefaultPolicy() Policy {
+	both := []string{RoleUser, RoleInternal}
+	internal := []string{RoleInternal}
+	return Policy{
+		Methods: map[string][]string{
+			"Create":                  both,
+			"Read":                    both,
+			"Update":                  both,
+			"Delete":                  both,
+			"Restore":                 both,
+			"ListDeleted":             both,
+			"SearchOwn":               both,
+			"Search":                  both,
+			"DryRun":                  both,
+			"UpdateFollowers":         internal,
+			"UpdateResultTime":        internal,
+			"SetEnabledBatch":         internal,
+			"ChangeOwner":             internal,
+			"Purge":                   internal,
+			"SearchByCondition":       internal,
+			"SearchByConditionStream": internal,
+			"SearchByConditionBatch":  internal,
+			"WatchChanges":            internal,
+			"ListRevisions":           both,
+			"ReadRevision":            both,
+			"RestoreRevision":         both,
+			"Export":                  both,
+			"Import":                  both,
+			"GetQuota":                both,
+		},
+		Flags: map[string][]string{
+			"Read." + flagInternal:            internal,
+			"Update." + flagInternal:          internal,
+			"Search." + flagAll:               internal,
+			"DryRun." + flagInternal:          internal,
+			"ListRevisions." + flagInternal:   internal,
+			"ReadRevision." + flagInternal:    internal,
+			"RestoreRevision." + flagInternal: internal,
+		},
+	}
+}
+
+// LoadPolicy reads the policy from the JSON file, returns the DefaultPolicy when the path is empty.
+func LoadPolicy(path string) (p Policy, err error) {
+	switch path {
+	case "":
+		p = DefaultPolicy()
+	default:
+		var data []byte
+		data, err = os.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(data, &p)
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to load the authorization policy from %s: %w", path, err)
+		}
+	}
+	return
+}
+
+func (p Policy) authorizeMethod(method string, roles []string) (err error) {
+	if !anyRoleAllowed(p.Methods[method], roles) {
+		err = status.Error(codes.PermissionDenied, fmt.Sprintf("method %s is not allowed for roles %v", method, roles))
+	}
+	return
+}
+
+func (p Policy) authorizeFlags(method string, req any, roles []string) (err error) {
+	var flags []string
+	if r, ok := req.(interface{ GetInternal() bool }); ok && r.GetInternal() {
+		flags = append(flags, flagInternal)
+	}
+	if r, ok := req.(interface{ GetAll() bool }); ok && r.GetAll() {
+		flags = append(flags, flagAll)
+	}
+	for _, flag := range flags {
+		if !anyRoleAllowed(p.Flags[method+"."+flag], roles) {
+			err = status.Error(
+				codes.PermissionDenied, fmt.Sprintf("flag %s of method %s is not allowed for roles %v", flag, method, roles),
+			)
+			break
+		}
+	}
+	return
+}
+
+func anyRoleAllowed(allowed, roles []string) (ok bool) {
+	for _, r := range roles {
+		if ok = slices.Contains(allowed, r); ok {
+			break
+		}
+	}
+	return
+}
+
+// authzUnaryInterceptor enforces the policy for the Service methods, should follow the authUnaryInterceptor.
+func authzUnaryInterceptor(p Policy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		method, found := strings.CutPrefix(info.FullMethod, prefixServiceMethod)
+		if found {
+			roles := getRoles(ctx)
+			err = p.authorizeMethod(method, roles)
+			if err == nil {
+				err = p.authorizeFlags(method, req, roles)
+			}
+		}
+		if err == nil {
+			resp, err = handler(ctx, req)
+		}
+		return
+	}
+}
+
+// authzStreamInterceptor enforces the policy for the Service streaming methods, the flags are checked on every
+// received request message.
+func authzStreamInterceptor(p Policy) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		method, found := strings.CutPrefix(info.FullMethod, prefixServiceMethod)
+		if found {
+			roles := getRoles(ss.Context())
+			err = p.authorizeMethod(method, roles)
+			if err == nil {
+				ss = authzServerStream{
+					ServerStream: ss,
+					p:            p,
+					method:       method,
+					roles:        roles,
+				}
+			}
+		}
+		if err == nil {
+			err = handler(srv, ss)
+		}
+		return
+	}
+}
+
+type authzServerStream struct {
+	grpc.ServerStream
+	p      Policy
+	method string
+	roles  []string
+}
+
+func (ass authzServerStream) RecvMsg(m any) (err error) {
+	err = ass.ServerStream.RecvMsg(m)
+	if err == nil {
+		err = ass.p.authorizeFlags(ass.method, m, ass.roles)
+	}
+	return
+}
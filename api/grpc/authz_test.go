@@ -0,0 +1,135 @@
+package grpc
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuthzUnaryInterceptor(t *testing.T) {
+	//
+	authn := NewTrustedGatewayAuthenticator([]string{"anonymous"}, true)
+	interceptor := authzUnaryInterceptor(DefaultPolicy())
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	}
+	//
+	cases := map[string]struct {
+		md     []string
+		method string
+		req    any
+		err    error
+	}{
+		"user calls public method": {
+			md:     []string{keyGroupId, "group0", keyUserId, "user0"},
+			method: "Read",
+			req:    &ReadRequest{},
+		},
+		"user uses internal flag": {
+			md:     []string{keyGroupId, "group0", keyUserId, "user0"},
+			method: "Read",
+			req: &ReadRequest{
+				Internal: true,
+			},
+			err: status.Error(codes.PermissionDenied, "flag internal of method Read is not allowed for roles [user]"),
+		},
+		"user uses all flag": {
+			md:     []string{keyGroupId, "group0", keyUserId, "user0"},
+			method: "Search",
+			req: &SearchRequest{
+				All: true,
+			},
+			err: status.Error(codes.PermissionDenied, "flag all of method Search is not allowed for roles [user]"),
+		},
+		"user calls internal method": {
+			md:     []string{keyGroupId, "group0", keyUserId, "user0"},
+			method: "SetEnabledBatch",
+			req:    &SetEnabledBatchRequest{},
+			err:    status.Error(codes.PermissionDenied, "method SetEnabledBatch is not allowed for roles [user]"),
+		},
+		"internal calls internal method": {
+			md:     []string{keyRoles, "internal"},
+			method: "SearchByCondition",
+			req:    &SearchByConditionRequest{},
+		},
+		"internal uses internal flag": {
+			md:     []string{keyRoles, "internal"},
+			method: "Update",
+			req: &UpdateRequest{
+				Internal: true,
+			},
+		},
+		"anonymous": {
+			method: "ChangeOwner",
+			req:    &ChangeOwnerRequest{},
+			err:    status.Error(codes.PermissionDenied, "method ChangeOwner is not allowed for roles [anonymous]"),
+		},
+		"relayed roles are ignored": {
+			md:     []string{keyGroupId, "group0", keyUserId, "user0", keyRoles, "internal", KeyGateway, "http"},
+			method: "ChangeOwner",
+			req:    &ChangeOwnerRequest{},
+			err:    status.Error(codes.PermissionDenied, "method ChangeOwner is not allowed for roles [user]"),
+		},
+		"relayed anonymous": {
+			md:     []string{KeyGateway, "http"},
+			method: "Read",
+			req:    &ReadRequest{},
+			err:    status.Error(codes.PermissionDenied, "method Read is not allowed for roles []"),
+		},
+		"unknown method": {
+			md:     []string{keyRoles, "internal, user"},
+			method: "Unknown",
+			req:    &ReadRequest{},
+			err:    status.Error(codes.PermissionDenied, "method Unknown is not allowed for roles [internal user]"),
+		},
+		"non-service method": {
+			method: "/grpc.health.v1.Health/Check",
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.TODO(), metadata.Pairs(c.md...))
+			ctx = withAuthInfo(ctx, authn)
+			info := &grpc.UnaryServerInfo{
+				FullMethod: prefixServiceMethod + c.method,
+			}
+			if c.req == nil {
+				info.FullMethod = c.method
+			}
+			resp, err := interceptor(ctx, c.req, info, handler)
+			if c.err == nil {
+				assert.Nil(t, err)
+				assert.Equal(t, "ok", resp)
+			} else {
+				assert.Nil(t, resp)
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	//
+	p, err := LoadPolicy("")
+	require.Nil(t, err)
+	assert.Equal(t, DefaultPolicy(), p)
+	//
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.Nil(t, os.WriteFile(path, []byte(`{"methods":{"Read":["admin"]},"flags":{"Read.internal":["admin"]}}`), 0600))
+	p, err = LoadPolicy(path)
+	require.Nil(t, err)
+	assert.Nil(t, p.authorizeMethod("Read", []string{"admin"}))
+	assert.Nil(t, p.authorizeFlags("Read", &ReadRequest{Internal: true}, []string{"admin"}))
+	assert.NotNil(t, p.authorizeMethod("Create", []string{"admin"}))
+	//
+	_, err = LoadPolicy(filepath.Join(t.TempDir(), "missing.json"))
+	assert.NotNil(t, err)
+}
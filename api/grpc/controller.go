@@ -6,21 +6,37 @@ import (
 	"fmt"
 	"github.com/awakari/interests/api/grpc/common"
 	"github.com/awakari/interests/model/condition"
+	"github.com/awakari/interests/model/dsl"
 	"github.com/awakari/interests/model/interest"
+	"github.com/awakari/interests/model/matcher"
 	"github.com/awakari/interests/storage"
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
+	"strings"
 	"time"
 )
 
+// ExportVersion is the current InterestsDocument format version.
+const ExportVersion = 1
+
+// changesPageLimit is the max count of the changes read from the storage at once.
+const changesPageLimit = 100
+
+// changesPollInterval is the period to check for the new changes when all the previous ones are sent.
+const changesPollInterval = 1 * time.Second
+
 type serviceController struct {
-	stor storage.Storage
+	stor   storage.Storage
+	quotas storage.Quotas
 }
 
-func NewServiceController(stor storage.Storage) ServiceServer {
+func NewServiceController(stor storage.Storage, quotas storage.Quotas) ServiceServer {
 	return serviceController{
-		stor: stor,
+		stor:   stor,
+		quotas: quotas,
 	}
 }
 
@@ -36,8 +52,14 @@ func (sc serviceController) Create(ctx context.Context, req *CreateRequest) (res
 	}
 	if err == nil {
 		var cond condition.Condition
-		cond, err = decodeCondition(req.Cond)
+		cond, err = decodeConditionOrQuery(req.Cond, req.Query)
+		if err == nil {
+			err = validateCondition(cond)
+		}
 		if err == nil {
+			if req.Normalize {
+				cond = condition.Normalize(cond)
+			}
 			sd := interest.Data{
 				Description: req.Description,
 				Enabled:     req.Enabled,
@@ -71,6 +93,8 @@ func (sc serviceController) Read(ctx context.Context, req *ReadRequest) (resp *R
 		if err == nil {
 			resp.Cond = &Condition{}
 			encodeCondition(sd.Condition, resp.Cond)
+			// the stored condition may have no query form, the tree is returned anyway
+			resp.Query, _ = dsl.Print(sd.Condition)
 			resp.Description = sd.Description
 			resp.Enabled = sd.Enabled
 			if !sd.EnabledSince.IsZero() {
@@ -107,9 +131,15 @@ func (sc serviceController) Update(ctx context.Context, req *UpdateRequest) (res
 	}
 	var cond condition.Condition
 	if err == nil {
-		cond, err = decodeCondition(req.Cond)
+		cond, err = decodeConditionOrQuery(req.Cond, req.Query)
+	}
+	if err == nil {
+		err = validateCondition(cond)
 	}
 	if err == nil {
+		if req.Normalize {
+			cond = condition.Normalize(cond)
+		}
 		sd := interest.Data{
 			Description: req.Description,
 			Enabled:     req.Enabled,
@@ -157,7 +187,7 @@ func (sc serviceController) SetEnabledBatch(ctx context.Context, req *SetEnabled
 	if req.EnabledSince != nil && req.EnabledSince.IsValid() {
 		enabledSince = req.EnabledSince.AsTime().UTC()
 	}
-	resp.N, err = sc.stor.SetEnabledBatch(ctx, req.Ids, req.Enabled, enabledSince)
+	resp.N, _, err = sc.stor.SetEnabledBatch(ctx, req.Ids, req.Enabled, enabledSince)
 	err = encodeError(err)
 	return
 }
@@ -186,6 +216,57 @@ func (sc serviceController) Delete(ctx context.Context, req *DeleteRequest) (res
 	return
 }
 
+func (sc serviceController) Restore(ctx context.Context, req *RestoreRequest) (resp *RestoreResponse, err error) {
+	resp = &RestoreResponse{}
+	var groupId string
+	var userId string
+	groupId, userId, err = getAuthInfo(ctx)
+	if err == nil {
+		var sd interest.Data
+		sd, err = sc.stor.Restore(ctx, req.Id, groupId, userId)
+		if err == nil {
+			resp.Cond = &Condition{}
+			encodeCondition(sd.Condition, resp.Cond)
+		}
+		err = encodeError(err)
+	}
+	return
+}
+
+func (sc serviceController) ListDeleted(ctx context.Context, req *ListDeletedRequest) (resp *ListDeletedResponse, err error) {
+	resp = &ListDeletedResponse{}
+	var groupId string
+	var userId string
+	groupId, userId, err = getAuthInfo(ctx)
+	if err == nil {
+		var page []interest.Deleted
+		page, err = sc.stor.ListDeleted(ctx, groupId, userId, req.Cursor, req.Limit)
+		for _, d := range page {
+			dst := &DeletedInterest{}
+			encodeDeleted(d, dst)
+			resp.Page = append(resp.Page, dst)
+		}
+		err = encodeError(err)
+	}
+	return
+}
+
+func (sc serviceController) Purge(ctx context.Context, req *PurgeRequest) (resp *PurgeResponse, err error) {
+	resp = &PurgeResponse{}
+	switch {
+	case req.GroupId == "", req.UserId == "":
+		err = status.Error(codes.InvalidArgument, "purge missing group id or user id")
+	default:
+		var p interest.Purged
+		p, err = sc.stor.Purge(ctx, req.GroupId, req.UserId, getActor(ctx))
+		resp.Count = p.Count
+		resp.Deleted = p.Deleted
+		resp.CondIds = p.CondIds
+		err = encodeError(err)
+	}
+	return
+}
+
 func (sc serviceController) SearchOwn(ctx context.Context, req *SearchOwnRequest) (resp *SearchOwnResponse, err error) {
 	resp = &SearchOwnResponse{}
 	var groupId string
@@ -278,8 +359,327 @@ func (sc serviceController) SearchByCondition(ctx context.Context, req *SearchBy
 	return
 }
 
+func (sc serviceController) SearchByConditionBatch(ctx context.Context, req *SearchByConditionBatchRequest) (resp *SearchByConditionBatchResponse, err error) {
+	if len(req.Queries) == 0 {
+		err = status.Error(codes.InvalidArgument, "no condition ids specified")
+	}
+	var batch interest.ConditionMatchBatch
+	if err == nil {
+		qs := make([]interest.QueryByConditionCursor, len(req.Queries))
+		for i, q := range req.Queries {
+			qs[i] = interest.QueryByConditionCursor{
+				QueryByCondition: interest.QueryByCondition{
+					CondId: q.CondId,
+					Limit:  q.Limit,
+				},
+				Cursor: q.Cursor,
+			}
+		}
+		batch, err = sc.stor.SearchByConditionBatch(ctx, qs)
+		err = encodeError(err)
+	}
+	if err == nil {
+		resp = &SearchByConditionBatchResponse{
+			Pages: make(map[string]*SearchByConditionBatchPage, len(batch.Pages)),
+			Conds: make(map[string]*Condition, len(batch.Conditions)),
+		}
+		for condId, page := range batch.Pages {
+			resp.Pages[condId] = &SearchByConditionBatchPage{
+				Ids:     page.InterestIds,
+				Expires: timestamppb.New(page.Expires),
+			}
+		}
+		for id, cond := range batch.Conditions {
+			dst := &Condition{}
+			encodeCondition(cond, dst)
+			resp.Conds[id] = dst
+		}
+	}
+	return
+}
+
+func (sc serviceController) SearchByConditionStream(req *SearchByConditionStreamRequest, stream Service_SearchByConditionStreamServer) (err error) {
+	var expires time.Time
+	expires, err = sc.stor.SearchByConditionStream(stream.Context(), req.CondId, func(cm interest.ConditionMatch) error {
+		result := SearchByConditionResult{}
+		encodeConditionMatch(cm, &result)
+		return stream.Send(&SearchByConditionStreamResponse{
+			Item: &SearchByConditionStreamResponse_Result{
+				Result: &result,
+			},
+		})
+	})
+	if err == nil {
+		err = stream.Send(&SearchByConditionStreamResponse{
+			Item: &SearchByConditionStreamResponse_Expires{
+				Expires: timestamppb.New(expires),
+			},
+		})
+	}
+	err = encodeError(err)
+	return
+}
+
+func (sc serviceController) DryRun(ctx context.Context, req *DryRunRequest) (resp *DryRunResponse, err error) {
+	resp = &DryRunResponse{}
+	if len(req.Events) == 0 {
+		err = status.Error(codes.InvalidArgument, "no sample events to test against")
+	}
+	var groupId string
+	var userId string
+	if err == nil && !req.Internal {
+		groupId, userId, err = getAuthInfo(ctx)
+	}
+	var cond condition.Condition
+	if err == nil {
+		switch t := req.Target.(type) {
+		case *DryRunRequest_Id:
+			var sd interest.Data
+			sd, _, _, err = sc.stor.Read(ctx, t.Id, groupId, userId, req.Internal)
+			cond = sd.Condition
+			err = encodeError(err)
+		case *DryRunRequest_Cond:
+			cond, err = decodeCondition(t.Cond)
+			if err == nil {
+				err = validateCondition(cond)
+			}
+		default:
+			err = status.Error(codes.InvalidArgument, "neither interest id nor condition specified")
+		}
+	}
+	if err == nil {
+		for _, evt := range req.Events {
+			r := matcher.Match(cond, matcher.Event{
+				Attributes: evt.Attributes,
+				Data:       evt.Data,
+			})
+			dr := DryRunResult{
+				Matched:     r.Matched,
+				Explanation: &ConditionResult{},
+			}
+			encodeMatchResult(r, dr.Explanation)
+			resp.Results = append(resp.Results, &dr)
+		}
+	}
+	return
+}
+
+func (sc serviceController) WatchChanges(req *WatchChangesRequest, stream Service_WatchChangesServer) (err error) {
+	ctx := stream.Context()
+	after := req.After
+	for {
+		var changes []interest.Change
+		changes, err = sc.stor.ReadChanges(ctx, after, changesPageLimit)
+		for _, c := range changes {
+			if err != nil {
+				break
+			}
+			dst := Change{}
+			encodeChange(c, &dst)
+			err = stream.Send(&dst)
+			after = c.Position
+		}
+		if err != nil {
+			err = encodeError(err)
+			break
+		}
+		if len(changes) < changesPageLimit {
+			// all sent, wait for the new changes
+			select {
+			case <-ctx.Done():
+				err = status.FromContextError(ctx.Err()).Err()
+			case <-time.After(changesPollInterval):
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	return
+}
+
+func (sc serviceController) ListRevisions(ctx context.Context, req *ListRevisionsRequest) (resp *ListRevisionsResponse, err error) {
+	resp = &ListRevisionsResponse{}
+	var groupId string
+	var userId string
+	if !req.Internal {
+		groupId, userId, err = getAuthInfo(ctx)
+	}
+	if err == nil {
+		var revs []interest.Revision
+		revs, err = sc.stor.ListRevisions(ctx, req.Id, groupId, userId, req.Internal, req.Cursor, req.Limit)
+		for _, rev := range revs {
+			dst := &Revision{}
+			encodeRevision(rev, dst)
+			resp.Page = append(resp.Page, dst)
+		}
+		err = encodeError(err)
+	}
+	return
+}
+
+func (sc serviceController) ReadRevision(ctx context.Context, req *ReadRevisionRequest) (resp *Revision, err error) {
+	resp = &Revision{}
+	var groupId string
+	var userId string
+	if !req.Internal {
+		groupId, userId, err = getAuthInfo(ctx)
+	}
+	if err == nil {
+		var rev interest.Revision
+		rev, err = sc.stor.ReadRevision(ctx, req.Id, groupId, userId, req.Internal, req.Number)
+		if err == nil {
+			encodeRevision(rev, resp)
+		}
+		err = encodeError(err)
+	}
+	return
+}
+
+func (sc serviceController) RestoreRevision(ctx context.Context, req *RestoreRevisionRequest) (resp *RestoreRevisionResponse, err error) {
+	resp = &RestoreRevisionResponse{}
+	var groupId string
+	var userId string
+	if !req.Internal {
+		groupId, userId, err = getAuthInfo(ctx)
+	}
+	if err == nil {
+		// restore is a regular update, so it's subject to the same checks and produces a new revision
+		var prev interest.Data
+		_, prev, err = sc.stor.RestoreRevision(ctx, req.Id, groupId, userId, req.Internal, req.Number, time.Now().UTC())
+		if err == nil {
+			resp.Cond = &Condition{}
+			encodeCondition(prev.Condition, resp.Cond)
+		}
+		err = encodeError(err)
+	}
+	return
+}
+
+func (sc serviceController) Export(ctx context.Context, req *ExportRequest) (resp *InterestsDocument, err error) {
+	resp = &InterestsDocument{
+		Version: ExportVersion,
+	}
+	var groupId string
+	var userId string
+	groupId, userId, err = getAuthInfo(ctx)
+	if err == nil {
+		// the whole document is returned at once, so read all interests by the single query to get the snapshot
+		var page []interest.Interest
+		page, err = sc.stor.ListOwned(ctx, groupId, userId, "", 0)
+		for _, i := range page {
+			resp.Interests = append(resp.Interests, encodeDocumentEntry(i.Id, i.Data))
+		}
+		err = encodeError(err)
+	}
+	return
+}
+
+func (sc serviceController) Import(ctx context.Context, req *ImportRequest) (resp *ImportResponse, err error) {
+	resp = &ImportResponse{}
+	var groupId string
+	var userId string
+	groupId, userId, err = getAuthInfo(ctx)
+	if err == nil && req.GetDoc().GetVersion() != ExportVersion {
+		err = status.Error(codes.InvalidArgument, fmt.Sprintf("unsupported document version: %d", req.GetDoc().GetVersion()))
+	}
+	if err == nil {
+		now := time.Now().UTC()
+		for _, e := range req.Doc.Interests {
+			r := &ImportResult{
+				SrcId: e.Id,
+				Id:    e.Id,
+			}
+			importErr := sc.importEntry(ctx, groupId, userId, e, req.Conflict, now, r)
+			if importErr != nil {
+				r.Status = ImportStatus_FAILED
+				r.Error = status.Convert(importErr).Message()
+			}
+			resp.Results = append(resp.Results, r)
+		}
+	}
+	return
+}
+
+func (sc serviceController) importEntry(
+	ctx context.Context,
+	groupId, userId string,
+	src *InterestsDocumentEntry,
+	conflict ImportConflict,
+	now time.Time,
+	dst *ImportResult,
+) (err error) {
+	if src.Id == "" {
+		err = status.Error(codes.InvalidArgument, "empty interest id")
+	}
+	var cond condition.Condition
+	if err == nil {
+		cond, err = decodeCondition(src.Cond)
+	}
+	if err == nil {
+		err = validateCondition(cond)
+	}
+	if err == nil {
+		sd := interest.Data{
+			Description: src.Description,
+			Enabled:     src.Enabled,
+			Condition:   cond,
+			Created:     now,
+			Public:      src.Public,
+		}
+		if src.Expires != nil {
+			sd.Expires = src.Expires.AsTime()
+		}
+		err = sc.stor.Create(ctx, src.Id, groupId, userId, sd)
+		if errors.Is(err, storage.ErrConflict) {
+			switch conflict {
+			case ImportConflict_OVERWRITE:
+				sd.Created = time.Time{}
+				sd.Updated = now
+				_, err = sc.stor.Update(ctx, src.Id, groupId, userId, false, sd)
+				dst.Status = ImportStatus_OVERWRITTEN
+			case ImportConflict_NEW_ID:
+				dst.Id = uuid.NewString()
+				err = sc.stor.Create(ctx, dst.Id, groupId, userId, sd)
+			default:
+				err = nil
+				dst.Status = ImportStatus_SKIPPED
+			}
+		}
+		err = encodeError(err)
+	}
+	return
+}
+
+func (sc serviceController) GetQuota(ctx context.Context, req *GetQuotaRequest) (resp *GetQuotaResponse, err error) {
+	resp = &GetQuotaResponse{}
+	var groupId string
+	var userId string
+	groupId, userId, err = getAuthInfo(ctx)
+	if err == nil {
+		var u interest.Usage
+		u, err = sc.stor.Usage(ctx, groupId, userId)
+		if err == nil {
+			limits := sc.quotas.Limits(groupId, userId)
+			resp.Limits = &QuotaLimits{
+				Interests:       limits.Interests,
+				Public:          limits.Public,
+				ConditionDepth:  limits.ConditionDepth,
+				ConditionLeaves: limits.ConditionLeaves,
+			}
+			resp.Usage = &QuotaUsage{
+				Interests: u.Interests,
+				Public:    u.Public,
+			}
+		}
+		err = encodeError(err)
+	}
+	return
+}
+
 func decodeCondition(src *Condition) (dst condition.Condition, err error) {
-	gc, tc, nc, sc := src.GetGc(), src.GetTc(), src.GetNc(), src.GetSc()
+	gc, tc, nc, sc, rc, rgc := src.GetGc(), src.GetTc(), src.GetNc(), src.GetSc(), src.GetRc(), src.GetRgc()
+	setc, ec := src.GetSetc(), src.GetEc()
 	switch {
 	case gc != nil:
 		var group []condition.Condition
@@ -312,12 +712,82 @@ func decodeCondition(src *Condition) (dst condition.Condition, err error) {
 		)
 	case sc != nil:
 		dst = condition.NewSemanticCondition(condition.NewCondition(src.Not), sc.Id, sc.Query, sc.SimilarityMin)
+	case rgc != nil:
+		dst = condition.NewRangeCondition(
+			condition.NewKeyCondition(condition.NewCondition(src.Not), rgc.GetId(), rgc.GetKey()),
+			condition.Bound{
+				Value:     rgc.GetLower(),
+				Inclusive: rgc.GetLowerInclusive(),
+			},
+			condition.Bound{
+				Value:     rgc.GetUpper(),
+				Inclusive: rgc.GetUpperInclusive(),
+			},
+		)
+	case setc != nil:
+		dst = condition.NewSetCondition(
+			condition.NewKeyCondition(condition.NewCondition(src.Not), setc.GetId(), setc.GetKey()),
+			setc.GetTexts(),
+			setc.GetNumbers(),
+		)
+	case ec != nil:
+		dst = condition.NewExistsCondition(
+			condition.NewKeyCondition(condition.NewCondition(src.Not), ec.GetId(), ec.GetKey()),
+		)
+	case rc != nil:
+		dst = condition.NewRegexCondition(
+			condition.NewKeyCondition(condition.NewCondition(src.Not), rc.GetId(), rc.GetKey()),
+			rc.GetPattern(),
+		)
 	default:
 		err = status.Error(codes.InvalidArgument, "unsupported condition type")
 	}
 	return
 }
 
+// decodeConditionOrQuery decodes the condition specified either as the tree or as the query, see the dsl package.
+func decodeConditionOrQuery(src *Condition, query string) (dst condition.Condition, err error) {
+	switch {
+	case query == "":
+		dst, err = decodeCondition(src)
+	case src != nil:
+		err = status.Error(codes.InvalidArgument, "both cond and query are specified")
+	default:
+		dst, err = dsl.Parse(query)
+		if err != nil {
+			err = status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	return
+}
+
+// validateCondition rejects the condition tree having any defect found by condition.Validate, every defect is
+// reported in the error details.
+func validateCondition(cond condition.Condition) (err error) {
+	violations := condition.Validate(cond)
+	if len(violations) > 0 {
+		br := &errdetails.BadRequest{}
+		var descrs []string
+		for _, v := range violations {
+			field := "cond"
+			if v.Path != "" {
+				field += "." + v.Path
+			}
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       field,
+				Description: v.Description,
+			})
+			descrs = append(descrs, fmt.Sprintf("%s: %s", field, v.Description))
+		}
+		st := status.New(codes.InvalidArgument, fmt.Sprintf("invalid condition: %s", strings.Join(descrs, "; ")))
+		if stWithDetails, errDetails := st.WithDetails(br); errDetails == nil {
+			st = stWithDetails
+		}
+		err = st.Err()
+	}
+	return
+}
+
 func decodeNumOp(src Operation) (dst condition.NumOp) {
 	switch src {
 	case Operation_Gt:
@@ -370,6 +840,41 @@ func encodeCondition(src condition.Condition, dst *Condition) {
 				Val: c.GetValue(),
 			},
 		}
+	case condition.RangeCondition:
+		dst.Cond = &Condition_Rgc{
+			Rgc: &RangeCondition{
+				Id:             c.GetId(),
+				Key:            c.GetKey(),
+				Lower:          c.GetLower().Value,
+				LowerInclusive: c.GetLower().Inclusive,
+				Upper:          c.GetUpper().Value,
+				UpperInclusive: c.GetUpper().Inclusive,
+			},
+		}
+	case condition.SetCondition:
+		dst.Cond = &Condition_Setc{
+			Setc: &SetCondition{
+				Id:      c.GetId(),
+				Key:     c.GetKey(),
+				Texts:   c.GetTexts(),
+				Numbers: c.GetNumbers(),
+			},
+		}
+	case condition.ExistsCondition:
+		dst.Cond = &Condition_Ec{
+			Ec: &ExistsCondition{
+				Id:  c.GetId(),
+				Key: c.GetKey(),
+			},
+		}
+	case condition.RegexCondition:
+		dst.Cond = &Condition_Rc{
+			Rc: &RegexCondition{
+				Id:      c.GetId(),
+				Key:     c.GetKey(),
+				Pattern: c.GetPattern(),
+			},
+		}
 	case condition.SemanticCondition:
 		dst.Cond = &Condition_Sc{
 			Sc: &SemanticCondition{
@@ -406,16 +911,161 @@ func encodeConditionMatch(src interest.ConditionMatch, dst *SearchByConditionRes
 	encodeCondition(src.Condition, dst.Cond)
 }
 
+func encodeChange(src interest.Change, dst *Change) {
+	dst.Position = src.Position
+	dst.Type = ChangeType(src.Type)
+	dst.Id = src.InterestId
+	dst.GroupId = src.GroupId
+	dst.UserId = src.UserId
+	dst.Time = timestamppb.New(src.Time)
+	dst.Enabled = src.Enabled
+	if !src.EnabledSince.IsZero() {
+		dst.EnabledSince = timestamppb.New(src.EnabledSince)
+	}
+	if src.Before != nil {
+		dst.Before = &Condition{}
+		encodeCondition(src.Before, dst.Before)
+	}
+	if src.After != nil {
+		dst.After = &Condition{}
+		encodeCondition(src.After, dst.After)
+	}
+	dst.CondIdsAdded = src.CondIdsAdded
+	dst.CondIdsRemoved = src.CondIdsRemoved
+}
+
+func encodeDeleted(src interest.Deleted, dst *DeletedInterest) {
+	dst.Id = src.Id
+	dst.DeletedAt = timestamppb.New(src.DeletedAt)
+	dst.Description = src.Data.Description
+	dst.Enabled = src.Data.Enabled
+	dst.Cond = &Condition{}
+	encodeCondition(src.Data.Condition, dst.Cond)
+	if !src.Data.Expires.IsZero() {
+		dst.Expires = timestamppb.New(src.Data.Expires)
+	}
+	if !src.Data.Created.IsZero() {
+		dst.Created = timestamppb.New(src.Data.Created)
+	}
+	if !src.Data.Updated.IsZero() {
+		dst.Updated = timestamppb.New(src.Data.Updated)
+	}
+	dst.Public = src.Data.Public
+}
+
+func encodeDocumentEntry(id string, src interest.Data) (dst *InterestsDocumentEntry) {
+	dst = &InterestsDocumentEntry{
+		Id:          id,
+		Description: src.Description,
+		Enabled:     src.Enabled,
+		Cond:        &Condition{},
+		Public:      src.Public,
+	}
+	encodeCondition(src.Condition, dst.Cond)
+	if !src.Expires.IsZero() {
+		dst.Expires = timestamppb.New(src.Expires)
+	}
+	return
+}
+
+func encodeRevision(src interest.Revision, dst *Revision) {
+	dst.Number = src.Number
+	dst.GroupId = src.GroupId
+	dst.UserId = src.UserId
+	dst.Time = timestamppb.New(src.Time)
+	dst.Description = src.Data.Description
+	dst.Enabled = src.Data.Enabled
+	dst.Cond = &Condition{}
+	encodeCondition(src.Data.Condition, dst.Cond)
+	if !src.Data.Expires.IsZero() {
+		dst.Expires = timestamppb.New(src.Data.Expires)
+	}
+	if !src.Data.Created.IsZero() {
+		dst.Created = timestamppb.New(src.Data.Created)
+	}
+	if !src.Data.Updated.IsZero() {
+		dst.Updated = timestamppb.New(src.Data.Updated)
+	}
+	dst.Public = src.Data.Public
+	dst.Followers = src.Data.Followers
+	if !src.Data.Result.IsZero() {
+		dst.Result = timestamppb.New(src.Data.Result)
+	}
+	if !src.Data.EnabledSince.IsZero() {
+		dst.EnabledSince = timestamppb.New(src.Data.EnabledSince)
+	}
+}
+
+func encodeMatchResult(src matcher.Result, dst *ConditionResult) {
+	dst.Not = src.Condition.IsNot()
+	dst.Matched = src.Matched
+	dst.Unsupported = src.Unsupported
+	switch c := src.Condition.(type) {
+	case condition.GroupCondition:
+		var dstGroup []*ConditionResult
+		for _, childSrc := range src.Group {
+			var childDst ConditionResult
+			encodeMatchResult(childSrc, &childDst)
+			dstGroup = append(dstGroup, &childDst)
+		}
+		dst.Cond = &ConditionResult_Gc{
+			Gc: &GroupConditionResult{
+				Logic: common.GroupLogic(c.GetLogic()),
+				Group: dstGroup,
+			},
+		}
+	default:
+		var leaf Condition
+		encodeCondition(c, &leaf)
+		switch lc := leaf.Cond.(type) {
+		case *Condition_Tc:
+			dst.Cond = &ConditionResult_Tc{
+				Tc: lc.Tc,
+			}
+		case *Condition_Nc:
+			dst.Cond = &ConditionResult_Nc{
+				Nc: lc.Nc,
+			}
+		case *Condition_Sc:
+			dst.Cond = &ConditionResult_Sc{
+				Sc: lc.Sc,
+			}
+		case *Condition_Rc:
+			dst.Cond = &ConditionResult_Rc{
+				Rc: lc.Rc,
+			}
+		case *Condition_Rgc:
+			dst.Cond = &ConditionResult_Rgc{
+				Rgc: lc.Rgc,
+			}
+		case *Condition_Setc:
+			dst.Cond = &ConditionResult_Setc{
+				Setc: lc.Setc,
+			}
+		case *Condition_Ec:
+			dst.Cond = &ConditionResult_Ec{
+				Ec: lc.Ec,
+			}
+		}
+	}
+	return
+}
+
 func encodeError(svcErr error) (err error) {
 	switch {
 	case svcErr == nil:
 		err = nil
+	case status.Code(svcErr) != codes.Unknown:
+		// already encoded, e.g. the invalid argument
+		err = svcErr
 	case errors.Is(svcErr, storage.ErrInternal):
 		err = status.Error(codes.Internal, svcErr.Error())
 	case errors.Is(svcErr, storage.ErrNotFound):
 		err = status.Error(codes.NotFound, svcErr.Error())
 	case errors.Is(svcErr, storage.ErrConflict):
 		err = status.Error(codes.AlreadyExists, svcErr.Error())
+	case errors.Is(svcErr, storage.ErrQuotaExceeded):
+		err = status.Error(codes.ResourceExhausted, svcErr.Error())
 	default:
 		err = status.Error(codes.Internal, svcErr.Error())
 	}
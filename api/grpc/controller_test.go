@@ -4,17 +4,22 @@ import (
 	"context"
 	"fmt"
 	"github.com/awakari/interests/api/grpc/common"
+	"github.com/awakari/interests/model/condition"
+	"github.com/awakari/interests/model/dsl"
 	"github.com/awakari/interests/model/interest"
 	"github.com/awakari/interests/storage"
 	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
+	"io"
 	"log/slog"
 	"os"
 	"testing"
@@ -29,7 +34,30 @@ func TestMain(m *testing.M) {
 	stor := storage.NewStorageMock(make(map[string]interest.Data))
 	stor = storage.NewLoggingMiddleware(stor, log)
 	go func() {
-		err := Serve(stor, port)
+		// the authorization is covered by the separate tests, allow everything here
+		authz := DefaultPolicy()
+		for k := range authz.Methods {
+			authz.Methods[k] = []string{RoleUser, RoleInternal}
+		}
+		for k := range authz.Flags {
+			authz.Flags[k] = []string{RoleUser, RoleInternal}
+		}
+		quotas := storage.Quotas{
+			Default: interest.Limits{
+				Interests: 10,
+			},
+			Groups: map[string]storage.GroupQuotas{
+				"group1": {
+					Users: map[string]interest.Limits{
+						"user1": {
+							Public:         2,
+							ConditionDepth: 3,
+						},
+					},
+				},
+			},
+		}
+		err := Serve(stor, quotas, port, NewTrustedGatewayAuthenticator([]string{RoleInternal}, false), authz)
 		if err != nil {
 			log.Error(err.Error())
 		}
@@ -62,7 +90,9 @@ func TestServiceController_Create(t *testing.T) {
 			public:  true,
 			cond: &Condition{
 				Cond: &Condition_Tc{
-					Tc: &TextCondition{},
+					Tc: &TextCondition{
+						Term: "term0",
+					},
 				},
 			},
 			err: status.Error(codes.InvalidArgument, "empty interest id"),
@@ -117,7 +147,9 @@ func TestServiceController_Create(t *testing.T) {
 			},
 			cond: &Condition{
 				Cond: &Condition_Tc{
-					Tc: &TextCondition{},
+					Tc: &TextCondition{
+						Term: "term0",
+					},
 				},
 			},
 			id:  "fail",
@@ -130,12 +162,29 @@ func TestServiceController_Create(t *testing.T) {
 			},
 			cond: &Condition{
 				Cond: &Condition_Tc{
-					Tc: &TextCondition{},
+					Tc: &TextCondition{
+						Term: "term0",
+					},
 				},
 			},
 			id:  "conflict",
 			err: status.Error(codes.AlreadyExists, "interest id is already in use"),
 		},
+		"quota exceeded": {
+			md: []string{
+				"x-awakari-group-id", "group0",
+				"X-Awakari-User-ID", "user0",
+			},
+			cond: &Condition{
+				Cond: &Condition_Tc{
+					Tc: &TextCondition{
+						Term: "term0",
+					},
+				},
+			},
+			id:  "quota",
+			err: status.Error(codes.ResourceExhausted, "interest quota exceeded"),
+		},
 		"empty group": {
 			md: []string{
 				"x-awakari-group-id", "",
@@ -143,7 +192,9 @@ func TestServiceController_Create(t *testing.T) {
 			},
 			cond: &Condition{
 				Cond: &Condition_Tc{
-					Tc: &TextCondition{},
+					Tc: &TextCondition{
+						Term: "term0",
+					},
 				},
 			},
 			err: status.Error(codes.Unauthenticated, "missing value for x-awakari-group-id in request metadata"),
@@ -155,7 +206,9 @@ func TestServiceController_Create(t *testing.T) {
 			},
 			cond: &Condition{
 				Cond: &Condition_Tc{
-					Tc: &TextCondition{},
+					Tc: &TextCondition{
+						Term: "term0",
+					},
 				},
 			},
 			err: status.Error(codes.Unauthenticated, "missing value for x-awakari-user-id in request metadata"),
@@ -163,7 +216,9 @@ func TestServiceController_Create(t *testing.T) {
 		"no auth info": {
 			cond: &Condition{
 				Cond: &Condition_Tc{
-					Tc: &TextCondition{},
+					Tc: &TextCondition{
+						Term: "term0",
+					},
 				},
 			},
 			err: status.Error(codes.Unauthenticated, "missing value for x-awakari-group-id in request metadata"),
@@ -185,6 +240,334 @@ func TestServiceController_Create(t *testing.T) {
 	}
 }
 
+func TestServiceController_InvalidCondition(t *testing.T) {
+	//
+	addr := fmt.Sprintf("localhost:%d", port)
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	defer conn.Close()
+	client := NewServiceClient(conn)
+	//
+	cond := &Condition{
+		Cond: &Condition_Gc{
+			Gc: &GroupCondition{
+				Group: []*Condition{
+					{
+						Cond: &Condition_Sc{
+							Sc: &SemanticCondition{
+								Id:            "cond0",
+								SimilarityMin: 2,
+							},
+						},
+					},
+					{
+						Cond: &Condition_Gc{
+							Gc: &GroupCondition{},
+						},
+					},
+				},
+			},
+		},
+	}
+	msg := "invalid condition: cond.group[0].query: blank query; " +
+		"cond.group[0].similarityMin: 2 is out of the [0, 1] range; " +
+		"cond.group[1].group: empty group"
+	fields := []string{
+		"cond.group[0].query",
+		"cond.group[0].similarityMin",
+		"cond.group[1].group",
+	}
+	calls := map[string]func(ctx context.Context) error{
+		"create": func(ctx context.Context) (err error) {
+			_, err = client.Create(ctx, &CreateRequest{
+				Id:   "interest0",
+				Cond: cond,
+			})
+			return
+		},
+		"update": func(ctx context.Context) (err error) {
+			_, err = client.Update(ctx, &UpdateRequest{
+				Id:   "interest0",
+				Cond: cond,
+			})
+			return
+		},
+		"dry run": func(ctx context.Context) (err error) {
+			_, err = client.DryRun(ctx, &DryRunRequest{
+				Target: &DryRunRequest_Cond{
+					Cond: cond,
+				},
+				Events: []*Event{
+					{
+						Data: "lorem ipsum",
+					},
+				},
+			})
+			return
+		},
+	}
+	//
+	for k, call := range calls {
+		t.Run(k, func(t *testing.T) {
+			ctx := metadata.AppendToOutgoingContext(context.TODO(), "x-awakari-group-id", "group0", "x-awakari-user-id", "user0")
+			err := call(ctx)
+			st := status.Convert(err)
+			assert.Equal(t, codes.InvalidArgument, st.Code())
+			assert.Equal(t, msg, st.Message())
+			require.Len(t, st.Details(), 1)
+			br, ok := st.Details()[0].(*errdetails.BadRequest)
+			require.True(t, ok)
+			var brFields []string
+			for _, fv := range br.FieldViolations {
+				brFields = append(brFields, fv.Field)
+			}
+			assert.Equal(t, fields, brFields)
+		})
+	}
+}
+
+type conditionCapturingStorage struct {
+	storage.Storage
+	conds map[string]condition.Condition
+}
+
+func (s conditionCapturingStorage) Create(ctx context.Context, id, groupId, userId string, sd interest.Data) (err error) {
+	s.conds[id] = sd.Condition
+	return
+}
+
+func (s conditionCapturingStorage) Update(ctx context.Context, id, groupId, userId string, internal bool, sd interest.Data) (prev interest.Data, err error) {
+	s.conds[id] = sd.Condition
+	prev = sd
+	return
+}
+
+func TestServiceController_Normalize(t *testing.T) {
+	// not(and(or(cond0), cond0)) -> not(cond0)
+	cond := &Condition{
+		Not: true,
+		Cond: &Condition_Gc{
+			Gc: &GroupCondition{
+				Group: []*Condition{
+					{
+						Cond: &Condition_Gc{
+							Gc: &GroupCondition{
+								Logic: common.GroupLogic_Or,
+								Group: []*Condition{
+									{
+										Cond: &Condition_Tc{
+											Tc: &TextCondition{
+												Id:   "cond0",
+												Term: "term0",
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+					{
+						Cond: &Condition_Tc{
+							Tc: &TextCondition{
+								Term: "term0",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	cases := map[string]struct {
+		normalize bool
+		depth     uint32
+		leaves    uint32
+	}{
+		"as is": {
+			depth:  3,
+			leaves: 2,
+		},
+		"normalized": {
+			normalize: true,
+			depth:     1,
+			leaves:    1,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			stor := conditionCapturingStorage{
+				conds: make(map[string]condition.Condition),
+			}
+			sc := NewServiceController(stor, storage.Quotas{})
+			ctx := context.WithValue(context.TODO(), ctxKeyAuthInfo{}, authInfo{
+				p: Principal{
+					GroupId: "group0",
+					UserId:  "user0",
+				},
+			})
+			_, err := sc.Create(ctx, &CreateRequest{
+				Id:        "interest0",
+				Cond:      cond,
+				Normalize: c.normalize,
+			})
+			require.Nil(t, err)
+			_, err = sc.Update(ctx, &UpdateRequest{
+				Id:        "interest1",
+				Cond:      cond,
+				Normalize: c.normalize,
+			})
+			require.Nil(t, err)
+			for _, id := range []string{"interest0", "interest1"} {
+				stored := stor.conds[id]
+				assert.Equal(t, c.depth, condition.Depth(stored))
+				assert.Equal(t, c.leaves, condition.LeafCount(stored))
+				assert.True(t, stored.IsNot())
+			}
+		})
+	}
+}
+
+func TestServiceController_Query(t *testing.T) {
+	cases := map[string]struct {
+		cond  *Condition
+		query string
+		out   string
+		err   error
+	}{
+		"ok": {
+			query: `(title:"golang" AND NOT lang:=ru) OR price<100#cond0 OR ~"electric cars"@0.8`,
+			out:   `(title:"golang" AND NOT lang:="ru") OR price<100#cond0 OR ~"electric cars"@0.8`,
+		},
+		"syntax error": {
+			query: `title:"golang" AND`,
+			err:   status.Error(codes.InvalidArgument, "query syntax error at 18: expected condition, got end of query"),
+		},
+		"invalid condition": {
+			query: `title:"golang" AND AND()`,
+			err:   status.Error(codes.InvalidArgument, "invalid condition: cond.group[1].group: empty group"),
+		},
+		"regex cond": {
+			cond: &Condition{
+				Not: true,
+				Cond: &Condition_Rc{
+					Rc: &RegexCondition{
+						Id:      "cond0",
+						Key:     "title",
+						Pattern: "^go(lang)?$",
+					},
+				},
+			},
+			out: `NOT title:~"^go(lang)?$"#cond0`,
+		},
+		"range cond": {
+			cond: &Condition{
+				Cond: &Condition_Rgc{
+					Rgc: &RangeCondition{
+						Id:             "cond0",
+						Key:            "price",
+						Lower:          10,
+						LowerInclusive: true,
+						Upper:          100,
+					},
+				},
+			},
+			out: `10<=price<100#cond0`,
+		},
+		"set cond": {
+			cond: &Condition{
+				Not: true,
+				Cond: &Condition_Setc{
+					Setc: &SetCondition{
+						Id:      "cond0",
+						Key:     "category",
+						Texts:   []string{"news", "sports"},
+						Numbers: []float64{1},
+					},
+				},
+			},
+			out: `NOT category IN ("news", "sports", 1)#cond0`,
+		},
+		"invalid set": {
+			query: `category IN (news, news)`,
+			err:   status.Error(codes.InvalidArgument, "invalid condition: cond.texts[1]: duplicate value \"news\""),
+		},
+		"exists cond": {
+			cond: &Condition{
+				Not: true,
+				Cond: &Condition_Ec{
+					Ec: &ExistsCondition{
+						Id:  "cond0",
+						Key: "subject",
+					},
+				},
+			},
+			out: `NOT subject EXISTS#cond0`,
+		},
+		"invalid exists": {
+			cond: &Condition{
+				Cond: &Condition_Ec{
+					Ec: &ExistsCondition{
+						Key: " ",
+					},
+				},
+			},
+			err: status.Error(codes.InvalidArgument, "invalid condition: cond.key: blank key"),
+		},
+		"invalid range": {
+			query: `100<=price<10`,
+			err:   status.Error(codes.InvalidArgument, "invalid condition: cond: lower bound 100 is greater than the upper bound 10"),
+		},
+		"invalid regex": {
+			query: `title:~"(golang"`,
+			err:   status.Error(codes.InvalidArgument, "invalid condition: cond.pattern: error parsing regexp: missing closing ): `(golang`"),
+		},
+		"both cond and query": {
+			cond: &Condition{
+				Cond: &Condition_Tc{
+					Tc: &TextCondition{
+						Term: "golang",
+					},
+				},
+			},
+			query: `title:"golang"`,
+			err:   status.Error(codes.InvalidArgument, "both cond and query are specified"),
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			stor := conditionCapturingStorage{
+				conds: make(map[string]condition.Condition),
+			}
+			sc := NewServiceController(stor, storage.Quotas{})
+			ctx := context.WithValue(context.TODO(), ctxKeyAuthInfo{}, authInfo{
+				p: Principal{
+					GroupId: "group0",
+					UserId:  "user0",
+				},
+			})
+			_, err := sc.Create(ctx, &CreateRequest{
+				Id:    "interest0",
+				Cond:  c.cond,
+				Query: c.query,
+			})
+			assert.Equal(t, status.Code(c.err), status.Code(err))
+			assert.Equal(t, status.Convert(c.err).Message(), status.Convert(err).Message())
+			_, err = sc.Update(ctx, &UpdateRequest{
+				Id:    "interest1",
+				Cond:  c.cond,
+				Query: c.query,
+			})
+			assert.Equal(t, status.Code(c.err), status.Code(err))
+			if c.err == nil {
+				for _, id := range []string{"interest0", "interest1"} {
+					q, err := dsl.Print(stor.conds[id])
+					require.Nil(t, err)
+					assert.Equal(t, c.out, q)
+				}
+			}
+		})
+	}
+}
+
 func TestServiceController_Read(t *testing.T) {
 	//
 	addr := fmt.Sprintf("localhost:%d", port)
@@ -247,6 +630,7 @@ func TestServiceController_Read(t *testing.T) {
 						},
 					},
 				},
+				Query:   `key0:"pattern0" AND NOT ~"lorem ipsum..."@0.5 AND key2=42`,
 				GroupId: "group0",
 				UserId:  "user0",
 			},
@@ -313,6 +697,7 @@ func TestServiceController_Read(t *testing.T) {
 						},
 					},
 				},
+				Query:   `key0:"pattern0" AND NOT ~"lorem ipsum..."@0.5 AND key2=42`,
 				GroupId: "group0",
 				UserId:  "user0",
 			},
@@ -349,6 +734,7 @@ func TestServiceController_Read(t *testing.T) {
 				assert.Equal(t, c.sub.Cond.GetGc().GetGroup()[1].Not, sub.Cond.GetGc().GetGroup()[1].Not)
 				assert.Equal(t, c.sub.Cond.GetGc().GetGroup()[1].GetSc().Query, sub.Cond.GetGc().GetGroup()[1].GetSc().Query)
 				assert.Equal(t, c.sub.Cond.GetGc().GetGroup()[1].GetSc().SimilarityMin, sub.Cond.GetGc().GetGroup()[1].GetSc().SimilarityMin)
+				assert.Equal(t, c.sub.Query, sub.Query)
 				assert.Equal(t, c.sub.Own, sub.Own)
 			} else {
 				assert.ErrorIs(t, err, c.err)
@@ -357,6 +743,22 @@ func TestServiceController_Read(t *testing.T) {
 	}
 }
 
+func TestServiceController_ReadUnprintable(t *testing.T) {
+	addr := fmt.Sprintf("localhost:%d", port)
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	client := NewServiceClient(conn)
+	ctx := metadata.AppendToOutgoingContext(context.TODO(), "x-awakari-group-id", "group0", "x-awakari-user-id", "user0")
+	sub, err := client.Read(ctx, &ReadRequest{
+		Id: "unprintable",
+	})
+	require.Nil(t, err)
+	assert.Equal(t, "description", sub.Description)
+	assert.Equal(t, common.GroupLogic(3), sub.Cond.GetGc().Logic)
+	assert.Equal(t, Operation_Undefined, sub.Cond.GetGc().GetGroup()[0].GetNc().Op)
+	assert.Equal(t, "", sub.Query)
+}
+
 func TestServiceController_Update(t *testing.T) {
 	//
 	addr := fmt.Sprintf("localhost:%d", port)
@@ -583,44 +985,36 @@ func TestServiceController_Delete(t *testing.T) {
 	}
 }
 
-func TestServiceController_SearchOwn(t *testing.T) {
+func TestServiceController_Restore(t *testing.T) {
 	//
 	addr := fmt.Sprintf("localhost:%d", port)
-	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	require.Nil(t, err)
+	defer conn.Close()
 	client := NewServiceClient(conn)
 	//
 	cases := map[string]struct {
-		auth   bool
-		cursor string
-		order  Order
-		err    error
-		ids    []string
+		auth bool
+		err  error
 	}{
-		"asc": {
+		"ok": {
 			auth: true,
-			ids: []string{
-				"sub0",
-				"sub1",
-			},
-		},
-		"desc": {
-			auth:  true,
-			order: Order_DESC,
-			ids: []string{
-				"sub1",
-				"sub0",
-			},
 		},
 		"fail": {
-			auth:   true,
-			cursor: "fail",
-			err:    status.Error(codes.Internal, "internal interest storage failure"),
+			auth: true,
+			err:  status.Error(codes.Internal, "internal interest storage failure"),
+		},
+		"missing": {
+			auth: true,
+			err:  status.Error(codes.NotFound, "interest was not found"),
+		},
+		"conflict": {
+			auth: true,
+			err:  status.Error(codes.AlreadyExists, "interest id is already in use"),
 		},
 		"no auth": {
-			auth:   false,
-			cursor: "no auth",
-			err:    status.Error(codes.Unauthenticated, "missing value for x-awakari-group-id in request metadata"),
+			auth: false,
+			err:  status.Error(codes.Unauthenticated, "missing value for x-awakari-group-id in request metadata"),
 		},
 	}
 	//
@@ -630,10 +1024,10 @@ func TestServiceController_SearchOwn(t *testing.T) {
 			if c.auth {
 				ctx = metadata.AppendToOutgoingContext(ctx, "x-awakari-group-id", "group0", "x-awakari-user-id", "user0")
 			}
-			resp, err := client.SearchOwn(ctx, &SearchOwnRequest{Cursor: c.cursor, Limit: 0, Order: c.order})
+			resp, err := client.Restore(ctx, &RestoreRequest{Id: k})
 			if c.err == nil {
-				assert.Nil(t, err)
-				assert.Equal(t, c.ids, resp.Ids)
+				require.Nil(t, err)
+				assert.Equal(t, "cond0", resp.Cond.GetTc().GetId())
 			} else {
 				assert.ErrorIs(t, err, c.err)
 			}
@@ -641,68 +1035,46 @@ func TestServiceController_SearchOwn(t *testing.T) {
 	}
 }
 
-func TestServiceController_Search(t *testing.T) {
+func TestServiceController_ListDeleted(t *testing.T) {
 	//
 	addr := fmt.Sprintf("localhost:%d", port)
-	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	require.Nil(t, err)
+	defer conn.Close()
 	client := NewServiceClient(conn)
 	//
 	cases := map[string]struct {
 		auth   bool
-		cursor Cursor
-		sort   Sort
-		order  Order
-		all    bool
-		err    error
+		cursor string
+		limit  uint32
 		ids    []string
+		err    error
 	}{
-		"asc": {
+		"all": {
 			auth: true,
 			ids: []string{
-				"sub0",
-				"sub1",
-			},
-		},
-		"desc": {
-			auth:  true,
-			order: Order_DESC,
-			ids: []string{
-				"sub1",
-				"sub0",
+				"interest0",
+				"interest1",
+				"interest2",
 			},
 		},
-		"desc by followers": {
-			auth:  true,
-			sort:  Sort_FOLLOWERS,
-			order: Order_DESC,
+		"page": {
+			auth:   true,
+			cursor: "x",
+			limit:  2,
 			ids: []string{
-				"sub0",
-				"sub1",
+				"xinterest0",
+				"xinterest1",
 			},
 		},
 		"fail": {
-			auth: true,
-			cursor: Cursor{
-				Id: "fail",
-			},
-			err: status.Error(codes.Internal, "internal interest storage failure"),
+			auth:   true,
+			cursor: "fail",
+			err:    status.Error(codes.Internal, "internal interest storage failure"),
 		},
 		"no auth": {
-			auth: false,
-			cursor: Cursor{
-				Id: "no auth",
-			},
 			err: status.Error(codes.Unauthenticated, "missing value for x-awakari-group-id in request metadata"),
 		},
-		"all": {
-			auth: false,
-			all:  true,
-			ids: []string{
-				"sub0",
-				"sub1",
-			},
-		},
 	}
 	//
 	for k, c := range cases {
@@ -711,9 +1083,213 @@ func TestServiceController_Search(t *testing.T) {
 			if c.auth {
 				ctx = metadata.AppendToOutgoingContext(ctx, "x-awakari-group-id", "group0", "x-awakari-user-id", "user0")
 			}
-			resp, err := client.Search(ctx, &SearchRequest{
-				Cursor: &c.cursor,
-				Limit:  0,
+			resp, err := client.ListDeleted(ctx, &ListDeletedRequest{
+				Cursor: c.cursor,
+				Limit:  c.limit,
+			})
+			if c.err == nil {
+				require.Nil(t, err)
+				var ids []string
+				for _, d := range resp.Page {
+					ids = append(ids, d.Id)
+					assert.NotNil(t, d.DeletedAt)
+					assert.Equal(t, "cond0", d.Cond.GetTc().GetId())
+				}
+				assert.Equal(t, c.ids, ids)
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+}
+
+func TestServiceController_Purge(t *testing.T) {
+	//
+	addr := fmt.Sprintf("localhost:%d", port)
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	defer conn.Close()
+	client := NewServiceClient(conn)
+	//
+	cases := map[string]struct {
+		groupId string
+		userId  string
+		resp    *PurgeResponse
+		err     error
+	}{
+		"ok": {
+			groupId: "group0",
+			userId:  "user0",
+			resp: &PurgeResponse{
+				Count:   2,
+				Deleted: 1,
+				CondIds: []string{
+					"cond0",
+					"cond1",
+				},
+			},
+		},
+		"missing user": {
+			groupId: "group0",
+			err:     status.Error(codes.InvalidArgument, "purge missing group id or user id"),
+		},
+		"fail": {
+			groupId: "fail",
+			userId:  "user0",
+			err:     status.Error(codes.Internal, "internal interest storage failure"),
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx := metadata.AppendToOutgoingContext(context.TODO(), "x-awakari-group-id", "group0", "x-awakari-user-id", "admin0")
+			resp, err := client.Purge(ctx, &PurgeRequest{
+				GroupId: c.groupId,
+				UserId:  c.userId,
+			})
+			if c.err == nil {
+				require.Nil(t, err)
+				assert.Equal(t, c.resp.Count, resp.Count)
+				assert.Equal(t, c.resp.Deleted, resp.Deleted)
+				assert.Equal(t, c.resp.CondIds, resp.CondIds)
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+}
+
+func TestServiceController_SearchOwn(t *testing.T) {
+	//
+	addr := fmt.Sprintf("localhost:%d", port)
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	client := NewServiceClient(conn)
+	//
+	cases := map[string]struct {
+		auth   bool
+		cursor string
+		order  Order
+		err    error
+		ids    []string
+	}{
+		"asc": {
+			auth: true,
+			ids: []string{
+				"sub0",
+				"sub1",
+			},
+		},
+		"desc": {
+			auth:  true,
+			order: Order_DESC,
+			ids: []string{
+				"sub1",
+				"sub0",
+			},
+		},
+		"fail": {
+			auth:   true,
+			cursor: "fail",
+			err:    status.Error(codes.Internal, "internal interest storage failure"),
+		},
+		"no auth": {
+			auth:   false,
+			cursor: "no auth",
+			err:    status.Error(codes.Unauthenticated, "missing value for x-awakari-group-id in request metadata"),
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx := context.TODO()
+			if c.auth {
+				ctx = metadata.AppendToOutgoingContext(ctx, "x-awakari-group-id", "group0", "x-awakari-user-id", "user0")
+			}
+			resp, err := client.SearchOwn(ctx, &SearchOwnRequest{Cursor: c.cursor, Limit: 0, Order: c.order})
+			if c.err == nil {
+				assert.Nil(t, err)
+				assert.Equal(t, c.ids, resp.Ids)
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+}
+
+func TestServiceController_Search(t *testing.T) {
+	//
+	addr := fmt.Sprintf("localhost:%d", port)
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	client := NewServiceClient(conn)
+	//
+	cases := map[string]struct {
+		auth   bool
+		cursor Cursor
+		sort   Sort
+		order  Order
+		all    bool
+		err    error
+		ids    []string
+	}{
+		"asc": {
+			auth: true,
+			ids: []string{
+				"sub0",
+				"sub1",
+			},
+		},
+		"desc": {
+			auth:  true,
+			order: Order_DESC,
+			ids: []string{
+				"sub1",
+				"sub0",
+			},
+		},
+		"desc by followers": {
+			auth:  true,
+			sort:  Sort_FOLLOWERS,
+			order: Order_DESC,
+			ids: []string{
+				"sub0",
+				"sub1",
+			},
+		},
+		"fail": {
+			auth: true,
+			cursor: Cursor{
+				Id: "fail",
+			},
+			err: status.Error(codes.Internal, "internal interest storage failure"),
+		},
+		"no auth": {
+			auth: false,
+			cursor: Cursor{
+				Id: "no auth",
+			},
+			err: status.Error(codes.Unauthenticated, "missing value for x-awakari-group-id in request metadata"),
+		},
+		"all": {
+			auth: false,
+			all:  true,
+			ids: []string{
+				"sub0",
+				"sub1",
+			},
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx := context.TODO()
+			if c.auth {
+				ctx = metadata.AppendToOutgoingContext(ctx, "x-awakari-group-id", "group0", "x-awakari-user-id", "user0")
+			}
+			resp, err := client.Search(ctx, &SearchRequest{
+				Cursor: &c.cursor,
+				Limit:  0,
 				Sort:   c.sort,
 				Order:  c.order,
 				All:    c.all,
@@ -764,33 +1340,43 @@ func TestServiceController_SearchByCondition(t *testing.T) {
 	}
 }
 
-func TestServiceController_SetEnabledBatch(t *testing.T) {
+func TestServiceController_SearchByConditionBatch(t *testing.T) {
 	//
 	addr := fmt.Sprintf("localhost:%d", port)
 	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	require.Nil(t, err)
+	defer conn.Close()
 	client := NewServiceClient(conn)
 	//
 	cases := map[string]struct {
-		ids          []string
-		enabled      bool
-		enabledSince *timestamppb.Timestamp
-		n            int64
-		err          error
+		queries []*SearchByConditionRequest
+		pages   map[string][]string
+		err     error
 	}{
 		"ok": {
-			ids: []string{
-				"interest0",
-				"interest1",
+			queries: []*SearchByConditionRequest{
+				{
+					CondId: "cond0",
+					Limit:  2,
+				},
+				{
+					CondId: "cond1",
+					Cursor: "sub0",
+				},
 			},
-			enabled:      true,
-			enabledSince: timestamppb.New(time.Now()),
-			n:            2,
+			pages: map[string][]string{
+				"cond0": {"sub0", "sub1"},
+				"cond1": {"sub1", "sub2"},
+			},
+		},
+		"empty": {
+			err: status.Error(codes.InvalidArgument, "no condition ids specified"),
 		},
 		"fail": {
-			ids: []string{
-				"fail",
-				"interest1",
+			queries: []*SearchByConditionRequest{
+				{
+					CondId: "fail",
+				},
 			},
 			err: status.Error(codes.Internal, "internal interest storage failure"),
 		},
@@ -798,54 +1384,1052 @@ func TestServiceController_SetEnabledBatch(t *testing.T) {
 	//
 	for k, c := range cases {
 		t.Run(k, func(t *testing.T) {
-			ctx := context.TODO()
-			ctx = metadata.AppendToOutgoingContext(ctx, "x-awakari-group-id", "group0", "x-awakari-user-id", "user0")
-			var resp *SetEnabledBatchResponse
-			resp, err = client.SetEnabledBatch(ctx, &SetEnabledBatchRequest{
-				Ids:     c.ids,
-				Enabled: c.enabled,
+			resp, err := client.SearchByConditionBatch(context.TODO(), &SearchByConditionBatchRequest{
+				Queries: c.queries,
 			})
+			assert.ErrorIs(t, err, c.err)
 			if c.err == nil {
-				assert.Equal(t, c.n, resp.N)
+				assert.Equal(t, len(c.pages), len(resp.Pages))
+				for condId, ids := range c.pages {
+					assert.Equal(t, ids, resp.Pages[condId].Ids)
+					assert.Equal(t, time.Date(2025, 3, 1, 13, 4, 55, 0, time.UTC), resp.Pages[condId].Expires.AsTime())
+				}
+				// the conditions are not repeated per page
+				assert.Equal(t, 3, len(resp.Conds))
+				assert.Equal(t, "cond0", resp.Conds["sub0"].GetTc().GetId())
 			}
-			assert.ErrorIs(t, err, c.err)
 		})
 	}
 }
 
-func TestServiceController_ChangeOwner(t *testing.T) {
+func TestServiceController_SearchByConditionStream(t *testing.T) {
 	//
 	addr := fmt.Sprintf("localhost:%d", port)
 	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	require.Nil(t, err)
+	defer conn.Close()
 	client := NewServiceClient(conn)
 	//
 	cases := map[string]struct {
-		newUserId string
-		n         int64
-		err       error
+		condId  string
+		ids     []string
+		expires *timestamppb.Timestamp
+		err     error
 	}{
 		"ok": {
-			n: 42,
+			condId: "cond0",
+			ids: []string{
+				"sub0",
+				"sub1",
+				"sub2",
+			},
+			expires: timestamppb.New(time.Date(2025, 3, 1, 13, 4, 55, 0, time.UTC)),
 		},
 		"fail": {
-			newUserId: "fail",
-			err:       status.Error(codes.Internal, "internal interest storage failure"),
+			condId: "fail",
+			err:    status.Error(codes.Internal, "internal interest storage failure"),
 		},
 	}
 	//
 	for k, c := range cases {
 		t.Run(k, func(t *testing.T) {
-			ctx := context.TODO()
-			ctx = metadata.AppendToOutgoingContext(ctx, "x-awakari-group-id", "group0", "x-awakari-user-id", "user0")
-			var resp *ChangeOwnerResponse
-			resp, err = client.ChangeOwner(ctx, &ChangeOwnerRequest{
-				NewUserId: c.newUserId,
+			stream, err := client.SearchByConditionStream(context.TODO(), &SearchByConditionStreamRequest{
+				CondId: c.condId,
 			})
+			require.Nil(t, err)
+			var ids []string
+			var expires *timestamppb.Timestamp
+			for {
+				var resp *SearchByConditionStreamResponse
+				resp, err = stream.Recv()
+				if err != nil {
+					break
+				}
+				switch item := resp.Item.(type) {
+				case *SearchByConditionStreamResponse_Result:
+					assert.Nil(t, expires, "result after the expiration time")
+					ids = append(ids, item.Result.Id)
+					assert.Equal(t, c.condId, item.Result.Cond.GetTc().GetId())
+				case *SearchByConditionStreamResponse_Expires:
+					expires = item.Expires
+				}
+			}
 			if c.err == nil {
-				assert.Equal(t, c.n, resp.N)
+				assert.ErrorIs(t, err, io.EOF)
+			} else {
+				assert.ErrorIs(t, err, c.err)
 			}
-			assert.ErrorIs(t, err, c.err)
+			assert.Equal(t, c.ids, ids)
+			assert.Equal(t, c.expires.AsTime(), expires.AsTime())
+		})
+	}
+}
+
+func TestServiceController_WatchChanges(t *testing.T) {
+	//
+	addr := fmt.Sprintf("localhost:%d", port)
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	defer conn.Close()
+	client := NewServiceClient(conn)
+	//
+	cases := map[string]struct {
+		after     uint64
+		positions []uint64
+		err       error
+	}{
+		"all": {
+			positions: []uint64{
+				1,
+				2,
+				3,
+			},
+		},
+		"resume": {
+			after: 1,
+			positions: []uint64{
+				2,
+				3,
+			},
+		},
+		"fail": {
+			after: 42,
+			err:   status.Error(codes.Internal, "internal interest storage failure"),
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.TODO())
+			defer cancel()
+			stream, err := client.WatchChanges(ctx, &WatchChangesRequest{
+				After: c.after,
+			})
+			require.Nil(t, err)
+			var positions []uint64
+			for len(positions) < len(c.positions) {
+				var resp *Change
+				resp, err = stream.Recv()
+				if err != nil {
+					break
+				}
+				positions = append(positions, resp.Position)
+				assert.Equal(t, ChangeType_CREATED, resp.Type)
+				assert.Equal(t, "cond0", resp.After.GetTc().GetId())
+				assert.Nil(t, resp.Before)
+				assert.Equal(t, []string{"cond0"}, resp.CondIdsAdded)
+			}
+			if c.err == nil {
+				assert.Nil(t, err)
+				// the stream waits for the new changes until cancelled
+				cancel()
+				_, err = stream.Recv()
+				assert.Equal(t, codes.Canceled, status.Code(err))
+			} else {
+				_, err = stream.Recv()
+				assert.ErrorIs(t, err, c.err)
+			}
+			assert.Equal(t, c.positions, positions)
+		})
+	}
+}
+
+func TestServiceController_ListRevisions(t *testing.T) {
+	//
+	addr := fmt.Sprintf("localhost:%d", port)
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	defer conn.Close()
+	client := NewServiceClient(conn)
+	//
+	cases := map[string]struct {
+		id       string
+		auth     bool
+		internal bool
+		cursor   uint64
+		limit    uint32
+		numbers  []uint64
+		err      error
+	}{
+		"all": {
+			id:   "interest0",
+			auth: true,
+			numbers: []uint64{
+				1,
+				2,
+				3,
+			},
+		},
+		"page": {
+			id:     "interest0",
+			auth:   true,
+			cursor: 1,
+			limit:  1,
+			numbers: []uint64{
+				2,
+			},
+		},
+		"internal": {
+			id:       "interest0",
+			internal: true,
+			numbers: []uint64{
+				1,
+				2,
+				3,
+			},
+		},
+		"fail": {
+			id:   "fail",
+			auth: true,
+			err:  status.Error(codes.Internal, "internal interest storage failure"),
+		},
+		"missing": {
+			id:   "missing",
+			auth: true,
+			err:  status.Error(codes.NotFound, "interest was not found"),
+		},
+		"no auth": {
+			id:  "interest0",
+			err: status.Error(codes.Unauthenticated, "missing value for x-awakari-group-id in request metadata"),
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx := context.TODO()
+			if c.auth {
+				ctx = metadata.AppendToOutgoingContext(ctx, "x-awakari-group-id", "group0", "x-awakari-user-id", "user0")
+			}
+			resp, err := client.ListRevisions(ctx, &ListRevisionsRequest{
+				Id:       c.id,
+				Cursor:   c.cursor,
+				Limit:    c.limit,
+				Internal: c.internal,
+			})
+			if c.err == nil {
+				require.Nil(t, err)
+				var numbers []uint64
+				for _, rev := range resp.Page {
+					numbers = append(numbers, rev.Number)
+					assert.Equal(t, fmt.Sprintf("description%d", rev.Number), rev.Description)
+				}
+				assert.Equal(t, c.numbers, numbers)
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+}
+
+func TestServiceController_ReadRevision(t *testing.T) {
+	//
+	addr := fmt.Sprintf("localhost:%d", port)
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	defer conn.Close()
+	client := NewServiceClient(conn)
+	//
+	cases := map[string]struct {
+		id     string
+		auth   bool
+		number uint64
+		err    error
+	}{
+		"ok": {
+			id:     "interest0",
+			auth:   true,
+			number: 2,
+		},
+		"missing number": {
+			id:     "interest0",
+			auth:   true,
+			number: 4,
+			err:    status.Error(codes.NotFound, "interest was not found"),
+		},
+		"fail": {
+			id:     "fail",
+			auth:   true,
+			number: 1,
+			err:    status.Error(codes.Internal, "internal interest storage failure"),
+		},
+		"no auth": {
+			id:     "interest0",
+			number: 1,
+			err:    status.Error(codes.Unauthenticated, "missing value for x-awakari-group-id in request metadata"),
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx := context.TODO()
+			if c.auth {
+				ctx = metadata.AppendToOutgoingContext(ctx, "x-awakari-group-id", "group0", "x-awakari-user-id", "user0")
+			}
+			resp, err := client.ReadRevision(ctx, &ReadRevisionRequest{
+				Id:     c.id,
+				Number: c.number,
+			})
+			if c.err == nil {
+				require.Nil(t, err)
+				assert.Equal(t, c.number, resp.Number)
+				assert.Equal(t, "group0", resp.GroupId)
+				assert.Equal(t, "user0", resp.UserId)
+				assert.Equal(t, "description2", resp.Description)
+				assert.True(t, resp.Enabled)
+				assert.Equal(t, "pattern2", resp.Cond.GetTc().GetTerm())
+				assert.Nil(t, resp.Expires)
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+}
+
+func TestServiceController_RestoreRevision(t *testing.T) {
+	//
+	addr := fmt.Sprintf("localhost:%d", port)
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	defer conn.Close()
+	client := NewServiceClient(conn)
+	//
+	cases := map[string]struct {
+		id     string
+		auth   bool
+		number uint64
+		err    error
+	}{
+		"ok": {
+			id:     "interest0",
+			auth:   true,
+			number: 1,
+		},
+		"missing number": {
+			id:     "interest0",
+			auth:   true,
+			number: 4,
+			err:    status.Error(codes.NotFound, "interest was not found"),
+		},
+		"missing": {
+			id:     "missing",
+			auth:   true,
+			number: 1,
+			err:    status.Error(codes.NotFound, "interest was not found"),
+		},
+		"fail": {
+			id:     "fail",
+			auth:   true,
+			number: 1,
+			err:    status.Error(codes.Internal, "internal interest storage failure"),
+		},
+		"no auth": {
+			id:     "interest0",
+			number: 1,
+			err:    status.Error(codes.Unauthenticated, "missing value for x-awakari-group-id in request metadata"),
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx := context.TODO()
+			if c.auth {
+				ctx = metadata.AppendToOutgoingContext(ctx, "x-awakari-group-id", "group0", "x-awakari-user-id", "user0")
+			}
+			resp, err := client.RestoreRevision(ctx, &RestoreRevisionRequest{
+				Id:     c.id,
+				Number: c.number,
+			})
+			if c.err == nil {
+				require.Nil(t, err)
+				assert.Equal(t, "sem_0", resp.Cond.GetGc().GetGroup()[0].GetSc().Id)
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+}
+
+func TestServiceController_SetEnabledBatch(t *testing.T) {
+	//
+	addr := fmt.Sprintf("localhost:%d", port)
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	client := NewServiceClient(conn)
+	//
+	cases := map[string]struct {
+		ids          []string
+		enabled      bool
+		enabledSince *timestamppb.Timestamp
+		n            int64
+		err          error
+	}{
+		"ok": {
+			ids: []string{
+				"interest0",
+				"interest1",
+			},
+			enabled:      true,
+			enabledSince: timestamppb.New(time.Now()),
+			n:            2,
+		},
+		"fail": {
+			ids: []string{
+				"fail",
+				"interest1",
+			},
+			err: status.Error(codes.Internal, "internal interest storage failure"),
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx := context.TODO()
+			ctx = metadata.AppendToOutgoingContext(ctx, "x-awakari-group-id", "group0", "x-awakari-user-id", "user0")
+			var resp *SetEnabledBatchResponse
+			resp, err = client.SetEnabledBatch(ctx, &SetEnabledBatchRequest{
+				Ids:     c.ids,
+				Enabled: c.enabled,
+			})
+			if c.err == nil {
+				assert.Equal(t, c.n, resp.N)
+			}
+			assert.ErrorIs(t, err, c.err)
+		})
+	}
+}
+
+func TestServiceController_ChangeOwner(t *testing.T) {
+	//
+	addr := fmt.Sprintf("localhost:%d", port)
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	client := NewServiceClient(conn)
+	//
+	cases := map[string]struct {
+		newUserId string
+		n         int64
+		err       error
+	}{
+		"ok": {
+			n: 42,
+		},
+		"fail": {
+			newUserId: "fail",
+			err:       status.Error(codes.Internal, "internal interest storage failure"),
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx := context.TODO()
+			ctx = metadata.AppendToOutgoingContext(ctx, "x-awakari-group-id", "group0", "x-awakari-user-id", "user0")
+			var resp *ChangeOwnerResponse
+			resp, err = client.ChangeOwner(ctx, &ChangeOwnerRequest{
+				NewUserId: c.newUserId,
+			})
+			if c.err == nil {
+				assert.Equal(t, c.n, resp.N)
+			}
+			assert.ErrorIs(t, err, c.err)
+		})
+	}
+}
+
+func TestServiceController_DryRun(t *testing.T) {
+	//
+	addr := fmt.Sprintf("localhost:%d", port)
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	client := NewServiceClient(conn)
+	//
+	events := []*Event{
+		{
+			Attributes: map[string]string{
+				"key0": "pattern0",
+				"key2": "42",
+			},
+		},
+		{
+			Attributes: map[string]string{
+				"key0": "pattern1",
+			},
+			Data: "pattern0",
+		},
+	}
+	cases := map[string]struct {
+		auth     bool
+		internal bool
+		req      *DryRunRequest
+		matched  []bool
+		leafs    [][]bool
+		err      error
+	}{
+		"inline condition": {
+			auth: true,
+			req: &DryRunRequest{
+				Target: &DryRunRequest_Cond{
+					Cond: &Condition{
+						Cond: &Condition_Gc{
+							Gc: &GroupCondition{
+								Logic: common.GroupLogic_Or,
+								Group: []*Condition{
+									{
+										Cond: &Condition_Tc{
+											Tc: &TextCondition{
+												Id:   "cond0",
+												Key:  "key0",
+												Term: "pattern0",
+											},
+										},
+									},
+									{
+										Not: true,
+										Cond: &Condition_Nc{
+											Nc: &NumberCondition{
+												Id:  "cond1",
+												Key: "key2",
+												Op:  Operation_Lt,
+												Val: 0,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+				Events: events,
+			},
+			matched: []bool{true, true},
+			leafs: [][]bool{
+				{true, true},
+				{false, true},
+			},
+		},
+		"interest id": {
+			auth: true,
+			req: &DryRunRequest{
+				Target: &DryRunRequest_Id{
+					Id: "interest0",
+				},
+				Events: events,
+			},
+			matched: []bool{false, false},
+			leafs: [][]bool{
+				{true, false, true},
+				{false, false, false},
+			},
+		},
+		"internal": {
+			internal: true,
+			req: &DryRunRequest{
+				Target: &DryRunRequest_Id{
+					Id: "interest0",
+				},
+				Events:   events[:1],
+				Internal: true,
+			},
+			matched: []bool{false},
+			leafs: [][]bool{
+				{true, false, true},
+			},
+		},
+		"missing": {
+			auth: true,
+			req: &DryRunRequest{
+				Target: &DryRunRequest_Id{
+					Id: "missing",
+				},
+				Events: events,
+			},
+			err: status.Error(codes.NotFound, "interest was not found"),
+		},
+		"no auth": {
+			req: &DryRunRequest{
+				Target: &DryRunRequest_Id{
+					Id: "interest0",
+				},
+				Events: events,
+			},
+			err: status.Error(codes.Unauthenticated, "missing value for x-awakari-group-id in request metadata"),
+		},
+		"no events": {
+			auth: true,
+			req: &DryRunRequest{
+				Target: &DryRunRequest_Id{
+					Id: "interest0",
+				},
+			},
+			err: status.Error(codes.InvalidArgument, "no sample events to test against"),
+		},
+		"no target": {
+			auth: true,
+			req: &DryRunRequest{
+				Events: events,
+			},
+			err: status.Error(codes.InvalidArgument, "neither interest id nor condition specified"),
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx := context.TODO()
+			if c.auth {
+				ctx = metadata.AppendToOutgoingContext(ctx, "x-awakari-group-id", "group0", "x-awakari-user-id", "user0")
+			}
+			resp, err := client.DryRun(ctx, c.req)
+			if c.err == nil {
+				require.Nil(t, err)
+				require.Equal(t, len(c.matched), len(resp.Results))
+				for i, r := range resp.Results {
+					assert.Equal(t, c.matched[i], r.Matched)
+					assert.Equal(t, r.Matched, r.Explanation.Matched)
+					group := r.Explanation.GetGc().GetGroup()
+					require.Equal(t, len(c.leafs[i]), len(group))
+					for j, leaf := range group {
+						assert.Equal(t, c.leafs[i][j], leaf.Matched)
+					}
+				}
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+}
+
+func TestServiceController_DryRunExplanation(t *testing.T) {
+	//
+	addr := fmt.Sprintf("localhost:%d", port)
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	defer conn.Close()
+	client := NewServiceClient(conn)
+	//
+	evt := &Event{
+		Attributes: map[string]string{
+			"title": "golang",
+			"price": "42",
+		},
+	}
+	cases := map[string]struct {
+		cond *Condition
+		out  *ConditionResult
+	}{
+		"regex": {
+			cond: &Condition{
+				Cond: &Condition_Rc{
+					Rc: &RegexCondition{
+						Id:      "cond0",
+						Key:     "title",
+						Pattern: "^go(lang)?$",
+					},
+				},
+			},
+			out: &ConditionResult{
+				Matched: true,
+				Cond: &ConditionResult_Rc{
+					Rc: &RegexCondition{
+						Id:      "cond0",
+						Key:     "title",
+						Pattern: "^go(lang)?$",
+					},
+				},
+			},
+		},
+		"range": {
+			cond: &Condition{
+				Cond: &Condition_Rgc{
+					Rgc: &RangeCondition{
+						Id:             "cond0",
+						Key:            "price",
+						Lower:          10,
+						LowerInclusive: true,
+						Upper:          100,
+					},
+				},
+			},
+			out: &ConditionResult{
+				Matched: true,
+				Cond: &ConditionResult_Rgc{
+					Rgc: &RangeCondition{
+						Id:             "cond0",
+						Key:            "price",
+						Lower:          10,
+						LowerInclusive: true,
+						Upper:          100,
+					},
+				},
+			},
+		},
+		"set": {
+			cond: &Condition{
+				Cond: &Condition_Setc{
+					Setc: &SetCondition{
+						Id:      "cond0",
+						Key:     "title",
+						Texts:   []string{"go", "golang"},
+						Numbers: []float64{1},
+					},
+				},
+			},
+			out: &ConditionResult{
+				Matched: true,
+				Cond: &ConditionResult_Setc{
+					Setc: &SetCondition{
+						Id:      "cond0",
+						Key:     "title",
+						Texts:   []string{"go", "golang"},
+						Numbers: []float64{1},
+					},
+				},
+			},
+		},
+		"exists": {
+			cond: &Condition{
+				Not: true,
+				Cond: &Condition_Ec{
+					Ec: &ExistsCondition{
+						Id:  "cond0",
+						Key: "geo",
+					},
+				},
+			},
+			out: &ConditionResult{
+				Not:     true,
+				Matched: true,
+				Cond: &ConditionResult_Ec{
+					Ec: &ExistsCondition{
+						Id:  "cond0",
+						Key: "geo",
+					},
+				},
+			},
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx := metadata.AppendToOutgoingContext(context.TODO(), "x-awakari-group-id", "group0", "x-awakari-user-id", "user0")
+			resp, err := client.DryRun(ctx, &DryRunRequest{
+				Target: &DryRunRequest_Cond{
+					Cond: c.cond,
+				},
+				Events: []*Event{
+					evt,
+				},
+			})
+			require.Nil(t, err)
+			require.Len(t, resp.Results, 1)
+			assert.True(t, proto.Equal(c.out, resp.Results[0].Explanation), resp.Results[0].Explanation.String())
+		})
+	}
+}
+
+func TestServiceController_Export(t *testing.T) {
+	//
+	addr := fmt.Sprintf("localhost:%d", port)
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	defer conn.Close()
+	client := NewServiceClient(conn)
+	//
+	cases := map[string]struct {
+		groupId string
+		ids     []string
+		err     error
+	}{
+		"ok": {
+			groupId: "group0",
+			ids: []string{
+				"sub0",
+				"sub1",
+			},
+		},
+		"fail": {
+			groupId: "fail",
+			err:     status.Error(codes.Internal, "internal interest storage failure"),
+		},
+		"no auth": {
+			err: status.Error(codes.Unauthenticated, "missing value for x-awakari-group-id in request metadata"),
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx := context.TODO()
+			if c.groupId != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "x-awakari-group-id", c.groupId, "x-awakari-user-id", "user0")
+			}
+			resp, err := client.Export(ctx, &ExportRequest{})
+			if c.err == nil {
+				require.Nil(t, err)
+				assert.Equal(t, uint32(ExportVersion), resp.Version)
+				var ids []string
+				for _, e := range resp.Interests {
+					ids = append(ids, e.Id)
+					assert.Equal(t, "description", e.Description)
+					assert.True(t, e.Enabled)
+					assert.True(t, e.Public)
+					assert.Equal(t, time.Date(2023, 10, 4, 10, 20, 45, 0, time.UTC), e.Expires.AsTime())
+					assert.Len(t, e.Cond.GetGc().GetGroup(), 3)
+				}
+				assert.Equal(t, c.ids, ids)
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+}
+
+func TestServiceController_Import(t *testing.T) {
+	//
+	addr := fmt.Sprintf("localhost:%d", port)
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	defer conn.Close()
+	client := NewServiceClient(conn)
+	//
+	cond := &Condition{
+		Cond: &Condition_Tc{
+			Tc: &TextCondition{
+				Id:   "cond0",
+				Key:  "key0",
+				Term: "term0",
+			},
+		},
+	}
+	doc := &InterestsDocument{
+		Version: ExportVersion,
+		Interests: []*InterestsDocumentEntry{
+			{
+				Id:   "interest0",
+				Cond: cond,
+			},
+			{
+				Id:   "conflict",
+				Cond: cond,
+			},
+			{
+				Id: "interest1",
+			},
+			{
+				Id:   "fail",
+				Cond: cond,
+			},
+		},
+	}
+	cases := map[string]struct {
+		auth     bool
+		doc      *InterestsDocument
+		conflict ImportConflict
+		statuses []ImportStatus
+		errs     []string
+		err      error
+	}{
+		"skip": {
+			auth:     true,
+			doc:      doc,
+			conflict: ImportConflict_SKIP,
+			statuses: []ImportStatus{
+				ImportStatus_IMPORTED,
+				ImportStatus_SKIPPED,
+				ImportStatus_FAILED,
+				ImportStatus_FAILED,
+			},
+			errs: []string{
+				"",
+				"",
+				"unsupported condition type",
+				"internal interest storage failure",
+			},
+		},
+		"overwrite": {
+			auth:     true,
+			doc:      doc,
+			conflict: ImportConflict_OVERWRITE,
+			statuses: []ImportStatus{
+				ImportStatus_IMPORTED,
+				ImportStatus_OVERWRITTEN,
+				ImportStatus_FAILED,
+				ImportStatus_FAILED,
+			},
+			errs: []string{
+				"",
+				"",
+				"unsupported condition type",
+				"internal interest storage failure",
+			},
+		},
+		"new id": {
+			auth:     true,
+			doc:      doc,
+			conflict: ImportConflict_NEW_ID,
+			statuses: []ImportStatus{
+				ImportStatus_IMPORTED,
+				ImportStatus_IMPORTED,
+				ImportStatus_FAILED,
+				ImportStatus_FAILED,
+			},
+			errs: []string{
+				"",
+				"",
+				"unsupported condition type",
+				"internal interest storage failure",
+			},
+		},
+		"unsupported version": {
+			auth: true,
+			doc: &InterestsDocument{
+				Version: 2,
+			},
+			err: status.Error(codes.InvalidArgument, "unsupported document version: 2"),
+		},
+		"no auth": {
+			doc: doc,
+			err: status.Error(codes.Unauthenticated, "missing value for x-awakari-group-id in request metadata"),
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx := context.TODO()
+			if c.auth {
+				ctx = metadata.AppendToOutgoingContext(ctx, "x-awakari-group-id", "group0", "x-awakari-user-id", "user0")
+			}
+			resp, err := client.Import(ctx, &ImportRequest{
+				Doc:      c.doc,
+				Conflict: c.conflict,
+			})
+			if c.err == nil {
+				require.Nil(t, err)
+				require.Len(t, resp.Results, len(c.doc.Interests))
+				for i, r := range resp.Results {
+					assert.Equal(t, c.doc.Interests[i].Id, r.SrcId)
+					assert.Equal(t, c.statuses[i], r.Status)
+					assert.Equal(t, c.errs[i], r.Error)
+					switch {
+					case c.conflict == ImportConflict_NEW_ID && r.SrcId == "conflict":
+						assert.NotEqual(t, r.SrcId, r.Id)
+						assert.NotEmpty(t, r.Id)
+					default:
+						assert.Equal(t, r.SrcId, r.Id)
+					}
+				}
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+}
+
+func TestServiceController_GetQuota(t *testing.T) {
+	//
+	addr := fmt.Sprintf("localhost:%d", port)
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	defer conn.Close()
+	client := NewServiceClient(conn)
+	//
+	cases := map[string]struct {
+		md     []string
+		limits *QuotaLimits
+		err    error
+	}{
+		"default": {
+			md: []string{
+				"x-awakari-group-id", "group0",
+				"x-awakari-user-id", "user0",
+			},
+			limits: &QuotaLimits{
+				Interests: 10,
+			},
+		},
+		"user override": {
+			md: []string{
+				"x-awakari-group-id", "group1",
+				"x-awakari-user-id", "user1",
+			},
+			limits: &QuotaLimits{
+				Public:         2,
+				ConditionDepth: 3,
+			},
+		},
+		"fail": {
+			md: []string{
+				"x-awakari-group-id", "fail",
+				"x-awakari-user-id", "user0",
+			},
+			err: status.Error(codes.Internal, "internal interest storage failure"),
+		},
+		"no auth": {
+			err: status.Error(codes.Unauthenticated, "missing value for x-awakari-group-id in request metadata"),
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx := metadata.AppendToOutgoingContext(context.TODO(), c.md...)
+			resp, err := client.GetQuota(ctx, &GetQuotaRequest{})
+			if c.err == nil {
+				require.Nil(t, err)
+				assert.Equal(t, c.limits.Interests, resp.Limits.Interests)
+				assert.Equal(t, c.limits.Public, resp.Limits.Public)
+				assert.Equal(t, c.limits.ConditionDepth, resp.Limits.ConditionDepth)
+				assert.Equal(t, c.limits.ConditionLeaves, resp.Limits.ConditionLeaves)
+				assert.Equal(t, int64(3), resp.Usage.Interests)
+				assert.Equal(t, int64(1), resp.Usage.Public)
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+}
+
+type actorCapturingStorage struct {
+	storage.Storage
+	actors *[]string
+}
+
+func (s actorCapturingStorage) Purge(ctx context.Context, groupId, userId, actor string) (p interest.Purged, err error) {
+	*s.actors = append(*s.actors, actor)
+	return
+}
+
+func TestServiceController_PurgeActor(t *testing.T) {
+	cases := map[string]struct {
+		p     Principal
+		actor string
+	}{
+		"user": {
+			p: Principal{
+				GroupId: "group0",
+				UserId:  "admin0",
+			},
+			actor: "group0/admin0",
+		},
+		"service": {
+			p: Principal{
+				UserId: "service0",
+				Roles:  []string{RoleInternal},
+			},
+			actor: "service0",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			stor := actorCapturingStorage{
+				actors: &[]string{},
+			}
+			sc := NewServiceController(stor, storage.Quotas{})
+			ctx := context.WithValue(context.TODO(), ctxKeyAuthInfo{}, authInfo{
+				p: c.p,
+			})
+			_, err := sc.Purge(ctx, &PurgeRequest{
+				GroupId: "group1",
+				UserId:  "user1",
+			})
+			require.Nil(t, err)
+			assert.Equal(t, []string{c.actor}, *stor.actors)
 		})
 	}
 }
@@ -10,9 +10,12 @@ import (
 	"net"
 )
 
-func Serve(stor storage.Storage, port uint16) (err error) {
-	c := NewServiceController(stor)
-	srv := grpc.NewServer()
+func Serve(stor storage.Storage, quotas storage.Quotas, port uint16, authn Authenticator, authz Policy) (err error) {
+	c := NewServiceController(stor, quotas)
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(authUnaryInterceptor(authn), authzUnaryInterceptor(authz)),
+		grpc.ChainStreamInterceptor(authStreamInterceptor(authn), authzStreamInterceptor(authz)),
+	)
 	RegisterServiceServer(srv, c)
 	grpc_health_v1.RegisterHealthServer(srv, health.NewServer())
 	reflection.Register(srv)
@@ -0,0 +1,491 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	grpcApi "github.com/awakari/interests/api/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PathPrefix is the common prefix of all the gateway routes.
+const PathPrefix = "/v1/"
+
+// headers forwarded to the gRPC API as the request metadata, the roles header is never forwarded: the REST callers are
+// the end users
+var headersForwarded = []string{
+	"Authorization",
+	"X-Awakari-Group-Id",
+	"X-Awakari-User-Id",
+}
+
+const contentTypeJson = "application/json"
+
+var unmarshalOpts = protojson.UnmarshalOptions{
+	DiscardUnknown: true,
+}
+
+var marshalOpts = protojson.MarshalOptions{
+	EmitUnpopulated: true,
+}
+
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type handler struct {
+	client grpcApi.ServiceClient
+}
+
+// NewHandler returns the HTTP/JSON gateway to the gRPC Service API. Every route is translated to the corresponding
+// Service method call using the specified client, so the same authentication and authorization rules apply.
+func NewHandler(client grpcApi.ServiceClient) http.Handler {
+	h := handler{
+		client: client,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/interests", h.create)
+	mux.HandleFunc("GET /v1/interests", h.search)
+	mux.HandleFunc("GET /v1/interests/{id}", h.read)
+	mux.HandleFunc("PUT /v1/interests/{id}", h.update)
+	mux.HandleFunc("DELETE /v1/interests/{id}", h.delete)
+	mux.HandleFunc("POST /v1/interests/{id}/restore", h.restore)
+	mux.HandleFunc("GET /v1/interests:listDeleted", h.listDeleted)
+	mux.HandleFunc("PUT /v1/interests/{id}/followers", h.updateFollowers)
+	mux.HandleFunc("PUT /v1/interests/{id}/result", h.updateResultTime)
+	mux.HandleFunc("GET /v1/interests/{id}/revisions", h.listRevisions)
+	mux.HandleFunc("GET /v1/interests/{id}/revisions/{number}", h.readRevision)
+	mux.HandleFunc("POST /v1/interests/{id}/revisions/{number}/restore", h.restoreRevision)
+	mux.HandleFunc("POST /v1/interests:setEnabledBatch", h.setEnabledBatch)
+	mux.HandleFunc("POST /v1/interests:changeOwner", h.changeOwner)
+	mux.HandleFunc("POST /v1/interests:purge", h.purge)
+	mux.HandleFunc("GET /v1/interests:export", h.export)
+	mux.HandleFunc("POST /v1/interests:import", h.importInterests)
+	mux.HandleFunc("GET /v1/interests:quota", h.getQuota)
+	mux.HandleFunc("POST /v1/interests:dryRun", h.dryRun)
+	mux.HandleFunc("GET /v1/conditions/{condId}/interests", h.searchByCondition)
+	mux.HandleFunc("POST /v1/conditions:searchInterests", h.searchByConditionBatch)
+	return mux
+}
+
+func (h handler) create(w http.ResponseWriter, r *http.Request) {
+	req := &grpcApi.CreateRequest{}
+	err := decodeBody(r, req)
+	if err == nil {
+		_, err = h.client.Create(outgoingContext(r), req)
+	}
+	if err == nil {
+		w.Header().Set("Location", PathPrefix+"interests/"+url.PathEscape(req.Id))
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		writeError(w, err)
+	}
+}
+
+func (h handler) read(w http.ResponseWriter, r *http.Request) {
+	req := &grpcApi.ReadRequest{
+		Id: r.PathValue("id"),
+	}
+	q := r.URL.Query()
+	err := parseBool(q, "internal", &req.Internal)
+	var resp *grpcApi.ReadResponse
+	if err == nil {
+		resp, err = h.client.Read(outgoingContext(r), req)
+	}
+	writeResponse(w, resp, err)
+}
+
+func (h handler) update(w http.ResponseWriter, r *http.Request) {
+	req := &grpcApi.UpdateRequest{}
+	err := decodeBody(r, req)
+	var resp *grpcApi.UpdateResponse
+	if err == nil {
+		req.Id = r.PathValue("id")
+		resp, err = h.client.Update(outgoingContext(r), req)
+	}
+	writeResponse(w, resp, err)
+}
+
+func (h handler) delete(w http.ResponseWriter, r *http.Request) {
+	req := &grpcApi.DeleteRequest{
+		Id: r.PathValue("id"),
+	}
+	resp, err := h.client.Delete(outgoingContext(r), req)
+	writeResponse(w, resp, err)
+}
+
+func (h handler) restore(w http.ResponseWriter, r *http.Request) {
+	req := &grpcApi.RestoreRequest{
+		Id: r.PathValue("id"),
+	}
+	resp, err := h.client.Restore(outgoingContext(r), req)
+	writeResponse(w, resp, err)
+}
+
+func (h handler) listDeleted(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := &grpcApi.ListDeletedRequest{
+		Cursor: q.Get("cursor"),
+	}
+	err := parseUint32(q, "limit", &req.Limit)
+	var resp *grpcApi.ListDeletedResponse
+	if err == nil {
+		resp, err = h.client.ListDeleted(outgoingContext(r), req)
+	}
+	writeResponse(w, resp, err)
+}
+
+func (h handler) updateFollowers(w http.ResponseWriter, r *http.Request) {
+	req := &grpcApi.UpdateFollowersRequest{}
+	err := decodeBody(r, req)
+	var resp *grpcApi.UpdateFollowersResponse
+	if err == nil {
+		req.Id = r.PathValue("id")
+		resp, err = h.client.UpdateFollowers(outgoingContext(r), req)
+	}
+	writeResponse(w, resp, err)
+}
+
+func (h handler) updateResultTime(w http.ResponseWriter, r *http.Request) {
+	req := &grpcApi.UpdateResultTimeRequest{}
+	err := decodeBody(r, req)
+	var resp *grpcApi.UpdateResultTimeResponse
+	if err == nil {
+		req.Id = r.PathValue("id")
+		resp, err = h.client.UpdateResultTime(outgoingContext(r), req)
+	}
+	writeResponse(w, resp, err)
+}
+
+func (h handler) listRevisions(w http.ResponseWriter, r *http.Request) {
+	req := &grpcApi.ListRevisionsRequest{
+		Id: r.PathValue("id"),
+	}
+	q := r.URL.Query()
+	err := parseUint64(q, "cursor", &req.Cursor)
+	if err == nil {
+		err = parseUint32(q, "limit", &req.Limit)
+	}
+	if err == nil {
+		err = parseBool(q, "internal", &req.Internal)
+	}
+	var resp *grpcApi.ListRevisionsResponse
+	if err == nil {
+		resp, err = h.client.ListRevisions(outgoingContext(r), req)
+	}
+	writeResponse(w, resp, err)
+}
+
+func (h handler) readRevision(w http.ResponseWriter, r *http.Request) {
+	req := &grpcApi.ReadRevisionRequest{
+		Id: r.PathValue("id"),
+	}
+	err := parsePathUint64(r, "number", &req.Number)
+	if err == nil {
+		err = parseBool(r.URL.Query(), "internal", &req.Internal)
+	}
+	var resp *grpcApi.Revision
+	if err == nil {
+		resp, err = h.client.ReadRevision(outgoingContext(r), req)
+	}
+	writeResponse(w, resp, err)
+}
+
+func (h handler) restoreRevision(w http.ResponseWriter, r *http.Request) {
+	req := &grpcApi.RestoreRevisionRequest{}
+	err := decodeBody(r, req)
+	if err == nil {
+		req.Id = r.PathValue("id")
+		err = parsePathUint64(r, "number", &req.Number)
+	}
+	var resp *grpcApi.RestoreRevisionResponse
+	if err == nil {
+		resp, err = h.client.RestoreRevision(outgoingContext(r), req)
+	}
+	writeResponse(w, resp, err)
+}
+
+func (h handler) setEnabledBatch(w http.ResponseWriter, r *http.Request) {
+	handleBody(w, r, &grpcApi.SetEnabledBatchRequest{}, h.client.SetEnabledBatch)
+}
+
+func (h handler) changeOwner(w http.ResponseWriter, r *http.Request) {
+	handleBody(w, r, &grpcApi.ChangeOwnerRequest{}, h.client.ChangeOwner)
+}
+
+func (h handler) purge(w http.ResponseWriter, r *http.Request) {
+	handleBody(w, r, &grpcApi.PurgeRequest{}, h.client.Purge)
+}
+
+func (h handler) export(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.client.Export(outgoingContext(r), &grpcApi.ExportRequest{})
+	writeResponse(w, resp, err)
+}
+
+func (h handler) importInterests(w http.ResponseWriter, r *http.Request) {
+	handleBody(w, r, &grpcApi.ImportRequest{}, h.client.Import)
+}
+
+func (h handler) getQuota(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.client.GetQuota(outgoingContext(r), &grpcApi.GetQuotaRequest{})
+	writeResponse(w, resp, err)
+}
+
+func (h handler) dryRun(w http.ResponseWriter, r *http.Request) {
+	handleBody(w, r, &grpcApi.DryRunRequest{}, h.client.DryRun)
+}
+
+// search selects the own interests when "own=true" is set in the query, otherwise searches among all accessible
+// interests using the "sort" and the corresponding cursor parameters.
+func (h handler) search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var own bool
+	var limit uint32
+	var order grpcApi.Order
+	err := parseBool(q, "own", &own)
+	if err == nil {
+		err = parseUint32(q, "limit", &limit)
+	}
+	if err == nil {
+		err = parseEnum(q, "order", grpcApi.Order_value, (*int32)(&order))
+	}
+	ctx := outgoingContext(r)
+	switch {
+	case err != nil:
+		writeError(w, err)
+	case own:
+		req := &grpcApi.SearchOwnRequest{
+			Cursor:  q.Get("cursor"),
+			Limit:   limit,
+			Order:   order,
+			Pattern: q.Get("pattern"),
+		}
+		err = parseBool(q, "private", &req.Private)
+		var resp *grpcApi.SearchOwnResponse
+		if err == nil {
+			resp, err = h.client.SearchOwn(ctx, req)
+		}
+		writeResponse(w, resp, err)
+	default:
+		req := &grpcApi.SearchRequest{
+			Cursor: &grpcApi.Cursor{
+				Id: q.Get("cursor"),
+			},
+			Limit:   limit,
+			Order:   order,
+			Pattern: q.Get("pattern"),
+		}
+		err = parseBool(q, "all", &req.All)
+		if err == nil {
+			err = parseEnum(q, "sort", grpcApi.Sort_value, (*int32)(&req.Sort))
+		}
+		if err == nil {
+			err = parseInt64(q, "cursorFollowers", &req.Cursor.Followers)
+		}
+		if err == nil {
+			err = parseTime(q, "cursorTimeCreated", &req.Cursor.TimeCreated)
+		}
+		var resp *grpcApi.SearchResponse
+		if err == nil {
+			resp, err = h.client.Search(ctx, req)
+		}
+		writeResponse(w, resp, err)
+	}
+}
+
+func (h handler) searchByCondition(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := &grpcApi.SearchByConditionRequest{
+		CondId: r.PathValue("condId"),
+		Cursor: q.Get("cursor"),
+	}
+	err := parseUint32(q, "limit", &req.Limit)
+	var resp *grpcApi.SearchByConditionResponse
+	if err == nil {
+		resp, err = h.client.SearchByCondition(outgoingContext(r), req)
+	}
+	writeResponse(w, resp, err)
+}
+
+func (h handler) searchByConditionBatch(w http.ResponseWriter, r *http.Request) {
+	handleBody(w, r, &grpcApi.SearchByConditionBatchRequest{}, h.client.SearchByConditionBatch)
+}
+
+// handleBody serves the route where the whole request message is the request body.
+func handleBody[Req, Resp proto.Message](
+	w http.ResponseWriter,
+	r *http.Request,
+	req Req,
+	call func(ctx context.Context, req Req, opts ...grpc.CallOption) (Resp, error),
+) {
+	err := decodeBody(r, req)
+	var resp Resp
+	if err == nil {
+		resp, err = call(outgoingContext(r), req)
+	}
+	writeResponse(w, resp, err)
+}
+
+func outgoingContext(r *http.Request) context.Context {
+	md := metadata.MD{}
+	md.Set(grpcApi.KeyGateway, "http")
+	for _, k := range headersForwarded {
+		if v := r.Header.Get(k); v != "" {
+			md.Set(k, v)
+		}
+	}
+	return metadata.NewOutgoingContext(r.Context(), md)
+}
+
+func decodeBody(r *http.Request, req proto.Message) (err error) {
+	var data []byte
+	data, err = io.ReadAll(r.Body)
+	if err == nil && len(data) > 0 {
+		err = unmarshalOpts.Unmarshal(data, req)
+	}
+	if err != nil {
+		err = status.Error(codes.InvalidArgument, fmt.Sprintf("invalid request body: %s", err))
+	}
+	return
+}
+
+func writeResponse[Resp proto.Message](w http.ResponseWriter, resp Resp, err error) {
+	var data []byte
+	if err == nil {
+		data, err = marshalOpts.Marshal(resp)
+	}
+	switch err {
+	case nil:
+		w.Header().Set("Content-Type", contentTypeJson)
+		_, _ = w.Write(data)
+	default:
+		writeError(w, err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	s := status.Convert(err)
+	w.Header().Set("Content-Type", contentTypeJson)
+	w.WriteHeader(httpStatus(s.Code()))
+	_ = json.NewEncoder(w).Encode(errorResponse{
+		Code:    s.Code().String(),
+		Message: s.Message(),
+	})
+}
+
+// httpStatus maps the gRPC status code to the HTTP status, the same way as the grpc-gateway does.
+func httpStatus(code codes.Code) (s int) {
+	switch code {
+	case codes.OK:
+		s = http.StatusOK
+	case codes.Canceled:
+		s = 499
+	case codes.InvalidArgument, codes.OutOfRange, codes.FailedPrecondition:
+		s = http.StatusBadRequest
+	case codes.Unauthenticated:
+		s = http.StatusUnauthorized
+	case codes.PermissionDenied:
+		s = http.StatusForbidden
+	case codes.NotFound:
+		s = http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		s = http.StatusConflict
+	case codes.ResourceExhausted:
+		s = http.StatusTooManyRequests
+	case codes.Unimplemented:
+		s = http.StatusNotImplemented
+	case codes.Unavailable:
+		s = http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		s = http.StatusGatewayTimeout
+	default:
+		s = http.StatusInternalServerError
+	}
+	return
+}
+
+func parseBool(q url.Values, k string, dst *bool) (err error) {
+	if v := q.Get(k); v != "" {
+		*dst, err = strconv.ParseBool(v)
+		err = queryParamError(k, err)
+	}
+	return
+}
+
+func parseUint32(q url.Values, k string, dst *uint32) (err error) {
+	if v := q.Get(k); v != "" {
+		var n uint64
+		n, err = strconv.ParseUint(v, 10, 32)
+		*dst = uint32(n)
+		err = queryParamError(k, err)
+	}
+	return
+}
+
+func parseUint64(q url.Values, k string, dst *uint64) (err error) {
+	if v := q.Get(k); v != "" {
+		*dst, err = strconv.ParseUint(v, 10, 64)
+		err = queryParamError(k, err)
+	}
+	return
+}
+
+func parsePathUint64(r *http.Request, k string, dst *uint64) (err error) {
+	*dst, err = strconv.ParseUint(r.PathValue(k), 10, 64)
+	if err != nil {
+		err = status.Error(codes.InvalidArgument, fmt.Sprintf("invalid path parameter %s: %s", k, err))
+	}
+	return
+}
+
+func parseInt64(q url.Values, k string, dst *int64) (err error) {
+	if v := q.Get(k); v != "" {
+		*dst, err = strconv.ParseInt(v, 10, 64)
+		err = queryParamError(k, err)
+	}
+	return
+}
+
+func parseTime(q url.Values, k string, dst **timestamppb.Timestamp) (err error) {
+	if v := q.Get(k); v != "" {
+		var t time.Time
+		t, err = time.Parse(time.RFC3339Nano, v)
+		if err == nil {
+			*dst = timestamppb.New(t)
+		}
+		err = queryParamError(k, err)
+	}
+	return
+}
+
+// parseEnum accepts the enum value name in any case.
+func parseEnum(q url.Values, k string, values map[string]int32, dst *int32) (err error) {
+	if v := q.Get(k); v != "" {
+		var found bool
+		*dst, found = values[strings.ToUpper(v)]
+		if !found {
+			err = queryParamError(k, fmt.Errorf("unknown value %q", v))
+		}
+	}
+	return
+}
+
+func queryParamError(k string, src error) (err error) {
+	if src != nil {
+		err = status.Error(codes.InvalidArgument, fmt.Sprintf("invalid query parameter %s: %s", k, src))
+	}
+	return
+}
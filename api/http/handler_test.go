@@ -0,0 +1,555 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	grpcApi "github.com/awakari/interests/api/grpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type clientStub struct {
+	// the methods not used in the tests panic
+	grpcApi.ServiceClient
+}
+
+func (cs clientStub) Create(ctx context.Context, req *grpcApi.CreateRequest, opts ...grpc.CallOption) (*grpcApi.CreateResponse, error) {
+	return &grpcApi.CreateResponse{}, stubError(ctx, req.Id)
+}
+
+func (cs clientStub) Read(ctx context.Context, req *grpcApi.ReadRequest, opts ...grpc.CallOption) (*grpcApi.ReadResponse, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	resp := &grpcApi.ReadResponse{
+		Description: req.Id,
+		Enabled:     req.Internal,
+	}
+	if vals := md.Get("x-awakari-group-id"); len(vals) > 0 {
+		resp.GroupId = vals[0]
+	}
+	if vals := md.Get("x-awakari-user-id"); len(vals) > 0 {
+		resp.UserId = vals[0]
+	}
+	return resp, stubError(ctx, req.Id)
+}
+
+func (cs clientStub) Search(ctx context.Context, req *grpcApi.SearchRequest, opts ...grpc.CallOption) (*grpcApi.SearchResponse, error) {
+	return &grpcApi.SearchResponse{
+		Ids: []string{
+			req.Sort.String(),
+			req.Order.String(),
+			req.Cursor.Id,
+			req.Cursor.TimeCreated.AsTime().Format(time.RFC3339),
+		},
+	}, stubError(ctx, req.Cursor.Id)
+}
+
+func (cs clientStub) SearchOwn(ctx context.Context, req *grpcApi.SearchOwnRequest, opts ...grpc.CallOption) (*grpcApi.SearchOwnResponse, error) {
+	return &grpcApi.SearchOwnResponse{
+		Ids: []string{
+			"own",
+			req.Cursor,
+		},
+	}, stubError(ctx, req.Cursor)
+}
+
+func (cs clientStub) SearchByCondition(ctx context.Context, req *grpcApi.SearchByConditionRequest, opts ...grpc.CallOption) (*grpcApi.SearchByConditionResponse, error) {
+	return &grpcApi.SearchByConditionResponse{
+		Page: []*grpcApi.SearchByConditionResult{
+			{
+				Id: req.CondId,
+			},
+		},
+	}, stubError(ctx, req.CondId)
+}
+
+func (cs clientStub) SearchByConditionBatch(ctx context.Context, req *grpcApi.SearchByConditionBatchRequest, opts ...grpc.CallOption) (*grpcApi.SearchByConditionBatchResponse, error) {
+	resp := &grpcApi.SearchByConditionBatchResponse{
+		Pages: map[string]*grpcApi.SearchByConditionBatchPage{},
+	}
+	for _, q := range req.Queries {
+		resp.Pages[q.CondId] = &grpcApi.SearchByConditionBatchPage{
+			Ids: []string{q.Cursor},
+		}
+	}
+	return resp, stubError(ctx, "")
+}
+
+func (cs clientStub) DryRun(ctx context.Context, req *grpcApi.DryRunRequest, opts ...grpc.CallOption) (*grpcApi.DryRunResponse, error) {
+	resp := &grpcApi.DryRunResponse{}
+	for _, evt := range req.Events {
+		resp.Results = append(resp.Results, &grpcApi.DryRunResult{
+			Matched: evt.Data != "",
+		})
+	}
+	return resp, stubError(ctx, req.GetId())
+}
+
+func (cs clientStub) Restore(ctx context.Context, req *grpcApi.RestoreRequest, opts ...grpc.CallOption) (*grpcApi.RestoreResponse, error) {
+	return &grpcApi.RestoreResponse{
+		Cond: &grpcApi.Condition{
+			Cond: &grpcApi.Condition_Tc{
+				Tc: &grpcApi.TextCondition{
+					Id: req.Id,
+				},
+			},
+		},
+	}, stubError(ctx, req.Id)
+}
+
+func (cs clientStub) ListDeleted(ctx context.Context, req *grpcApi.ListDeletedRequest, opts ...grpc.CallOption) (*grpcApi.ListDeletedResponse, error) {
+	return &grpcApi.ListDeletedResponse{
+		Page: []*grpcApi.DeletedInterest{
+			{
+				Id:          req.Cursor,
+				Description: fmt.Sprintf("%d", req.Limit),
+			},
+		},
+	}, stubError(ctx, req.Cursor)
+}
+
+func (cs clientStub) Purge(ctx context.Context, req *grpcApi.PurgeRequest, opts ...grpc.CallOption) (*grpcApi.PurgeResponse, error) {
+	return &grpcApi.PurgeResponse{
+		Count:   1,
+		CondIds: []string{req.GroupId + "/" + req.UserId},
+	}, stubError(ctx, req.UserId)
+}
+
+func (cs clientStub) Export(ctx context.Context, req *grpcApi.ExportRequest, opts ...grpc.CallOption) (*grpcApi.InterestsDocument, error) {
+	return &grpcApi.InterestsDocument{
+		Version: 1,
+		Interests: []*grpcApi.InterestsDocumentEntry{
+			{
+				Id:          "interest0",
+				Description: "description0",
+			},
+		},
+	}, stubError(ctx, "")
+}
+
+func (cs clientStub) Import(ctx context.Context, req *grpcApi.ImportRequest, opts ...grpc.CallOption) (*grpcApi.ImportResponse, error) {
+	resp := &grpcApi.ImportResponse{}
+	var err error
+	for _, e := range req.GetDoc().GetInterests() {
+		resp.Results = append(resp.Results, &grpcApi.ImportResult{
+			SrcId: e.Id,
+			Id:    e.Id + "/" + req.Conflict.String(),
+		})
+		if err == nil {
+			err = stubError(ctx, e.Id)
+		}
+	}
+	return resp, err
+}
+
+func (cs clientStub) GetQuota(ctx context.Context, req *grpcApi.GetQuotaRequest, opts ...grpc.CallOption) (*grpcApi.GetQuotaResponse, error) {
+	return &grpcApi.GetQuotaResponse{
+		Limits: &grpcApi.QuotaLimits{
+			Interests: 10,
+		},
+		Usage: &grpcApi.QuotaUsage{
+			Interests: 3,
+			Public:    1,
+		},
+	}, stubError(ctx, "")
+}
+
+func (cs clientStub) ListRevisions(ctx context.Context, req *grpcApi.ListRevisionsRequest, opts ...grpc.CallOption) (*grpcApi.ListRevisionsResponse, error) {
+	return &grpcApi.ListRevisionsResponse{
+		Page: []*grpcApi.Revision{
+			{
+				Number:      req.Cursor + 1,
+				Description: req.Id,
+				Enabled:     req.Internal,
+			},
+		},
+	}, stubError(ctx, req.Id)
+}
+
+func (cs clientStub) ReadRevision(ctx context.Context, req *grpcApi.ReadRevisionRequest, opts ...grpc.CallOption) (*grpcApi.Revision, error) {
+	return &grpcApi.Revision{
+		Number:      req.Number,
+		Description: req.Id,
+	}, stubError(ctx, req.Id)
+}
+
+func (cs clientStub) RestoreRevision(ctx context.Context, req *grpcApi.RestoreRevisionRequest, opts ...grpc.CallOption) (*grpcApi.RestoreRevisionResponse, error) {
+	return &grpcApi.RestoreRevisionResponse{
+		Cond: &grpcApi.Condition{
+			Cond: &grpcApi.Condition_Tc{
+				Tc: &grpcApi.TextCondition{
+					Id:   fmt.Sprintf("%s/%d", req.Id, req.Number),
+					Term: fmt.Sprintf("%t", req.Internal),
+				},
+			},
+		},
+	}, stubError(ctx, req.Id)
+}
+
+func stubError(ctx context.Context, id string) (err error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	switch {
+	case len(md.Get("x-awakari-group-id")) == 0:
+		err = status.Error(codes.Unauthenticated, "missing value for x-awakari-group-id in request metadata")
+	case id == "fail":
+		err = status.Error(codes.Internal, "internal interest storage failure")
+	case id == "missing":
+		err = status.Error(codes.NotFound, "interest was not found")
+	case id == "conflict":
+		err = status.Error(codes.AlreadyExists, "id already in use")
+	case id == "denied":
+		err = status.Error(codes.PermissionDenied, "method is not allowed for roles [user]")
+	}
+	return
+}
+
+func TestHandler(t *testing.T) {
+	//
+	h := NewHandler(clientStub{})
+	//
+	cases := map[string]struct {
+		method  string
+		target  string
+		body    string
+		anon    bool
+		status  int
+		resp    string
+		headers map[string]string
+	}{
+		"create": {
+			method: http.MethodPost,
+			target: "/v1/interests",
+			body:   `{"id":"interest0","description":"my interest","cond":{"tc":{"key":"key0","term":"term0"}}}`,
+			status: http.StatusCreated,
+			headers: map[string]string{
+				"Location": "/v1/interests/interest0",
+			},
+		},
+		"create conflict": {
+			method: http.MethodPost,
+			target: "/v1/interests",
+			body:   `{"id":"conflict"}`,
+			status: http.StatusConflict,
+			resp:   `{"code":"AlreadyExists","message":"id already in use"}`,
+		},
+		"create invalid body": {
+			method: http.MethodPost,
+			target: "/v1/interests",
+			body:   `{"id":`,
+			status: http.StatusBadRequest,
+		},
+		"create unauthenticated": {
+			method: http.MethodPost,
+			target: "/v1/interests",
+			body:   `{"id":"interest0"}`,
+			anon:   true,
+			status: http.StatusUnauthorized,
+			resp:   `{"code":"Unauthenticated","message":"missing value for x-awakari-group-id in request metadata"}`,
+		},
+		"read": {
+			method: http.MethodGet,
+			target: "/v1/interests/interest0?internal=true",
+			status: http.StatusOK,
+			resp:   `"description":"interest0","enabled":true,`,
+		},
+		"read forwards headers": {
+			method: http.MethodGet,
+			target: "/v1/interests/interest0",
+			status: http.StatusOK,
+			resp:   `"groupId":"group0","userId":"user0"`,
+		},
+		"read missing": {
+			method: http.MethodGet,
+			target: "/v1/interests/missing",
+			status: http.StatusNotFound,
+			resp:   `{"code":"NotFound","message":"interest was not found"}`,
+		},
+		"read fail": {
+			method: http.MethodGet,
+			target: "/v1/interests/fail",
+			status: http.StatusInternalServerError,
+			resp:   `{"code":"Internal","message":"internal interest storage failure"}`,
+		},
+		"read denied": {
+			method: http.MethodGet,
+			target: "/v1/interests/denied",
+			status: http.StatusForbidden,
+		},
+		"read invalid flag": {
+			method: http.MethodGet,
+			target: "/v1/interests/interest0?internal=maybe",
+			status: http.StatusBadRequest,
+			resp:   `{"code":"InvalidArgument","message":"invalid query parameter internal: strconv.ParseBool: parsing \"maybe\": invalid syntax"}`,
+		},
+		"restore": {
+			method: http.MethodPost,
+			target: "/v1/interests/interest0/restore",
+			status: http.StatusOK,
+			resp:   `"tc":{"id":"interest0",`,
+		},
+		"restore conflict": {
+			method: http.MethodPost,
+			target: "/v1/interests/conflict/restore",
+			status: http.StatusConflict,
+			resp:   `{"code":"AlreadyExists","message":"id already in use"}`,
+		},
+		"list deleted": {
+			method: http.MethodGet,
+			target: "/v1/interests:listDeleted?cursor=interest1&limit=10",
+			status: http.StatusOK,
+			resp:   `{"page":[{"id":"interest1","deletedAt":null,"description":"10",`,
+		},
+		"list deleted invalid limit": {
+			method: http.MethodGet,
+			target: "/v1/interests:listDeleted?limit=x",
+			status: http.StatusBadRequest,
+		},
+		"purge": {
+			method: http.MethodPost,
+			target: "/v1/interests:purge",
+			body:   `{"groupId":"group1","userId":"user1"}`,
+			status: http.StatusOK,
+			resp:   `{"count":"1","deleted":"0","condIds":["group1/user1"]}`,
+		},
+		"purge denied": {
+			method: http.MethodPost,
+			target: "/v1/interests:purge",
+			body:   `{"groupId":"group1","userId":"denied"}`,
+			status: http.StatusForbidden,
+		},
+		"export": {
+			method: http.MethodGet,
+			target: "/v1/interests:export",
+			status: http.StatusOK,
+			resp:   `{"version":1,"interests":[{"id":"interest0","description":"description0",`,
+		},
+		"import": {
+			method: http.MethodPost,
+			target: "/v1/interests:import",
+			body:   `{"doc":{"version":1,"interests":[{"id":"interest0"}]},"conflict":"NEW_ID"}`,
+			status: http.StatusOK,
+			resp:   `{"results":[{"srcId":"interest0","id":"interest0/NEW_ID","status":"IMPORTED","error":""}]}`,
+		},
+		"import invalid body": {
+			method: http.MethodPost,
+			target: "/v1/interests:import",
+			body:   `{"doc":[]}`,
+			status: http.StatusBadRequest,
+		},
+		"get quota": {
+			method: http.MethodGet,
+			target: "/v1/interests:quota",
+			status: http.StatusOK,
+			resp:   `{"limits":{"interests":"10","public":"0","conditionDepth":0,"conditionLeaves":0},"usage":{"interests":"3","public":"1"}}`,
+		},
+		"list revisions": {
+			method: http.MethodGet,
+			target: "/v1/interests/interest0/revisions?cursor=2&limit=1&internal=true",
+			status: http.StatusOK,
+			resp:   `{"page":[{"number":"3","groupId":"","userId":"","time":null,"description":"interest0","enabled":true,`,
+		},
+		"list revisions missing": {
+			method: http.MethodGet,
+			target: "/v1/interests/missing/revisions",
+			status: http.StatusNotFound,
+		},
+		"read revision": {
+			method: http.MethodGet,
+			target: "/v1/interests/interest0/revisions/2",
+			status: http.StatusOK,
+			resp:   `{"number":"2","groupId":"","userId":"","time":null,"description":"interest0",`,
+		},
+		"read revision invalid number": {
+			method: http.MethodGet,
+			target: "/v1/interests/interest0/revisions/last",
+			status: http.StatusBadRequest,
+			resp:   `{"code":"InvalidArgument","message":"invalid path parameter number: strconv.ParseUint: parsing \"last\": invalid syntax"}`,
+		},
+		"restore revision": {
+			method: http.MethodPost,
+			target: "/v1/interests/interest0/revisions/1/restore",
+			body:   `{"internal":true}`,
+			status: http.StatusOK,
+			resp:   `"tc":{"id":"interest0/1","key":"","term":"true","exact":false}`,
+		},
+		"restore revision fail": {
+			method: http.MethodPost,
+			target: "/v1/interests/fail/revisions/1/restore",
+			status: http.StatusInternalServerError,
+		},
+		"search": {
+			method: http.MethodGet,
+			target: "/v1/interests?sort=time_created&order=desc&cursor=interest1&cursorTimeCreated=2024-01-02T03:04:05Z",
+			status: http.StatusOK,
+			resp:   `{"ids":["TIME_CREATED","DESC","interest1","2024-01-02T03:04:05Z"]}`,
+		},
+		"search unknown sort": {
+			method: http.MethodGet,
+			target: "/v1/interests?sort=random",
+			status: http.StatusBadRequest,
+			resp:   `{"code":"InvalidArgument","message":"invalid query parameter sort: unknown value \"random\""}`,
+		},
+		"search invalid limit": {
+			method: http.MethodGet,
+			target: "/v1/interests?limit=-1",
+			status: http.StatusBadRequest,
+		},
+		"search own": {
+			method: http.MethodGet,
+			target: "/v1/interests?own=true&cursor=interest1",
+			status: http.StatusOK,
+			resp:   `{"ids":["own","interest1"]}`,
+		},
+		"search by condition": {
+			method: http.MethodGet,
+			target: "/v1/conditions/cond0/interests?limit=10",
+			status: http.StatusOK,
+			resp:   `"page":[{"id":"cond0","cond":null}]`,
+		},
+		"search by condition batch": {
+			method: http.MethodPost,
+			target: "/v1/conditions:searchInterests",
+			body:   `{"queries":[{"condId":"cond0","cursor":"interest1","limit":10}]}`,
+			status: http.StatusOK,
+			resp:   `"pages":{"cond0":{"ids":["interest1"],"expires":null}}`,
+		},
+		"dry run": {
+			method: http.MethodPost,
+			target: "/v1/interests:dryRun",
+			body:   `{"id":"interest0","events":[{"data":"lorem ipsum"},{}]}`,
+			status: http.StatusOK,
+			resp:   `{"results":[{"matched":true,"explanation":null},{"matched":false,"explanation":null}]}`,
+		},
+		"method not allowed": {
+			method: http.MethodPatch,
+			target: "/v1/interests/interest0",
+			status: http.StatusMethodNotAllowed,
+		},
+		"unknown route": {
+			method: http.MethodGet,
+			target: "/v1/subscriptions",
+			status: http.StatusNotFound,
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, c.target, strings.NewReader(c.body))
+			if !c.anon {
+				req.Header.Set("X-Awakari-Group-Id", "group0")
+				req.Header.Set("X-Awakari-User-Id", "user0")
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			assert.Equal(t, c.status, w.Code)
+			// protojson output whitespace is unstable by design
+			var body bytes.Buffer
+			if c.resp != "" {
+				require.Nil(t, json.Compact(&body, w.Body.Bytes()))
+			}
+			assert.Contains(t, body.String(), c.resp)
+			for hk, hv := range c.headers {
+				assert.Equal(t, hv, w.Header().Get(hk))
+			}
+		})
+	}
+}
+
+type metadataCapturingStub struct {
+	clientStub
+	md *metadata.MD
+}
+
+func (mcs metadataCapturingStub) GetQuota(ctx context.Context, req *grpcApi.GetQuotaRequest, opts ...grpc.CallOption) (*grpcApi.GetQuotaResponse, error) {
+	*mcs.md, _ = metadata.FromOutgoingContext(ctx)
+	return &grpcApi.GetQuotaResponse{}, nil
+}
+
+func TestHandler_Metadata(t *testing.T) {
+	cases := map[string]struct {
+		headers map[string]string
+		md      metadata.MD
+	}{
+		"identity": {
+			headers: map[string]string{
+				"Authorization":      "Bearer token0",
+				"X-Awakari-Group-Id": "group0",
+				"X-Awakari-User-Id":  "user0",
+			},
+			md: metadata.MD{
+				"authorization":      []string{"Bearer token0"},
+				"x-awakari-group-id": []string{"group0"},
+				"x-awakari-user-id":  []string{"user0"},
+				grpcApi.KeyGateway:   []string{"http"},
+			},
+		},
+		"roles are ignored": {
+			headers: map[string]string{
+				"X-Awakari-Group-Id": "group0",
+				"X-Awakari-User-Id":  "user0",
+				"X-Awakari-Roles":    "internal",
+			},
+			md: metadata.MD{
+				"x-awakari-group-id": []string{"group0"},
+				"x-awakari-user-id":  []string{"user0"},
+				grpcApi.KeyGateway:   []string{"http"},
+			},
+		},
+		"anonymous is marked relayed": {
+			headers: map[string]string{
+				"X-Awakari-Roles": "internal",
+			},
+			md: metadata.MD{
+				grpcApi.KeyGateway: []string{"http"},
+			},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			var md metadata.MD
+			h := NewHandler(metadataCapturingStub{
+				md: &md,
+			})
+			req := httptest.NewRequest(http.MethodGet, "/v1/interests:quota", nil)
+			for hk, hv := range c.headers {
+				req.Header.Set(hk, hv)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, c.md, md)
+		})
+	}
+}
+
+func TestHttpStatus(t *testing.T) {
+	cases := map[codes.Code]int{
+		codes.OK:                http.StatusOK,
+		codes.InvalidArgument:   http.StatusBadRequest,
+		codes.Unauthenticated:   http.StatusUnauthorized,
+		codes.PermissionDenied:  http.StatusForbidden,
+		codes.NotFound:          http.StatusNotFound,
+		codes.AlreadyExists:     http.StatusConflict,
+		codes.ResourceExhausted: http.StatusTooManyRequests,
+		codes.Unimplemented:     http.StatusNotImplemented,
+		codes.Unavailable:       http.StatusServiceUnavailable,
+		codes.DeadlineExceeded:  http.StatusGatewayTimeout,
+		codes.Internal:          http.StatusInternalServerError,
+		codes.Unknown:           http.StatusInternalServerError,
+	}
+	for code, expected := range cases {
+		t.Run(code.String(), func(t *testing.T) {
+			assert.Equal(t, expected, httpStatus(code))
+		})
+	}
+}
@@ -9,14 +9,19 @@ type Config struct {
 	Api struct {
 		Port uint16 `envconfig:"API_PORT" default:"50051" required:"true"`
 		Http HttpConfig
+		Auth AuthConfig
 	}
-	Db  DbConfig
-	Log struct {
+	Db    DbConfig
+	Cache CacheConfig
+	Quota QuotaConfig
+	Log   struct {
 		Level int `envconfig:"LOG_LEVEL" default:"-4" required:"true"`
 	}
 }
 
 type DbConfig struct {
+	// Type selects the storage backend implementation, see the DbType* constants.
+	Type     string `envconfig:"DB_TYPE" default:"mongo" required:"true"`
 	Uri      string `envconfig:"DB_URI" default:"mongodb://localhost:27017/?retryWrites=true&w=majority" required:"true"`
 	Name     string `envconfig:"DB_NAME" default:"interests" required:"true"`
 	UserName string `envconfig:"DB_USERNAME" default:""`
@@ -33,6 +38,49 @@ type DbConfig struct {
 	ResultTtl time.Duration `envconfig:"DB_RESULT_TTL" default:"1h" required:"true"`
 }
 
+const (
+	DbTypeMongo    = "mongo"
+	DbTypeMemory   = "memory"
+	DbTypePostgres = "postgres"
+	DbTypeSqlite   = "sqlite"
+)
+
+type AuthConfig struct {
+	// TrustedGateway enables taking the caller identity from the x-awakari-group-id and x-awakari-user-id headers
+	// as is. Should be used only when the service is reachable through the gateway authenticating the callers.
+	TrustedGateway bool `envconfig:"API_AUTH_TRUSTED_GATEWAY" default:"false" required:"true"`
+	// TrustedGatewayRoles enables taking the caller roles from the x-awakari-roles header in the trusted gateway mode.
+	// Should be used only when the gateway strips the header of every incoming request and sets it for the
+	// authenticated callers only, the roles are never taken from the headers otherwise.
+	TrustedGatewayRoles bool `envconfig:"API_AUTH_TRUSTED_GATEWAY_ROLES" default:"false" required:"true"`
+	// AnonymousRoles are given to the callers without any credentials, e.g. the internal services in the same cluster.
+	AnonymousRoles []string `envconfig:"API_AUTH_ANONYMOUS_ROLES" default:""`
+	// PolicyFile is the path to the JSON file with the roles allowed to call every method, the default policy is used
+	// when not set.
+	PolicyFile string `envconfig:"API_AUTH_POLICY_FILE" default:""`
+	Jwt        struct {
+		// KeysFile is the path to the JSON Web Key Set file containing the keys to verify the token signatures.
+		KeysFile     string `envconfig:"API_AUTH_JWT_KEYS_FILE" default:""`
+		Issuer       string `envconfig:"API_AUTH_JWT_ISSUER" default:""`
+		Audience     string `envconfig:"API_AUTH_JWT_AUDIENCE" default:""`
+		ClaimGroupId string `envconfig:"API_AUTH_JWT_CLAIM_GROUP_ID" default:"groupId" required:"true"`
+		ClaimUserId  string `envconfig:"API_AUTH_JWT_CLAIM_USER_ID" default:"sub" required:"true"`
+		ClaimRoles   string `envconfig:"API_AUTH_JWT_CLAIM_ROLES" default:"roles" required:"true"`
+	}
+}
+
+type CacheConfig struct {
+	// Size is the max count of the SearchByCondition result pages to cache, 0 disables the cache.
+	Size int `envconfig:"CACHE_SIZE" default:"10000" required:"true"`
+	// Ttl limits the time a page is cached, the changes made by other service instances are not seen until then.
+	Ttl time.Duration `envconfig:"CACHE_TTL" default:"1m" required:"true"`
+}
+
+type QuotaConfig struct {
+	// File is the path to the JSON file with the interest limits per account, not limited when not set.
+	File string `envconfig:"QUOTA_FILE" default:""`
+}
+
 type HttpConfig struct {
 	Port uint16 `envconfig:"API_HTTP_PORT" default:"8080" required:"true"`
 }
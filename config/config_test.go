@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestConfig(t *testing.T) {
@@ -15,5 +16,8 @@ func TestConfig(t *testing.T) {
 	assert.Equal(t, "mongodb://localhost:27017/?retryWrites=true&w=majority", cfg.Db.Uri)
 	assert.Equal(t, "interests", cfg.Db.Name)
 	assert.Equal(t, "interests", cfg.Db.Table.Name)
+	assert.Equal(t, 10000, cfg.Cache.Size)
+	assert.Equal(t, time.Minute, cfg.Cache.Ttl)
+	assert.Equal(t, "", cfg.Quota.File)
 	assert.Equal(t, int(slog.LevelDebug), cfg.Log.Level)
 }
@@ -4,11 +4,17 @@ import (
 	"context"
 	"fmt"
 	grpcApi "github.com/awakari/interests/api/grpc"
+	apiHttp "github.com/awakari/interests/api/http"
 	"github.com/awakari/interests/config"
 	"github.com/awakari/interests/storage"
+	"github.com/awakari/interests/storage/memory"
 	"github.com/awakari/interests/storage/mongo"
+	"github.com/awakari/interests/storage/postgres"
+	"github.com/awakari/interests/storage/sqlite"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 	"log/slog"
 	"net/http"
 	"os"
@@ -26,12 +32,46 @@ func main() {
 	}
 	log := slog.New(slog.NewTextHandler(os.Stdout, &opts))
 	//
-	stor, err := mongo.NewStorage(context.TODO(), cfg.Db)
-	if err == nil {
-		log.Info("connected the database")
-	} else {
+	var stor storage.Storage
+	switch cfg.Db.Type {
+	case config.DbTypeMemory:
+		stor = memory.NewStorage(cfg.Db)
+		log.Info("using the in-memory storage, the data will be lost on exit")
+	case config.DbTypeSqlite:
+		stor, err = sqlite.NewStorage(context.TODO(), cfg.Db)
+		if err == nil {
+			log.Info("opened the embedded database")
+		} else {
+			panic(err)
+		}
+	case config.DbTypePostgres:
+		stor, err = postgres.NewStorage(context.TODO(), cfg.Db)
+		if err == nil {
+			log.Info("connected the database")
+		} else {
+			panic(err)
+		}
+	case config.DbTypeMongo:
+		stor, err = mongo.NewStorage(context.TODO(), cfg.Db)
+		if err == nil {
+			log.Info("connected the database")
+		} else {
+			panic(err)
+		}
+	default:
+		panic(fmt.Sprintf("unsupported db type %q", cfg.Db.Type))
+	}
+	quotas, err := storage.LoadQuotas(cfg.Quota.File)
+	if err != nil {
 		panic(err)
 	}
+	if cfg.Cache.Size > 0 {
+		stor = storage.NewCacheMiddleware(stor, cfg.Cache.Size, cfg.Cache.Ttl)
+		prometheus.MustRegister(storage.CacheMetrics()...)
+	}
+	if !quotas.Empty() {
+		stor = storage.NewQuotaMiddleware(stor, quotas)
+	}
 	stor = storage.NewLoggingMiddleware(stor, log)
 	//
 	prometheus.MustRegister(
@@ -57,12 +97,37 @@ func main() {
 		),
 	)
 	//
+	authn, err := grpcApi.NewAuthenticator(cfg.Api.Auth)
+	if err != nil {
+		panic(err)
+	}
+	if cfg.Api.Auth.TrustedGateway {
+		log.Warn("trusting the caller identity headers, the service should be reachable through the gateway only")
+		if cfg.Api.Auth.TrustedGatewayRoles {
+			log.Warn("trusting the caller roles header, the gateway should strip it of every incoming request")
+		}
+	}
+	authz, err := grpcApi.LoadPolicy(cfg.Api.Auth.PolicyFile)
+	if err != nil {
+		panic(err)
+	}
+	//
 	log.Info(fmt.Sprintf("starting to listen the API @ port #%d...", cfg.Api.Port))
 	go func() {
-		if err = grpcApi.Serve(stor, cfg.Api.Port); err != nil {
+		if err = grpcApi.Serve(stor, quotas, cfg.Api.Port, authn, authz); err != nil {
 			panic(err)
 		}
 	}()
+	// the REST gateway calls the gRPC API above, so the same authentication and authorization apply
+	conn, err := grpc.NewClient(
+		fmt.Sprintf("localhost:%d", cfg.Api.Port),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+	http.Handle(apiHttp.PathPrefix, apiHttp.NewHandler(grpcApi.NewServiceClient(conn)))
 	//
 	http.Handle("/metrics", promhttp.Handler())
 	http.ListenAndServe(fmt.Sprintf(":%d", cfg.Api.Http.Port), nil)
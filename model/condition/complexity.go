@@ -0,0 +1,28 @@
+package condition
+
+// Depth returns the nesting level of the specified condition tree, a single leaf condition is of level 1.
+func Depth(c Condition) (depth uint32) {
+	switch ct := c.(type) {
+	case GroupCondition:
+		for _, child := range ct.GetGroup() {
+			depth = max(depth, Depth(child))
+		}
+		depth++
+	case LeafCondition:
+		depth = 1
+	}
+	return
+}
+
+// LeafCount returns the count of all leaf conditions found in the specified condition tree.
+func LeafCount(c Condition) (count uint32) {
+	switch ct := c.(type) {
+	case GroupCondition:
+		for _, child := range ct.GetGroup() {
+			count += LeafCount(child)
+		}
+	case LeafCondition:
+		count = 1
+	}
+	return
+}
@@ -0,0 +1,44 @@
+package condition
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDepth_LeafCount(t *testing.T) {
+	leaf := NewTextCondition(NewKeyCondition(NewCondition(false), "cond0", "key0"), "term0", false)
+	cases := map[string]struct {
+		cond   Condition
+		depth  uint32
+		leaves uint32
+	}{
+		"nil": {},
+		"leaf": {
+			cond:   leaf,
+			depth:  1,
+			leaves: 1,
+		},
+		"empty group": {
+			cond:  NewGroupCondition(NewCondition(false), GroupLogicAnd, nil),
+			depth: 1,
+		},
+		"nested groups": {
+			cond: NewGroupCondition(NewCondition(false), GroupLogicOr, []Condition{
+				leaf,
+				NewGroupCondition(NewCondition(true), GroupLogicAnd, []Condition{
+					leaf,
+					NewSemanticCondition(NewCondition(false), "cond1", "query", 0.5),
+				}),
+				NewNumberCondition(NewKeyCondition(NewCondition(false), "cond2", "key2"), NumOpGt, 1),
+			}),
+			depth:  3,
+			leaves: 4,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, c.depth, Depth(c.cond))
+			assert.Equal(t, c.leaves, LeafCount(c.cond))
+		})
+	}
+}
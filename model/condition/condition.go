@@ -22,3 +22,28 @@ func (c condition) IsNot() bool {
 func (c condition) Equal(another Condition) bool {
 	return c.Not == another.IsNot()
 }
+
+// WithNot returns the copy of the condition with the specified negation flag.
+func WithNot(c Condition, not bool) (dst Condition) {
+	switch ct := c.(type) {
+	case GroupCondition:
+		dst = NewGroupCondition(NewCondition(not), ct.GetLogic(), ct.GetGroup())
+	case TextCondition:
+		dst = NewTextCondition(NewKeyCondition(NewCondition(not), ct.GetId(), ct.GetKey()), ct.GetTerm(), ct.IsExact())
+	case NumberCondition:
+		dst = NewNumberCondition(NewKeyCondition(NewCondition(not), ct.GetId(), ct.GetKey()), ct.GetOperation(), ct.GetValue())
+	case RangeCondition:
+		dst = NewRangeCondition(NewKeyCondition(NewCondition(not), ct.GetId(), ct.GetKey()), ct.GetLower(), ct.GetUpper())
+	case SetCondition:
+		dst = NewSetCondition(NewKeyCondition(NewCondition(not), ct.GetId(), ct.GetKey()), ct.GetTexts(), ct.GetNumbers())
+	case ExistsCondition:
+		dst = NewExistsCondition(NewKeyCondition(NewCondition(not), ct.GetId(), ct.GetKey()))
+	case RegexCondition:
+		dst = NewRegexCondition(NewKeyCondition(NewCondition(not), ct.GetId(), ct.GetKey()), ct.GetPattern())
+	case SemanticCondition:
+		dst = NewSemanticCondition(NewCondition(not), ct.GetId(), ct.Query(), ct.SimilarityMin())
+	default:
+		dst = c
+	}
+	return
+}
@@ -0,0 +1,43 @@
+package condition
+
+// ExistsCondition is the key condition matching the events having the key attribute regardless its value. The negated
+// one matches the events without the key attribute.
+type ExistsCondition interface {
+	KeyCondition
+
+	// exists distinguishes the exists condition from the other key conditions having no criteria besides the key.
+	exists()
+}
+
+type existsCondition struct {
+	KeyCondition KeyCondition
+}
+
+func NewExistsCondition(kc KeyCondition) ExistsCondition {
+	return existsCondition{
+		KeyCondition: kc,
+	}
+}
+
+func (ec existsCondition) IsNot() bool {
+	return ec.KeyCondition.IsNot()
+}
+
+func (ec existsCondition) Equal(another Condition) (equal bool) {
+	equal = ec.KeyCondition.Equal(another)
+	if equal {
+		_, equal = another.(ExistsCondition)
+	}
+	return
+}
+
+func (ec existsCondition) GetId() string {
+	return ec.KeyCondition.GetId()
+}
+
+func (ec existsCondition) GetKey() string {
+	return ec.KeyCondition.GetKey()
+}
+
+func (ec existsCondition) exists() {
+}
@@ -35,10 +35,12 @@ func (gc groupCondition) Equal(another Condition) (equal bool) {
 	if equal {
 		var anotherGc GroupCondition
 		anotherGc, equal = another.(GroupCondition)
-		condEqFunc := func(c1, c2 Condition) bool {
-			return c1.Equal(c2)
+		if equal {
+			condEqFunc := func(c1, c2 Condition) bool {
+				return c1.Equal(c2)
+			}
+			equal = gc.Logic == anotherGc.GetLogic() && slices.EqualFunc(gc.Group, anotherGc.GetGroup(), condEqFunc)
 		}
-		equal = gc.Logic == anotherGc.GetLogic() && slices.EqualFunc(gc.Group, anotherGc.GetGroup(), condEqFunc)
 	}
 	return
 }
@@ -4,3 +4,16 @@ type LeafCondition interface {
 	Condition
 	GetId() string
 }
+
+// LeafIds returns the flat list of all leaf condition ids found in the specified condition tree.
+func LeafIds(c Condition) (ids []string) {
+	switch ct := c.(type) {
+	case GroupCondition:
+		for _, child := range ct.GetGroup() {
+			ids = append(ids, LeafIds(child)...)
+		}
+	case LeafCondition:
+		ids = append(ids, ct.GetId())
+	}
+	return
+}
@@ -0,0 +1,77 @@
+package condition
+
+import "slices"
+
+// Normalize returns the equivalent condition tree without the redundancy, the order of the conditions is preserved:
+//   - the single child group is replaced with the child, the negation flags are combined
+//   - the child group of the same And/Or logic is merged into the parent
+//   - the negated child And/Or group is merged into the parent of the opposite logic by De Morgan's laws
+//   - the duplicate children of the And/Or group are removed, see Equal, the first one is kept
+//
+// The Xor group children are neither merged nor deduplicated: the Xor matches when exactly one child matches.
+func Normalize(c Condition) (norm Condition) {
+	switch ct := c.(type) {
+	case GroupCondition:
+		logic := ct.GetLogic()
+		var group []Condition
+		for _, child := range ct.GetGroup() {
+			group = appendMerged(group, logic, Normalize(child))
+		}
+		if mergeable(logic) {
+			group = dedup(group)
+		}
+		switch len(group) {
+		case 1:
+			norm = WithNot(group[0], group[0].IsNot() != ct.IsNot())
+		default:
+			norm = NewGroupCondition(NewCondition(ct.IsNot()), logic, group)
+		}
+	default:
+		norm = c
+	}
+	return
+}
+
+// appendMerged appends the child condition to the group of the specified logic, merges the child group when possible.
+func appendMerged(group []Condition, logic GroupLogic, child Condition) []Condition {
+	childGc, isGroup := child.(GroupCondition)
+	switch {
+	case isGroup && mergeable(logic) && childGc.GetLogic() == logic && !childGc.IsNot():
+		for _, grandChild := range childGc.GetGroup() {
+			group = appendMerged(group, logic, grandChild)
+		}
+	case isGroup && mergeable(logic) && childGc.GetLogic() == opposite(logic) && childGc.IsNot():
+		for _, grandChild := range childGc.GetGroup() {
+			group = appendMerged(group, logic, WithNot(grandChild, !grandChild.IsNot()))
+		}
+	default:
+		group = append(group, child)
+	}
+	return group
+}
+
+func mergeable(logic GroupLogic) bool {
+	return logic == GroupLogicAnd || logic == GroupLogicOr
+}
+
+func opposite(logic GroupLogic) (o GroupLogic) {
+	switch logic {
+	case GroupLogicAnd:
+		o = GroupLogicOr
+	case GroupLogicOr:
+		o = GroupLogicAnd
+	default:
+		o = logic
+	}
+	return
+}
+
+// dedup removes the conditions equal to any previous one.
+func dedup(group []Condition) (unique []Condition) {
+	for _, c := range group {
+		if !slices.ContainsFunc(unique, c.Equal) {
+			unique = append(unique, c)
+		}
+	}
+	return
+}
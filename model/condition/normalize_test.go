@@ -0,0 +1,149 @@
+package condition
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	text := func(not bool, id, term string) Condition {
+		return NewTextCondition(NewKeyCondition(NewCondition(not), id, "key0"), term, false)
+	}
+	group := func(not bool, logic GroupLogic, children ...Condition) Condition {
+		return NewGroupCondition(NewCondition(not), logic, children)
+	}
+	cases := map[string]struct {
+		cond Condition
+		norm Condition
+		ids  []string
+	}{
+		"leaf": {
+			cond: text(true, "cond0", "a"),
+			norm: text(true, "cond0", "a"),
+			ids:  []string{"cond0"},
+		},
+		"single child group": {
+			cond: group(true, GroupLogicAnd, text(false, "cond0", "a")),
+			norm: text(true, "cond0", "a"),
+			ids:  []string{"cond0"},
+		},
+		"double negation": {
+			cond: group(true, GroupLogicXor, group(false, GroupLogicOr, text(true, "cond0", "a"))),
+			norm: text(false, "cond0", "a"),
+			ids:  []string{"cond0"},
+		},
+		"same logic flattened": {
+			cond: group(
+				false, GroupLogicAnd,
+				text(false, "cond0", "a"),
+				group(false, GroupLogicAnd, text(false, "cond1", "b"), group(false, GroupLogicAnd, text(false, "cond2", "c"))),
+			),
+			norm: group(false, GroupLogicAnd, text(false, "cond0", "a"), text(false, "cond1", "b"), text(false, "cond2", "c")),
+			ids:  []string{"cond0", "cond1", "cond2"},
+		},
+		"negated same logic kept": {
+			cond: group(
+				false, GroupLogicOr,
+				text(false, "cond0", "a"),
+				group(true, GroupLogicOr, text(false, "cond1", "b"), text(false, "cond2", "c")),
+			),
+			norm: group(
+				false, GroupLogicOr,
+				text(false, "cond0", "a"),
+				group(true, GroupLogicOr, text(false, "cond1", "b"), text(false, "cond2", "c")),
+			),
+			ids: []string{"cond0", "cond1", "cond2"},
+		},
+		"de morgan": {
+			cond: group(
+				false, GroupLogicOr,
+				text(false, "cond0", "a"),
+				group(
+					true, GroupLogicAnd,
+					text(false, "cond1", "b"),
+					group(true, GroupLogicOr, text(false, "cond2", "c"), text(true, "cond3", "d")),
+				),
+			),
+			norm: group(
+				false, GroupLogicOr,
+				text(false, "cond0", "a"),
+				text(true, "cond1", "b"),
+				text(false, "cond2", "c"),
+				text(true, "cond3", "d"),
+			),
+			ids: []string{"cond0", "cond1", "cond2", "cond3"},
+		},
+		"duplicates removed": {
+			cond: group(
+				false, GroupLogicOr,
+				text(false, "cond0", "a"),
+				text(false, "cond1", "b"),
+				text(false, "cond2", "a"),
+				text(true, "cond3", "a"),
+				NewTextCondition(NewKeyCondition(NewCondition(false), "cond4", "key0"), "a", true),
+			),
+			norm: group(
+				false, GroupLogicOr,
+				text(false, "cond0", "a"),
+				text(false, "cond1", "b"),
+				text(true, "cond3", "a"),
+				NewTextCondition(NewKeyCondition(NewCondition(false), "cond4", "key0"), "a", true),
+			),
+			ids: []string{"cond0", "cond1", "cond3", "cond4"},
+		},
+		"duplicate groups collapse": {
+			cond: group(
+				true, GroupLogicAnd,
+				group(false, GroupLogicOr, text(false, "cond0", "a"), text(false, "cond1", "b")),
+				group(false, GroupLogicOr, text(false, "cond2", "a"), text(false, "cond3", "b")),
+			),
+			norm: group(true, GroupLogicOr, text(false, "cond0", "a"), text(false, "cond1", "b")),
+			ids:  []string{"cond0", "cond1"},
+		},
+		"xor kept": {
+			cond: group(
+				false, GroupLogicXor,
+				text(false, "cond0", "a"),
+				group(false, GroupLogicXor, text(false, "cond1", "b"), text(false, "cond2", "c")),
+				text(false, "cond3", "a"),
+			),
+			norm: group(
+				false, GroupLogicXor,
+				text(false, "cond0", "a"),
+				group(false, GroupLogicXor, text(false, "cond1", "b"), text(false, "cond2", "c")),
+				text(false, "cond3", "a"),
+			),
+			ids: []string{"cond0", "cond1", "cond2", "cond3"},
+		},
+		"mixed types": {
+			cond: group(
+				false, GroupLogicAnd,
+				group(true, GroupLogicOr,
+					NewNumberCondition(NewKeyCondition(NewCondition(false), "cond0", "key1"), NumOpGt, 1),
+					NewSemanticCondition(NewCondition(true), "cond1", "query", 0.5),
+				),
+				NewNumberCondition(NewKeyCondition(NewCondition(true), "cond2", "key1"), NumOpGt, 1),
+			),
+			norm: group(
+				false, GroupLogicAnd,
+				NewNumberCondition(NewKeyCondition(NewCondition(true), "cond0", "key1"), NumOpGt, 1),
+				NewSemanticCondition(NewCondition(false), "cond1", "query", 0.5),
+			),
+			ids: []string{"cond0", "cond1"},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			norm := Normalize(c.cond)
+			assert.True(t, c.norm.Equal(norm), "expected %+v, got %+v", c.norm, norm)
+			assert.Equal(t, c.ids, LeafIds(norm))
+		})
+	}
+}
+
+func TestGroupCondition_Equal_Leaf(t *testing.T) {
+	gc := NewGroupCondition(NewCondition(false), GroupLogicAnd, nil)
+	tc := NewTextCondition(NewKeyCondition(NewCondition(false), "cond0", "key0"), "a", false)
+	assert.False(t, gc.Equal(tc))
+	assert.False(t, tc.Equal(gc))
+}
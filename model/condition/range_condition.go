@@ -0,0 +1,60 @@
+package condition
+
+// Bound is the range condition boundary.
+type Bound struct {
+	Value     float64
+	Inclusive bool
+}
+
+// RangeCondition is the key condition matching the number values between the lower and the upper bounds.
+type RangeCondition interface {
+	KeyCondition
+	GetLower() Bound
+	GetUpper() Bound
+}
+
+type rangeCondition struct {
+	KeyCondition KeyCondition
+	Lower        Bound
+	Upper        Bound
+}
+
+func NewRangeCondition(kc KeyCondition, lower, upper Bound) RangeCondition {
+	return rangeCondition{
+		KeyCondition: kc,
+		Lower:        lower,
+		Upper:        upper,
+	}
+}
+
+func (rc rangeCondition) IsNot() bool {
+	return rc.KeyCondition.IsNot()
+}
+
+func (rc rangeCondition) Equal(another Condition) (equal bool) {
+	equal = rc.KeyCondition.Equal(another)
+	if equal {
+		var anotherRc RangeCondition
+		anotherRc, equal = another.(RangeCondition)
+		if equal {
+			equal = rc.Lower == anotherRc.GetLower() && rc.Upper == anotherRc.GetUpper()
+		}
+	}
+	return
+}
+
+func (rc rangeCondition) GetId() string {
+	return rc.KeyCondition.GetId()
+}
+
+func (rc rangeCondition) GetKey() string {
+	return rc.KeyCondition.GetKey()
+}
+
+func (rc rangeCondition) GetLower() Bound {
+	return rc.Lower
+}
+
+func (rc rangeCondition) GetUpper() Bound {
+	return rc.Upper
+}
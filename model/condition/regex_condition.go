@@ -0,0 +1,48 @@
+package condition
+
+// RegexCondition is the key condition matching the values against the regular expression pattern, see the regexp
+// package for the syntax.
+type RegexCondition interface {
+	KeyCondition
+	GetPattern() string
+}
+
+type regexCondition struct {
+	KeyCondition KeyCondition
+	Pattern      string
+}
+
+func NewRegexCondition(kc KeyCondition, pattern string) RegexCondition {
+	return regexCondition{
+		KeyCondition: kc,
+		Pattern:      pattern,
+	}
+}
+
+func (rc regexCondition) IsNot() bool {
+	return rc.KeyCondition.IsNot()
+}
+
+func (rc regexCondition) Equal(another Condition) (equal bool) {
+	equal = rc.KeyCondition.Equal(another)
+	if equal {
+		var anotherRc RegexCondition
+		anotherRc, equal = another.(RegexCondition)
+		if equal {
+			equal = rc.Pattern == anotherRc.GetPattern()
+		}
+	}
+	return
+}
+
+func (rc regexCondition) GetId() string {
+	return rc.KeyCondition.GetId()
+}
+
+func (rc regexCondition) GetKey() string {
+	return rc.KeyCondition.GetKey()
+}
+
+func (rc regexCondition) GetPattern() string {
+	return rc.Pattern
+}
@@ -0,0 +1,56 @@
+package condition
+
+import "slices"
+
+// SetCondition is the key condition matching the values equal to any text or to any number of the set.
+type SetCondition interface {
+	KeyCondition
+	GetTexts() []string
+	GetNumbers() []float64
+}
+
+type setCondition struct {
+	KeyCondition KeyCondition
+	Texts        []string
+	Numbers      []float64
+}
+
+func NewSetCondition(kc KeyCondition, texts []string, numbers []float64) SetCondition {
+	return setCondition{
+		KeyCondition: kc,
+		Texts:        texts,
+		Numbers:      numbers,
+	}
+}
+
+func (sc setCondition) IsNot() bool {
+	return sc.KeyCondition.IsNot()
+}
+
+func (sc setCondition) Equal(another Condition) (equal bool) {
+	equal = sc.KeyCondition.Equal(another)
+	if equal {
+		var anotherSc SetCondition
+		anotherSc, equal = another.(SetCondition)
+		if equal {
+			equal = slices.Equal(sc.Texts, anotherSc.GetTexts()) && slices.Equal(sc.Numbers, anotherSc.GetNumbers())
+		}
+	}
+	return
+}
+
+func (sc setCondition) GetId() string {
+	return sc.KeyCondition.GetId()
+}
+
+func (sc setCondition) GetKey() string {
+	return sc.KeyCondition.GetKey()
+}
+
+func (sc setCondition) GetTexts() []string {
+	return sc.Texts
+}
+
+func (sc setCondition) GetNumbers() []float64 {
+	return sc.Numbers
+}
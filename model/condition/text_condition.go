@@ -30,7 +30,7 @@ func (tc textCondition) Equal(another Condition) (equal bool) {
 		var anotherTc TextCondition
 		anotherTc, equal = another.(TextCondition)
 		if equal {
-			equal = tc.Term == anotherTc.GetTerm()
+			equal = tc.Term == anotherTc.GetTerm() && tc.Exact == anotherTc.IsExact()
 		}
 	}
 	return
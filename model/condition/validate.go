@@ -0,0 +1,222 @@
+package condition
+
+import (
+	"fmt"
+	"math"
+	"regexp/syntax"
+	"slices"
+	"strings"
+)
+
+// DepthMax is the max nesting level of the valid condition tree, a single leaf condition is of level 1.
+const DepthMax = 16
+
+// SetValuesMax is the max count of the texts and numbers of the valid set condition.
+const SetValuesMax = 256
+
+// RegexPatternLenMax is the max length of the valid regex condition pattern.
+const RegexPatternLenMax = 256
+
+// RegexInstMax is the max size of the compiled regex condition pattern program, e.g. the nested counted repetitions
+// exceed it quickly.
+const RegexInstMax = 1000
+
+// Violation is the condition tree defect found by Validate.
+type Violation struct {
+
+	// Path is the dot separated path to the invalid field in the tree, e.g. "group[1].term".
+	Path string
+
+	Description string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Path, v.Description)
+}
+
+type validator struct {
+	// paths of the leaf conditions by the leaf id, to detect the duplicates
+	leafPaths  map[string]string
+	violations []Violation
+}
+
+// Validate returns all structural defects of the condition tree, none when the tree is valid. The empty text
+// condition key selects all values (see the matcher), while the blank one is a defect. The empty leaf ids are not
+// checked for the duplicates.
+func Validate(c Condition) (violations []Violation) {
+	v := validator{
+		leafPaths: make(map[string]string),
+	}
+	v.validate(c, "", 1)
+	return v.violations
+}
+
+func (v *validator) validate(c Condition, path string, depth uint32) {
+	if depth > DepthMax {
+		v.add(path, fmt.Sprintf("nesting level exceeds %d", DepthMax))
+		return
+	}
+	switch ct := c.(type) {
+	case GroupCondition:
+		if ct.GetLogic() < GroupLogicAnd || ct.GetLogic() > GroupLogicXor {
+			v.add(joinPath(path, "logic"), fmt.Sprintf("unsupported group logic %d", ct.GetLogic()))
+		}
+		if len(ct.GetGroup()) == 0 {
+			v.add(joinPath(path, "group"), "empty group")
+		}
+		for i, child := range ct.GetGroup() {
+			v.validate(child, joinPath(path, fmt.Sprintf("group[%d]", i)), depth+1)
+		}
+	case TextCondition:
+		v.validateLeaf(ct, path)
+		if ct.GetKey() != "" && strings.TrimSpace(ct.GetKey()) == "" {
+			v.add(joinPath(path, "key"), "blank key")
+		}
+		if strings.TrimSpace(ct.GetTerm()) == "" {
+			v.add(joinPath(path, "term"), "blank term")
+		}
+	case NumberCondition:
+		v.validateLeaf(ct, path)
+		if strings.TrimSpace(ct.GetKey()) == "" {
+			v.add(joinPath(path, "key"), "blank key")
+		}
+		if ct.GetOperation() <= NumOpUndefined || ct.GetOperation() > NumOpLt {
+			v.add(joinPath(path, "op"), "undefined operation")
+		}
+		if math.IsNaN(ct.GetValue()) || math.IsInf(ct.GetValue(), 0) {
+			v.add(joinPath(path, "val"), "not a finite number")
+		}
+	case RangeCondition:
+		v.validateLeaf(ct, path)
+		if strings.TrimSpace(ct.GetKey()) == "" {
+			v.add(joinPath(path, "key"), "blank key")
+		}
+		lower, upper := ct.GetLower(), ct.GetUpper()
+		lowerFinite := !math.IsNaN(lower.Value) && !math.IsInf(lower.Value, 0)
+		upperFinite := !math.IsNaN(upper.Value) && !math.IsInf(upper.Value, 0)
+		if !lowerFinite {
+			v.add(joinPath(path, "lower"), "not a finite number")
+		}
+		if !upperFinite {
+			v.add(joinPath(path, "upper"), "not a finite number")
+		}
+		switch {
+		case !lowerFinite || !upperFinite:
+		case lower.Value > upper.Value:
+			v.add(path, fmt.Sprintf("lower bound %v is greater than the upper bound %v", lower.Value, upper.Value))
+		case lower.Value == upper.Value && !(lower.Inclusive && upper.Inclusive):
+			v.add(path, fmt.Sprintf("empty range, the equal bounds %v should be both inclusive", lower.Value))
+		}
+	case SetCondition:
+		v.validateLeaf(ct, path)
+		if ct.GetKey() != "" && strings.TrimSpace(ct.GetKey()) == "" {
+			v.add(joinPath(path, "key"), "blank key")
+		}
+		v.validateSet(ct, path)
+	case ExistsCondition:
+		v.validateLeaf(ct, path)
+		if strings.TrimSpace(ct.GetKey()) == "" {
+			v.add(joinPath(path, "key"), "blank key")
+		}
+	case RegexCondition:
+		v.validateLeaf(ct, path)
+		if ct.GetKey() != "" && strings.TrimSpace(ct.GetKey()) == "" {
+			v.add(joinPath(path, "key"), "blank key")
+		}
+		if defect := regexDefect(ct.GetPattern()); defect != "" {
+			v.add(joinPath(path, "pattern"), defect)
+		}
+	case SemanticCondition:
+		v.validateLeaf(ct, path)
+		if strings.TrimSpace(ct.Query()) == "" {
+			v.add(joinPath(path, "query"), "blank query")
+		}
+		if !(ct.SimilarityMin() >= 0 && ct.SimilarityMin() <= 1) {
+			v.add(joinPath(path, "similarityMin"), fmt.Sprintf("%v is out of the [0, 1] range", ct.SimilarityMin()))
+		}
+	case nil:
+		v.add(path, "missing condition")
+	default:
+		v.add(path, fmt.Sprintf("unsupported condition type %T", c))
+	}
+}
+
+func (v *validator) validateLeaf(lc LeafCondition, path string) {
+	id := lc.GetId()
+	if id != "" {
+		first, found := v.leafPaths[id]
+		switch found {
+		case true:
+			v.add(joinPath(path, "id"), fmt.Sprintf("duplicate id %s, first used at %q", id, first))
+		default:
+			v.leafPaths[id] = path
+		}
+	}
+}
+
+func (v *validator) validateSet(sc SetCondition, path string) {
+	texts, numbers := sc.GetTexts(), sc.GetNumbers()
+	switch count := len(texts) + len(numbers); {
+	case count == 0:
+		v.add(path, "empty set")
+	case count > SetValuesMax:
+		// the values are not checked one by one, the duplicates check is quadratic
+		v.add(path, fmt.Sprintf("values count %d exceeds %d", count, SetValuesMax))
+		return
+	}
+	for i, t := range texts {
+		p := joinPath(path, fmt.Sprintf("texts[%d]", i))
+		switch {
+		case strings.TrimSpace(t) == "":
+			v.add(p, "blank value")
+		case slices.Contains(texts[:i], t):
+			v.add(p, fmt.Sprintf("duplicate value %q", t))
+		}
+	}
+	for i, n := range numbers {
+		p := joinPath(path, fmt.Sprintf("numbers[%d]", i))
+		switch {
+		case math.IsNaN(n) || math.IsInf(n, 0):
+			v.add(p, "not a finite number")
+		case slices.Contains(numbers[:i], n):
+			v.add(p, fmt.Sprintf("duplicate value %v", n))
+		}
+	}
+}
+
+// regexDefect returns the description of the pattern syntax or complexity defect, empty when the pattern is valid.
+func regexDefect(pattern string) (defect string) {
+	switch {
+	case strings.TrimSpace(pattern) == "":
+		defect = "blank pattern"
+	case len(pattern) > RegexPatternLenMax:
+		defect = fmt.Sprintf("pattern length %d exceeds %d", len(pattern), RegexPatternLenMax)
+	default:
+		re, err := syntax.Parse(pattern, syntax.Perl)
+		var prog *syntax.Prog
+		if err == nil {
+			prog, err = syntax.Compile(re.Simplify())
+		}
+		switch {
+		case err != nil:
+			defect = err.Error()
+		case len(prog.Inst) > RegexInstMax:
+			defect = fmt.Sprintf("too complex pattern of %d instructions, max %d", len(prog.Inst), RegexInstMax)
+		}
+	}
+	return
+}
+
+func (v *validator) add(path, description string) {
+	v.violations = append(v.violations, Violation{
+		Path:        path,
+		Description: description,
+	})
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
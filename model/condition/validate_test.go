@@ -0,0 +1,272 @@
+package condition
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	text := func(id, key, term string) Condition {
+		return NewTextCondition(NewKeyCondition(NewCondition(false), id, key), term, false)
+	}
+	group := func(logic GroupLogic, children ...Condition) Condition {
+		return NewGroupCondition(NewCondition(false), logic, children)
+	}
+	nested := text("cond0", "key0", "term0")
+	for i := 1; i <= DepthMax; i++ {
+		nested = group(GroupLogicAnd, nested)
+	}
+	cases := map[string]struct {
+		cond       Condition
+		violations []Violation
+	}{
+		"valid": {
+			cond: group(
+				GroupLogicOr,
+				text("cond0", "", "term0"),
+				NewNumberCondition(NewKeyCondition(NewCondition(true), "cond1", "key1"), NumOpGte, 42),
+				group(
+					GroupLogicXor,
+					NewSemanticCondition(NewCondition(false), "cond2", "lorem ipsum", 0.75),
+					text("", "key3", "term3"),
+					text("", "key4", "term4"),
+					NewRegexCondition(NewKeyCondition(NewCondition(true), "cond5", ""), `^go(lang)?\b`),
+					NewRangeCondition(NewKeyCondition(NewCondition(false), "cond6", "key6"), Bound{Value: -1}, Bound{Value: 1}),
+					NewSetCondition(NewKeyCondition(NewCondition(true), "cond7", ""), []string{"a", "b"}, []float64{1}),
+					NewExistsCondition(NewKeyCondition(NewCondition(true), "cond8", "key8")),
+				),
+			),
+		},
+		"missing": {
+			violations: []Violation{
+				{
+					Description: "missing condition",
+				},
+			},
+		},
+		"empty group": {
+			cond: group(GroupLogicAnd),
+			violations: []Violation{
+				{
+					Path:        "group",
+					Description: "empty group",
+				},
+			},
+		},
+		"unsupported logic": {
+			cond: group(GroupLogic(3), text("cond0", "key0", "term0")),
+			violations: []Violation{
+				{
+					Path:        "logic",
+					Description: "unsupported group logic 3",
+				},
+			},
+		},
+		"blank key and term": {
+			cond: group(GroupLogicAnd, text("cond0", "key0", "term0"), text("cond1", " ", "\t")),
+			violations: []Violation{
+				{
+					Path:        "group[1].key",
+					Description: "blank key",
+				},
+				{
+					Path:        "group[1].term",
+					Description: "blank term",
+				},
+			},
+		},
+		"invalid number": {
+			cond: NewNumberCondition(NewKeyCondition(NewCondition(false), "cond0", ""), NumOpUndefined, math.NaN()),
+			violations: []Violation{
+				{
+					Path:        "key",
+					Description: "blank key",
+				},
+				{
+					Path:        "op",
+					Description: "undefined operation",
+				},
+				{
+					Path:        "val",
+					Description: "not a finite number",
+				},
+			},
+		},
+		"invalid semantic": {
+			cond: group(
+				GroupLogicAnd,
+				text("cond0", "key0", "term0"),
+				group(GroupLogicOr, NewSemanticCondition(NewCondition(false), "cond1", "", 1.5)),
+			),
+			violations: []Violation{
+				{
+					Path:        "group[1].group[0].query",
+					Description: "blank query",
+				},
+				{
+					Path:        "group[1].group[0].similarityMin",
+					Description: "1.5 is out of the [0, 1] range",
+				},
+			},
+		},
+		"invalid regex": {
+			cond: group(
+				GroupLogicAnd,
+				NewRegexCondition(NewKeyCondition(NewCondition(false), "cond0", " "), " "),
+				NewRegexCondition(NewKeyCondition(NewCondition(false), "cond1", "key1"), "(golang"),
+				NewRegexCondition(NewKeyCondition(NewCondition(false), "cond2", "key2"), strings.Repeat("a", 257)),
+				NewRegexCondition(NewKeyCondition(NewCondition(false), "cond3", "key3"), "((a{100}){100}){100}"),
+				NewRegexCondition(NewKeyCondition(NewCondition(false), "cond4", "key4"), "a{600}b{600}"),
+			),
+			violations: []Violation{
+				{
+					Path:        "group[0].key",
+					Description: "blank key",
+				},
+				{
+					Path:        "group[0].pattern",
+					Description: "blank pattern",
+				},
+				{
+					Path:        "group[1].pattern",
+					Description: "error parsing regexp: missing closing ): `(golang`",
+				},
+				{
+					Path:        "group[2].pattern",
+					Description: "pattern length 257 exceeds 256",
+				},
+				{
+					Path:        "group[3].pattern",
+					Description: "error parsing regexp: invalid repeat count: `{100}`",
+				},
+				{
+					Path:        "group[4].pattern",
+					Description: "too complex pattern of 1202 instructions, max 1000",
+				},
+			},
+		},
+		"invalid range": {
+			cond: group(
+				GroupLogicOr,
+				NewRangeCondition(NewKeyCondition(NewCondition(false), "cond0", "key0"), Bound{Value: 1}, Bound{Value: 1}),
+				NewRangeCondition(NewKeyCondition(NewCondition(false), "cond1", ""), Bound{Value: 2}, Bound{Value: 1}),
+				NewRangeCondition(NewKeyCondition(NewCondition(false), "cond2", "key2"), Bound{Value: math.Inf(-1)}, Bound{Value: math.NaN()}),
+				NewRangeCondition(
+					NewKeyCondition(NewCondition(true), "cond3", "key3"),
+					Bound{Value: 1, Inclusive: true},
+					Bound{Value: 1, Inclusive: true},
+				),
+			),
+			violations: []Violation{
+				{
+					Path:        "group[0]",
+					Description: "empty range, the equal bounds 1 should be both inclusive",
+				},
+				{
+					Path:        "group[1].key",
+					Description: "blank key",
+				},
+				{
+					Path:        "group[1]",
+					Description: "lower bound 2 is greater than the upper bound 1",
+				},
+				{
+					Path:        "group[2].lower",
+					Description: "not a finite number",
+				},
+				{
+					Path:        "group[2].upper",
+					Description: "not a finite number",
+				},
+			},
+		},
+		"invalid set": {
+			cond: group(
+				GroupLogicOr,
+				NewSetCondition(NewKeyCondition(NewCondition(false), "cond0", " "), nil, nil),
+				NewSetCondition(
+					NewKeyCondition(NewCondition(true), "cond1", "key1"),
+					[]string{"a", " ", "a"},
+					[]float64{1, math.Inf(1), 1},
+				),
+				NewSetCondition(NewKeyCondition(NewCondition(false), "cond2", "key2"), nil, make([]float64, SetValuesMax+1)),
+			),
+			violations: []Violation{
+				{
+					Path:        "group[0].key",
+					Description: "blank key",
+				},
+				{
+					Path:        "group[0]",
+					Description: "empty set",
+				},
+				{
+					Path:        "group[1].texts[1]",
+					Description: "blank value",
+				},
+				{
+					Path:        "group[1].texts[2]",
+					Description: `duplicate value "a"`,
+				},
+				{
+					Path:        "group[1].numbers[1]",
+					Description: "not a finite number",
+				},
+				{
+					Path:        "group[1].numbers[2]",
+					Description: "duplicate value 1",
+				},
+				{
+					Path:        "group[2]",
+					Description: "values count 257 exceeds 256",
+				},
+			},
+		},
+		"exists without key": {
+			cond: NewExistsCondition(NewKeyCondition(NewCondition(false), "cond0", "")),
+			violations: []Violation{
+				{
+					Path:        "key",
+					Description: "blank key",
+				},
+			},
+		},
+		"duplicate ids": {
+			cond: group(
+				GroupLogicAnd,
+				text("cond0", "key0", "term0"),
+				group(GroupLogicOr, text("cond1", "key1", "term1"), text("cond0", "key2", "term2")),
+			),
+			violations: []Violation{
+				{
+					Path:        "group[1].group[1].id",
+					Description: `duplicate id cond0, first used at "group[0]"`,
+				},
+			},
+		},
+		"too deep": {
+			cond: nested,
+			violations: []Violation{
+				{
+					Path:        "group[0].group[0].group[0].group[0].group[0].group[0].group[0].group[0].group[0].group[0].group[0].group[0].group[0].group[0].group[0].group[0]",
+					Description: "nesting level exceeds 16",
+				},
+			},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, c.violations, Validate(c.cond))
+		})
+	}
+}
+
+func TestViolation_String(t *testing.T) {
+	v := Violation{
+		Path:        "group[1].term",
+		Description: "blank term",
+	}
+	assert.Equal(t, "group[1].term: blank term", v.String())
+}
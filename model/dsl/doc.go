@@ -0,0 +1,35 @@
+// Package dsl implements the textual condition query language, e.g.
+//
+//	(title:"golang" AND NOT lang:="ru") OR price<100 OR ~"electric cars"@0.8
+//
+// The grammar, from the lowest to the highest precedence:
+//
+//	query    = or
+//	or       = xor { "OR" xor }
+//	xor      = and { "XOR" and }
+//	and      = unary { "AND" unary }
+//	unary    = { "NOT" } primary
+//	primary  = "(" or ")" | logic "(" [ or { "," or } ] ")" | leaf [ "#" value ]
+//	logic    = "AND" | "OR" | "XOR"
+//	leaf     = text | regex | number | range | set | exists | semantic
+//	text     = [ value ] ( ":" | ":=" ) value | value
+//	regex    = [ value ] ":" "~" value
+//	number   = value ( ">" | ">=" | "=" | "<=" | "<" ) value
+//	range    = value ( "<" | "<=" ) value ( "<" | "<=" ) value
+//	set      = value "IN" "(" [ value { "," value } ] ")"
+//	exists   = value "EXISTS"
+//	semantic = "~" value [ "@" value ]
+//
+// The value is either a word or a double-quoted string using the Go escapes. A word is a sequence of any characters
+// except the whitespace and the ( ) , " : = < > ~ @ # ones, the keywords AND, OR, XOR, NOT, IN and EXISTS are not
+// words.
+//
+// The text condition with ":=" is the exact one, the regex condition pattern follows ":~", e.g. title:~"^go(lang)?".
+// The range condition is the key between the lower and the upper bounds, e.g. 10<=price<100. The set condition values
+// are the numbers when written as the finite number words, the texts otherwise, e.g. category IN (news, "1", 2) has the
+// texts "news" and "1" and the number 2. The exists condition matches the events having the key, e.g. geo EXISTS, and
+// the negated one matches the events without it. The leaf is followed by the optional condition id after "#".
+//
+// The explicit logic form is used for the groups of less than 2 conditions, the parenthesized expression is not a
+// group by itself, so "a AND (b AND c)" is the group nested into another.
+package dsl
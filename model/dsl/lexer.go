@@ -0,0 +1,172 @@
+package dsl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenWord
+	tokenString
+	tokenKeyword
+	tokenLeftParen
+	tokenRightParen
+	tokenComma
+	tokenColon
+	tokenColonEq
+	tokenOp
+	tokenTilde
+	tokenAt
+	tokenHash
+)
+
+type token struct {
+	kind tokenKind
+	// text is the unquoted value of the string token, the source text otherwise
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEnd {
+		return "end of query"
+	}
+	if t.kind == tokenString {
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+const (
+	keywordAnd    = "AND"
+	keywordOr     = "OR"
+	keywordXor    = "XOR"
+	keywordNot    = "NOT"
+	keywordIn     = "IN"
+	keywordExists = "EXISTS"
+)
+
+const special = `(),":=<>~@#`
+
+func isKeyword(s string) bool {
+	switch s {
+	case keywordAnd, keywordOr, keywordXor, keywordNot, keywordIn, keywordExists:
+		return true
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return !unicode.IsSpace(r) && !strings.ContainsRune(special, r)
+}
+
+// tokenize splits the whole query into the tokens, the last one is always tokenEnd.
+func tokenize(src string) (tokens []token, err error) {
+	pos := 0
+	for err == nil {
+		for pos < len(src) {
+			r, size := utf8.DecodeRuneInString(src[pos:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			pos += size
+		}
+		if pos == len(src) {
+			tokens = append(tokens, token{kind: tokenEnd, pos: pos})
+			break
+		}
+		var t token
+		t, err = nextToken(src, pos)
+		if err == nil {
+			tokens = append(tokens, t)
+			pos = t.pos + tokenLen(src, t)
+		}
+	}
+	return
+}
+
+// nextToken reads the token starting at the specified non-whitespace position.
+func nextToken(src string, pos int) (t token, err error) {
+	t.pos = pos
+	switch c := src[pos]; c {
+	case '(':
+		t.kind, t.text = tokenLeftParen, "("
+	case ')':
+		t.kind, t.text = tokenRightParen, ")"
+	case ',':
+		t.kind, t.text = tokenComma, ","
+	case '~':
+		t.kind, t.text = tokenTilde, "~"
+	case '@':
+		t.kind, t.text = tokenAt, "@"
+	case '#':
+		t.kind, t.text = tokenHash, "#"
+	case ':':
+		t.kind, t.text = tokenColon, ":"
+		if strings.HasPrefix(src[pos:], ":=") {
+			t.kind, t.text = tokenColonEq, ":="
+		}
+	case '=':
+		t.kind, t.text = tokenOp, "="
+	case '<', '>':
+		t.kind, t.text = tokenOp, string(c)
+		if strings.HasPrefix(src[pos+1:], "=") {
+			t.text += "="
+		}
+	case '"':
+		t.kind = tokenString
+		end := quotedEnd(src, pos)
+		if end < 0 {
+			err = fmt.Errorf("%w at %d: unterminated string", ErrSyntax, pos)
+		} else {
+			t.text, err = strconv.Unquote(src[pos:end])
+			if err != nil {
+				err = fmt.Errorf("%w at %d: invalid string %s", ErrSyntax, pos, src[pos:end])
+			}
+		}
+	default:
+		end := pos
+		for end < len(src) {
+			r, size := utf8.DecodeRuneInString(src[end:])
+			if !isWordRune(r) {
+				break
+			}
+			end += size
+		}
+		t.kind, t.text = tokenWord, src[pos:end]
+		if isKeyword(t.text) {
+			t.kind = tokenKeyword
+		}
+	}
+	return
+}
+
+func tokenLen(src string, t token) (l int) {
+	switch t.kind {
+	case tokenString:
+		l = quotedEnd(src, t.pos) - t.pos
+	default:
+		l = len(t.text)
+	}
+	return
+}
+
+// quotedEnd returns the position after the closing quote of the string starting at the specified position, -1 if the
+// string is not terminated.
+func quotedEnd(src string, start int) int {
+	for i := start + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
@@ -0,0 +1,402 @@
+package dsl
+
+import (
+	"errors"
+	"fmt"
+	"github.com/awakari/interests/model/condition"
+	"math"
+	"strconv"
+)
+
+// ErrSyntax indicates the query is not valid, the error message contains the position of the first invalid token.
+var ErrSyntax = errors.New("query syntax error")
+
+// nestingMax limits the parentheses nesting level, so the parser stack is bounded.
+const nestingMax = 4 * condition.DepthMax
+
+var numOps = map[string]condition.NumOp{
+	">":  condition.NumOpGt,
+	">=": condition.NumOpGte,
+	"=":  condition.NumOpEq,
+	"<=": condition.NumOpLte,
+	"<":  condition.NumOpLt,
+}
+
+var logics = map[string]condition.GroupLogic{
+	keywordAnd: condition.GroupLogicAnd,
+	keywordOr:  condition.GroupLogicOr,
+	keywordXor: condition.GroupLogicXor,
+}
+
+type parser struct {
+	tokens  []token
+	next    int
+	nesting int
+}
+
+// Parse returns the condition tree of the query. The result is not validated, see condition.Validate.
+func Parse(query string) (c condition.Condition, err error) {
+	var p parser
+	p.tokens, err = tokenize(query)
+	if err == nil {
+		c, err = p.parseGroup(keywordOr)
+	}
+	if err == nil {
+		if t := p.peek(); t.kind != tokenEnd {
+			err = unexpected(t, "end of query")
+		}
+	}
+	return
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) take() (t token) {
+	t = p.tokens[p.next]
+	if t.kind != tokenEnd {
+		p.next++
+	}
+	return
+}
+
+func (p *parser) expect(kind tokenKind, expected string) (t token, err error) {
+	t = p.take()
+	if t.kind != kind {
+		err = unexpected(t, expected)
+	}
+	return
+}
+
+// parseGroup parses the operands joined with the specified logic keyword, each operand is parsed with the logic of
+// the next higher precedence. A single operand is returned as is.
+func (p *parser) parseGroup(keyword string) (c condition.Condition, err error) {
+	var group []condition.Condition
+	for err == nil {
+		var operand condition.Condition
+		switch keyword {
+		case keywordOr:
+			operand, err = p.parseGroup(keywordXor)
+		case keywordXor:
+			operand, err = p.parseGroup(keywordAnd)
+		default:
+			operand, err = p.parseUnary()
+		}
+		if err == nil {
+			group = append(group, operand)
+			if t := p.peek(); t.kind == tokenKeyword && t.text == keyword {
+				p.take()
+			} else {
+				break
+			}
+		}
+	}
+	if err == nil {
+		switch len(group) {
+		case 1:
+			c = group[0]
+		default:
+			c = condition.NewGroupCondition(condition.NewCondition(false), logics[keyword], group)
+		}
+	}
+	return
+}
+
+func (p *parser) parseUnary() (c condition.Condition, err error) {
+	var not bool
+	for t := p.peek(); t.kind == tokenKeyword && t.text == keywordNot; t = p.peek() {
+		p.take()
+		not = !not
+	}
+	c, err = p.parsePrimary()
+	if err == nil && not {
+		c = condition.WithNot(c, !c.IsNot())
+	}
+	return
+}
+
+func (p *parser) parsePrimary() (c condition.Condition, err error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokenLeftParen:
+		p.take()
+		err = p.enter(t)
+		if err == nil {
+			c, err = p.parseGroup(keywordOr)
+		}
+		if err == nil {
+			_, err = p.expect(tokenRightParen, `")"`)
+		}
+		p.nesting--
+	case t.kind == tokenKeyword && isLogic(t.text):
+		c, err = p.parseExplicitGroup()
+	default:
+		c, err = p.parseLeaf()
+	}
+	return
+}
+
+// parseExplicitGroup parses the logic "(" [ or { "," or } ] ")" form.
+func (p *parser) parseExplicitGroup() (c condition.Condition, err error) {
+	logic := logics[p.take().text]
+	var t token
+	t, err = p.expect(tokenLeftParen, `"("`)
+	if err == nil {
+		err = p.enter(t)
+	}
+	var group []condition.Condition
+	if err == nil && p.peek().kind != tokenRightParen {
+		for err == nil {
+			var child condition.Condition
+			child, err = p.parseGroup(keywordOr)
+			if err == nil {
+				group = append(group, child)
+				if p.peek().kind == tokenComma {
+					p.take()
+				} else {
+					break
+				}
+			}
+		}
+	}
+	if err == nil {
+		_, err = p.expect(tokenRightParen, `"," or ")"`)
+	}
+	p.nesting--
+	if err == nil {
+		c = condition.NewGroupCondition(condition.NewCondition(false), logic, group)
+	}
+	return
+}
+
+func (p *parser) enter(t token) (err error) {
+	p.nesting++
+	if p.nesting > nestingMax {
+		err = fmt.Errorf("%w at %d: nesting level exceeds %d", ErrSyntax, t.pos, nestingMax)
+	}
+	return
+}
+
+func (p *parser) parseLeaf() (c condition.Condition, err error) {
+	t := p.take()
+	switch t.kind {
+	case tokenTilde:
+		c, err = p.parseSemantic()
+	case tokenColon, tokenColonEq:
+		c, err = p.parseMatch(t, "")
+	case tokenWord, tokenString:
+		c, err = p.parseKeyCondition(t)
+	default:
+		err = unexpected(t, "condition")
+	}
+	if err == nil && p.peek().kind == tokenHash {
+		p.take()
+		var id token
+		id, err = p.expectValue()
+		if err == nil {
+			c = withId(c, id.text)
+		}
+	}
+	return
+}
+
+func (p *parser) parseSemantic() (c condition.Condition, err error) {
+	var query token
+	query, err = p.expectValue()
+	var similarityMin float64
+	if err == nil && p.peek().kind == tokenAt {
+		p.take()
+		var v token
+		v, err = p.expectValue()
+		if err == nil {
+			similarityMin, err = strconv.ParseFloat(v.text, 32)
+			if err != nil {
+				err = fmt.Errorf("%w at %d: invalid similarity %s", ErrSyntax, v.pos, v)
+			}
+		}
+	}
+	if err == nil {
+		c = condition.NewSemanticCondition(condition.NewCondition(false), "", query.text, float32(similarityMin))
+	}
+	return
+}
+
+// parseKeyCondition parses the rest of the text, regex, number, range, set or exists condition after the first value,
+// which is either the key, the term of the text condition without a key or the lower bound of the range.
+func (p *parser) parseKeyCondition(first token) (c condition.Condition, err error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenColon, tokenColonEq:
+		p.take()
+		c, err = p.parseMatch(t, first.text)
+	case tokenOp:
+		p.take()
+		var v token
+		v, err = p.expectValue()
+		switch {
+		case err != nil:
+		case p.peek().kind == tokenOp:
+			c, err = p.parseRange(first, t, v)
+		default:
+			var val float64
+			val, err = parseNumber(v)
+			if err == nil {
+				kc := condition.NewKeyCondition(condition.NewCondition(false), "", first.text)
+				c = condition.NewNumberCondition(kc, numOps[t.text], val)
+			}
+		}
+	case tokenKeyword:
+		switch t.text {
+		case keywordIn:
+			p.take()
+			c, err = p.parseSet(first)
+		case keywordExists:
+			p.take()
+			c = condition.NewExistsCondition(condition.NewKeyCondition(condition.NewCondition(false), "", first.text))
+		default:
+			c = newTextCondition("", "", first.text, false)
+		}
+	default:
+		c = newTextCondition("", "", first.text, false)
+	}
+	return
+}
+
+// parseSet parses the "(" [ value { "," value } ] ")" list of the set condition after the key and the "IN" keyword.
+// The quoted values are the texts, the words are the numbers when parsed as the finite ones, the texts otherwise.
+func (p *parser) parseSet(key token) (c condition.Condition, err error) {
+	var texts []string
+	var numbers []float64
+	_, err = p.expect(tokenLeftParen, `"("`)
+	if err == nil && p.peek().kind != tokenRightParen {
+		for err == nil {
+			var v token
+			v, err = p.expectValue()
+			if err == nil {
+				n, nErr := strconv.ParseFloat(v.text, 64)
+				switch {
+				case v.kind == tokenWord && nErr == nil && !math.IsInf(n, 0) && !math.IsNaN(n):
+					numbers = append(numbers, n)
+				default:
+					texts = append(texts, v.text)
+				}
+				if p.peek().kind == tokenComma {
+					p.take()
+				} else {
+					break
+				}
+			}
+		}
+	}
+	if err == nil {
+		_, err = p.expect(tokenRightParen, `"," or ")"`)
+	}
+	if err == nil {
+		c = condition.NewSetCondition(condition.NewKeyCondition(condition.NewCondition(false), "", key.text), texts, numbers)
+	}
+	return
+}
+
+// parseRange parses the rest of the range condition after the lower bound, the lower operator and the key.
+func (p *parser) parseRange(lower, lowerOp, key token) (c condition.Condition, err error) {
+	upperOp := p.take()
+	var lb, ub condition.Bound
+	for _, op := range []token{lowerOp, upperOp} {
+		if op.text != "<" && op.text != "<=" {
+			err = unexpected(op, `"<" or "<="`)
+			break
+		}
+	}
+	if err == nil {
+		lb.Value, err = parseNumber(lower)
+		lb.Inclusive = lowerOp.text == "<="
+	}
+	var upper token
+	if err == nil {
+		upper, err = p.expectValue()
+	}
+	if err == nil {
+		ub.Value, err = parseNumber(upper)
+		ub.Inclusive = upperOp.text == "<="
+	}
+	if err == nil {
+		c = condition.NewRangeCondition(condition.NewKeyCondition(condition.NewCondition(false), "", key.text), lb, ub)
+	}
+	return
+}
+
+// parseMatch parses the rest of the text or regex condition after the ":" or ":=" token.
+func (p *parser) parseMatch(t token, key string) (c condition.Condition, err error) {
+	regex := t.kind == tokenColon && p.peek().kind == tokenTilde
+	if regex {
+		p.take()
+	}
+	var v token
+	v, err = p.expectValue()
+	if err == nil {
+		switch regex {
+		case true:
+			c = condition.NewRegexCondition(condition.NewKeyCondition(condition.NewCondition(false), "", key), v.text)
+		default:
+			c = newTextCondition("", key, v.text, t.kind == tokenColonEq)
+		}
+	}
+	return
+}
+
+func (p *parser) expectValue() (t token, err error) {
+	t = p.take()
+	if t.kind != tokenWord && t.kind != tokenString {
+		err = unexpected(t, "word or string")
+	}
+	return
+}
+
+func parseNumber(t token) (val float64, err error) {
+	val, err = strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		err = fmt.Errorf("%w at %d: invalid number %s", ErrSyntax, t.pos, t)
+	}
+	return
+}
+
+func newTextCondition(id, key, term string, exact bool) condition.Condition {
+	return condition.NewTextCondition(condition.NewKeyCondition(condition.NewCondition(false), id, key), term, exact)
+}
+
+// withId returns the copy of the leaf condition with the specified id.
+func withId(c condition.Condition, id string) (dst condition.Condition) {
+	switch ct := c.(type) {
+	case condition.TextCondition:
+		dst = newTextCondition(id, ct.GetKey(), ct.GetTerm(), ct.IsExact())
+	case condition.NumberCondition:
+		kc := condition.NewKeyCondition(condition.NewCondition(false), id, ct.GetKey())
+		dst = condition.NewNumberCondition(kc, ct.GetOperation(), ct.GetValue())
+	case condition.SetCondition:
+		kc := condition.NewKeyCondition(condition.NewCondition(false), id, ct.GetKey())
+		dst = condition.NewSetCondition(kc, ct.GetTexts(), ct.GetNumbers())
+	case condition.ExistsCondition:
+		dst = condition.NewExistsCondition(condition.NewKeyCondition(condition.NewCondition(false), id, ct.GetKey()))
+	case condition.RangeCondition:
+		kc := condition.NewKeyCondition(condition.NewCondition(false), id, ct.GetKey())
+		dst = condition.NewRangeCondition(kc, ct.GetLower(), ct.GetUpper())
+	case condition.RegexCondition:
+		kc := condition.NewKeyCondition(condition.NewCondition(false), id, ct.GetKey())
+		dst = condition.NewRegexCondition(kc, ct.GetPattern())
+	case condition.SemanticCondition:
+		dst = condition.NewSemanticCondition(condition.NewCondition(false), id, ct.Query(), ct.SimilarityMin())
+	default:
+		dst = c
+	}
+	return
+}
+
+func isLogic(keyword string) (logic bool) {
+	_, logic = logics[keyword]
+	return
+}
+
+func unexpected(t token, expected string) error {
+	return fmt.Errorf("%w at %d: expected %s, got %s", ErrSyntax, t.pos, expected, t)
+}
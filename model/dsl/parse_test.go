@@ -0,0 +1,250 @@
+package dsl
+
+import (
+	"github.com/awakari/interests/model/condition"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func newText(not bool, id, key, term string, exact bool) condition.Condition {
+	return condition.NewTextCondition(condition.NewKeyCondition(condition.NewCondition(not), id, key), term, exact)
+}
+
+func newNumber(not bool, id, key string, op condition.NumOp, val float64) condition.Condition {
+	return condition.NewNumberCondition(condition.NewKeyCondition(condition.NewCondition(not), id, key), op, val)
+}
+
+func newGroup(not bool, logic condition.GroupLogic, group ...condition.Condition) condition.Condition {
+	return condition.NewGroupCondition(condition.NewCondition(not), logic, group)
+}
+
+func TestParse(t *testing.T) {
+	cases := map[string]struct {
+		query string
+		out   condition.Condition
+		err   error
+	}{
+		"example": {
+			query: `(title:"golang" AND NOT lang:=ru) OR price<100 OR ~"electric cars"@0.8`,
+			out: newGroup(false, condition.GroupLogicOr,
+				newGroup(false, condition.GroupLogicAnd,
+					newText(false, "", "title", "golang", false),
+					newText(true, "", "lang", "ru", true),
+				),
+				newNumber(false, "", "price", condition.NumOpLt, 100),
+				condition.NewSemanticCondition(condition.NewCondition(false), "", "electric cars", 0.8),
+			),
+		},
+		"precedence": {
+			query: `a OR b XOR c AND d`,
+			out: newGroup(false, condition.GroupLogicOr,
+				newText(false, "", "", "a", false),
+				newGroup(false, condition.GroupLogicXor,
+					newText(false, "", "", "b", false),
+					newGroup(false, condition.GroupLogicAnd,
+						newText(false, "", "", "c", false),
+						newText(false, "", "", "d", false),
+					),
+				),
+			),
+		},
+		"parentheses are not a group": {
+			query: `((a))`,
+			out:   newText(false, "", "", "a", false),
+		},
+		"nested group of the same logic": {
+			query: `a AND (b AND c)`,
+			out: newGroup(false, condition.GroupLogicAnd,
+				newText(false, "", "", "a", false),
+				newGroup(false, condition.GroupLogicAnd,
+					newText(false, "", "", "b", false),
+					newText(false, "", "", "c", false),
+				),
+			),
+		},
+		"explicit groups": {
+			query: `NOT OR(a) AND XOR() AND AND(b, c OR d)`,
+			out: newGroup(false, condition.GroupLogicAnd,
+				newGroup(true, condition.GroupLogicOr, newText(false, "", "", "a", false)),
+				newGroup(false, condition.GroupLogicXor),
+				newGroup(false, condition.GroupLogicAnd,
+					newText(false, "", "", "b", false),
+					newGroup(false, condition.GroupLogicOr,
+						newText(false, "", "", "c", false),
+						newText(false, "", "", "d", false),
+					),
+				),
+			),
+		},
+		"double negation": {
+			query: `NOT NOT NOT (a AND NOT NOT b)`,
+			out: newGroup(true, condition.GroupLogicAnd,
+				newText(false, "", "", "a", false),
+				newText(false, "", "", "b", false),
+			),
+		},
+		"keyword key": {
+			query: `AND:="f"`,
+			err:   ErrSyntax,
+		},
+		"text keys": {
+			query: `"a b" AND :="c\"d" AND "my key":e AND "AND":="f"`,
+			out: newGroup(false, condition.GroupLogicAnd,
+				newText(false, "", "", "a b", false),
+				newText(false, "", "", `c"d`, true),
+				newText(false, "", "my key", "e", false),
+				newText(false, "", "AND", "f", true),
+			),
+		},
+		"number ops": {
+			query: `a>1 AND b>=-2.5 AND c=3e3 AND d<=0 AND ""<1`,
+			out: newGroup(false, condition.GroupLogicAnd,
+				newNumber(false, "", "a", condition.NumOpGt, 1),
+				newNumber(false, "", "b", condition.NumOpGte, -2.5),
+				newNumber(false, "", "c", condition.NumOpEq, 3000),
+				newNumber(false, "", "d", condition.NumOpLte, 0),
+				newNumber(false, "", "", condition.NumOpLt, 1),
+			),
+		},
+		"ids": {
+			query: `k:v#cond0 OR n>1#"cond 1" OR ~"q"#cond2 OR NOT "t"#3f0e-1`,
+			out: newGroup(false, condition.GroupLogicOr,
+				newText(false, "cond0", "k", "v", false),
+				newNumber(false, "cond 1", "n", condition.NumOpGt, 1),
+				condition.NewSemanticCondition(condition.NewCondition(false), "cond2", "q", 0),
+				newText(true, "3f0e-1", "", "t", false),
+			),
+		},
+		"regex": {
+			query: `title:~"^go(lang)?\\b"#cond0 AND NOT :~x+`,
+			out: newGroup(false, condition.GroupLogicAnd,
+				condition.NewRegexCondition(condition.NewKeyCondition(condition.NewCondition(false), "cond0", "title"), `^go(lang)?\b`),
+				condition.NewRegexCondition(condition.NewKeyCondition(condition.NewCondition(true), "", ""), "x+"),
+			),
+		},
+		"range": {
+			query: `10<=price<100#cond0 OR NOT -1<"my key"<=1e3`,
+			out: newGroup(false, condition.GroupLogicOr,
+				condition.NewRangeCondition(
+					condition.NewKeyCondition(condition.NewCondition(false), "cond0", "price"),
+					condition.Bound{Value: 10, Inclusive: true},
+					condition.Bound{Value: 100},
+				),
+				condition.NewRangeCondition(
+					condition.NewKeyCondition(condition.NewCondition(true), "", "my key"),
+					condition.Bound{Value: -1},
+					condition.Bound{Value: 1000, Inclusive: true},
+				),
+			),
+		},
+		"set": {
+			query: `category IN (news, "1", 2, -1.5e3, NaN)#cond0 AND NOT "my key" IN () AND lang IN ("en")`,
+			out: newGroup(false, condition.GroupLogicAnd,
+				condition.NewSetCondition(
+					condition.NewKeyCondition(condition.NewCondition(false), "cond0", "category"),
+					[]string{"news", "1", "NaN"},
+					[]float64{2, -1500},
+				),
+				condition.NewSetCondition(condition.NewKeyCondition(condition.NewCondition(true), "", "my key"), nil, nil),
+				condition.NewSetCondition(condition.NewKeyCondition(condition.NewCondition(false), "", "lang"), []string{"en"}, nil),
+			),
+		},
+		"exists": {
+			query: `geo EXISTS#cond0 AND NOT subject EXISTS AND "EXISTS" EXISTS`,
+			out: newGroup(false, condition.GroupLogicAnd,
+				condition.NewExistsCondition(condition.NewKeyCondition(condition.NewCondition(false), "cond0", "geo")),
+				condition.NewExistsCondition(condition.NewKeyCondition(condition.NewCondition(true), "", "subject")),
+				condition.NewExistsCondition(condition.NewKeyCondition(condition.NewCondition(false), "", "EXISTS")),
+			),
+		},
+		"unicode": {
+			query: `заголовок:голанг`,
+			out:   newText(false, "", "заголовок", "голанг", false),
+		},
+		"empty": {
+			query: `  `,
+			err:   ErrSyntax,
+		},
+		"missing operand": {
+			query: `a AND`,
+			err:   ErrSyntax,
+		},
+		"unbalanced": {
+			query: `(a OR b`,
+			err:   ErrSyntax,
+		},
+		"trailing": {
+			query: `a b`,
+			err:   ErrSyntax,
+		},
+		"unterminated string": {
+			query: `k:"abc`,
+			err:   ErrSyntax,
+		},
+		"invalid number": {
+			query: `k>abc`,
+			err:   ErrSyntax,
+		},
+		"invalid similarity": {
+			query: `~q@high`,
+			err:   ErrSyntax,
+		},
+		"missing explicit group parenthesis": {
+			query: `OR a`,
+			err:   ErrSyntax,
+		},
+		"invalid range operator": {
+			query: `1<price>2`,
+			err:   ErrSyntax,
+		},
+		"invalid range bound": {
+			query: `1<price<max`,
+			err:   ErrSyntax,
+		},
+		"missing set parenthesis": {
+			query: `category IN news`,
+			err:   ErrSyntax,
+		},
+		"unterminated set": {
+			query: `category IN (news, sports`,
+			err:   ErrSyntax,
+		},
+		"set without key": {
+			query: `IN (news)`,
+			err:   ErrSyntax,
+		},
+		"exists without key": {
+			query: `EXISTS`,
+			err:   ErrSyntax,
+		},
+		"missing id": {
+			query: `a#`,
+			err:   ErrSyntax,
+		},
+		"too deep": {
+			query: strings.Repeat("(", nestingMax+1) + "a" + strings.Repeat(")", nestingMax+1),
+			err:   ErrSyntax,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			out, err := Parse(c.query)
+			assert.ErrorIs(t, err, c.err)
+			if c.err == nil {
+				q, _ := Print(out)
+				assert.True(t, c.out.Equal(out), q)
+				// Equal ignores the ids
+				expected, err := Print(c.out)
+				require.Nil(t, err)
+				assert.Equal(t, expected, q)
+			}
+		})
+	}
+}
+
+func TestParse_ErrorPosition(t *testing.T) {
+	_, err := Parse(`a AND (b OR )`)
+	assert.EqualError(t, err, `query syntax error at 12: expected condition, got ")"`)
+}
@@ -0,0 +1,198 @@
+package dsl
+
+import (
+	"errors"
+	"fmt"
+	"github.com/awakari/interests/model/condition"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrPrint indicates the condition tree has no query form, e.g. it contains an unknown group logic or number operation.
+var ErrPrint = errors.New("condition is not printable")
+
+var logicKeywords = map[condition.GroupLogic]string{
+	condition.GroupLogicAnd: keywordAnd,
+	condition.GroupLogicOr:  keywordOr,
+	condition.GroupLogicXor: keywordXor,
+}
+
+var numOpSymbols = map[condition.NumOp]string{
+	condition.NumOpGt:  ">",
+	condition.NumOpGte: ">=",
+	condition.NumOpEq:  "=",
+	condition.NumOpLte: "<=",
+	condition.NumOpLt:  "<",
+}
+
+// Print returns the query of the condition tree, Parse of it returns the equal tree with the same condition ids.
+// The nested groups are always parenthesized, the groups of less than 2 conditions use the explicit logic form.
+// Returns ErrPrint when the tree can not be expressed as a query.
+func Print(c condition.Condition) (q string, err error) {
+	var sb strings.Builder
+	err = printCondition(&sb, c, true)
+	if err == nil {
+		q = sb.String()
+	}
+	return
+}
+
+func printCondition(sb *strings.Builder, c condition.Condition, top bool) (err error) {
+	if c == nil {
+		return
+	}
+	if c.IsNot() {
+		sb.WriteString(keywordNot)
+		sb.WriteString(" ")
+	}
+	switch ct := c.(type) {
+	case condition.GroupCondition:
+		err = printGroup(sb, ct, top)
+	case condition.TextCondition:
+		if ct.GetKey() != "" {
+			printValue(sb, ct.GetKey())
+		}
+		switch {
+		case ct.IsExact():
+			sb.WriteString(":=")
+		case ct.GetKey() != "":
+			sb.WriteString(":")
+		}
+		sb.WriteString(strconv.Quote(ct.GetTerm()))
+		printId(sb, ct.GetId())
+	case condition.NumberCondition:
+		op, ok := numOpSymbols[ct.GetOperation()]
+		if !ok {
+			err = fmt.Errorf("%w: number operation %d", ErrPrint, ct.GetOperation())
+			break
+		}
+		printValue(sb, ct.GetKey())
+		sb.WriteString(op)
+		sb.WriteString(strconv.FormatFloat(ct.GetValue(), 'g', -1, 64))
+		printId(sb, ct.GetId())
+	case condition.RangeCondition:
+		lower, upper := ct.GetLower(), ct.GetUpper()
+		sb.WriteString(strconv.FormatFloat(lower.Value, 'g', -1, 64))
+		sb.WriteString(rangeOpSymbol(lower))
+		printValue(sb, ct.GetKey())
+		sb.WriteString(rangeOpSymbol(upper))
+		sb.WriteString(strconv.FormatFloat(upper.Value, 'g', -1, 64))
+		printId(sb, ct.GetId())
+	case condition.SetCondition:
+		printValue(sb, ct.GetKey())
+		sb.WriteString(" ")
+		sb.WriteString(keywordIn)
+		sb.WriteString(" (")
+		for i, t := range ct.GetTexts() {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(strconv.Quote(t))
+		}
+		for i, n := range ct.GetNumbers() {
+			if i > 0 || len(ct.GetTexts()) > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(strconv.FormatFloat(n, 'g', -1, 64))
+		}
+		sb.WriteString(")")
+		printId(sb, ct.GetId())
+	case condition.ExistsCondition:
+		printValue(sb, ct.GetKey())
+		sb.WriteString(" ")
+		sb.WriteString(keywordExists)
+		printId(sb, ct.GetId())
+	case condition.RegexCondition:
+		if ct.GetKey() != "" {
+			printValue(sb, ct.GetKey())
+		}
+		sb.WriteString(":~")
+		sb.WriteString(strconv.Quote(ct.GetPattern()))
+		printId(sb, ct.GetId())
+	case condition.SemanticCondition:
+		sb.WriteString("~")
+		sb.WriteString(strconv.Quote(ct.Query()))
+		if ct.SimilarityMin() != 0 {
+			sb.WriteString("@")
+			sb.WriteString(strconv.FormatFloat(float64(ct.SimilarityMin()), 'g', -1, 32))
+		}
+		printId(sb, ct.GetId())
+	default:
+		err = fmt.Errorf("%w: condition type %T", ErrPrint, c)
+	}
+	return
+}
+
+func printGroup(sb *strings.Builder, gc condition.GroupCondition, top bool) (err error) {
+	keyword, ok := logicKeywords[gc.GetLogic()]
+	if !ok {
+		err = fmt.Errorf("%w: group logic %d", ErrPrint, gc.GetLogic())
+		return
+	}
+	group := gc.GetGroup()
+	switch {
+	case len(group) < 2:
+		sb.WriteString(keyword)
+		sb.WriteString("(")
+		for i, child := range group {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			if err = printCondition(sb, child, true); err != nil {
+				return
+			}
+		}
+		sb.WriteString(")")
+	default:
+		parens := !top || gc.IsNot()
+		if parens {
+			sb.WriteString("(")
+		}
+		for i, child := range group {
+			if i > 0 {
+				sb.WriteString(" ")
+				sb.WriteString(keyword)
+				sb.WriteString(" ")
+			}
+			if err = printCondition(sb, child, false); err != nil {
+				return
+			}
+		}
+		if parens {
+			sb.WriteString(")")
+		}
+	}
+	return
+}
+
+func rangeOpSymbol(b condition.Bound) (op string) {
+	op = "<"
+	if b.Inclusive {
+		op = "<="
+	}
+	return
+}
+
+func printId(sb *strings.Builder, id string) {
+	if id != "" {
+		sb.WriteString("#")
+		printValue(sb, id)
+	}
+}
+
+// printValue writes the word as is when possible, quoted otherwise.
+func printValue(sb *strings.Builder, v string) {
+	word := v != "" && !isKeyword(v) && utf8.ValidString(v)
+	for _, r := range v {
+		if !word || !isWordRune(r) {
+			word = false
+			break
+		}
+	}
+	if word {
+		sb.WriteString(v)
+	} else {
+		sb.WriteString(strconv.Quote(v))
+	}
+}
@@ -0,0 +1,178 @@
+package dsl
+
+import (
+	"github.com/awakari/interests/model/condition"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+func TestPrint(t *testing.T) {
+	cases := map[string]struct {
+		in  condition.Condition
+		out string
+		err error
+	}{
+		"nil": {},
+		"text": {
+			in:  newText(false, "cond0", "title", "golang", false),
+			out: `title:"golang"#cond0`,
+		},
+		"text without key": {
+			in:  newText(true, "", "", `say "hi"`, false),
+			out: `NOT "say \"hi\""`,
+		},
+		"exact text without key": {
+			in:  newText(false, "", "", "golang", true),
+			out: `:="golang"`,
+		},
+		"quoted key and id": {
+			in:  newText(false, "cond 0", "OR", "golang", true),
+			out: `"OR":="golang"#"cond 0"`,
+		},
+		"number": {
+			in:  newNumber(false, "", "price", condition.NumOpGte, -1.5e-7),
+			out: `price>=-1.5e-07`,
+		},
+		"number without key": {
+			in:  newNumber(false, "", "", condition.NumOpEq, 1),
+			out: `""=1`,
+		},
+		"regex": {
+			in:  condition.NewRegexCondition(condition.NewKeyCondition(condition.NewCondition(true), "cond0", "title"), `^"go"\b`),
+			out: `NOT title:~"^\"go\"\\b"#cond0`,
+		},
+		"regex without key": {
+			in:  condition.NewRegexCondition(condition.NewKeyCondition(condition.NewCondition(false), "", ""), "x+"),
+			out: `:~"x+"`,
+		},
+		"range": {
+			in: condition.NewRangeCondition(
+				condition.NewKeyCondition(condition.NewCondition(true), "cond0", "price"),
+				condition.Bound{Value: -0.5},
+				condition.Bound{Value: 1e21, Inclusive: true},
+			),
+			out: `NOT -0.5<price<=1e+21#cond0`,
+		},
+		"set": {
+			in: condition.NewSetCondition(
+				condition.NewKeyCondition(condition.NewCondition(true), "cond0", "category"),
+				[]string{"news", "1"},
+				[]float64{2, -0.5},
+			),
+			out: `NOT category IN ("news", "1", 2, -0.5)#cond0`,
+		},
+		"set numbers without key": {
+			in:  condition.NewSetCondition(condition.NewKeyCondition(condition.NewCondition(false), "", ""), nil, []float64{1}),
+			out: `"" IN (1)`,
+		},
+		"exists": {
+			in:  condition.NewExistsCondition(condition.NewKeyCondition(condition.NewCondition(true), "cond0", "geo")),
+			out: `NOT geo EXISTS#cond0`,
+		},
+		"semantic": {
+			in:  condition.NewSemanticCondition(condition.NewCondition(true), "", "electric cars", 0.8),
+			out: `NOT ~"electric cars"@0.8`,
+		},
+		"semantic without similarity": {
+			in:  condition.NewSemanticCondition(condition.NewCondition(false), "", "electric cars", 0),
+			out: `~"electric cars"`,
+		},
+		"groups": {
+			in: newGroup(true, condition.GroupLogicOr,
+				newGroup(false, condition.GroupLogicAnd,
+					newText(false, "", "title", "golang", false),
+					newText(true, "", "lang", "ru", true),
+				),
+				newGroup(true, condition.GroupLogicXor,
+					newText(false, "", "", "a", false),
+					newText(false, "", "", "b", false),
+				),
+				newGroup(false, condition.GroupLogicAnd),
+				newGroup(true, condition.GroupLogicOr, newText(false, "", "", "c", false)),
+			),
+			out: `NOT ((title:"golang" AND NOT lang:="ru") OR NOT ("a" XOR "b") OR AND() OR NOT OR("c"))`,
+		},
+		"explicit group children": {
+			in: newGroup(false, condition.GroupLogicAnd,
+				newGroup(false, condition.GroupLogicOr,
+					newText(false, "", "", "a", false),
+					newText(false, "", "", "b", false),
+				),
+			),
+			out: `AND("a" OR "b")`,
+		},
+		"unknown group logic": {
+			in: newGroup(false, condition.GroupLogic(3),
+				newText(false, "", "", "a", false),
+				newText(false, "", "", "b", false),
+			),
+			err: ErrPrint,
+		},
+		"nested unknown group logic": {
+			in: newGroup(false, condition.GroupLogicAnd,
+				newText(false, "", "", "a", false),
+				newGroup(true, condition.GroupLogic(-1)),
+			),
+			err: ErrPrint,
+		},
+		"undefined number operation": {
+			in:  newNumber(false, "", "price", condition.NumOpUndefined, 1),
+			err: ErrPrint,
+		},
+		"nested undefined number operation": {
+			in: newGroup(false, condition.GroupLogicOr,
+				newText(false, "", "", "a", false),
+				newNumber(false, "", "price", condition.NumOpUndefined, 1),
+			),
+			err: ErrPrint,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			out, err := Print(c.in)
+			assert.Equal(t, c.out, out)
+			assert.ErrorIs(t, err, c.err)
+		})
+	}
+}
+
+func TestPrint_RoundTrip(t *testing.T) {
+	trees := []condition.Condition{
+		newGroup(false, condition.GroupLogicAnd,
+			newText(false, "cond0", "", "a", false),
+			newGroup(false, condition.GroupLogicAnd,
+				newText(false, "cond1", "k", "b", true),
+				newGroup(true, condition.GroupLogicOr,
+					newNumber(true, "cond2", "n", condition.NumOpLt, math.MaxFloat64),
+					condition.NewSemanticCondition(condition.NewCondition(false), "cond3", "q", 0.123456),
+				),
+			),
+			newGroup(false, condition.GroupLogicXor,
+				newGroup(false, condition.GroupLogicXor,
+					newText(false, "", "k\n", "\t", false),
+					newNumber(false, "", "NOT", condition.NumOpGt, math.Inf(-1)),
+					condition.NewRegexCondition(condition.NewKeyCondition(condition.NewCondition(true), "cond4", "k"), `\d{2,}`),
+					condition.NewRangeCondition(
+						condition.NewKeyCondition(condition.NewCondition(false), "cond5", ""),
+						condition.Bound{Value: 1, Inclusive: true},
+						condition.Bound{Value: 2},
+					),
+				),
+				newGroup(true, condition.GroupLogicAnd),
+			),
+		),
+		newGroup(true, condition.GroupLogicAnd, newGroup(true, condition.GroupLogicAnd, newText(true, "", "", "a", false))),
+	}
+	for _, tree := range trees {
+		q, err := Print(tree)
+		require.Nil(t, err)
+		parsed, err := Parse(q)
+		require.Nil(t, err, q)
+		assert.True(t, tree.Equal(parsed), q)
+		reprinted, err := Print(parsed)
+		require.Nil(t, err)
+		assert.Equal(t, q, reprinted)
+	}
+}
@@ -0,0 +1,94 @@
+package interest
+
+import (
+	"github.com/awakari/interests/model/condition"
+	"slices"
+	"time"
+)
+
+type ChangeType int
+
+const (
+	ChangeCreated ChangeType = iota
+	ChangeUpdated
+	ChangeDeleted
+	ChangeEnabled
+	ChangeOwner
+	ChangeRestored
+	ChangePurged
+)
+
+func (ct ChangeType) String() string {
+	return [...]string{
+		"Created",
+		"Updated",
+		"Deleted",
+		"Enabled",
+		"Owner",
+		"Restored",
+		"Purged",
+	}[ct]
+}
+
+// Change represents a single interest mutation.
+type Change struct {
+
+	// Position is the unique change number, it grows in the order the changes happen.
+	Position uint64
+
+	Type       ChangeType
+	InterestId string
+
+	// GroupId and UserId identify the interest owner after the change.
+	GroupId string
+	UserId  string
+
+	Time time.Time
+
+	// Enabled and EnabledSince are the interest state after the change.
+	Enabled      bool
+	EnabledSince time.Time
+
+	// Before is the root condition before the change, nil when the interest is created or restored.
+	Before condition.Condition
+
+	// After is the root condition after the change, nil when the interest is deleted or purged.
+	After condition.Condition
+
+	CondIdsAdded   []string
+	CondIdsRemoved []string
+}
+
+// NewChange returns the Change with the condition ids added and removed resolved from the conditions before and
+// after the change.
+func NewChange(t ChangeType, id, groupId, userId string, before, after condition.Condition) (c Change) {
+	c.Type = t
+	c.InterestId = id
+	c.GroupId = groupId
+	c.UserId = userId
+	c.Time = time.Now().UTC()
+	c.Before = before
+	c.After = after
+	idsBefore := condition.LeafIds(before)
+	idsAfter := condition.LeafIds(after)
+	for _, condId := range idsAfter {
+		if !slices.Contains(idsBefore, condId) && !slices.Contains(c.CondIdsAdded, condId) {
+			c.CondIdsAdded = append(c.CondIdsAdded, condId)
+		}
+	}
+	for _, condId := range idsBefore {
+		if !slices.Contains(idsAfter, condId) && !slices.Contains(c.CondIdsRemoved, condId) {
+			c.CondIdsRemoved = append(c.CondIdsRemoved, condId)
+		}
+	}
+	return
+}
+
+// CondIdsAfter returns the unique sorted condition ids of the interests after the changes.
+func CondIdsAfter(changes []Change) (condIds []string) {
+	for _, c := range changes {
+		condIds = append(condIds, condition.LeafIds(c.After)...)
+	}
+	slices.Sort(condIds)
+	return slices.Compact(condIds)
+}
@@ -17,3 +17,18 @@ type ConditionMatchPage struct {
 	ConditionMatches []ConditionMatch
 	Expires          time.Time
 }
+
+// ConditionMatchBatch is the result of the search by multiple condition ids.
+type ConditionMatchBatch struct {
+
+	// Pages contains the matching interest ids page by the condition id.
+	Pages map[string]InterestIdsPage
+
+	// Conditions contains the root condition by the interest id, once for every interest in the pages.
+	Conditions map[string]condition.Condition
+}
+
+type InterestIdsPage struct {
+	InterestIds []string
+	Expires     time.Time
+}
@@ -0,0 +1,10 @@
+package interest
+
+import "time"
+
+// Deleted is the interest deleted but not purged yet, so it may be restored.
+type Deleted struct {
+	Id        string
+	DeletedAt time.Time
+	Data      Data
+}
@@ -0,0 +1,46 @@
+package interest
+
+import (
+	"github.com/awakari/interests/model/condition"
+	"slices"
+	"time"
+)
+
+// Purged is the result of the permanent removal of all account interests.
+type Purged struct {
+
+	// Count is the number of the interests removed including the deleted ones.
+	Count int64
+
+	// Deleted is the number of the interests those were deleted already before the removal.
+	Deleted int64
+
+	// CondIds contains the unique sorted condition ids of all removed interests.
+	CondIds []string
+}
+
+// Add counts the removed interest having the specified root condition.
+func (p *Purged) Add(cond condition.Condition, deleted bool) {
+	p.Count++
+	if deleted {
+		p.Deleted++
+	}
+	p.CondIds = append(p.CondIds, condition.LeafIds(cond)...)
+	slices.Sort(p.CondIds)
+	p.CondIds = slices.Compact(p.CondIds)
+}
+
+// PurgeAudit is the permanent record of the purge, unlike the changes it is not removed after the retention period.
+type PurgeAudit struct {
+	Purged
+
+	// Actor identifies the principal requested the purge.
+	Actor string
+
+	// GroupId and UserId identify the purged account.
+	GroupId string
+
+	UserId string
+
+	Time time.Time
+}
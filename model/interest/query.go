@@ -17,6 +17,12 @@ type QueryByCondition struct {
 	Limit  uint32
 }
 
+// QueryByConditionCursor is the batch search item having own cursor.
+type QueryByConditionCursor struct {
+	QueryByCondition
+	Cursor string
+}
+
 type Sort int
 
 const (
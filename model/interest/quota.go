@@ -0,0 +1,41 @@
+package interest
+
+// Limits restricts the interests of an account, zero means no limit.
+type Limits struct {
+
+	// Interests is the max count of the account interests.
+	Interests int64 `json:"interests"`
+
+	// Public is the max count of the account public interests.
+	Public int64 `json:"public"`
+
+	// ConditionDepth is the max nesting level of the interest condition tree, a single leaf condition is of level 1.
+	ConditionDepth uint32 `json:"conditionDepth"`
+
+	// ConditionLeaves is the max count of the leaf conditions in the interest condition tree.
+	ConditionLeaves uint32 `json:"conditionLeaves"`
+}
+
+// Usage is the current count of the account interests those are not deleted.
+type Usage struct {
+	Interests int64
+	Public    int64
+}
+
+// Add returns the sum of the usages.
+func (u Usage) Add(a Usage) Usage {
+	u.Interests += a.Interests
+	u.Public += a.Public
+	return u
+}
+
+// UsageAdded returns the usage added by writing the interest data over the previous one, nil when the interest is new.
+func UsageAdded(prev *Data, d Data) (a Usage) {
+	if prev == nil {
+		a.Interests = 1
+	}
+	if d.Public && (prev == nil || !prev.Public) {
+		a.Public = 1
+	}
+	return
+}
@@ -0,0 +1,31 @@
+package interest
+
+import "time"
+
+// Revision is the immutable snapshot of the interest Data written by a create or an update.
+type Revision struct {
+
+	// Number starts from 1 for every interest and is incremented by every write.
+	Number uint64
+
+	// GroupId and UserId identify the account made the change, empty when changed internally.
+	GroupId string
+	UserId  string
+
+	Time time.Time
+
+	Data Data
+}
+
+// RestoreData returns the data to update the interest with to restore the revision: the description, enabled flag,
+// expiration, public flag and condition.
+func (rev Revision) RestoreData(updated time.Time) Data {
+	return Data{
+		Description: rev.Data.Description,
+		Enabled:     rev.Data.Enabled,
+		Expires:     rev.Data.Expires,
+		Condition:   rev.Data.Condition,
+		Updated:     updated,
+		Public:      rev.Data.Public,
+	}
+}
@@ -0,0 +1,2 @@
+// Package matcher evaluates the interest conditions against the messages.
+package matcher
@@ -0,0 +1,234 @@
+package matcher
+
+import (
+	"github.com/awakari/interests/model/condition"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Event represents a message to evaluate the conditions against.
+type Event struct {
+
+	// Attributes are the message metadata, e.g. CloudEvents attributes.
+	Attributes map[string]string
+
+	// Data is the message text data.
+	Data string
+}
+
+// Result is the condition evaluation result tree which has the same structure as the evaluated condition.
+type Result struct {
+
+	// Condition is the evaluated condition.
+	Condition condition.Condition
+
+	// Matched is the final evaluation result, with the condition negation flag applied.
+	Matched bool
+
+	// Unsupported is true when the condition can not be evaluated locally, e.g. a semantic one.
+	// Such condition is treated as not matching regardless the negation flag.
+	Unsupported bool
+
+	// Group contains the child results when the evaluated condition is a group one.
+	Group []Result
+}
+
+// Match evaluates the specified condition against the event.
+func Match(c condition.Condition, evt Event) (r Result) {
+	r.Condition = c
+	switch ct := c.(type) {
+	case condition.GroupCondition:
+		r.Group = make([]Result, len(ct.GetGroup()))
+		for i, child := range ct.GetGroup() {
+			r.Group[i] = Match(child, evt)
+		}
+		r.Matched = matchGroup(ct.GetLogic(), r.Group)
+	case condition.TextCondition:
+		r.Matched = matchText(ct, evt)
+	case condition.NumberCondition:
+		r.Matched = matchNumber(ct, evt)
+	case condition.RegexCondition:
+		r.Matched = matchRegex(ct, evt)
+	case condition.RangeCondition:
+		r.Matched = matchRange(ct, evt)
+	case condition.SetCondition:
+		r.Matched = matchSet(ct, evt)
+	case condition.ExistsCondition:
+		_, r.Matched = evt.Attributes[ct.GetKey()]
+	default:
+		r.Unsupported = true
+		return
+	}
+	r.Matched = r.Matched != c.IsNot()
+	return
+}
+
+// MatchedLeafIds returns the ids of the leaf conditions those criteria matched the event, regardless the negation flag.
+// This is the same set of condition ids the message routing reports as matching.
+func (r Result) MatchedLeafIds() (ids []string) {
+	switch ct := r.Condition.(type) {
+	case condition.GroupCondition:
+		for _, child := range r.Group {
+			ids = append(ids, child.MatchedLeafIds()...)
+		}
+	case condition.LeafCondition:
+		if !r.Unsupported && r.Matched != ct.IsNot() {
+			ids = append(ids, ct.GetId())
+		}
+	}
+	return
+}
+
+func matchGroup(logic condition.GroupLogic, group []Result) (matched bool) {
+	var count int
+	for _, r := range group {
+		if r.Matched {
+			count++
+		}
+	}
+	switch logic {
+	case condition.GroupLogicAnd:
+		matched = count == len(group)
+	case condition.GroupLogicOr:
+		matched = count > 0
+	case condition.GroupLogicXor:
+		matched = count == 1
+	}
+	return
+}
+
+// matchText returns true if any of the event values selected by the condition key matches the term.
+// The exact term should be equal to the value, otherwise every word of the term should be present in the value
+// ignoring the case. The empty key selects all the attribute values and the text data.
+func matchText(tc condition.TextCondition, evt Event) (matched bool) {
+	termWords := words(tc.GetTerm())
+	for _, v := range values(tc.GetKey(), evt) {
+		switch tc.IsExact() {
+		case true:
+			matched = v == tc.GetTerm()
+		default:
+			matched = containsAll(words(v), termWords)
+		}
+		if matched {
+			break
+		}
+	}
+	return
+}
+
+// matchNumber returns true if any of the event values selected by the condition key is a number satisfying the
+// condition operation.
+func matchNumber(nc condition.NumberCondition, evt Event) (matched bool) {
+	for _, v := range values(nc.GetKey(), evt) {
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			continue
+		}
+		switch nc.GetOperation() {
+		case condition.NumOpGt:
+			matched = n > nc.GetValue()
+		case condition.NumOpGte:
+			matched = n >= nc.GetValue()
+		case condition.NumOpEq:
+			matched = n == nc.GetValue()
+		case condition.NumOpLte:
+			matched = n <= nc.GetValue()
+		case condition.NumOpLt:
+			matched = n < nc.GetValue()
+		}
+		if matched {
+			break
+		}
+	}
+	return
+}
+
+// matchRange returns true if any of the event values selected by the condition key is a number within the bounds.
+func matchRange(rc condition.RangeCondition, evt Event) (matched bool) {
+	lower, upper := rc.GetLower(), rc.GetUpper()
+	for _, v := range values(rc.GetKey(), evt) {
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			continue
+		}
+		aboveLower := n > lower.Value || lower.Inclusive && n == lower.Value
+		belowUpper := n < upper.Value || upper.Inclusive && n == upper.Value
+		if matched = aboveLower && belowUpper; matched {
+			break
+		}
+	}
+	return
+}
+
+// matchSet returns true if any of the event values selected by the condition key is equal to any text of the set or
+// is a number equal to any number of the set.
+func matchSet(sc condition.SetCondition, evt Event) (matched bool) {
+	for _, v := range values(sc.GetKey(), evt) {
+		matched = slices.Contains(sc.GetTexts(), v)
+		if !matched && len(sc.GetNumbers()) > 0 {
+			n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			matched = err == nil && slices.Contains(sc.GetNumbers(), n)
+		}
+		if matched {
+			break
+		}
+	}
+	return
+}
+
+// matchRegex returns true if any of the event values selected by the condition key contains a match of the pattern.
+// The invalid pattern matches nothing.
+func matchRegex(rc condition.RegexCondition, evt Event) (matched bool) {
+	re, err := regexp.Compile(rc.GetPattern())
+	if err == nil {
+		for _, v := range values(rc.GetKey(), evt) {
+			if matched = re.MatchString(v); matched {
+				break
+			}
+		}
+	}
+	return
+}
+
+func values(key string, evt Event) (vals []string) {
+	switch key {
+	case "":
+		for _, v := range evt.Attributes {
+			vals = append(vals, v)
+		}
+		if evt.Data != "" {
+			vals = append(vals, evt.Data)
+		}
+	default:
+		if v, found := evt.Attributes[key]; found {
+			vals = append(vals, v)
+		}
+	}
+	return
+}
+
+func words(txt string) []string {
+	return strings.FieldsFunc(strings.ToLower(txt), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func containsAll(words, subset []string) (contains bool) {
+	if len(subset) == 0 {
+		return
+	}
+	set := make(map[string]struct{}, len(words))
+	for _, w := range words {
+		set[w] = struct{}{}
+	}
+	contains = true
+	for _, w := range subset {
+		if _, contains = set[w]; !contains {
+			break
+		}
+	}
+	return
+}
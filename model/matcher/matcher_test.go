@@ -0,0 +1,242 @@
+package matcher
+
+import (
+	"github.com/awakari/interests/model/condition"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	newTc := func(not bool, id, key, term string, exact bool) condition.Condition {
+		return condition.NewTextCondition(condition.NewKeyCondition(condition.NewCondition(not), id, key), term, exact)
+	}
+	newNc := func(not bool, id, key string, op condition.NumOp, val float64) condition.Condition {
+		return condition.NewNumberCondition(condition.NewKeyCondition(condition.NewCondition(not), id, key), op, val)
+	}
+	newRc := func(not bool, id, key, pattern string) condition.Condition {
+		return condition.NewRegexCondition(condition.NewKeyCondition(condition.NewCondition(not), id, key), pattern)
+	}
+	newRgc := func(not bool, id, key string, lower, upper condition.Bound) condition.Condition {
+		return condition.NewRangeCondition(condition.NewKeyCondition(condition.NewCondition(not), id, key), lower, upper)
+	}
+	newSc := func(not bool, id, key string, texts []string, numbers []float64) condition.Condition {
+		return condition.NewSetCondition(condition.NewKeyCondition(condition.NewCondition(not), id, key), texts, numbers)
+	}
+	newEc := func(not bool, id, key string) condition.Condition {
+		return condition.NewExistsCondition(condition.NewKeyCondition(condition.NewCondition(not), id, key))
+	}
+	evt := Event{
+		Attributes: map[string]string{
+			"title":    "Lorem Ipsum, dolor sit amet",
+			"category": "news",
+			"price":    "42.5",
+		},
+		Data: "consectetur adipiscing elit",
+	}
+	cases := map[string]struct {
+		cond    condition.Condition
+		matched bool
+		ids     []string
+	}{
+		"text non-exact": {
+			cond:    newTc(false, "tc0", "title", "ipsum lorem", false),
+			matched: true,
+			ids:     []string{"tc0"},
+		},
+		"text non-exact missing word": {
+			cond: newTc(false, "tc0", "title", "lorem foo", false),
+		},
+		"text exact": {
+			cond:    newTc(false, "tc0", "category", "news", true),
+			matched: true,
+			ids:     []string{"tc0"},
+		},
+		"text exact mismatch": {
+			cond: newTc(false, "tc0", "category", "News", true),
+		},
+		"text empty key matches data": {
+			cond:    newTc(false, "tc0", "", "elit", false),
+			matched: true,
+			ids:     []string{"tc0"},
+		},
+		"text missing key": {
+			cond: newTc(false, "tc0", "author", "lorem", false),
+		},
+		"text negated": {
+			cond: newTc(true, "tc0", "title", "lorem", false),
+			ids:  []string{"tc0"},
+		},
+		"regex": {
+			cond:    newRc(false, "rc0", "title", `(?i)^lorem\s+ipsum`),
+			matched: true,
+			ids:     []string{"rc0"},
+		},
+		"regex mismatch": {
+			cond: newRc(false, "rc0", "title", `^ipsum`),
+		},
+		"regex empty key matches data": {
+			cond:    newRc(false, "rc0", "", `adipi(s|z)cing`),
+			matched: true,
+			ids:     []string{"rc0"},
+		},
+		"regex negated": {
+			cond: newRc(true, "rc0", "category", `^news$`),
+			ids:  []string{"rc0"},
+		},
+		"regex invalid": {
+			cond:    newRc(true, "rc0", "category", `(news`),
+			matched: true,
+		},
+		"range": {
+			cond:    newRgc(false, "rgc0", "price", condition.Bound{Value: 42}, condition.Bound{Value: 43}),
+			matched: true,
+			ids:     []string{"rgc0"},
+		},
+		"range inclusive": {
+			cond:    newRgc(false, "rgc0", "price", condition.Bound{Value: 42.5, Inclusive: true}, condition.Bound{Value: 42.5, Inclusive: true}),
+			matched: true,
+			ids:     []string{"rgc0"},
+		},
+		"range exclusive lower": {
+			cond: newRgc(false, "rgc0", "price", condition.Bound{Value: 42.5}, condition.Bound{Value: 50, Inclusive: true}),
+		},
+		"range exclusive upper": {
+			cond: newRgc(false, "rgc0", "price", condition.Bound{Value: 0, Inclusive: true}, condition.Bound{Value: 42.5}),
+		},
+		"range not a number": {
+			cond: newRgc(false, "rgc0", "category", condition.Bound{Value: 0}, condition.Bound{Value: 100}),
+		},
+		"range negated": {
+			cond: newRgc(true, "rgc0", "price", condition.Bound{Value: 40}, condition.Bound{Value: 50}),
+			ids:  []string{"rgc0"},
+		},
+		"set text": {
+			cond:    newSc(false, "sc0", "category", []string{"sports", "news"}, nil),
+			matched: true,
+			ids:     []string{"sc0"},
+		},
+		"set text is exact": {
+			cond: newSc(false, "sc0", "category", []string{"News"}, []float64{1}),
+		},
+		"set number": {
+			cond:    newSc(false, "sc0", "price", []string{"42"}, []float64{1, 42.50}),
+			matched: true,
+			ids:     []string{"sc0"},
+		},
+		"not in set": {
+			cond:    newSc(true, "sc0", "category", []string{"sports", "weather"}, nil),
+			matched: true,
+		},
+		"exists": {
+			cond:    newEc(false, "ec0", "category"),
+			matched: true,
+			ids:     []string{"ec0"},
+		},
+		"exists missing key": {
+			cond: newEc(false, "ec0", "author"),
+		},
+		"absent": {
+			cond:    newEc(true, "ec0", "author"),
+			matched: true,
+		},
+		"absent present key": {
+			cond: newEc(true, "ec0", "category"),
+			ids:  []string{"ec0"},
+		},
+		"number gt": {
+			cond:    newNc(false, "nc0", "price", condition.NumOpGt, 42),
+			matched: true,
+			ids:     []string{"nc0"},
+		},
+		"number gte": {
+			cond:    newNc(false, "nc0", "price", condition.NumOpGte, 42.5),
+			matched: true,
+			ids:     []string{"nc0"},
+		},
+		"number eq": {
+			cond:    newNc(false, "nc0", "price", condition.NumOpEq, 42.5),
+			matched: true,
+			ids:     []string{"nc0"},
+		},
+		"number lte": {
+			cond: newNc(false, "nc0", "price", condition.NumOpLte, 42),
+		},
+		"number lt": {
+			cond:    newNc(false, "nc0", "price", condition.NumOpLt, 43),
+			matched: true,
+			ids:     []string{"nc0"},
+		},
+		"number undefined op": {
+			cond: newNc(false, "nc0", "price", condition.NumOpUndefined, 42.5),
+		},
+		"number not a number": {
+			cond: newNc(false, "nc0", "category", condition.NumOpGt, 0),
+		},
+		"number negated": {
+			cond:    newNc(true, "nc0", "price", condition.NumOpLt, 0),
+			matched: true,
+		},
+		"semantic is unsupported": {
+			cond: condition.NewSemanticCondition(condition.NewCondition(true), "sc0", "lorem ipsum", 0.9),
+		},
+		"group and": {
+			cond: condition.NewGroupCondition(
+				condition.NewCondition(false),
+				condition.GroupLogicAnd,
+				[]condition.Condition{
+					newTc(false, "tc0", "title", "dolor", false),
+					newNc(false, "nc0", "price", condition.NumOpGt, 100),
+				},
+			),
+			ids: []string{"tc0"},
+		},
+		"group or": {
+			cond: condition.NewGroupCondition(
+				condition.NewCondition(false),
+				condition.GroupLogicOr,
+				[]condition.Condition{
+					newTc(false, "tc0", "title", "dolor", false),
+					newNc(false, "nc0", "price", condition.NumOpGt, 100),
+				},
+			),
+			matched: true,
+			ids:     []string{"tc0"},
+		},
+		"group xor": {
+			cond: condition.NewGroupCondition(
+				condition.NewCondition(false),
+				condition.GroupLogicXor,
+				[]condition.Condition{
+					newTc(false, "tc0", "title", "dolor", false),
+					newTc(false, "tc1", "category", "news", true),
+				},
+			),
+			ids: []string{"tc0", "tc1"},
+		},
+		"group negated nested": {
+			cond: condition.NewGroupCondition(
+				condition.NewCondition(true),
+				condition.GroupLogicAnd,
+				[]condition.Condition{
+					newTc(false, "tc0", "title", "dolor", false),
+					condition.NewGroupCondition(
+						condition.NewCondition(false),
+						condition.GroupLogicOr,
+						[]condition.Condition{
+							newNc(true, "nc0", "price", condition.NumOpGt, 100),
+							newTc(false, "tc1", "category", "sports", true),
+						},
+					),
+				},
+			),
+			ids: []string{"tc0"},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			r := Match(c.cond, evt)
+			assert.Equal(t, c.matched, r.Matched)
+			assert.Equal(t, c.ids, r.MatchedLeafIds())
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"github.com/awakari/interests/model/condition"
+	"github.com/awakari/interests/model/interest"
+	"slices"
+	"time"
+)
+
+// ConditionMatchBatchCollector distributes the interests read in the ascending id order among the batch pages,
+// respecting every query cursor and limit. Allows the storage implementations to serve the batch search using
+// a single database query.
+type ConditionMatchBatchCollector struct {
+	qs    map[string]interest.QueryByConditionCursor
+	batch interest.ConditionMatchBatch
+}
+
+// NewConditionMatchBatchCollector returns the collector having an empty page for every query with the specified
+// default expiration time.
+func NewConditionMatchBatchCollector(qs []interest.QueryByConditionCursor, expires time.Time) (c ConditionMatchBatchCollector) {
+	c.qs = make(map[string]interest.QueryByConditionCursor, len(qs))
+	c.batch.Pages = make(map[string]interest.InterestIdsPage, len(qs))
+	c.batch.Conditions = make(map[string]condition.Condition)
+	for _, q := range qs {
+		c.qs[q.CondId] = q
+		c.batch.Pages[q.CondId] = interest.InterestIdsPage{
+			Expires: expires,
+		}
+	}
+	return
+}
+
+// CondIds returns the requested condition ids, sorted.
+func (c ConditionMatchBatchCollector) CondIds() (condIds []string) {
+	condIds = make([]string, 0, len(c.qs))
+	for condId := range c.qs {
+		condIds = append(condIds, condId)
+	}
+	slices.Sort(condIds)
+	return
+}
+
+// CursorMin returns the least cursor among the queries, the interests with the lower ids are not needed.
+func (c ConditionMatchBatchCollector) CursorMin() (cursor string) {
+	first := true
+	for _, q := range c.qs {
+		if first || q.Cursor < cursor {
+			cursor = q.Cursor
+			first = false
+		}
+	}
+	return
+}
+
+// Add puts the interest id to the pages of the specified condition ids where it fits by the cursor and the limit.
+// Returns true when the interest condition should be set using SetCondition, i.e. the interest is added to any page
+// and the condition is not set yet.
+func (c ConditionMatchBatchCollector) Add(id string, condIds []string, expires time.Time) (condMissing bool) {
+	for _, condId := range condIds {
+		q, requested := c.qs[condId]
+		if !requested || id <= q.Cursor {
+			continue
+		}
+		page := c.batch.Pages[condId]
+		if q.Limit > 0 && len(page.InterestIds) >= int(q.Limit) {
+			continue
+		}
+		if slices.Contains(page.InterestIds, id) {
+			continue
+		}
+		page.InterestIds = append(page.InterestIds, id)
+		if !expires.IsZero() && page.Expires.After(expires) {
+			page.Expires = expires.UTC()
+		}
+		c.batch.Pages[condId] = page
+		_, condSet := c.batch.Conditions[id]
+		condMissing = condMissing || !condSet
+	}
+	return
+}
+
+// SetCondition sets the root condition of the added interest.
+func (c ConditionMatchBatchCollector) SetCondition(id string, cond condition.Condition) {
+	c.batch.Conditions[id] = cond
+}
+
+// Full returns true when no more interests can be added, i.e. every query has the limit and its page is full.
+func (c ConditionMatchBatchCollector) Full() (full bool) {
+	full = true
+	for condId, q := range c.qs {
+		if q.Limit == 0 || len(c.batch.Pages[condId].InterestIds) < int(q.Limit) {
+			full = false
+			break
+		}
+	}
+	return
+}
+
+// Batch returns the collected result.
+func (c ConditionMatchBatchCollector) Batch() interest.ConditionMatchBatch {
+	return c.batch
+}
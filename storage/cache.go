@@ -0,0 +1,303 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"github.com/awakari/interests/model/condition"
+	"github.com/awakari/interests/model/interest"
+	"github.com/prometheus/client_golang/prometheus"
+	"sync"
+	"time"
+)
+
+var (
+	metricCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "awk_interests_cache_hit_total",
+		Help: "Awakari interests condition match cache hits total count",
+	})
+	metricCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "awk_interests_cache_miss_total",
+		Help: "Awakari interests condition match cache misses total count",
+	})
+)
+
+// CacheMetrics returns the cache hits and misses counters to register when the cache is enabled.
+func CacheMetrics() []prometheus.Collector {
+	return []prometheus.Collector{
+		metricCacheHits,
+		metricCacheMisses,
+	}
+}
+
+type cacheMiddleware struct {
+	stor  Storage
+	size  int
+	ttl   time.Duration
+	lock  *sync.Mutex
+	state *cacheState
+}
+
+type cacheState struct {
+	// lru contains the entries, the most recently used first
+	lru *list.List
+	// entries contains the lru elements by the key
+	entries map[cacheKey]*list.Element
+	// keysByCondId contains the entry keys by the condition id, used for the invalidation
+	keysByCondId map[string]map[cacheKey]struct{}
+	// gen is incremented on every invalidation: the page read before it should not be cached after
+	gen uint64
+}
+
+type cacheKey struct {
+	condId string
+	cursor string
+	limit  uint32
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	page    interest.ConditionMatchPage
+	expires time.Time
+}
+
+// NewCacheMiddleware returns the Storage caching up to the specified count of the SearchByCondition result pages.
+// A page is cached until the earliest of its Expires and the specified ttl, or until any interest containing the
+// page condition id is changed using this Storage instance. The changes made by other service instances are
+// not seen until the cached page expires.
+func NewCacheMiddleware(stor Storage, size int, ttl time.Duration) Storage {
+	return cacheMiddleware{
+		stor: stor,
+		size: size,
+		ttl:  ttl,
+		lock: &sync.Mutex{},
+		state: &cacheState{
+			lru:          list.New(),
+			entries:      make(map[cacheKey]*list.Element),
+			keysByCondId: make(map[string]map[cacheKey]struct{}),
+		},
+	}
+}
+
+func (cm cacheMiddleware) Close() error {
+	return cm.stor.Close()
+}
+
+func (cm cacheMiddleware) Create(ctx context.Context, id, groupId, userId string, sd interest.Data) (err error) {
+	err = cm.stor.Create(ctx, id, groupId, userId, sd)
+	if err == nil {
+		cm.invalidate(condition.LeafIds(sd.Condition))
+	}
+	return
+}
+
+func (cm cacheMiddleware) Read(ctx context.Context, id, groupId, userId string, internal bool) (sd interest.Data, ownerGroupId, ownerUserId string, err error) {
+	return cm.stor.Read(ctx, id, groupId, userId, internal)
+}
+
+func (cm cacheMiddleware) Update(ctx context.Context, id, groupId, userId string, internal bool, sd interest.Data) (prev interest.Data, err error) {
+	prev, err = cm.stor.Update(ctx, id, groupId, userId, internal, sd)
+	if err == nil {
+		cm.invalidate(append(condition.LeafIds(prev.Condition), condition.LeafIds(sd.Condition)...))
+	}
+	return
+}
+
+func (cm cacheMiddleware) UpdateFollowers(ctx context.Context, id string, count int64) (err error) {
+	return cm.stor.UpdateFollowers(ctx, id, count)
+}
+
+func (cm cacheMiddleware) UpdateResultTime(ctx context.Context, id string, last time.Time) (err error) {
+	return cm.stor.UpdateResultTime(ctx, id, last)
+}
+
+func (cm cacheMiddleware) SetEnabledBatch(ctx context.Context, ids []string, enabled bool, enabledSince time.Time) (n int64, condIds []string, err error) {
+	n, condIds, err = cm.stor.SetEnabledBatch(ctx, ids, enabled, enabledSince)
+	if err == nil && n > 0 {
+		cm.invalidate(condIds)
+	}
+	return
+}
+
+// ChangeOwner invalidates nothing: the condition match pages don't contain the interest owner.
+func (cm cacheMiddleware) ChangeOwner(ctx context.Context, oldGroupId, oldUserId, newGroupId, newUserId string) (n int64, err error) {
+	return cm.stor.ChangeOwner(ctx, oldGroupId, oldUserId, newGroupId, newUserId)
+}
+
+func (cm cacheMiddleware) Delete(ctx context.Context, id, groupId, userId string) (sd interest.Data, err error) {
+	sd, err = cm.stor.Delete(ctx, id, groupId, userId)
+	if err == nil {
+		cm.invalidate(condition.LeafIds(sd.Condition))
+	}
+	return
+}
+
+func (cm cacheMiddleware) Restore(ctx context.Context, id, groupId, userId string) (sd interest.Data, err error) {
+	sd, err = cm.stor.Restore(ctx, id, groupId, userId)
+	if err == nil {
+		cm.invalidate(condition.LeafIds(sd.Condition))
+	}
+	return
+}
+
+func (cm cacheMiddleware) ListOwned(ctx context.Context, groupId, userId, cursor string, limit uint32) (page []interest.Interest, err error) {
+	return cm.stor.ListOwned(ctx, groupId, userId, cursor, limit)
+}
+
+func (cm cacheMiddleware) ListDeleted(ctx context.Context, groupId, userId, cursor string, limit uint32) (page []interest.Deleted, err error) {
+	return cm.stor.ListDeleted(ctx, groupId, userId, cursor, limit)
+}
+
+func (cm cacheMiddleware) Purge(ctx context.Context, groupId, userId, actor string) (p interest.Purged, err error) {
+	p, err = cm.stor.Purge(ctx, groupId, userId, actor)
+	if err == nil {
+		cm.invalidate(p.CondIds)
+	}
+	return
+}
+
+func (cm cacheMiddleware) ListPurges(ctx context.Context, groupId, userId string) (audits []interest.PurgeAudit, err error) {
+	return cm.stor.ListPurges(ctx, groupId, userId)
+}
+
+func (cm cacheMiddleware) Search(ctx context.Context, q interest.Query, cursor interest.Cursor) (ids []string, err error) {
+	return cm.stor.Search(ctx, q, cursor)
+}
+
+func (cm cacheMiddleware) SearchByCondition(ctx context.Context, q interest.QueryByCondition, cursor string) (page interest.ConditionMatchPage, err error) {
+	k := cacheKey{
+		condId: q.CondId,
+		cursor: cursor,
+		limit:  q.Limit,
+	}
+	var found bool
+	var gen uint64
+	page, found, gen = cm.get(k)
+	switch found {
+	case true:
+		metricCacheHits.Inc()
+	default:
+		metricCacheMisses.Inc()
+		page, err = cm.stor.SearchByCondition(ctx, q, cursor)
+		if err == nil {
+			cm.put(k, page, gen)
+		}
+	}
+	return
+}
+
+func (cm cacheMiddleware) SearchByConditionStream(ctx context.Context, condId string, consume func(match interest.ConditionMatch) (err error)) (expires time.Time, err error) {
+	return cm.stor.SearchByConditionStream(ctx, condId, consume)
+}
+
+func (cm cacheMiddleware) SearchByConditionBatch(ctx context.Context, qs []interest.QueryByConditionCursor) (batch interest.ConditionMatchBatch, err error) {
+	return cm.stor.SearchByConditionBatch(ctx, qs)
+}
+
+func (cm cacheMiddleware) ListRevisions(ctx context.Context, id, groupId, userId string, internal bool, cursor uint64, limit uint32) (revs []interest.Revision, err error) {
+	return cm.stor.ListRevisions(ctx, id, groupId, userId, internal, cursor, limit)
+}
+
+func (cm cacheMiddleware) ReadRevision(ctx context.Context, id, groupId, userId string, internal bool, number uint64) (rev interest.Revision, err error) {
+	return cm.stor.ReadRevision(ctx, id, groupId, userId, internal, number)
+}
+
+func (cm cacheMiddleware) RestoreRevision(ctx context.Context, id, groupId, userId string, internal bool, number uint64, updated time.Time) (rev interest.Revision, prev interest.Data, err error) {
+	rev, prev, err = cm.stor.RestoreRevision(ctx, id, groupId, userId, internal, number, updated)
+	if err == nil {
+		cm.invalidate(append(condition.LeafIds(prev.Condition), condition.LeafIds(rev.Data.Condition)...))
+	}
+	return
+}
+
+func (cm cacheMiddleware) ReadChanges(ctx context.Context, after uint64, limit uint32) (changes []interest.Change, err error) {
+	return cm.stor.ReadChanges(ctx, after, limit)
+}
+
+func (cm cacheMiddleware) Usage(ctx context.Context, groupId, userId string) (u interest.Usage, err error) {
+	return cm.stor.Usage(ctx, groupId, userId)
+}
+
+func (cm cacheMiddleware) Count(ctx context.Context) (count int64, err error) {
+	return cm.stor.Count(ctx)
+}
+
+func (cm cacheMiddleware) CountUsersUnique(ctx context.Context) (count int64, err error) {
+	return cm.stor.CountUsersUnique(ctx)
+}
+
+// get returns the cached page if any, otherwise the current generation to put the page read from the storage.
+func (cm cacheMiddleware) get(k cacheKey) (page interest.ConditionMatchPage, found bool, gen uint64) {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+	var e *list.Element
+	e, found = cm.state.entries[k]
+	if found {
+		entry := e.Value.(*cacheEntry)
+		switch time.Now().Before(entry.expires) {
+		case true:
+			page = entry.page
+			cm.state.lru.MoveToFront(e)
+		default:
+			cm.remove(e)
+			found = false
+		}
+	}
+	gen = cm.state.gen
+	return
+}
+
+func (cm cacheMiddleware) put(k cacheKey, page interest.ConditionMatchPage, gen uint64) {
+	expires := time.Now().Add(cm.ttl)
+	if page.Expires.Before(expires) {
+		expires = page.Expires
+	}
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+	switch {
+	case cm.size <= 0:
+	case gen != cm.state.gen:
+		// the storage may have changed while the page was being read
+	default:
+		if e, found := cm.state.entries[k]; found {
+			cm.remove(e)
+		}
+		cm.state.entries[k] = cm.state.lru.PushFront(&cacheEntry{
+			key:     k,
+			page:    page,
+			expires: expires,
+		})
+		keys, found := cm.state.keysByCondId[k.condId]
+		if !found {
+			keys = make(map[cacheKey]struct{})
+			cm.state.keysByCondId[k.condId] = keys
+		}
+		keys[k] = struct{}{}
+		for cm.state.lru.Len() > cm.size {
+			cm.remove(cm.state.lru.Back())
+		}
+	}
+}
+
+// remove deletes the entry, should be invoked under the lock.
+func (cm cacheMiddleware) remove(e *list.Element) {
+	k := e.Value.(*cacheEntry).key
+	cm.state.lru.Remove(e)
+	delete(cm.state.entries, k)
+	if keys, found := cm.state.keysByCondId[k.condId]; found {
+		delete(keys, k)
+		if len(keys) == 0 {
+			delete(cm.state.keysByCondId, k.condId)
+		}
+	}
+}
+
+func (cm cacheMiddleware) invalidate(condIds []string) {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+	cm.state.gen++
+	for _, condId := range condIds {
+		for k := range cm.state.keysByCondId[condId] {
+			cm.remove(cm.state.entries[k])
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"context"
+	"github.com/awakari/interests/model/condition"
+	"github.com/awakari/interests/model/interest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type storageCountingMock struct {
+	Storage
+	expires time.Time
+	calls   *int
+}
+
+func (s storageCountingMock) SearchByCondition(ctx context.Context, q interest.QueryByCondition, cursor string) (page interest.ConditionMatchPage, err error) {
+	*s.calls++
+	if q.CondId == "fail" {
+		err = ErrInternal
+	} else {
+		page.ConditionMatches = []interest.ConditionMatch{
+			{
+				InterestId: "interest0",
+			},
+		}
+		page.Expires = s.expires
+	}
+	return
+}
+
+func newTextCondition(id string) condition.Condition {
+	return condition.NewTextCondition(
+		condition.NewKeyCondition(condition.NewCondition(false), id, "key0"),
+		"term0", false,
+	)
+}
+
+func TestCacheMiddleware_SearchByCondition(t *testing.T) {
+	cases := map[string]struct {
+		size    int
+		ttl     time.Duration
+		expires time.Duration
+		q       interest.QueryByCondition
+		between func(s Storage)
+		calls   int
+	}{
+		"hit": {
+			size:    10,
+			ttl:     time.Minute,
+			expires: time.Hour,
+			q: interest.QueryByCondition{
+				CondId: "cond0",
+				Limit:  10,
+			},
+			calls: 1,
+		},
+		"disabled": {
+			ttl:     time.Minute,
+			expires: time.Hour,
+			q: interest.QueryByCondition{
+				CondId: "cond0",
+			},
+			calls: 2,
+		},
+		"page expired": {
+			size:    10,
+			ttl:     time.Minute,
+			expires: -time.Second,
+			q: interest.QueryByCondition{
+				CondId: "cond0",
+			},
+			calls: 2,
+		},
+		"ttl expired": {
+			size:    10,
+			ttl:     time.Millisecond,
+			expires: time.Hour,
+			q: interest.QueryByCondition{
+				CondId: "cond0",
+			},
+			between: func(s Storage) {
+				time.Sleep(10 * time.Millisecond)
+			},
+			calls: 2,
+		},
+		"error is not cached": {
+			size:    10,
+			ttl:     time.Minute,
+			expires: time.Hour,
+			q: interest.QueryByCondition{
+				CondId: "fail",
+			},
+			calls: 2,
+		},
+		"evicted": {
+			size:    1,
+			ttl:     time.Minute,
+			expires: time.Hour,
+			q: interest.QueryByCondition{
+				CondId: "cond0",
+			},
+			between: func(s Storage) {
+				_, _ = s.SearchByCondition(context.TODO(), interest.QueryByCondition{CondId: "cond1"}, "")
+			},
+			calls: 3,
+		},
+		"create with other condition": {
+			size:    10,
+			ttl:     time.Minute,
+			expires: time.Hour,
+			q: interest.QueryByCondition{
+				CondId: "cond0",
+			},
+			between: func(s Storage) {
+				_ = s.Create(context.TODO(), "interest1", "group0", "user0", interest.Data{
+					Condition: newTextCondition("cond1"),
+				})
+			},
+			calls: 1,
+		},
+		"invalidated by create": {
+			size:    10,
+			ttl:     time.Minute,
+			expires: time.Hour,
+			q: interest.QueryByCondition{
+				CondId: "cond0",
+			},
+			between: func(s Storage) {
+				_ = s.Create(context.TODO(), "interest1", "group0", "user0", interest.Data{
+					Condition: newTextCondition("cond0"),
+				})
+			},
+			calls: 2,
+		},
+		"failed create": {
+			size:    10,
+			ttl:     time.Minute,
+			expires: time.Hour,
+			q: interest.QueryByCondition{
+				CondId: "cond0",
+			},
+			between: func(s Storage) {
+				_ = s.Create(context.TODO(), "fail", "group0", "user0", interest.Data{
+					Condition: newTextCondition("cond0"),
+				})
+			},
+			calls: 1,
+		},
+		"invalidated by previous condition on update": {
+			size:    10,
+			ttl:     time.Minute,
+			expires: time.Hour,
+			q: interest.QueryByCondition{
+				CondId: "txt_1",
+			},
+			between: func(s Storage) {
+				_, _ = s.Update(context.TODO(), "interest0", "group0", "user0", false, interest.Data{
+					Condition: newTextCondition("cond0"),
+				})
+			},
+			calls: 2,
+		},
+		"invalidated by delete": {
+			size:    10,
+			ttl:     time.Minute,
+			expires: time.Hour,
+			q: interest.QueryByCondition{
+				CondId: "sem_0",
+			},
+			between: func(s Storage) {
+				_, _ = s.Delete(context.TODO(), "interest0", "group0", "user0")
+			},
+			calls: 2,
+		},
+		"invalidated by restore": {
+			size:    10,
+			ttl:     time.Minute,
+			expires: time.Hour,
+			q: interest.QueryByCondition{
+				CondId: "cond0",
+			},
+			between: func(s Storage) {
+				_, _ = s.Restore(context.TODO(), "interest0", "group0", "user0")
+			},
+			calls: 2,
+		},
+		"invalidated by restored revision condition": {
+			size:    10,
+			ttl:     time.Minute,
+			expires: time.Hour,
+			q: interest.QueryByCondition{
+				CondId: "cond0",
+			},
+			between: func(s Storage) {
+				_, _, _ = s.RestoreRevision(context.TODO(), "interest0", "group0", "user0", false, 1, time.Now())
+			},
+			calls: 2,
+		},
+		"invalidated by set enabled batch": {
+			size:    10,
+			ttl:     time.Minute,
+			expires: time.Hour,
+			q: interest.QueryByCondition{
+				CondId: "cond0",
+			},
+			between: func(s Storage) {
+				_, _, _ = s.SetEnabledBatch(context.TODO(), []string{"interest0"}, false, time.Time{})
+			},
+			calls: 2,
+		},
+		"set enabled batch with other condition": {
+			size:    10,
+			ttl:     time.Minute,
+			expires: time.Hour,
+			q: interest.QueryByCondition{
+				CondId: "cond1",
+			},
+			between: func(s Storage) {
+				_, _, _ = s.SetEnabledBatch(context.TODO(), []string{"interest0"}, false, time.Time{})
+			},
+			calls: 1,
+		},
+		"change owner": {
+			size:    10,
+			ttl:     time.Minute,
+			expires: time.Hour,
+			q: interest.QueryByCondition{
+				CondId: "cond0",
+			},
+			between: func(s Storage) {
+				_, _ = s.ChangeOwner(context.TODO(), "group0", "user0", "group1", "user1")
+			},
+			calls: 1,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			var calls int
+			s := NewCacheMiddleware(storageCountingMock{
+				Storage: NewStorageMock(nil),
+				expires: time.Now().Add(c.expires),
+				calls:   &calls,
+			}, c.size, c.ttl)
+			page0, err0 := s.SearchByCondition(context.TODO(), c.q, "")
+			if c.between != nil {
+				c.between(s)
+			}
+			page1, err1 := s.SearchByCondition(context.TODO(), c.q, "")
+			assert.Equal(t, c.calls, calls)
+			assert.Equal(t, err0, err1)
+			assert.Equal(t, page0, page1)
+		})
+	}
+}
+
+func TestCacheMiddleware_SearchByCondition_Key(t *testing.T) {
+	var calls int
+	s := NewCacheMiddleware(storageCountingMock{
+		Storage: NewStorageMock(nil),
+		expires: time.Now().Add(time.Hour),
+		calls:   &calls,
+	}, 10, time.Minute)
+	ctx := context.TODO()
+	for i := 0; i < 2; i++ {
+		_, err := s.SearchByCondition(ctx, interest.QueryByCondition{CondId: "cond0", Limit: 10}, "")
+		require.Nil(t, err)
+		_, err = s.SearchByCondition(ctx, interest.QueryByCondition{CondId: "cond0", Limit: 10}, "interest0")
+		require.Nil(t, err)
+		_, err = s.SearchByCondition(ctx, interest.QueryByCondition{CondId: "cond0", Limit: 20}, "")
+		require.Nil(t, err)
+	}
+	assert.Equal(t, 3, calls)
+}
@@ -0,0 +1,224 @@
+package jsoncond
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/awakari/interests/model/condition"
+	"github.com/awakari/interests/storage"
+)
+
+type conditionRec struct {
+	Not  bool                `json:"not,omitempty"`
+	Gc   *groupConditionRec  `json:"gc,omitempty"`
+	Tc   *textConditionRec   `json:"tc,omitempty"`
+	Nc   *numConditionRec    `json:"nc,omitempty"`
+	Sc   *semConditionRec    `json:"sc,omitempty"`
+	Rc   *regexConditionRec  `json:"rc,omitempty"`
+	Rgc  *rangeConditionRec  `json:"rgc,omitempty"`
+	Setc *setConditionRec    `json:"setc,omitempty"`
+	Ec   *existsConditionRec `json:"ec,omitempty"`
+}
+
+type groupConditionRec struct {
+	Logic int            `json:"logic"`
+	Group []conditionRec `json:"group"`
+}
+
+type textConditionRec struct {
+	Id    string `json:"id"`
+	Key   string `json:"key"`
+	Term  string `json:"term"`
+	Exact bool   `json:"exact,omitempty"`
+}
+
+type numConditionRec struct {
+	Id  string  `json:"id"`
+	Key string  `json:"key"`
+	Op  int     `json:"op"`
+	Val float64 `json:"val"`
+}
+
+type rangeConditionRec struct {
+	Id             string  `json:"id"`
+	Key            string  `json:"key"`
+	Lower          float64 `json:"lower"`
+	LowerInclusive bool    `json:"lowerIncl,omitempty"`
+	Upper          float64 `json:"upper"`
+	UpperInclusive bool    `json:"upperIncl,omitempty"`
+}
+
+type setConditionRec struct {
+	Id      string    `json:"id"`
+	Key     string    `json:"key"`
+	Texts   []string  `json:"texts,omitempty"`
+	Numbers []float64 `json:"numbers,omitempty"`
+}
+
+type existsConditionRec struct {
+	Id  string `json:"id"`
+	Key string `json:"key"`
+}
+
+type regexConditionRec struct {
+	Id      string `json:"id"`
+	Key     string `json:"key"`
+	Pattern string `json:"pattern"`
+}
+
+type semConditionRec struct {
+	Id            string  `json:"id"`
+	Query         string  `json:"q"`
+	SimilarityMin float32 `json:"similarity"`
+}
+
+// Encode returns the JSON document representing the specified condition tree.
+func Encode(src condition.Condition) (data []byte, err error) {
+	var rec conditionRec
+	err = encodeCondition(src, &rec)
+	if err == nil {
+		data, err = json.Marshal(rec)
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: failed to encode the condition %+v: %s", storage.ErrInternal, src, err)
+	}
+	return
+}
+
+// Decode restores the condition tree from the JSON document produced by Encode.
+func Decode(data []byte) (dst condition.Condition, err error) {
+	var rec conditionRec
+	err = json.Unmarshal(data, &rec)
+	if err == nil {
+		dst, err = decodeCondition(rec)
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: failed to decode the condition %s: %s", storage.ErrInternal, string(data), err)
+	}
+	return
+}
+
+func encodeCondition(src condition.Condition, dst *conditionRec) (err error) {
+	dst.Not = src.IsNot()
+	switch c := src.(type) {
+	case condition.GroupCondition:
+		gc := groupConditionRec{
+			Logic: int(c.GetLogic()),
+			Group: make([]conditionRec, len(c.GetGroup())),
+		}
+		for i, child := range c.GetGroup() {
+			err = encodeCondition(child, &gc.Group[i])
+			if err != nil {
+				break
+			}
+		}
+		dst.Gc = &gc
+	case condition.TextCondition:
+		dst.Tc = &textConditionRec{
+			Id:    c.GetId(),
+			Key:   c.GetKey(),
+			Term:  c.GetTerm(),
+			Exact: c.IsExact(),
+		}
+	case condition.NumberCondition:
+		dst.Nc = &numConditionRec{
+			Id:  c.GetId(),
+			Key: c.GetKey(),
+			Op:  int(c.GetOperation()),
+			Val: c.GetValue(),
+		}
+	case condition.RangeCondition:
+		dst.Rgc = &rangeConditionRec{
+			Id:             c.GetId(),
+			Key:            c.GetKey(),
+			Lower:          c.GetLower().Value,
+			LowerInclusive: c.GetLower().Inclusive,
+			Upper:          c.GetUpper().Value,
+			UpperInclusive: c.GetUpper().Inclusive,
+		}
+	case condition.SetCondition:
+		dst.Setc = &setConditionRec{
+			Id:      c.GetId(),
+			Key:     c.GetKey(),
+			Texts:   c.GetTexts(),
+			Numbers: c.GetNumbers(),
+		}
+	case condition.ExistsCondition:
+		dst.Ec = &existsConditionRec{
+			Id:  c.GetId(),
+			Key: c.GetKey(),
+		}
+	case condition.RegexCondition:
+		dst.Rc = &regexConditionRec{
+			Id:      c.GetId(),
+			Key:     c.GetKey(),
+			Pattern: c.GetPattern(),
+		}
+	case condition.SemanticCondition:
+		dst.Sc = &semConditionRec{
+			Id:            c.GetId(),
+			Query:         c.Query(),
+			SimilarityMin: c.SimilarityMin(),
+		}
+	default:
+		err = fmt.Errorf("unsupported condition type: %T", src)
+	}
+	return
+}
+
+func decodeCondition(src conditionRec) (dst condition.Condition, err error) {
+	base := condition.NewCondition(src.Not)
+	switch {
+	case src.Gc != nil:
+		group := make([]condition.Condition, len(src.Gc.Group))
+		for i, child := range src.Gc.Group {
+			group[i], err = decodeCondition(child)
+			if err != nil {
+				break
+			}
+		}
+		dst = condition.NewGroupCondition(base, condition.GroupLogic(src.Gc.Logic), group)
+	case src.Tc != nil:
+		dst = condition.NewTextCondition(
+			condition.NewKeyCondition(base, src.Tc.Id, src.Tc.Key),
+			src.Tc.Term,
+			src.Tc.Exact,
+		)
+	case src.Nc != nil:
+		op := condition.NumOp(src.Nc.Op)
+		if op < condition.NumOpUndefined || op > condition.NumOpLt {
+			op = condition.NumOpUndefined
+		}
+		dst = condition.NewNumberCondition(
+			condition.NewKeyCondition(base, src.Nc.Id, src.Nc.Key),
+			op,
+			src.Nc.Val,
+		)
+	case src.Rgc != nil:
+		dst = condition.NewRangeCondition(
+			condition.NewKeyCondition(base, src.Rgc.Id, src.Rgc.Key),
+			condition.Bound{
+				Value:     src.Rgc.Lower,
+				Inclusive: src.Rgc.LowerInclusive,
+			},
+			condition.Bound{
+				Value:     src.Rgc.Upper,
+				Inclusive: src.Rgc.UpperInclusive,
+			},
+		)
+	case src.Setc != nil:
+		dst = condition.NewSetCondition(
+			condition.NewKeyCondition(base, src.Setc.Id, src.Setc.Key),
+			src.Setc.Texts,
+			src.Setc.Numbers,
+		)
+	case src.Ec != nil:
+		dst = condition.NewExistsCondition(condition.NewKeyCondition(base, src.Ec.Id, src.Ec.Key))
+	case src.Rc != nil:
+		dst = condition.NewRegexCondition(condition.NewKeyCondition(base, src.Rc.Id, src.Rc.Key), src.Rc.Pattern)
+	case src.Sc != nil:
+		dst = condition.NewSemanticCondition(base, src.Sc.Id, src.Sc.Query, src.Sc.SimilarityMin)
+	default:
+		err = fmt.Errorf("undefined condition type: %+v", src)
+	}
+	return
+}
@@ -0,0 +1,108 @@
+package jsoncond
+
+import (
+	"github.com/awakari/interests/model/condition"
+	"github.com/awakari/interests/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	cases := map[string]struct {
+		src  condition.Condition
+		json string
+	}{
+		"text": {
+			src: condition.NewTextCondition(
+				condition.NewKeyCondition(condition.NewCondition(true), "cond0", "key0"),
+				"pattern0", true,
+			),
+			json: `{"not":true,"tc":{"id":"cond0","key":"key0","term":"pattern0","exact":true}}`,
+		},
+		"number": {
+			src: condition.NewNumberCondition(
+				condition.NewKeyCondition(condition.NewCondition(false), "cond1", "key1"),
+				condition.NumOpLte, -1.2e-3,
+			),
+			json: `{"nc":{"id":"cond1","key":"key1","op":4,"val":-0.0012}}`,
+		},
+		"semantic": {
+			src:  condition.NewSemanticCondition(condition.NewCondition(false), "cond2", "lorem ipsum", 0.75),
+			json: `{"sc":{"id":"cond2","q":"lorem ipsum","similarity":0.75}}`,
+		},
+		"regex": {
+			src: condition.NewRegexCondition(
+				condition.NewKeyCondition(condition.NewCondition(true), "cond3", "key3"),
+				`^go(lang)?\b`,
+			),
+			json: `{"not":true,"rc":{"id":"cond3","key":"key3","pattern":"^go(lang)?\\b"}}`,
+		},
+		"range": {
+			src: condition.NewRangeCondition(
+				condition.NewKeyCondition(condition.NewCondition(false), "cond4", "key4"),
+				condition.Bound{Value: 10, Inclusive: true},
+				condition.Bound{Value: 100.5},
+			),
+			json: `{"rgc":{"id":"cond4","key":"key4","lower":10,"lowerIncl":true,"upper":100.5}}`,
+		},
+		"set": {
+			src: condition.NewSetCondition(
+				condition.NewKeyCondition(condition.NewCondition(true), "cond5", "key5"),
+				[]string{"a", "b"},
+				[]float64{1.5},
+			),
+			json: `{"not":true,"setc":{"id":"cond5","key":"key5","texts":["a","b"],"numbers":[1.5]}}`,
+		},
+		"exists": {
+			src:  condition.NewExistsCondition(condition.NewKeyCondition(condition.NewCondition(true), "cond6", "key6")),
+			json: `{"not":true,"ec":{"id":"cond6","key":"key6"}}`,
+		},
+		"group": {
+			src: condition.NewGroupCondition(
+				condition.NewCondition(true),
+				condition.GroupLogicXor,
+				[]condition.Condition{
+					condition.NewTextCondition(
+						condition.NewKeyCondition(condition.NewCondition(false), "cond0", ""),
+						"pattern0", false,
+					),
+					condition.NewGroupCondition(
+						condition.NewCondition(false),
+						condition.GroupLogicOr,
+						[]condition.Condition{
+							condition.NewSemanticCondition(condition.NewCondition(true), "cond2", "lorem ipsum", 0.75),
+						},
+					),
+				},
+			),
+			json: `{"not":true,"gc":{"logic":2,"group":[{"tc":{"id":"cond0","key":"","term":"pattern0"}},{"gc":{"logic":1,"group":[{"not":true,"sc":{"id":"cond2","q":"lorem ipsum","similarity":0.75}}]}}]}}`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			data, err := Encode(c.src)
+			require.Nil(t, err)
+			assert.JSONEq(t, c.json, string(data))
+			var dst condition.Condition
+			dst, err = Decode(data)
+			require.Nil(t, err)
+			assert.True(t, c.src.Equal(dst))
+			assert.Equal(t, condition.LeafIds(c.src), condition.LeafIds(dst))
+		})
+	}
+}
+
+func TestDecode_Fail(t *testing.T) {
+	cases := map[string]string{
+		"invalid json":       `{"not":`,
+		"undefined type":     `{"not":true}`,
+		"undefined in group": `{"gc":{"logic":0,"group":[{}]}}`,
+	}
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Decode([]byte(data))
+			assert.ErrorIs(t, err, storage.ErrInternal)
+		})
+	}
+}
@@ -0,0 +1,3 @@
+// Package jsoncond contains the JSON encoding of the condition trees.
+// It's shared by the storage implementations those keep the interest condition as a JSON document.
+package jsoncond
@@ -55,9 +55,9 @@ func (lm loggingMiddleware) UpdateResultTime(ctx context.Context, id string, las
 	return lm.stor.UpdateResultTime(ctx, id, last)
 }
 
-func (lm loggingMiddleware) SetEnabledBatch(ctx context.Context, ids []string, enabled bool, enabledSince time.Time) (n int64, err error) {
+func (lm loggingMiddleware) SetEnabledBatch(ctx context.Context, ids []string, enabled bool, enabledSince time.Time) (n int64, condIds []string, err error) {
 	defer func() {
-		lm.log.Debug(fmt.Sprintf("SetEnabledBatch(%+v, %t, %s): %d, err=%s", ids, enabled, enabledSince, n, err))
+		lm.log.Debug(fmt.Sprintf("SetEnabledBatch(%+v, %t, %s): %d, %+v, err=%s", ids, enabled, enabledSince, n, condIds, err))
 	}()
 	return lm.stor.SetEnabledBatch(ctx, ids, enabled, enabledSince)
 }
@@ -83,6 +83,94 @@ func (lm loggingMiddleware) SearchByCondition(ctx context.Context, q interest.Qu
 	return lm.stor.SearchByCondition(ctx, q, cursor)
 }
 
+func (lm loggingMiddleware) SearchByConditionStream(ctx context.Context, condId string, consume func(cm interest.ConditionMatch) (err error)) (expires time.Time, err error) {
+	var count int
+	defer func() {
+		lm.log.Debug(fmt.Sprintf("SearchByConditionStream(condId=%s): %d, %s, %s", condId, count, expires, err))
+	}()
+	return lm.stor.SearchByConditionStream(ctx, condId, func(cm interest.ConditionMatch) (err error) {
+		count++
+		return consume(cm)
+	})
+}
+
+func (lm loggingMiddleware) SearchByConditionBatch(ctx context.Context, qs []interest.QueryByConditionCursor) (batch interest.ConditionMatchBatch, err error) {
+	defer func() {
+		lm.log.Debug(fmt.Sprintf("SearchByConditionBatch(qs=%+v): %d, %s", qs, len(batch.Conditions), err))
+	}()
+	return lm.stor.SearchByConditionBatch(ctx, qs)
+}
+
+func (lm loggingMiddleware) Restore(ctx context.Context, id, groupId, userId string) (sd interest.Data, err error) {
+	defer func() {
+		lm.log.Debug(fmt.Sprintf("Restore(%s, %s, %s): %s", id, groupId, userId, err))
+	}()
+	return lm.stor.Restore(ctx, id, groupId, userId)
+}
+
+func (lm loggingMiddleware) ListOwned(ctx context.Context, groupId, userId, cursor string, limit uint32) (page []interest.Interest, err error) {
+	defer func() {
+		lm.log.Debug(fmt.Sprintf("ListOwned(%s, %s, cursor=%s, limit=%d): %d, %s", groupId, userId, cursor, limit, len(page), err))
+	}()
+	return lm.stor.ListOwned(ctx, groupId, userId, cursor, limit)
+}
+
+func (lm loggingMiddleware) ListDeleted(ctx context.Context, groupId, userId, cursor string, limit uint32) (page []interest.Deleted, err error) {
+	defer func() {
+		lm.log.Debug(fmt.Sprintf("ListDeleted(%s, %s, cursor=%s, limit=%d): %d, %s", groupId, userId, cursor, limit, len(page), err))
+	}()
+	return lm.stor.ListDeleted(ctx, groupId, userId, cursor, limit)
+}
+
+// Purge is logged at the info level regardless of the result to keep the audit trail.
+func (lm loggingMiddleware) Purge(ctx context.Context, groupId, userId, actor string) (p interest.Purged, err error) {
+	defer func() {
+		lm.log.Info(fmt.Sprintf("Purge(%s, %s, actor=%s): count=%d, deleted=%d, conds=%d, %s", groupId, userId, actor, p.Count, p.Deleted, len(p.CondIds), err))
+	}()
+	return lm.stor.Purge(ctx, groupId, userId, actor)
+}
+
+func (lm loggingMiddleware) ListPurges(ctx context.Context, groupId, userId string) (audits []interest.PurgeAudit, err error) {
+	defer func() {
+		lm.log.Debug(fmt.Sprintf("ListPurges(%s, %s): %d, %s", groupId, userId, len(audits), err))
+	}()
+	return lm.stor.ListPurges(ctx, groupId, userId)
+}
+
+func (lm loggingMiddleware) ListRevisions(ctx context.Context, id, groupId, userId string, internal bool, cursor uint64, limit uint32) (revs []interest.Revision, err error) {
+	defer func() {
+		lm.log.Debug(fmt.Sprintf("ListRevisions(%s, %s, %s, %t, cursor=%d, limit=%d): %d, %s", id, groupId, userId, internal, cursor, limit, len(revs), err))
+	}()
+	return lm.stor.ListRevisions(ctx, id, groupId, userId, internal, cursor, limit)
+}
+
+func (lm loggingMiddleware) ReadRevision(ctx context.Context, id, groupId, userId string, internal bool, number uint64) (rev interest.Revision, err error) {
+	defer func() {
+		lm.log.Debug(fmt.Sprintf("ReadRevision(%s, %s, %s, %t, %d): %s", id, groupId, userId, internal, number, err))
+	}()
+	return lm.stor.ReadRevision(ctx, id, groupId, userId, internal, number)
+}
+
+func (lm loggingMiddleware) RestoreRevision(ctx context.Context, id, groupId, userId string, internal bool, number uint64, updated time.Time) (rev interest.Revision, prev interest.Data, err error) {
+	defer func() {
+		lm.log.Debug(fmt.Sprintf("RestoreRevision(%s, %s, %s, %t, %d, %s): %s", id, groupId, userId, internal, number, updated, err))
+	}()
+	return lm.stor.RestoreRevision(ctx, id, groupId, userId, internal, number, updated)
+}
+
+func (lm loggingMiddleware) ReadChanges(ctx context.Context, after uint64, limit uint32) (changes []interest.Change, err error) {
+	defer func() {
+		lm.log.Debug(fmt.Sprintf("ReadChanges(after=%d, limit=%d): %d, %s", after, limit, len(changes), err))
+	}()
+	return lm.stor.ReadChanges(ctx, after, limit)
+}
+
+func (lm loggingMiddleware) Usage(ctx context.Context, groupId, userId string) (u interest.Usage, err error) {
+	u, err = lm.stor.Usage(ctx, groupId, userId)
+	lm.log.Debug(fmt.Sprintf("Usage(%s/%s): %+v, %s", groupId, userId, u, err))
+	return
+}
+
 func (lm loggingMiddleware) Count(ctx context.Context) (count int64, err error) {
 	count, err = lm.stor.Count(ctx)
 	lm.log.Debug(fmt.Sprintf("Count(): %d, %s", count, err))
@@ -0,0 +1,3 @@
+// Package memory contains the in-memory interests storage implementation.
+// It's intended for the tests and local development when there's no database available.
+package memory
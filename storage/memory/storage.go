@@ -0,0 +1,714 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"github.com/awakari/interests/config"
+	"github.com/awakari/interests/model/condition"
+	"github.com/awakari/interests/model/interest"
+	"github.com/awakari/interests/storage"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+)
+
+type storageImpl struct {
+	lock             *sync.RWMutex
+	recs             map[string]*interestRec
+	changes          *changeLog
+	purges           *[]interest.PurgeAudit
+	resultTtlDefault time.Duration
+	retentionPeriod  time.Duration
+}
+
+type interestRec struct {
+	interest.Interest
+
+	// CondIds contains a flat list of all condition ids.
+	// The CondIds field is necessary to support the interests search by a condition id.
+	CondIds []string
+
+	// DeletedAt is set when the interest is deleted, the record is kept until the retention period expires.
+	DeletedAt time.Time
+
+	// Revisions contains the data snapshot per every write, the revision number is the index + 1.
+	Revisions []interest.Revision
+}
+
+type changeLog struct {
+	entries  []interest.Change
+	position uint64
+}
+
+func NewStorage(cfgDb config.DbConfig) storage.Storage {
+	return storageImpl{
+		lock:             &sync.RWMutex{},
+		recs:             make(map[string]*interestRec),
+		changes:          &changeLog{},
+		purges:           &[]interest.PurgeAudit{},
+		resultTtlDefault: cfgDb.ResultTtl,
+		retentionPeriod:  cfgDb.Table.Retention,
+	}
+}
+
+func (s storageImpl) Close() error {
+	return nil
+}
+
+func (s storageImpl) Create(ctx context.Context, id, groupId, userId string, sd interest.Data) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.purgeDeleted(time.Now())
+	if _, found := s.recs[id]; found {
+		err = fmt.Errorf("%w: id already in use: %s", storage.ErrConflict, id)
+	} else {
+		err = s.enforceQuotas(ctx, groupId, userId, sd.Condition, interest.UsageAdded(nil, sd))
+	}
+	if err == nil {
+		s.recs[id] = &interestRec{
+			Interest: interest.Interest{
+				Id:      id,
+				GroupId: groupId,
+				UserId:  userId,
+				Data: interest.Data{
+					Description: sd.Description,
+					Enabled:     sd.Enabled,
+					Expires:     sd.Expires.UTC(),
+					Created:     sd.Created.UTC(),
+					Updated:     sd.Updated.UTC(),
+					Public:      sd.Public,
+					Followers:   sd.Followers,
+					Condition:   sd.Condition,
+				},
+			},
+			CondIds: condition.LeafIds(sd.Condition),
+		}
+		s.recs[id].appendRevision(groupId, userId)
+		s.appendChange(interest.ChangeCreated, s.recs[id], nil)
+	}
+	return
+}
+
+func (s storageImpl) Read(ctx context.Context, id, groupId, userId string, internal bool) (sd interest.Data, ownerGroupId, ownerUserId string, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	rec, found := s.recs[id]
+	switch {
+	case !found, rec.deleted():
+		err = fmt.Errorf("%w: id=%s, acc=%s/%s", storage.ErrNotFound, id, groupId, userId)
+	case !internal && !rec.ownedBy(groupId, userId) && !rec.Data.Public:
+		err = fmt.Errorf("%w: id=%s, acc=%s/%s", storage.ErrNotFound, id, groupId, userId)
+	default:
+		sd = rec.Data
+		ownerGroupId = rec.GroupId
+		ownerUserId = rec.UserId
+	}
+	return
+}
+
+func (s storageImpl) Update(ctx context.Context, id, groupId, userId string, internal bool, d interest.Data) (prev interest.Data, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.update(ctx, id, groupId, userId, internal, d)
+}
+
+// update should be invoked under the lock.
+func (s storageImpl) update(ctx context.Context, id, groupId, userId string, internal bool, d interest.Data) (prev interest.Data, err error) {
+	rec, found := s.recs[id]
+	switch {
+	case !found, rec.deleted():
+		err = fmt.Errorf("%w: not found, id: %s, acc: %s/%s", storage.ErrNotFound, id, groupId, userId)
+	case !internal && !rec.ownedBy(groupId, userId):
+		err = fmt.Errorf("%w: not found, id: %s, acc: %s/%s", storage.ErrNotFound, id, groupId, userId)
+	default:
+		err = s.enforceQuotas(ctx, rec.GroupId, rec.UserId, d.Condition, interest.UsageAdded(&rec.Data, d))
+	}
+	if err == nil {
+		prev = rec.Data
+		rec.Data.Description = d.Description
+		rec.Data.Enabled = d.Enabled
+		rec.Data.Expires = d.Expires.UTC()
+		rec.Data.Updated = d.Updated.UTC()
+		rec.Data.Public = d.Public
+		rec.Data.Condition = d.Condition
+		rec.CondIds = condition.LeafIds(d.Condition)
+		rec.appendRevision(groupId, userId)
+		s.appendChange(interest.ChangeUpdated, rec, prev.Condition)
+	}
+	return
+}
+
+func (s storageImpl) UpdateFollowers(ctx context.Context, id string, count int64) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	rec, found := s.recs[id]
+	switch {
+	case !found, rec.deleted():
+		err = fmt.Errorf("%w: not found, id: %s", storage.ErrNotFound, id)
+	default:
+		rec.Data.Followers = count
+	}
+	return
+}
+
+func (s storageImpl) UpdateResultTime(ctx context.Context, id string, last time.Time) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	rec, found := s.recs[id]
+	switch {
+	case !found, rec.deleted():
+		err = fmt.Errorf("%w: not found, id: %s", storage.ErrNotFound, id)
+	default:
+		rec.Data.Result = last.UTC()
+	}
+	return
+}
+
+func (s storageImpl) SetEnabledBatch(ctx context.Context, ids []string, enabled bool, enabledSince time.Time) (n int64, condIds []string, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, id := range ids {
+		rec, found := s.recs[id]
+		if !found || rec.deleted() {
+			continue
+		}
+		modified := rec.Data.Enabled != enabled
+		rec.Data.Enabled = enabled
+		if !enabledSince.IsZero() && !rec.Data.EnabledSince.Equal(enabledSince) {
+			rec.Data.EnabledSince = enabledSince.UTC()
+			modified = true
+		}
+		if modified {
+			n++
+			s.appendChange(interest.ChangeEnabled, rec, rec.Data.Condition)
+			condIds = append(condIds, condition.LeafIds(rec.Data.Condition)...)
+		}
+	}
+	slices.Sort(condIds)
+	condIds = slices.Compact(condIds)
+	return
+}
+
+func (s storageImpl) ChangeOwner(ctx context.Context, oldGroupId, oldUserId, newGroupId, newUserId string) (n int64, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var moved []*interestRec
+	var movedUsage interest.Usage
+	var conds []condition.Condition
+	for _, rec := range s.recs {
+		if rec.deleted() || !rec.ownedBy(oldGroupId, oldUserId) {
+			continue
+		}
+		if rec.GroupId != newGroupId || rec.UserId != newUserId {
+			moved = append(moved, rec)
+			movedUsage = movedUsage.Add(interest.UsageAdded(nil, rec.Data))
+			conds = append(conds, rec.Data.Condition)
+		}
+	}
+	err = storage.EnforceQuotas(ctx, newGroupId, newUserId, conds, movedUsage, func() (u interest.Usage, err error) {
+		u = s.usage(newGroupId, newUserId).Add(movedUsage)
+		return
+	})
+	if err == nil {
+		for _, rec := range moved {
+			rec.GroupId = newGroupId
+			rec.UserId = newUserId
+			n++
+			s.appendChange(interest.ChangeOwner, rec, rec.Data.Condition)
+		}
+	}
+	return
+}
+
+func (s storageImpl) Delete(ctx context.Context, id, groupId, userId string) (sd interest.Data, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	rec, found := s.recs[id]
+	switch {
+	case !found, rec.deleted(), !rec.ownedBy(groupId, userId):
+		err = fmt.Errorf("%w: id=%s, acc=%s/%s", storage.ErrNotFound, id, groupId, userId)
+	default:
+		sd = rec.Data
+		rec.DeletedAt = time.Now().UTC()
+		s.appendChange(interest.ChangeDeleted, rec, rec.Data.Condition)
+	}
+	return
+}
+
+func (s storageImpl) Restore(ctx context.Context, id, groupId, userId string) (sd interest.Data, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	rec, found := s.recs[id]
+	switch {
+	case !found:
+		err = fmt.Errorf("%w: id=%s, acc=%s/%s", storage.ErrNotFound, id, groupId, userId)
+	case !rec.deleted():
+		err = fmt.Errorf("%w: id already in use: %s", storage.ErrConflict, id)
+	case !rec.ownedBy(groupId, userId), s.purgeable(rec, time.Now()):
+		err = fmt.Errorf("%w: id=%s, acc=%s/%s", storage.ErrNotFound, id, groupId, userId)
+	default:
+		err = s.enforceQuotas(ctx, groupId, userId, rec.Data.Condition, interest.UsageAdded(nil, rec.Data))
+	}
+	if err == nil {
+		rec.DeletedAt = time.Time{}
+		sd = rec.Data
+		s.appendChange(interest.ChangeRestored, rec, nil)
+	}
+	return
+}
+
+func (s storageImpl) ListOwned(ctx context.Context, groupId, userId, cursor string, limit uint32) (page []interest.Interest, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for _, rec := range s.recs {
+		if !rec.deleted() && rec.ownedBy(groupId, userId) && rec.Id > cursor {
+			page = append(page, rec.Interest)
+		}
+	}
+	slices.SortFunc(page, func(a, b interest.Interest) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	if limit > 0 && len(page) > int(limit) {
+		page = page[:limit]
+	}
+	return
+}
+
+func (s storageImpl) ListDeleted(ctx context.Context, groupId, userId, cursor string, limit uint32) (page []interest.Deleted, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	now := time.Now()
+	for _, rec := range s.recs {
+		if rec.deleted() && rec.ownedBy(groupId, userId) && rec.Id > cursor && !s.purgeable(rec, now) {
+			page = append(page, interest.Deleted{
+				Id:        rec.Id,
+				DeletedAt: rec.DeletedAt,
+				Data:      rec.Data,
+			})
+		}
+	}
+	slices.SortFunc(page, func(a, b interest.Deleted) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	if limit > 0 && len(page) > int(limit) {
+		page = page[:limit]
+	}
+	return
+}
+
+func (s storageImpl) Purge(ctx context.Context, groupId, userId, actor string) (p interest.Purged, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var recs []*interestRec
+	for id, rec := range s.recs {
+		if rec.ownedBy(groupId, userId) {
+			recs = append(recs, rec)
+			delete(s.recs, id)
+		}
+	}
+	slices.SortFunc(recs, func(a, b *interestRec) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	s.changes.entries = slices.DeleteFunc(s.changes.entries, func(c interest.Change) bool {
+		return slices.ContainsFunc(recs, func(rec *interestRec) bool {
+			return rec.Id == c.InterestId
+		})
+	})
+	for _, rec := range recs {
+		p.Add(rec.Data.Condition, rec.deleted())
+		var before condition.Condition
+		if !rec.deleted() {
+			before = rec.Data.Condition
+			rec.DeletedAt = time.Now().UTC()
+		}
+		s.appendChange(interest.ChangePurged, rec, before)
+	}
+	*s.purges = append(*s.purges, interest.PurgeAudit{
+		Purged:  p,
+		Actor:   actor,
+		GroupId: groupId,
+		UserId:  userId,
+		Time:    time.Now().UTC(),
+	})
+	return
+}
+
+func (s storageImpl) ListPurges(ctx context.Context, groupId, userId string) (audits []interest.PurgeAudit, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for _, a := range *s.purges {
+		if a.GroupId == groupId && a.UserId == userId {
+			audits = append(audits, a)
+		}
+	}
+	return
+}
+
+func (s storageImpl) Search(ctx context.Context, q interest.Query, cursor interest.Cursor) (ids []string, err error) {
+	var pattern *regexp.Regexp
+	if q.Pattern != "" {
+		pattern, err = regexp.Compile(q.Pattern)
+		if err != nil {
+			err = fmt.Errorf("%w: invalid pattern: %s, %s", storage.ErrInternal, q.Pattern, err)
+			return
+		}
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	var recs []*interestRec
+	for _, rec := range s.recs {
+		switch {
+		case rec.deleted():
+		case !rec.matchesQuery(q):
+		case pattern != nil && !pattern.MatchString(rec.Data.Description):
+		case compareCursor(rec, q.Sort, cursor) != cursorDirection(q.Order):
+		default:
+			recs = append(recs, rec)
+		}
+	}
+	slices.SortFunc(recs, func(a, b *interestRec) int {
+		return cursorDirection(q.Order) * compareRecs(a, b, q.Sort)
+	})
+	if q.Limit > 0 && len(recs) > int(q.Limit) {
+		recs = recs[:q.Limit]
+	}
+	for _, rec := range recs {
+		ids = append(ids, rec.Id)
+	}
+	return
+}
+
+func (s storageImpl) SearchByCondition(ctx context.Context, q interest.QueryByCondition, cursor string) (page interest.ConditionMatchPage, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	tNow := time.Now()
+	var recs []*interestRec
+	for _, rec := range s.recs {
+		switch {
+		case rec.Id <= cursor:
+		case rec.deleted():
+		case !slices.Contains(rec.CondIds, q.CondId):
+		case !rec.Data.Enabled:
+		case !rec.Data.EnabledSince.IsZero() && !rec.Data.EnabledSince.Before(tNow):
+		case !rec.Data.Expires.IsZero() && !rec.Data.Expires.After(tNow):
+		default:
+			recs = append(recs, rec)
+		}
+	}
+	slices.SortFunc(recs, func(a, b *interestRec) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	if q.Limit > 0 && len(recs) > int(q.Limit) {
+		recs = recs[:q.Limit]
+	}
+	page.Expires = tNow.Add(s.resultTtlDefault).UTC()
+	for _, rec := range recs {
+		page.ConditionMatches = append(page.ConditionMatches, interest.ConditionMatch{
+			InterestId: rec.Id,
+			Condition:  rec.Data.Condition,
+		})
+		if !rec.Data.Expires.IsZero() && page.Expires.After(rec.Data.Expires) {
+			page.Expires = rec.Data.Expires
+		}
+	}
+	return
+}
+
+func (s storageImpl) SearchByConditionStream(ctx context.Context, condId string, consume func(cm interest.ConditionMatch) (err error)) (expires time.Time, err error) {
+	tNow := time.Now()
+	expires = tNow.Add(s.resultTtlDefault).UTC()
+	var cms []interest.ConditionMatch
+	// don't hold the lock while consuming
+	s.lock.RLock()
+	for _, rec := range s.recs {
+		switch {
+		case rec.deleted():
+		case !slices.Contains(rec.CondIds, condId):
+		case !rec.Data.Enabled:
+		case !rec.Data.Expires.IsZero() && !rec.Data.Expires.After(tNow):
+		case !rec.Data.EnabledSince.IsZero() && !rec.Data.EnabledSince.Before(tNow):
+			// not enabled yet, the result becomes outdated when it is
+			if expires.After(rec.Data.EnabledSince) {
+				expires = rec.Data.EnabledSince.UTC()
+			}
+		default:
+			cms = append(cms, interest.ConditionMatch{
+				InterestId: rec.Id,
+				Condition:  rec.Data.Condition,
+			})
+			if !rec.Data.Expires.IsZero() && expires.After(rec.Data.Expires) {
+				expires = rec.Data.Expires.UTC()
+			}
+		}
+	}
+	s.lock.RUnlock()
+	slices.SortFunc(cms, func(a, b interest.ConditionMatch) int {
+		return cmp.Compare(a.InterestId, b.InterestId)
+	})
+	for _, cm := range cms {
+		err = consume(cm)
+		if err != nil {
+			break
+		}
+	}
+	return
+}
+
+func (s storageImpl) SearchByConditionBatch(ctx context.Context, qs []interest.QueryByConditionCursor) (batch interest.ConditionMatchBatch, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	tNow := time.Now()
+	c := storage.NewConditionMatchBatchCollector(qs, tNow.Add(s.resultTtlDefault).UTC())
+	condIds := c.CondIds()
+	cursor := c.CursorMin()
+	var recs []*interestRec
+	for _, rec := range s.recs {
+		switch {
+		case rec.Id <= cursor:
+		case rec.deleted():
+		case !slices.ContainsFunc(rec.CondIds, func(condId string) bool {
+			_, found := slices.BinarySearch(condIds, condId)
+			return found
+		}):
+		case !rec.Data.Enabled:
+		case !rec.Data.EnabledSince.IsZero() && !rec.Data.EnabledSince.Before(tNow):
+		case !rec.Data.Expires.IsZero() && !rec.Data.Expires.After(tNow):
+		default:
+			recs = append(recs, rec)
+		}
+	}
+	slices.SortFunc(recs, func(a, b *interestRec) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	for _, rec := range recs {
+		if c.Full() {
+			break
+		}
+		if c.Add(rec.Id, rec.CondIds, rec.Data.Expires) {
+			c.SetCondition(rec.Id, rec.Data.Condition)
+		}
+	}
+	batch = c.Batch()
+	return
+}
+
+func (s storageImpl) ListRevisions(ctx context.Context, id, groupId, userId string, internal bool, cursor uint64, limit uint32) (revs []interest.Revision, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	rec, found := s.recs[id]
+	switch {
+	case !found, rec.deleted():
+		err = fmt.Errorf("%w: not found, id: %s, acc: %s/%s", storage.ErrNotFound, id, groupId, userId)
+	case !internal && !rec.ownedBy(groupId, userId):
+		err = fmt.Errorf("%w: not found, id: %s, acc: %s/%s", storage.ErrNotFound, id, groupId, userId)
+	case cursor < uint64(len(rec.Revisions)):
+		revs = rec.Revisions[cursor:]
+		if limit > 0 && len(revs) > int(limit) {
+			revs = revs[:limit]
+		}
+		revs = slices.Clone(revs)
+	}
+	return
+}
+
+func (s storageImpl) ReadRevision(ctx context.Context, id, groupId, userId string, internal bool, number uint64) (rev interest.Revision, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.readRevision(id, groupId, userId, internal, number)
+}
+
+func (s storageImpl) RestoreRevision(ctx context.Context, id, groupId, userId string, internal bool, number uint64, updated time.Time) (rev interest.Revision, prev interest.Data, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	rev, err = s.readRevision(id, groupId, userId, internal, number)
+	if err == nil {
+		prev, err = s.update(ctx, id, groupId, userId, internal, rev.RestoreData(updated))
+	}
+	return
+}
+
+// readRevision should be invoked under the lock.
+func (s storageImpl) readRevision(id, groupId, userId string, internal bool, number uint64) (rev interest.Revision, err error) {
+	rec, found := s.recs[id]
+	switch {
+	case !found, rec.deleted():
+		err = fmt.Errorf("%w: not found, id: %s, acc: %s/%s", storage.ErrNotFound, id, groupId, userId)
+	case !internal && !rec.ownedBy(groupId, userId):
+		err = fmt.Errorf("%w: not found, id: %s, acc: %s/%s", storage.ErrNotFound, id, groupId, userId)
+	case number < 1 || number > uint64(len(rec.Revisions)):
+		err = fmt.Errorf("%w: revision not found, id: %s, number: %d", storage.ErrNotFound, id, number)
+	default:
+		rev = rec.Revisions[number-1]
+	}
+	return
+}
+
+func (s storageImpl) ReadChanges(ctx context.Context, after uint64, limit uint32) (changes []interest.Change, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	i, _ := slices.BinarySearchFunc(s.changes.entries, after+1, func(c interest.Change, pos uint64) int {
+		return cmp.Compare(c.Position, pos)
+	})
+	for _, c := range s.changes.entries[i:] {
+		if limit > 0 && len(changes) == int(limit) {
+			break
+		}
+		changes = append(changes, c)
+	}
+	return
+}
+
+func (s storageImpl) Usage(ctx context.Context, groupId, userId string) (u interest.Usage, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	u = s.usage(groupId, userId)
+	return
+}
+
+// usage counts the account interests those are not deleted, should be invoked under the lock.
+func (s storageImpl) usage(groupId, userId string) (u interest.Usage) {
+	for _, rec := range s.recs {
+		if !rec.deleted() && rec.ownedBy(groupId, userId) {
+			u = u.Add(interest.UsageAdded(nil, rec.Data))
+		}
+	}
+	return
+}
+
+// enforceQuotas checks the single interest write before it's applied, should be invoked under the lock, so the usage
+// after the write is the current one plus the added.
+func (s storageImpl) enforceQuotas(ctx context.Context, groupId, userId string, cond condition.Condition, a interest.Usage) (err error) {
+	return storage.EnforceQuotas(ctx, groupId, userId, []condition.Condition{cond}, a, func() (u interest.Usage, err error) {
+		u = s.usage(groupId, userId).Add(a)
+		return
+	})
+}
+
+func (s storageImpl) Count(ctx context.Context) (count int64, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for _, rec := range s.recs {
+		if !rec.deleted() {
+			count++
+		}
+	}
+	return
+}
+
+func (s storageImpl) CountUsersUnique(ctx context.Context) (count int64, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	userIds := make(map[string]bool)
+	for _, rec := range s.recs {
+		if !rec.deleted() {
+			userIds[rec.UserId] = true
+		}
+	}
+	count = int64(len(userIds))
+	return
+}
+
+// purgeDeleted removes the deleted records and the changes those retention period is over, should be invoked under
+// the write lock.
+func (s storageImpl) purgeDeleted(now time.Time) {
+	if s.retentionPeriod > 0 {
+		for id, rec := range s.recs {
+			if s.purgeable(rec, now) {
+				delete(s.recs, id)
+			}
+		}
+		s.changes.entries = slices.DeleteFunc(s.changes.entries, func(c interest.Change) bool {
+			return c.Time.Add(s.retentionPeriod).Before(now)
+		})
+	}
+}
+
+// purgeable returns true when the record is deleted and its retention period is over.
+func (s storageImpl) purgeable(rec *interestRec, now time.Time) bool {
+	return rec.deleted() && s.retentionPeriod > 0 && rec.DeletedAt.Add(s.retentionPeriod).Before(now)
+}
+
+// appendChange records the change of the interest record state, should be invoked under the write lock.
+func (s storageImpl) appendChange(t interest.ChangeType, rec *interestRec, before condition.Condition) {
+	after := rec.Data.Condition
+	if rec.deleted() {
+		after = nil
+	}
+	c := interest.NewChange(t, rec.Id, rec.GroupId, rec.UserId, before, after)
+	c.Enabled = rec.Data.Enabled
+	c.EnabledSince = rec.Data.EnabledSince
+	s.changes.position++
+	c.Position = s.changes.position
+	s.changes.entries = append(s.changes.entries, c)
+}
+
+// appendRevision records the current data snapshot made by the specified account.
+func (rec *interestRec) appendRevision(groupId, userId string) {
+	rec.Revisions = append(rec.Revisions, interest.Revision{
+		Number:  uint64(len(rec.Revisions) + 1),
+		GroupId: groupId,
+		UserId:  userId,
+		Time:    time.Now().UTC(),
+		Data:    rec.Data,
+	})
+}
+
+func (rec *interestRec) deleted() bool {
+	return !rec.DeletedAt.IsZero()
+}
+
+func (rec *interestRec) ownedBy(groupId, userId string) bool {
+	return rec.GroupId == groupId && rec.UserId == userId
+}
+
+func (rec *interestRec) matchesQuery(q interest.Query) (matches bool) {
+	switch {
+	case q.All:
+		matches = true
+	case q.PrivateOnly:
+		matches = rec.ownedBy(q.GroupId, q.UserId) && !rec.Data.Public
+	case q.IncludePublic:
+		matches = rec.ownedBy(q.GroupId, q.UserId) || rec.Data.Public
+	default:
+		matches = rec.ownedBy(q.GroupId, q.UserId)
+	}
+	return
+}
+
+func cursorDirection(o interest.Order) (d int) {
+	switch o {
+	case interest.OrderDesc:
+		d = -1
+	default:
+		d = 1
+	}
+	return
+}
+
+func compareRecs(a, b *interestRec, sort interest.Sort) (result int) {
+	switch sort {
+	case interest.SortFollowers:
+		result = cmp.Compare(a.Data.Followers, b.Data.Followers)
+	case interest.SortTimeCreated:
+		result = a.Data.Created.Compare(b.Data.Created)
+	}
+	if result == 0 {
+		result = cmp.Compare(a.Id, b.Id)
+	}
+	return
+}
+
+func compareCursor(rec *interestRec, sort interest.Sort, cursor interest.Cursor) (result int) {
+	switch sort {
+	case interest.SortFollowers:
+		result = cmp.Compare(rec.Data.Followers, cursor.Followers)
+	case interest.SortTimeCreated:
+		result = rec.Data.Created.Compare(cursor.CreatedAt)
+	}
+	if result == 0 {
+		result = cmp.Compare(rec.Id, cursor.Id)
+	}
+	return
+}
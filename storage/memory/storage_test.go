@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"context"
+	"github.com/awakari/interests/config"
+	"github.com/awakari/interests/model/condition"
+	"github.com/awakari/interests/model/interest"
+	"github.com/awakari/interests/storage"
+	"github.com/awakari/interests/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newTestStorage(resultTtl, retention time.Duration) storage.Storage {
+	dbCfg := config.DbConfig{
+		Type:      config.DbTypeMemory,
+		ResultTtl: resultTtl,
+	}
+	dbCfg.Table.Retention = retention
+	return NewStorage(dbCfg)
+}
+
+func TestStorageImpl_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, resultTtl time.Duration) storage.Storage {
+		s := newTestStorage(resultTtl, time.Hour)
+		t.Cleanup(func() {
+			require.Nil(t, s.Close())
+		})
+		return s
+	})
+}
+
+func TestStorageImpl_Create_RetentionExpired(t *testing.T) {
+	s := newTestStorage(time.Minute, time.Nanosecond)
+	ctx := context.TODO()
+	cond0 := condition.NewSemanticCondition(condition.NewCondition(false), "cond0", "lorem ipsum", 0.5)
+	err := s.Create(ctx, "interest0", "group0", "user0", interest.Data{
+		Condition: cond0,
+	})
+	require.Nil(t, err)
+	_, err = s.Delete(ctx, "interest0", "group0", "user0")
+	require.Nil(t, err)
+	time.Sleep(time.Millisecond)
+	err = s.Create(ctx, "interest0", "group1", "user1", interest.Data{
+		Condition: cond0,
+	})
+	assert.Nil(t, err)
+}
+
+func TestStorageImpl_Purge_RetentionExpired(t *testing.T) {
+	s := newTestStorage(time.Minute, time.Nanosecond)
+	ctx := context.TODO()
+	cond0 := condition.NewSemanticCondition(condition.NewCondition(false), "cond0", "lorem ipsum", 0.5)
+	err := s.Create(ctx, "interest0", "group0", "user0", interest.Data{
+		Condition: cond0,
+	})
+	require.Nil(t, err)
+	p, err := s.Purge(ctx, "group0", "user0", "service0")
+	require.Nil(t, err)
+	time.Sleep(time.Millisecond)
+	// the next write removes the changes those retention period is over, including the purge ones
+	err = s.Create(ctx, "interest1", "group1", "user1", interest.Data{
+		Condition: cond0,
+	})
+	require.Nil(t, err)
+	changes, err := s.ReadChanges(ctx, 0, 0)
+	require.Nil(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, interest.ChangeCreated, changes[0].Type)
+	// the purge audit survives
+	audits, err := s.ListPurges(ctx, "group0", "user0")
+	require.Nil(t, err)
+	require.Len(t, audits, 1)
+	assert.Equal(t, "service0", audits[0].Actor)
+	assert.Equal(t, p, audits[0].Purged)
+}
@@ -0,0 +1,107 @@
+package mongo
+
+import (
+	"github.com/awakari/interests/model/condition"
+	"github.com/awakari/interests/model/interest"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+type changeWrite struct {
+	Position uint64 `bson:"position"`
+
+	Type interest.ChangeType `bson:"type"`
+
+	InterestId string `bson:"interestId"`
+
+	GroupId string `bson:"groupId"`
+
+	UserId string `bson:"userId"`
+
+	Time time.Time `bson:"time"`
+
+	Enabled bool `bson:"enabled"`
+
+	EnabledSince time.Time `bson:"enabledSince,omitempty"`
+
+	// Before is missing when the interest is created.
+	Before Condition `bson:"before,omitempty"`
+
+	// After is missing when the interest is deleted.
+	After Condition `bson:"after,omitempty"`
+}
+
+// intermediate read result that contains the conditions not decoded yet
+type changeRec struct {
+	Position uint64 `bson:"position"`
+
+	Type interest.ChangeType `bson:"type"`
+
+	InterestId string `bson:"interestId"`
+
+	GroupId string `bson:"groupId"`
+
+	UserId string `bson:"userId"`
+
+	Time time.Time `bson:"time"`
+
+	Enabled bool `bson:"enabled"`
+
+	EnabledSince time.Time `bson:"enabledSince,omitempty"`
+
+	RawBefore bson.M `bson:"before,omitempty"`
+
+	RawAfter bson.M `bson:"after,omitempty"`
+}
+
+// positionRec is the single document counting the changes positions.
+type positionRec struct {
+	Value uint64 `bson:"value"`
+}
+
+const attrChangePosition = "position"
+const attrChangeInterestId = "interestId"
+const attrChangeTime = "time"
+const attrPositionValue = "value"
+const positionRecId = "changes"
+
+func encodeChange(src interest.Change) (dst changeWrite) {
+	dst.Position = src.Position
+	dst.Type = src.Type
+	dst.InterestId = src.InterestId
+	dst.GroupId = src.GroupId
+	dst.UserId = src.UserId
+	dst.Time = src.Time.UTC()
+	dst.Enabled = src.Enabled
+	dst.EnabledSince = src.EnabledSince.UTC()
+	dst.Before, _ = encodeCondition(src.Before)
+	dst.After, _ = encodeCondition(src.After)
+	return
+}
+
+func (rec changeRec) decodeChange(c *interest.Change) (err error) {
+	var before, after condition.Condition
+	before, err = decodeNullCondition(rec.RawBefore)
+	if err == nil {
+		after, err = decodeNullCondition(rec.RawAfter)
+	}
+	if err == nil {
+		*c = interest.NewChange(rec.Type, rec.InterestId, rec.GroupId, rec.UserId, before, after)
+		c.Position = rec.Position
+		c.Time = rec.Time.UTC()
+		c.Enabled = rec.Enabled
+		c.EnabledSince = rec.EnabledSince.UTC()
+	}
+	return
+}
+
+func decodeNullCondition(raw bson.M) (cond condition.Condition, err error) {
+	if raw != nil {
+		var condRec Condition
+		condRec, err = decodeRawCondition(raw)
+		if err == nil {
+			cond = decodeCondition(condRec)
+		}
+	}
+	return
+}
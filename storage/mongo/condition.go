@@ -29,7 +29,14 @@ func encodeCondition(src condition.Condition) (dst Condition, ids []string) {
 		dst, ids = encodeNumCondition(c)
 	case condition.SemanticCondition:
 		dst, ids = encodeSemCondition(c)
-
+	case condition.RegexCondition:
+		dst, ids = encodeRegexCondition(c)
+	case condition.RangeCondition:
+		dst, ids = encodeRangeCondition(c)
+	case condition.SetCondition:
+		dst, ids = encodeSetCondition(c)
+	case condition.ExistsCondition:
+		dst, ids = encodeExistsCondition(c)
 	}
 	return
 }
@@ -47,6 +54,11 @@ func decodeRawCondition(raw bson.M) (result Condition, err error) {
 		term, isText := raw[textConditionAttrTerm].(string)
 		num, isNum := raw[numConditionAttrVal].(float64)
 		sem, isSem := raw[semConditionAttrQuery].(string)
+		pattern, isRegex := raw[regexConditionAttrPattern].(string)
+		lower, isRange := raw[rangeConditionAttrLower].(float64)
+		_, hasTexts := raw[setConditionAttrTexts]
+		_, hasNumbers := raw[setConditionAttrNumbers]
+		isExists, _ := raw[existsConditionAttrExists].(bool)
 		switch {
 		case isGroup:
 			result, err = decodeRawGroupCondition(baseCond, group, raw)
@@ -56,6 +68,14 @@ func decodeRawCondition(raw bson.M) (result Condition, err error) {
 			result, err = decodeNumCondition(baseCond, num, raw)
 		case isSem:
 			result, err = decodeSemCondition(baseCond, sem, raw)
+		case isRegex:
+			result, err = decodeRegexCondition(baseCond, pattern, raw)
+		case isRange:
+			result, err = decodeRangeCondition(baseCond, lower, raw)
+		case hasTexts || hasNumbers:
+			result, err = decodeSetCondition(baseCond, raw)
+		case isExists:
+			result, err = decodeExistsCondition(baseCond, raw)
 		default:
 			err = fmt.Errorf("%w: undefined condition type: %v", storage.ErrInternal, raw)
 		}
@@ -84,6 +104,30 @@ func decodeCondition(src Condition) (dst condition.Condition) {
 	case semCondition:
 		dstBase := condition.NewCondition(c.Base.Not)
 		dst = condition.NewSemanticCondition(dstBase, c.Id, c.Query, c.SimilarityMin)
+	case regexCondition:
+		dstBase := condition.NewCondition(c.Base.Not)
+		dstKey := condition.NewKeyCondition(dstBase, c.Id, c.Key)
+		dst = condition.NewRegexCondition(dstKey, c.Pattern)
+	case rangeCondition:
+		dstBase := condition.NewCondition(c.Base.Not)
+		dstKey := condition.NewKeyCondition(dstBase, c.Id, c.Key)
+		lower := condition.Bound{
+			Value:     c.Lower,
+			Inclusive: c.LowerInclusive,
+		}
+		upper := condition.Bound{
+			Value:     c.Upper,
+			Inclusive: c.UpperInclusive,
+		}
+		dst = condition.NewRangeCondition(dstKey, lower, upper)
+	case setCondition:
+		dstBase := condition.NewCondition(c.Base.Not)
+		dstKey := condition.NewKeyCondition(dstBase, c.Id, c.Key)
+		dst = condition.NewSetCondition(dstKey, c.Texts, c.Numbers)
+	case existsCondition:
+		dstBase := condition.NewCondition(c.Base.Not)
+		dstKey := condition.NewKeyCondition(dstBase, c.Id, c.Key)
+		dst = condition.NewExistsCondition(dstKey)
 	}
 	return dst
 }
@@ -5,6 +5,7 @@ import (
 	"github.com/awakari/interests/storage"
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
+	"slices"
 	"testing"
 )
 
@@ -36,6 +37,87 @@ func Test_encodeCondition(t *testing.T) {
 				"cond0",
 			},
 		},
+		"single regex condition": {
+			src: condition.NewRegexCondition(
+				condition.NewKeyCondition(
+					condition.NewCondition(true), "cond0",
+					"key0",
+				),
+				"^go(lang)?$",
+			),
+			dst: regexCondition{
+				Id:      "cond0",
+				Key:     "key0",
+				Pattern: "^go(lang)?$",
+				Base: ConditionBase{
+					Not: true,
+				},
+			},
+			condIds: []string{
+				"cond0",
+			},
+		},
+		"single range condition": {
+			src: condition.NewRangeCondition(
+				condition.NewKeyCondition(
+					condition.NewCondition(false), "cond0",
+					"key0",
+				),
+				condition.Bound{Value: 10, Inclusive: true},
+				condition.Bound{Value: 100},
+			),
+			dst: rangeCondition{
+				Id:             "cond0",
+				Key:            "key0",
+				Lower:          10,
+				LowerInclusive: true,
+				Upper:          100,
+			},
+			condIds: []string{
+				"cond0",
+			},
+		},
+		"single set condition": {
+			src: condition.NewSetCondition(
+				condition.NewKeyCondition(
+					condition.NewCondition(true), "cond0",
+					"key0",
+				),
+				[]string{"a", "b"},
+				[]float64{1},
+			),
+			dst: setCondition{
+				Id:      "cond0",
+				Key:     "key0",
+				Texts:   []string{"a", "b"},
+				Numbers: []float64{1},
+				Base: ConditionBase{
+					Not: true,
+				},
+			},
+			condIds: []string{
+				"cond0",
+			},
+		},
+		"single exists condition": {
+			src: condition.NewExistsCondition(
+				condition.NewKeyCondition(
+					condition.NewCondition(true), "cond0",
+					"key0",
+				),
+			),
+			dst: existsCondition{
+				Id:     "cond0",
+				Key:    "key0",
+				Exists: true,
+				Base: ConditionBase{
+					Not: true,
+				},
+			},
+			condIds: []string{
+				"cond0",
+			},
+		},
 		"group condition": {
 			src: condition.NewGroupCondition(
 				condition.NewCondition(false),
@@ -133,6 +215,21 @@ func conditionRecsEqual(a, b Condition) (equal bool) {
 		if equal {
 			equal = at.Term == bk.Term
 		}
+	case regexCondition:
+		equal = at == b
+	case rangeCondition:
+		equal = at == b
+	case existsCondition:
+		equal = at == b
+	case setCondition:
+		var bs setCondition
+		bs, equal = b.(setCondition)
+		if equal {
+			equal = at.Base == bs.Base && at.Id == bs.Id && at.Key == bs.Key
+		}
+		if equal {
+			equal = slices.Equal(at.Texts, bs.Texts) && slices.Equal(at.Numbers, bs.Numbers)
+		}
 	}
 	return
 }
@@ -179,6 +276,79 @@ func Test_decodeRawCondition(t *testing.T) {
 				},
 			},
 		},
+		"regex condition ok": {
+			raw: bson.M{
+				"base": bson.M{
+					"not": true,
+				},
+				"id":      "cond0",
+				"key":     "k0",
+				"pattern": "^p0$",
+			},
+			out: regexCondition{
+				Id:      "cond0",
+				Key:     "k0",
+				Pattern: "^p0$",
+				Base: ConditionBase{
+					Not: true,
+				},
+			},
+		},
+		"range condition ok": {
+			raw: bson.M{
+				"base": bson.M{
+					"not": false,
+				},
+				"id":        "cond0",
+				"key":       "k0",
+				"lower":     1.0,
+				"upper":     2.0,
+				"upperIncl": true,
+			},
+			out: rangeCondition{
+				Id:             "cond0",
+				Key:            "k0",
+				Lower:          1,
+				Upper:          2,
+				UpperInclusive: true,
+			},
+		},
+		"set condition ok": {
+			raw: bson.M{
+				"base": bson.M{
+					"not": true,
+				},
+				"id":      "cond0",
+				"key":     "k0",
+				"numbers": bson.A{1.0, 2.0},
+			},
+			out: setCondition{
+				Id:      "cond0",
+				Key:     "k0",
+				Numbers: []float64{1, 2},
+				Base: ConditionBase{
+					Not: true,
+				},
+			},
+		},
+		"exists condition ok": {
+			raw: bson.M{
+				"base": bson.M{
+					"not": true,
+				},
+				"id":     "cond0",
+				"key":    "k0",
+				"exists": true,
+			},
+			out: existsCondition{
+				Id:     "cond0",
+				Key:    "k0",
+				Exists: true,
+				Base: ConditionBase{
+					Not: true,
+				},
+			},
+		},
 		"num condition ok": {
 			raw: bson.M{
 				"base": bson.M{
@@ -332,6 +502,71 @@ func Test_decodeCondition(t *testing.T) {
 				},
 			},
 		},
+		"single regex condition": {
+			dst: condition.NewRegexCondition(
+				condition.NewKeyCondition(
+					condition.NewCondition(true),
+					"cond0", "key0",
+				),
+				"^go(lang)?$",
+			),
+			src: regexCondition{
+				Id:      "cond0",
+				Key:     "key0",
+				Pattern: "^go(lang)?$",
+				Base: ConditionBase{
+					Not: true,
+				},
+			},
+		},
+		"single range condition": {
+			dst: condition.NewRangeCondition(
+				condition.NewKeyCondition(
+					condition.NewCondition(true),
+					"cond0", "key0",
+				),
+				condition.Bound{Value: -1},
+				condition.Bound{Value: 1, Inclusive: true},
+			),
+			src: rangeCondition{
+				Id:             "cond0",
+				Key:            "key0",
+				Lower:          -1,
+				Upper:          1,
+				UpperInclusive: true,
+				Base: ConditionBase{
+					Not: true,
+				},
+			},
+		},
+		"single set condition": {
+			dst: condition.NewSetCondition(
+				condition.NewKeyCondition(
+					condition.NewCondition(false),
+					"cond0", "key0",
+				),
+				[]string{"a"},
+				nil,
+			),
+			src: setCondition{
+				Id:    "cond0",
+				Key:   "key0",
+				Texts: []string{"a"},
+			},
+		},
+		"single exists condition": {
+			dst: condition.NewExistsCondition(
+				condition.NewKeyCondition(
+					condition.NewCondition(false),
+					"cond0", "key0",
+				),
+			),
+			src: existsCondition{
+				Id:     "cond0",
+				Key:    "key0",
+				Exists: true,
+			},
+		},
 		"single num condition": {
 			dst: condition.NewNumberCondition(
 				condition.NewKeyCondition(
@@ -0,0 +1,51 @@
+package mongo
+
+import (
+	"fmt"
+	"github.com/awakari/interests/model/condition"
+	"github.com/awakari/interests/storage"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// existsCondition has no criteria besides the key, so the constant "exists" attribute distinguishes it from the other
+// conditions.
+type existsCondition struct {
+	Base   ConditionBase `bson:"base"`
+	Id     string        `bson:"id"`
+	Key    string        `bson:"key"`
+	Exists bool          `bson:"exists"`
+}
+
+const existsConditionAttrId = "id"
+const existsConditionAttrKey = "key"
+const existsConditionAttrExists = "exists"
+
+var _ Condition = (*existsCondition)(nil)
+
+func encodeExistsCondition(src condition.ExistsCondition) (dst existsCondition, ids []string) {
+	id := src.GetId()
+	ids = append(ids, id)
+	dst = existsCondition{
+		Base: ConditionBase{
+			Not: src.IsNot(),
+		},
+		Id:     id,
+		Key:    src.GetKey(),
+		Exists: true,
+	}
+	return
+}
+
+func decodeExistsCondition(baseCond ConditionBase, raw bson.M) (ec existsCondition, err error) {
+	ec.Base = baseCond
+	ec.Exists = true
+	var ok bool
+	ec.Id, ok = raw[existsConditionAttrId].(string)
+	if ok {
+		ec.Key, ok = raw[existsConditionAttrKey].(string)
+	}
+	if !ok {
+		err = fmt.Errorf("%w: failed to decode the exists condition %v", storage.ErrInternal, raw)
+	}
+	return
+}
@@ -0,0 +1,55 @@
+package mongo
+
+import (
+	"github.com/awakari/interests/storage"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func Test_decodeExistsCondition(t *testing.T) {
+	cases := map[string]struct {
+		base ConditionBase
+		raw  bson.M
+		out  existsCondition
+		err  error
+	}{
+		"ok": {
+			base: ConditionBase{
+				Not: true,
+			},
+			raw: bson.M{
+				existsConditionAttrId:     "cond0",
+				existsConditionAttrKey:    "key0",
+				existsConditionAttrExists: true,
+			},
+			out: existsCondition{
+				Base: ConditionBase{
+					Not: true,
+				},
+				Id:     "cond0",
+				Key:    "key0",
+				Exists: true,
+			},
+		},
+		"fails due to missing \"key\" attribute": {
+			raw: bson.M{
+				existsConditionAttrId:     "cond0",
+				existsConditionAttrExists: true,
+			},
+			err: storage.ErrInternal,
+		},
+	}
+	//
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			out, err := decodeExistsCondition(c.base, c.raw)
+			if c.err == nil {
+				assert.Nil(t, err)
+				assert.Equal(t, c.out, out)
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+}
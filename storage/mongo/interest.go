@@ -65,6 +65,8 @@ type interestRec struct {
 	// CondIds contains a flat list of all condition ids.
 	// The CondIds field is necessary to support the interests search by a condition id.
 	CondIds []string `bson:"condIds"`
+
+	DeletedAt time.Time `bson:"deletedAt,omitempty"`
 }
 
 const attrId = "id"
@@ -118,3 +120,15 @@ func (rec interestRec) decodeInterestConditionMatch(cm *interest.ConditionMatch)
 	}
 	return
 }
+
+// decodeChange returns the change keeping the interest condition and state as is.
+func (rec interestRec) decodeChange(t interest.ChangeType) (c interest.Change, err error) {
+	var sd interest.Data
+	err = rec.decodeInterestData(&sd)
+	if err == nil {
+		c = interest.NewChange(t, rec.Id, rec.GroupId, rec.UserId, sd.Condition, sd.Condition)
+		c.Enabled = rec.Enabled
+		c.EnabledSince = rec.EnabledSince.UTC()
+	}
+	return
+}
@@ -0,0 +1,75 @@
+package mongo
+
+import (
+	"github.com/awakari/interests/model/interest"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+// purgeRec is the purge audit record, the collection has no TTL index unlike the changes.
+type purgeRec struct {
+	GroupId string `bson:"groupId"`
+
+	UserId string `bson:"userId"`
+
+	Time time.Time `bson:"time"`
+
+	Actor string `bson:"actor"`
+
+	Count int64 `bson:"count"`
+
+	Deleted int64 `bson:"deleted"`
+
+	CondIds []string `bson:"condIds,omitempty"`
+}
+
+const attrPurgeGroupId = "groupId"
+const attrPurgeUserId = "userId"
+const attrPurgeTime = "time"
+
+var projPurges = bson.D{
+	{
+		Key:   attrPurgeGroupId,
+		Value: 1,
+	},
+	{
+		Key:   attrPurgeUserId,
+		Value: 1,
+	},
+	{
+		Key:   attrPurgeTime,
+		Value: 1,
+	},
+}
+
+var sortPurges = bson.D{
+	{
+		Key:   attrPurgeTime,
+		Value: 1,
+	},
+	{
+		Key:   "_id",
+		Value: 1,
+	},
+}
+
+func encodePurgeAudit(src interest.PurgeAudit) (dst purgeRec) {
+	dst.GroupId = src.GroupId
+	dst.UserId = src.UserId
+	dst.Time = src.Time.UTC()
+	dst.Actor = src.Actor
+	dst.Count = src.Count
+	dst.Deleted = src.Deleted
+	dst.CondIds = src.CondIds
+	return
+}
+
+func (rec purgeRec) decodePurgeAudit(a *interest.PurgeAudit) {
+	a.GroupId = rec.GroupId
+	a.UserId = rec.UserId
+	a.Time = rec.Time.UTC()
+	a.Actor = rec.Actor
+	a.Count = rec.Count
+	a.Deleted = rec.Deleted
+	a.CondIds = rec.CondIds
+}
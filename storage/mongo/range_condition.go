@@ -0,0 +1,66 @@
+package mongo
+
+import (
+	"fmt"
+	"github.com/awakari/interests/model/condition"
+	"github.com/awakari/interests/storage"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type rangeCondition struct {
+	Base           ConditionBase `bson:"base"`
+	Id             string        `bson:"id"`
+	Key            string        `bson:"key"`
+	Lower          float64       `bson:"lower"`
+	LowerInclusive bool          `bson:"lowerIncl"`
+	Upper          float64       `bson:"upper"`
+	UpperInclusive bool          `bson:"upperIncl"`
+}
+
+const rangeConditionAttrId = "id"
+const rangeConditionAttrKey = "key"
+const rangeConditionAttrLower = "lower"
+const rangeConditionAttrLowerInclusive = "lowerIncl"
+const rangeConditionAttrUpper = "upper"
+const rangeConditionAttrUpperInclusive = "upperIncl"
+
+var _ Condition = (*rangeCondition)(nil)
+
+func encodeRangeCondition(src condition.RangeCondition) (dst rangeCondition, ids []string) {
+	id := src.GetId()
+	ids = append(ids, id)
+	lower, upper := src.GetLower(), src.GetUpper()
+	dst = rangeCondition{
+		Base: ConditionBase{
+			Not: src.IsNot(),
+		},
+		Id:             id,
+		Key:            src.GetKey(),
+		Lower:          lower.Value,
+		LowerInclusive: lower.Inclusive,
+		Upper:          upper.Value,
+		UpperInclusive: upper.Inclusive,
+	}
+	return
+}
+
+func decodeRangeCondition(baseCond ConditionBase, lower float64, raw bson.M) (rc rangeCondition, err error) {
+	rc.Base = baseCond
+	rc.Lower = lower
+	var ok bool
+	rc.Id, ok = raw[rangeConditionAttrId].(string)
+	if ok {
+		rc.Key, ok = raw[rangeConditionAttrKey].(string)
+	}
+	if ok {
+		rc.Upper, ok = raw[rangeConditionAttrUpper].(float64)
+	}
+	if ok {
+		rc.LowerInclusive, _ = raw[rangeConditionAttrLowerInclusive].(bool)
+		rc.UpperInclusive, _ = raw[rangeConditionAttrUpperInclusive].(bool)
+	}
+	if !ok {
+		err = fmt.Errorf("%w: failed to decode the range condition %v", storage.ErrInternal, raw)
+	}
+	return
+}
@@ -0,0 +1,68 @@
+package mongo
+
+import (
+	"github.com/awakari/interests/storage"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func Test_decodeRangeCondition(t *testing.T) {
+	cases := map[string]struct {
+		base ConditionBase
+		raw  bson.M
+		out  rangeCondition
+		err  error
+	}{
+		"ok": {
+			base: ConditionBase{
+				Not: true,
+			},
+			raw: bson.M{
+				rangeConditionAttrId:             "cond0",
+				rangeConditionAttrKey:            "key0",
+				rangeConditionAttrLower:          -1.5,
+				rangeConditionAttrLowerInclusive: true,
+				rangeConditionAttrUpper:          42.0,
+			},
+			out: rangeCondition{
+				Base: ConditionBase{
+					Not: true,
+				},
+				Id:             "cond0",
+				Key:            "key0",
+				Lower:          -1.5,
+				LowerInclusive: true,
+				Upper:          42,
+			},
+		},
+		"fails due to missing \"key\" attribute": {
+			raw: bson.M{
+				rangeConditionAttrId:    "cond0",
+				rangeConditionAttrLower: -1.5,
+				rangeConditionAttrUpper: 42.0,
+			},
+			err: storage.ErrInternal,
+		},
+		"fails due to missing \"upper\" attribute": {
+			raw: bson.M{
+				rangeConditionAttrId:    "cond0",
+				rangeConditionAttrKey:   "key0",
+				rangeConditionAttrLower: -1.5,
+			},
+			err: storage.ErrInternal,
+		},
+	}
+	//
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			out, err := decodeRangeCondition(c.base, c.raw[rangeConditionAttrLower].(float64), c.raw)
+			if c.err == nil {
+				assert.Nil(t, err)
+				assert.Equal(t, c.out, out)
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+}
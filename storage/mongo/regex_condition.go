@@ -0,0 +1,49 @@
+package mongo
+
+import (
+	"fmt"
+	"github.com/awakari/interests/model/condition"
+	"github.com/awakari/interests/storage"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type regexCondition struct {
+	Base    ConditionBase `bson:"base"`
+	Id      string        `bson:"id"`
+	Key     string        `bson:"key"`
+	Pattern string        `bson:"pattern"`
+}
+
+const regexConditionAttrId = "id"
+const regexConditionAttrKey = "key"
+const regexConditionAttrPattern = "pattern"
+
+var _ Condition = (*regexCondition)(nil)
+
+func encodeRegexCondition(src condition.RegexCondition) (dst regexCondition, ids []string) {
+	id := src.GetId()
+	ids = append(ids, id)
+	dst = regexCondition{
+		Base: ConditionBase{
+			Not: src.IsNot(),
+		},
+		Id:      id,
+		Key:     src.GetKey(),
+		Pattern: src.GetPattern(),
+	}
+	return
+}
+
+func decodeRegexCondition(baseCond ConditionBase, pattern string, raw bson.M) (rc regexCondition, err error) {
+	rc.Base = baseCond
+	rc.Pattern = pattern
+	var ok bool
+	rc.Id, ok = raw[regexConditionAttrId].(string)
+	if ok {
+		rc.Key, ok = raw[regexConditionAttrKey].(string)
+	}
+	if !ok {
+		err = fmt.Errorf("%w: failed to decode the regex condition %v", storage.ErrInternal, raw)
+	}
+	return
+}
@@ -0,0 +1,62 @@
+package mongo
+
+import (
+	"github.com/awakari/interests/storage"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func Test_decodeRegexCondition(t *testing.T) {
+	cases := map[string]struct {
+		base ConditionBase
+		raw  bson.M
+		out  regexCondition
+		err  error
+	}{
+		"ok": {
+			base: ConditionBase{
+				Not: true,
+			},
+			raw: bson.M{
+				regexConditionAttrId:      "cond0",
+				regexConditionAttrKey:     "key0",
+				regexConditionAttrPattern: "^go(lang)?$",
+			},
+			out: regexCondition{
+				Base: ConditionBase{
+					Not: true,
+				},
+				Id:      "cond0",
+				Key:     "key0",
+				Pattern: "^go(lang)?$",
+			},
+		},
+		"fails due to missing \"id\" attribute": {
+			raw: bson.M{
+				regexConditionAttrKey:     "key0",
+				regexConditionAttrPattern: "^go(lang)?$",
+			},
+			err: storage.ErrInternal,
+		},
+		"fails due to missing \"key\" attribute": {
+			raw: bson.M{
+				regexConditionAttrId:      "cond0",
+				regexConditionAttrPattern: "^go(lang)?$",
+			},
+			err: storage.ErrInternal,
+		},
+	}
+	//
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			out, err := decodeRegexCondition(c.base, c.raw[regexConditionAttrPattern].(string), c.raw)
+			if c.err == nil {
+				assert.Nil(t, err)
+				assert.Equal(t, c.out, out)
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+}
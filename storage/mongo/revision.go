@@ -0,0 +1,90 @@
+package mongo
+
+import (
+	"github.com/awakari/interests/model/interest"
+	"time"
+)
+
+type revisionWrite struct {
+	InterestId string `bson:"interestId"`
+
+	Number uint64 `bson:"number"`
+
+	// GroupId and UserId identify the account made the revision.
+	GroupId string `bson:"groupId"`
+
+	UserId string `bson:"userId"`
+
+	Time time.Time `bson:"time"`
+
+	Data revisionDataWrite `bson:"data"`
+}
+
+type revisionDataWrite struct {
+	Description string `bson:"descr"`
+
+	Enabled bool `bson:"enabled"`
+
+	EnabledSince time.Time `bson:"enabledSince,omitempty"`
+
+	Expires time.Time `bson:"expires,omitempty"`
+
+	Created time.Time `bson:"created,omitempty"`
+
+	Updated time.Time `bson:"updated,omitempty"`
+
+	Result time.Time `bson:"result,omitempty"`
+
+	Public bool `bson:"public,omitempty"`
+
+	Followers int64 `bson:"followers"`
+
+	Condition Condition `bson:"cond"`
+}
+
+// intermediate read result that contains the data condition not decoded yet
+type revisionRec struct {
+	Number uint64 `bson:"number"`
+
+	GroupId string `bson:"groupId"`
+
+	UserId string `bson:"userId"`
+
+	Time time.Time `bson:"time"`
+
+	// Data has the same attributes as the interest, except the owner and the condition ids.
+	Data interestRec `bson:"data"`
+}
+
+const attrRevisionInterestId = "interestId"
+const attrRevisionNumber = "number"
+
+func encodeRevision(src interest.Revision, interestId string) (dst revisionWrite) {
+	dst.InterestId = interestId
+	dst.Number = src.Number
+	dst.GroupId = src.GroupId
+	dst.UserId = src.UserId
+	dst.Time = src.Time.UTC()
+	dst.Data = revisionDataWrite{
+		Description:  src.Data.Description,
+		Enabled:      src.Data.Enabled,
+		EnabledSince: src.Data.EnabledSince.UTC(),
+		Expires:      src.Data.Expires.UTC(),
+		Created:      src.Data.Created.UTC(),
+		Updated:      src.Data.Updated.UTC(),
+		Result:       src.Data.Result.UTC(),
+		Public:       src.Data.Public,
+		Followers:    src.Data.Followers,
+	}
+	dst.Data.Condition, _ = encodeCondition(src.Data.Condition)
+	return
+}
+
+func (rec revisionRec) decodeRevision(rev *interest.Revision) (err error) {
+	rev.Number = rec.Number
+	rev.GroupId = rec.GroupId
+	rev.UserId = rec.UserId
+	rev.Time = rec.Time.UTC()
+	err = rec.Data.decodeInterestData(&rev.Data)
+	return
+}
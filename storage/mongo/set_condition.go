@@ -0,0 +1,74 @@
+package mongo
+
+import (
+	"fmt"
+	"github.com/awakari/interests/model/condition"
+	"github.com/awakari/interests/storage"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// setCondition omits the empty list of values, so the set of only texts or only numbers is stored without the other one.
+type setCondition struct {
+	Base    ConditionBase `bson:"base"`
+	Id      string        `bson:"id"`
+	Key     string        `bson:"key"`
+	Texts   []string      `bson:"texts,omitempty"`
+	Numbers []float64     `bson:"numbers,omitempty"`
+}
+
+const setConditionAttrId = "id"
+const setConditionAttrKey = "key"
+const setConditionAttrTexts = "texts"
+const setConditionAttrNumbers = "numbers"
+
+var _ Condition = (*setCondition)(nil)
+
+func encodeSetCondition(src condition.SetCondition) (dst setCondition, ids []string) {
+	id := src.GetId()
+	ids = append(ids, id)
+	dst = setCondition{
+		Base: ConditionBase{
+			Not: src.IsNot(),
+		},
+		Id:      id,
+		Key:     src.GetKey(),
+		Texts:   src.GetTexts(),
+		Numbers: src.GetNumbers(),
+	}
+	return
+}
+
+func decodeSetCondition(baseCond ConditionBase, raw bson.M) (sc setCondition, err error) {
+	sc.Base = baseCond
+	var ok bool
+	sc.Id, ok = raw[setConditionAttrId].(string)
+	if ok {
+		sc.Key, ok = raw[setConditionAttrKey].(string)
+	}
+	if ok {
+		texts, _ := raw[setConditionAttrTexts].(bson.A)
+		for _, v := range texts {
+			var text string
+			text, ok = v.(string)
+			if !ok {
+				break
+			}
+			sc.Texts = append(sc.Texts, text)
+		}
+	}
+	if ok {
+		numbers, _ := raw[setConditionAttrNumbers].(bson.A)
+		for _, v := range numbers {
+			var num float64
+			num, ok = v.(float64)
+			if !ok {
+				break
+			}
+			sc.Numbers = append(sc.Numbers, num)
+		}
+	}
+	if !ok {
+		err = fmt.Errorf("%w: failed to decode the set condition %v", storage.ErrInternal, raw)
+	}
+	return
+}
@@ -0,0 +1,85 @@
+package mongo
+
+import (
+	"github.com/awakari/interests/storage"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func Test_decodeSetCondition(t *testing.T) {
+	cases := map[string]struct {
+		base ConditionBase
+		raw  bson.M
+		out  setCondition
+		err  error
+	}{
+		"ok": {
+			base: ConditionBase{
+				Not: true,
+			},
+			raw: bson.M{
+				setConditionAttrId:      "cond0",
+				setConditionAttrKey:     "key0",
+				setConditionAttrTexts:   bson.A{"a", "b"},
+				setConditionAttrNumbers: bson.A{1.5},
+			},
+			out: setCondition{
+				Base: ConditionBase{
+					Not: true,
+				},
+				Id:      "cond0",
+				Key:     "key0",
+				Texts:   []string{"a", "b"},
+				Numbers: []float64{1.5},
+			},
+		},
+		"ok without texts": {
+			raw: bson.M{
+				setConditionAttrId:      "cond0",
+				setConditionAttrKey:     "key0",
+				setConditionAttrNumbers: bson.A{1.5},
+			},
+			out: setCondition{
+				Id:      "cond0",
+				Key:     "key0",
+				Numbers: []float64{1.5},
+			},
+		},
+		"fails due to missing \"key\" attribute": {
+			raw: bson.M{
+				setConditionAttrId:    "cond0",
+				setConditionAttrTexts: bson.A{"a"},
+			},
+			err: storage.ErrInternal,
+		},
+		"fails due to non text value": {
+			raw: bson.M{
+				setConditionAttrId:    "cond0",
+				setConditionAttrKey:   "key0",
+				setConditionAttrTexts: bson.A{"a", 1.5},
+			},
+			err: storage.ErrInternal,
+		},
+		"fails due to non number value": {
+			raw: bson.M{
+				setConditionAttrId:      "cond0",
+				setConditionAttrKey:     "key0",
+				setConditionAttrNumbers: bson.A{"a"},
+			},
+			err: storage.ErrInternal,
+		},
+	}
+	//
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			out, err := decodeSetCondition(c.base, c.raw)
+			if c.err == nil {
+				assert.Nil(t, err)
+				assert.Equal(t, c.out, out)
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+}
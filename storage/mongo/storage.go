@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/awakari/interests/config"
+	"github.com/awakari/interests/model/condition"
 	"github.com/awakari/interests/model/interest"
 	"github.com/awakari/interests/storage"
 	"go.mongodb.org/mongo-driver/bson"
@@ -18,11 +19,22 @@ type storageImpl struct {
 	conn             *mongo.Client
 	db               *mongo.Database
 	coll             *mongo.Collection
+	collChanges      *mongo.Collection
+	collPositions    *mongo.Collection
+	collPurges       *mongo.Collection
+	collRevisions    *mongo.Collection
 	resultTtlDefault time.Duration
 	retentionPeriod  time.Duration
 }
 
 const countUsersUnique = "countUsersUnique"
+const usageInterests = "interests"
+const usagePublic = "public"
+
+type usageRec struct {
+	Interests int64 `bson:"interests"`
+	Public    int64 `bson:"public"`
+}
 
 var timeZero = time.Time{}.UTC()
 var (
@@ -250,6 +262,78 @@ var (
 				SetProjection(projSearchByCondId).
 				SetShowRecordID(false).
 				SetSort(projId)
+	// no limit, the results are read until the cursor is exhausted or the consumer is satisfied
+	optsSearchByCondUnlimited = options.
+					Find().
+					SetProjection(projSearchByCondId).
+					SetShowRecordID(false).
+					SetSort(projId)
+	projChanges = bson.D{
+		{
+			Key:   attrChangePosition,
+			Value: 1,
+		},
+	}
+	projRevisions = bson.D{
+		{
+			Key:   attrRevisionInterestId,
+			Value: 1,
+		},
+		{
+			Key:   attrRevisionNumber,
+			Value: 1,
+		},
+	}
+	optsRevisionLast = options.
+				FindOne().
+				SetProjection(projRevisions).
+				SetSort(bson.D{
+			{
+				Key:   attrRevisionNumber,
+				Value: -1,
+			},
+		})
+	optsCheckOwn = options.
+			FindOne().
+			SetProjection(projId)
+	projChanged = bson.D{
+		{
+			Key:   attrId,
+			Value: 1,
+		},
+		{
+			Key:   attrGroupId,
+			Value: 1,
+		},
+		{
+			Key:   attrUserId,
+			Value: 1,
+		},
+		{
+			Key:   attrEnabled,
+			Value: 1,
+		},
+		{
+			Key:   attrEnabledSince,
+			Value: 1,
+		},
+		{
+			Key:   attrCond,
+			Value: 1,
+		},
+		{
+			Key:   attrPublic,
+			Value: 1,
+		},
+	}
+	optsFindChanged = options.
+			Find().
+			SetProjection(projChanged).
+			SetShowRecordID(false)
+	optsPositionInc = options.
+			FindOneAndUpdate().
+			SetUpsert(true).
+			SetReturnDocument(options.After)
 	pipelineCountUsersUniq = mongo.Pipeline{
 		bson.D{{
 			"$group",
@@ -289,8 +373,15 @@ func NewStorage(ctx context.Context, cfgDb config.DbConfig) (s storage.Storage,
 		stor.conn = conn
 		stor.db = db
 		stor.coll = coll
+		stor.collChanges = db.Collection(cfgDb.Table.Name + "_changes")
+		stor.collPositions = db.Collection(cfgDb.Table.Name + "_positions")
+		stor.collPurges = db.Collection(cfgDb.Table.Name + "_purges")
+		stor.collRevisions = db.Collection(cfgDb.Table.Name + "_revisions")
 		stor.resultTtlDefault = cfgDb.ResultTtl
 		stor.retentionPeriod = cfgDb.Table.Retention
+		err = stor.ensureTransactions(ctx)
+	}
+	if err == nil {
 		_, err = stor.ensureIndices(ctx)
 	}
 	if err == nil && cfgDb.Table.Shard {
@@ -320,7 +411,92 @@ func (s storageImpl) ensureIndices(ctx context.Context) ([]string, error) {
 				SetExpireAfterSeconds(retentionSeconds),
 		})
 	}
-	return s.coll.Indexes().CreateMany(ctx, indices)
+	names, err := s.coll.Indexes().CreateMany(ctx, indices)
+	if err == nil {
+		idxChanges := []mongo.IndexModel{
+			{
+				Keys: projChanges,
+				Options: options.
+					Index().
+					SetUnique(true),
+			},
+		}
+		if retentionSeconds > 0 {
+			idxChanges = append(idxChanges, mongo.IndexModel{
+				Keys: bson.D{
+					{
+						Key:   attrChangeTime,
+						Value: 1,
+					},
+				},
+				Options: options.
+					Index().
+					SetExpireAfterSeconds(retentionSeconds),
+			})
+		}
+		var namesChanges []string
+		namesChanges, err = s.collChanges.Indexes().CreateMany(ctx, idxChanges)
+		names = append(names, namesChanges...)
+	}
+	if err == nil {
+		idxRevisions := []mongo.IndexModel{
+			{
+				Keys: projRevisions,
+				Options: options.
+					Index().
+					SetUnique(true),
+			},
+		}
+		// the revisions of the deleted interest are marked as deleted too and expire together with the interest
+		if retentionSeconds > 0 {
+			idxRevisions = append(idxRevisions, mongo.IndexModel{
+				Keys: bson.D{
+					{
+						Key:   attrDeletedAt,
+						Value: 1,
+					},
+				},
+				Options: options.
+					Index().
+					SetExpireAfterSeconds(retentionSeconds),
+			})
+		}
+		var namesRevisions []string
+		namesRevisions, err = s.collRevisions.Indexes().CreateMany(ctx, idxRevisions)
+		names = append(names, namesRevisions...)
+	}
+	if err == nil {
+		// the purge audit records never expire
+		var namesPurges []string
+		namesPurges, err = s.collPurges.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{
+				Keys: projPurges,
+			},
+		})
+		names = append(names, namesPurges...)
+	}
+	return names, err
+}
+
+// ensureTransactions fails when the deployment doesn't support the multi-document transactions, e.g. the standalone
+// server. Every mutation writes the interest and the change to the outbox in the same transaction.
+func (s storageImpl) ensureTransactions(ctx context.Context) (err error) {
+	cmd := bson.D{
+		{
+			Key:   "hello",
+			Value: 1,
+		},
+	}
+	var hello bson.M
+	err = s.db.RunCommand(ctx, cmd).Decode(&hello)
+	if err == nil {
+		_, replicaSet := hello["setName"]
+		router := hello["msg"] == "isdbgrid"
+		if !replicaSet && !router {
+			err = errors.New("transactions are not supported by the standalone server, use a replica set or a sharded cluster")
+		}
+	}
+	return
 }
 
 func (s storageImpl) shardCollection(ctx context.Context) (err error) {
@@ -364,10 +540,34 @@ func (s storageImpl) Create(ctx context.Context, id, groupId, userId string, sd
 		Condition:   recCond,
 		CondIds:     condIds,
 	}
-	_, err = s.coll.InsertOne(ctx, rec)
+	err = s.inTx(ctx, func(ctx mongo.SessionContext) (err error) {
+		_, err = s.coll.InsertOne(ctx, rec)
+		if err == nil {
+			err = s.enforceQuotas(ctx, groupId, userId, []condition.Condition{sd.Condition}, interest.UsageAdded(nil, sd))
+		}
+		if err == nil {
+			err = s.insertRevision(ctx, id, groupId, userId, interest.Data{
+				Description: sd.Description,
+				Enabled:     sd.Enabled,
+				Expires:     sd.Expires,
+				Created:     sd.Created,
+				Updated:     sd.Updated,
+				Public:      sd.Public,
+				Followers:   sd.Followers,
+				Condition:   sd.Condition,
+			})
+		}
+		if err == nil {
+			c := interest.NewChange(interest.ChangeCreated, id, groupId, userId, nil, sd.Condition)
+			c.Enabled = sd.Enabled
+			err = s.insertChanges(ctx, c)
+		}
+		return
+	})
 	switch {
 	case mongo.IsDuplicateKeyError(err):
 		err = fmt.Errorf("%w: id already in use: %s", storage.ErrConflict, id)
+	case errors.Is(err, storage.ErrQuotaExceeded):
 	case err != nil:
 		err = fmt.Errorf("%w: failed to insert: %s", storage.ErrInternal, err)
 	}
@@ -421,6 +621,17 @@ func decodeSingleResult(id string, result *mongo.SingleResult) (sd interest.Data
 }
 
 func (s storageImpl) Update(ctx context.Context, id, groupId, userId string, internal bool, d interest.Data) (prev interest.Data, err error) {
+	err = s.inTx(ctx, func(ctx mongo.SessionContext) (err error) {
+		prev, err = s.update(ctx, id, groupId, userId, internal, d)
+		return
+	})
+	if err != nil && !errors.Is(err, storage.ErrNotFound) && !errors.Is(err, storage.ErrInternal) && !errors.Is(err, storage.ErrQuotaExceeded) {
+		err = fmt.Errorf("%w: failed to update interest, id: %s, err: %s", storage.ErrInternal, id, err)
+	}
+	return
+}
+
+func (s storageImpl) update(ctx mongo.SessionContext, id, groupId, userId string, internal bool, d interest.Data) (prev interest.Data, err error) {
 	q := bson.M{
 		attrId: id,
 		attrDeletedAt: bson.M{
@@ -443,8 +654,7 @@ func (s storageImpl) Update(ctx context.Context, id, groupId, userId string, int
 			attrCondIds: condIds,
 		},
 	}
-	var result *mongo.SingleResult
-	result = s.coll.FindOneAndUpdate(ctx, q, u, optsUpdate)
+	result := s.coll.FindOneAndUpdate(ctx, q, u, optsUpdate)
 	err = result.Err()
 	switch {
 	case errors.Is(err, mongo.ErrNoDocuments):
@@ -452,7 +662,29 @@ func (s storageImpl) Update(ctx context.Context, id, groupId, userId string, int
 	case err != nil:
 		err = fmt.Errorf("%w: failed to update interest, id: %s, err: %s", storage.ErrInternal, id, err)
 	default:
-		prev, _, _, err = decodeSingleResult(id, result)
+		var ownerGroupId, ownerUserId string
+		prev, ownerGroupId, ownerUserId, err = decodeSingleResult(id, result)
+		if err == nil {
+			conds := []condition.Condition{d.Condition}
+			err = s.enforceQuotas(ctx, ownerGroupId, ownerUserId, conds, interest.UsageAdded(&prev, d))
+		}
+		if err == nil {
+			// the data after the update
+			sd := prev
+			sd.Description = d.Description
+			sd.Enabled = d.Enabled
+			sd.Expires = d.Expires
+			sd.Updated = d.Updated
+			sd.Public = d.Public
+			sd.Condition = d.Condition
+			err = s.insertRevision(ctx, id, groupId, userId, sd)
+		}
+		if err == nil {
+			c := interest.NewChange(interest.ChangeUpdated, id, ownerGroupId, ownerUserId, prev.Condition, d.Condition)
+			c.Enabled = d.Enabled
+			c.EnabledSince = prev.EnabledSince
+			err = s.insertChanges(ctx, c)
+		}
 	}
 	return
 }
@@ -503,7 +735,7 @@ func (s storageImpl) UpdateResultTime(ctx context.Context, id string, last time.
 	return
 }
 
-func (s storageImpl) SetEnabledBatch(ctx context.Context, ids []string, enabled bool, enabledSince time.Time) (n int64, err error) {
+func (s storageImpl) SetEnabledBatch(ctx context.Context, ids []string, enabled bool, enabledSince time.Time) (n int64, condIds []string, err error) {
 	q := bson.M{
 		attrId: bson.M{
 			"$in": ids,
@@ -515,12 +747,29 @@ func (s storageImpl) SetEnabledBatch(ctx context.Context, ids []string, enabled
 	var u bson.M
 	switch enabledSince.IsZero() {
 	case true:
+		// select only the interests those are going to be modified
+		q[attrEnabled] = bson.M{
+			"$ne": enabled,
+		}
 		u = bson.M{
 			"$set": bson.M{
 				attrEnabled: enabled,
 			},
 		}
 	default:
+		enabledSince = enabledSince.UTC()
+		q["$or"] = []bson.M{
+			{
+				attrEnabled: bson.M{
+					"$ne": enabled,
+				},
+			},
+			{
+				attrEnabledSince: bson.M{
+					"$ne": enabledSince,
+				},
+			},
+		}
 		u = bson.M{
 			"$set": bson.M{
 				attrEnabled:      enabled,
@@ -528,12 +777,15 @@ func (s storageImpl) SetEnabledBatch(ctx context.Context, ids []string, enabled
 			},
 		}
 	}
-	var result *mongo.UpdateResult
-	result, err = s.coll.UpdateMany(ctx, q, u)
-	switch {
-	case err == nil:
-		n = result.ModifiedCount
-	default:
+	n, condIds, err = s.updateManyChanges(ctx, q, u, nil, func(rec interestRec) (c interest.Change, err error) {
+		c, err = rec.decodeChange(interest.ChangeEnabled)
+		c.Enabled = enabled
+		if !enabledSince.IsZero() {
+			c.EnabledSince = enabledSince
+		}
+		return
+	})
+	if err != nil {
 		err = fmt.Errorf("%w: failed to update interest, ids: %s, err: %s", storage.ErrInternal, ids, err)
 	}
 	return
@@ -553,9 +805,250 @@ func (s storageImpl) Delete(ctx context.Context, id, groupId, userId string) (sd
 			attrDeletedAt: time.Now().UTC(),
 		},
 	}
-	var result *mongo.SingleResult
-	result = s.coll.FindOneAndUpdate(ctx, q, u, optsUpdate)
-	sd, _, _, err = decodeSingleResult(id, result)
+	err = s.inTx(ctx, func(ctx mongo.SessionContext) (err error) {
+		var result *mongo.SingleResult
+		result = s.coll.FindOneAndUpdate(ctx, q, u, optsUpdate)
+		sd, _, _, err = decodeSingleResult(id, result)
+		if err == nil {
+			_, err = s.collRevisions.UpdateMany(ctx, bson.M{attrRevisionInterestId: id}, u)
+		}
+		if err == nil {
+			c := interest.NewChange(interest.ChangeDeleted, id, groupId, userId, sd.Condition, nil)
+			c.Enabled = sd.Enabled
+			c.EnabledSince = sd.EnabledSince
+			err = s.insertChanges(ctx, c)
+		}
+		return
+	})
+	if err != nil && !errors.Is(err, storage.ErrNotFound) && !errors.Is(err, storage.ErrInternal) {
+		err = fmt.Errorf("%w: failed to delete by id: %s, acc: %s/%s, %s", storage.ErrInternal, id, groupId, userId, err)
+	}
+	return
+}
+
+func (s storageImpl) Restore(ctx context.Context, id, groupId, userId string) (sd interest.Data, err error) {
+	u := bson.M{
+		"$unset": bson.M{
+			attrDeletedAt: "",
+		},
+	}
+	err = s.inTx(ctx, func(ctx mongo.SessionContext) (err error) {
+		var rec interestRec
+		err = s.coll.FindOne(ctx, bson.M{attrId: id}).Decode(&rec)
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			err = fmt.Errorf("%w: id=%s, acc=%s/%s", storage.ErrNotFound, id, groupId, userId)
+		case err != nil:
+		case rec.DeletedAt.IsZero():
+			err = fmt.Errorf("%w: id already in use: %s", storage.ErrConflict, id)
+		case rec.GroupId != groupId, rec.UserId != userId, rec.DeletedAt.Before(s.deletedSince()):
+			err = fmt.Errorf("%w: id=%s, acc=%s/%s", storage.ErrNotFound, id, groupId, userId)
+		default:
+			_, err = s.coll.UpdateOne(ctx, bson.M{attrId: id}, u)
+		}
+		if err == nil {
+			err = rec.decodeInterestData(&sd)
+		}
+		if err == nil {
+			err = s.enforceQuotas(ctx, groupId, userId, []condition.Condition{sd.Condition}, interest.UsageAdded(nil, sd))
+		}
+		if err == nil {
+			_, err = s.collRevisions.UpdateMany(ctx, bson.M{attrRevisionInterestId: id}, u)
+		}
+		if err == nil {
+			c := interest.NewChange(interest.ChangeRestored, id, groupId, userId, nil, sd.Condition)
+			c.Enabled = sd.Enabled
+			c.EnabledSince = sd.EnabledSince
+			err = s.insertChanges(ctx, c)
+		}
+		return
+	})
+	if err != nil && !errors.Is(err, storage.ErrNotFound) && !errors.Is(err, storage.ErrConflict) && !errors.Is(err, storage.ErrQuotaExceeded) {
+		err = fmt.Errorf("%w: failed to restore by id: %s, acc: %s/%s, %s", storage.ErrInternal, id, groupId, userId, err)
+	}
+	return
+}
+
+// ListOwned reads the interests in the transaction, so the pages fetched by the cursor are the same snapshot.
+func (s storageImpl) ListOwned(ctx context.Context, groupId, userId, cursor string, limit uint32) (page []interest.Interest, err error) {
+	q := bson.M{
+		attrGroupId: groupId,
+		attrUserId:  userId,
+		attrDeletedAt: bson.M{
+			"$exists": false,
+		},
+		attrId: bson.M{
+			"$gt": cursor,
+		},
+	}
+	opts := options.
+		Find().
+		SetLimit(int64(limit)).
+		SetShowRecordID(false).
+		SetSort(projId)
+	err = s.inTx(ctx, func(ctx mongo.SessionContext) (err error) {
+		page = nil
+		var cur *mongo.Cursor
+		cur, err = s.coll.Find(ctx, q, opts)
+		if err == nil {
+			defer cur.Close(ctx)
+			var recs []interestRec
+			err = cur.All(ctx, &recs)
+			for _, rec := range recs {
+				if err != nil {
+					break
+				}
+				i := interest.Interest{
+					Id:      rec.Id,
+					GroupId: rec.GroupId,
+					UserId:  rec.UserId,
+				}
+				err = rec.decodeInterestData(&i.Data)
+				page = append(page, i)
+			}
+		}
+		return
+	})
+	if err != nil {
+		err = fmt.Errorf("%w: failed to list owned, acc: %s/%s, %s", storage.ErrInternal, groupId, userId, err)
+	}
+	return
+}
+
+func (s storageImpl) ListDeleted(ctx context.Context, groupId, userId, cursor string, limit uint32) (page []interest.Deleted, err error) {
+	q := bson.M{
+		attrGroupId: groupId,
+		attrUserId:  userId,
+		attrDeletedAt: bson.M{
+			"$exists": true,
+			"$gte":    s.deletedSince(),
+		},
+		attrId: bson.M{
+			"$gt": cursor,
+		},
+	}
+	opts := options.
+		Find().
+		SetLimit(int64(limit)).
+		SetShowRecordID(false).
+		SetSort(projId)
+	var cur *mongo.Cursor
+	cur, err = s.coll.Find(ctx, q, opts)
+	if err == nil {
+		defer cur.Close(ctx)
+		var recs []interestRec
+		err = cur.All(ctx, &recs)
+		for _, rec := range recs {
+			if err != nil {
+				break
+			}
+			d := interest.Deleted{
+				Id:        rec.Id,
+				DeletedAt: rec.DeletedAt.UTC(),
+			}
+			err = rec.decodeInterestData(&d.Data)
+			page = append(page, d)
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: failed to list deleted, acc: %s/%s, %s", storage.ErrInternal, groupId, userId, err)
+	}
+	return
+}
+
+// deletedSince returns the earliest deletion time of the interest those retention period is not over yet.
+func (s storageImpl) deletedSince() (t time.Time) {
+	if s.retentionPeriod > 0 {
+		t = time.Now().UTC().Add(-s.retentionPeriod)
+	}
+	return
+}
+
+func (s storageImpl) Purge(ctx context.Context, groupId, userId, actor string) (p interest.Purged, err error) {
+	q := bson.M{
+		attrGroupId: groupId,
+		attrUserId:  userId,
+	}
+	err = s.inTx(ctx, func(ctx mongo.SessionContext) (err error) {
+		p = interest.Purged{}
+		var recs []interestRec
+		var cur *mongo.Cursor
+		cur, err = s.coll.Find(ctx, q, options.Find().SetSort(projId))
+		if err == nil {
+			err = cur.All(ctx, &recs)
+		}
+		var ids []string
+		var changes []interest.Change
+		for _, rec := range recs {
+			if err != nil {
+				break
+			}
+			var sd interest.Data
+			err = rec.decodeInterestData(&sd)
+			if err == nil {
+				deleted := !rec.DeletedAt.IsZero()
+				p.Add(sd.Condition, deleted)
+				var before condition.Condition
+				if !deleted {
+					before = sd.Condition
+				}
+				c := interest.NewChange(interest.ChangePurged, rec.Id, groupId, userId, before, nil)
+				c.Enabled = sd.Enabled
+				c.EnabledSince = sd.EnabledSince.UTC()
+				changes = append(changes, c)
+				ids = append(ids, rec.Id)
+			}
+		}
+		if err == nil && len(ids) > 0 {
+			_, err = s.coll.DeleteMany(ctx, q)
+			if err == nil {
+				_, err = s.collRevisions.DeleteMany(ctx, bson.M{attrRevisionInterestId: bson.M{"$in": ids}})
+			}
+			if err == nil {
+				_, err = s.collChanges.DeleteMany(ctx, bson.M{attrChangeInterestId: bson.M{"$in": ids}})
+			}
+			if err == nil {
+				err = s.insertChanges(ctx, changes...)
+			}
+		}
+		if err == nil {
+			a := interest.PurgeAudit{
+				Purged:  p,
+				Actor:   actor,
+				GroupId: groupId,
+				UserId:  userId,
+				Time:    time.Now().UTC(),
+			}
+			_, err = s.collPurges.InsertOne(ctx, encodePurgeAudit(a))
+		}
+		return
+	})
+	if err != nil {
+		err = fmt.Errorf("%w: failed to purge, acc: %s/%s, %s", storage.ErrInternal, groupId, userId, err)
+	}
+	return
+}
+
+func (s storageImpl) ListPurges(ctx context.Context, groupId, userId string) (audits []interest.PurgeAudit, err error) {
+	q := bson.M{
+		attrPurgeGroupId: groupId,
+		attrPurgeUserId:  userId,
+	}
+	var cur *mongo.Cursor
+	cur, err = s.collPurges.Find(ctx, q, options.Find().SetSort(sortPurges))
+	if err == nil {
+		defer cur.Close(ctx)
+		var recs []purgeRec
+		err = cur.All(ctx, &recs)
+		for _, rec := range recs {
+			var a interest.PurgeAudit
+			rec.decodePurgeAudit(&a)
+			audits = append(audits, a)
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: failed to list purges, acc: %s/%s, %s", storage.ErrInternal, groupId, userId, err)
+	}
 	return
 }
 
@@ -720,6 +1213,218 @@ func (s storageImpl) SearchByCondition(ctx context.Context, q interest.QueryByCo
 	return
 }
 
+func (s storageImpl) SearchByConditionStream(ctx context.Context, condId string, consume func(cm interest.ConditionMatch) (err error)) (expires time.Time, err error) {
+	tNow := time.Now().UTC()
+	dbQuery := bson.M{
+		attrCondIds: condId,
+		attrDeletedAt: bson.M{
+			"$exists": false,
+		},
+		attrEnabled: true,
+		"$or": []bson.M{
+			{
+				attrExpires: bson.M{
+					"$gt": tNow,
+				},
+			},
+			{
+				attrExpires: timeZero,
+			},
+			{
+				attrExpires: bson.M{
+					"$exists": false,
+				},
+			},
+		},
+	}
+	expires = tNow.Add(s.resultTtlDefault)
+	var cur *mongo.Cursor
+	cur, err = s.coll.Find(ctx, dbQuery, optsSearchByCondUnlimited)
+	if err != nil {
+		err = fmt.Errorf("%w: failed to find: query=%+v, %s", storage.ErrInternal, dbQuery, err)
+	} else {
+		defer cur.Close(ctx)
+		for cur.Next(ctx) {
+			var rec interestRec
+			err = cur.Decode(&rec)
+			if err != nil {
+				err = fmt.Errorf("%w: failed to decode interest record @ cursor %v: %s", storage.ErrInternal, cur.Current, err)
+				break
+			}
+			if !rec.EnabledSince.IsZero() && !rec.EnabledSince.Before(tNow) {
+				// not enabled yet, the result becomes outdated when it is
+				if expires.After(rec.EnabledSince) {
+					expires = rec.EnabledSince.UTC()
+				}
+				continue
+			}
+			var cm interest.ConditionMatch
+			err = rec.decodeInterestConditionMatch(&cm)
+			if err != nil {
+				err = fmt.Errorf("%w: failed to decode interest record %v: %s", storage.ErrInternal, rec, err)
+				break
+			}
+			if !rec.Expires.IsZero() && expires.After(rec.Expires) {
+				expires = rec.Expires.UTC()
+			}
+			err = consume(cm)
+			if err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = cur.Err()
+			if err != nil {
+				err = fmt.Errorf("%w: failed to iterate: query=%+v, %s", storage.ErrInternal, dbQuery, err)
+			}
+		}
+	}
+	return
+}
+
+func (s storageImpl) SearchByConditionBatch(ctx context.Context, qs []interest.QueryByConditionCursor) (batch interest.ConditionMatchBatch, err error) {
+	tNow := time.Now().UTC()
+	c := storage.NewConditionMatchBatchCollector(qs, tNow.Add(s.resultTtlDefault))
+	dbQuery := bson.M{
+		attrId: bson.M{
+			"$gt": c.CursorMin(),
+		},
+		attrCondIds: bson.M{
+			"$in": c.CondIds(),
+		},
+		attrDeletedAt: bson.M{
+			"$exists": false,
+		},
+		attrEnabled: true,
+		"$and": []bson.M{
+			{
+				"$or": []bson.M{
+					{
+						attrEnabledSince: bson.M{
+							"$exists": false,
+						},
+					},
+					{
+						attrEnabledSince: bson.M{
+							"$lt": tNow,
+						},
+					},
+				},
+			},
+			{
+				"$or": []bson.M{
+					{
+						attrExpires: bson.M{
+							"$gt": tNow,
+						},
+					},
+					{
+						attrExpires: timeZero,
+					},
+					{
+						attrExpires: bson.M{
+							"$exists": false,
+						},
+					},
+				},
+			},
+		},
+	}
+	var cur *mongo.Cursor
+	cur, err = s.coll.Find(ctx, dbQuery, optsSearchByCondUnlimited)
+	if err != nil {
+		err = fmt.Errorf("%w: failed to find: query=%+v, %s", storage.ErrInternal, dbQuery, err)
+	} else {
+		defer cur.Close(ctx)
+		for !c.Full() && cur.Next(ctx) {
+			var rec interestRec
+			err = cur.Decode(&rec)
+			if err != nil {
+				err = fmt.Errorf("%w: failed to decode interest record @ cursor %v: %s", storage.ErrInternal, cur.Current, err)
+				break
+			}
+			if c.Add(rec.Id, rec.CondIds, rec.Expires) {
+				var cm interest.ConditionMatch
+				err = rec.decodeInterestConditionMatch(&cm)
+				if err != nil {
+					err = fmt.Errorf("%w: failed to decode interest record %v: %s", storage.ErrInternal, rec, err)
+					break
+				}
+				c.SetCondition(rec.Id, cm.Condition)
+			}
+		}
+		if err == nil {
+			err = cur.Err()
+			if err != nil {
+				err = fmt.Errorf("%w: failed to iterate: query=%+v, %s", storage.ErrInternal, dbQuery, err)
+			}
+		}
+	}
+	batch = c.Batch()
+	return
+}
+
+func (s storageImpl) Usage(ctx context.Context, groupId, userId string) (u interest.Usage, err error) {
+	u, err = s.usage(ctx, groupId, userId)
+	if err != nil {
+		err = fmt.Errorf("%w: failed to count the usage, acc: %s/%s, %s", storage.ErrInternal, groupId, userId, err)
+	}
+	return
+}
+
+// usage counts the account interests and the public ones with the single aggregation.
+func (s storageImpl) usage(ctx context.Context, groupId, userId string) (u interest.Usage, err error) {
+	pipeline := mongo.Pipeline{
+		bson.D{{
+			"$match",
+			bson.M{
+				attrGroupId: groupId,
+				attrUserId:  userId,
+				attrDeletedAt: bson.M{
+					"$exists": false,
+				},
+			},
+		}},
+		bson.D{{
+			"$group",
+			bson.M{
+				"_id": nil,
+				usageInterests: bson.M{
+					"$sum": 1,
+				},
+				usagePublic: bson.M{
+					"$sum": bson.M{
+						"$cond": bson.A{"$" + attrPublic, 1, 0},
+					},
+				},
+			},
+		}},
+	}
+	var cursor *mongo.Cursor
+	cursor, err = s.coll.Aggregate(ctx, pipeline)
+	if err == nil {
+		defer cursor.Close(ctx)
+		var rec usageRec
+		if cursor.Next(ctx) {
+			err = cursor.Decode(&rec)
+		}
+		if err == nil {
+			err = cursor.Err()
+		}
+		u.Interests = rec.Interests
+		u.Public = rec.Public
+	}
+	return
+}
+
+// enforceQuotas counts the usage after the write in the transaction snapshot. Every write transaction modifies the
+// changes position document, so the concurrent one conflicts and is retried with the usage including this write.
+func (s storageImpl) enforceQuotas(ctx mongo.SessionContext, groupId, userId string, conds []condition.Condition, added interest.Usage) (err error) {
+	return storage.EnforceQuotas(ctx, groupId, userId, conds, added, func() (u interest.Usage, err error) {
+		return s.usage(ctx, groupId, userId)
+	})
+}
+
 func (s storageImpl) Count(ctx context.Context) (count int64, err error) {
 	return s.coll.EstimatedDocumentCount(ctx)
 }
@@ -813,23 +1518,15 @@ func pageQuerySortByFollowers(q interest.Query, cursor interest.Cursor, dbQuery
 	default:
 		switch cursor.Followers {
 		case 0:
+			// missing followers count is the same as zero, both should be after the cursor id to avoid the repeats
 			dbQuery = bson.M{
 				"$and": []bson.M{
 					dbQuery,
 					{
 						"$or": []bson.M{
 							{
-								"$or": []bson.M{
-									{
-										attrFollowers: bson.M{
-											"$gt": cursor.Followers,
-										},
-									},
-									{
-										attrFollowers: bson.M{
-											"$exists": true,
-										},
-									},
+								attrFollowers: bson.M{
+									"$gt": cursor.Followers,
 								},
 							},
 							{
@@ -1043,19 +1740,288 @@ func (s storageImpl) ChangeOwner(ctx context.Context, oldGroupId, oldUserId, new
 			"$exists": false,
 		},
 	}
+	if oldGroupId == newGroupId && oldUserId == newUserId {
+		// nothing to modify
+		return
+	}
 	u := bson.M{
 		"$set": bson.M{
 			attrGroupId: newGroupId,
 			attrUserId:  newUserId,
 		},
 	}
-	var result *mongo.UpdateResult
-	result, err = s.coll.UpdateMany(ctx, q, u)
-	switch {
-	case err == nil:
-		n = result.ModifiedCount
-	default:
+	enforce := func(ctx mongo.SessionContext, moved interest.Usage, conds []condition.Condition) (err error) {
+		return s.enforceQuotas(ctx, newGroupId, newUserId, conds, moved)
+	}
+	n, _, err = s.updateManyChanges(ctx, q, u, enforce, func(rec interestRec) (c interest.Change, err error) {
+		c, err = rec.decodeChange(interest.ChangeOwner)
+		c.GroupId = newGroupId
+		c.UserId = newUserId
+		return
+	})
+	if err != nil && !errors.Is(err, storage.ErrQuotaExceeded) {
 		err = fmt.Errorf("%w: failed to change owner: %s", storage.ErrInternal, err)
 	}
 	return
 }
+
+func (s storageImpl) ListRevisions(ctx context.Context, id, groupId, userId string, internal bool, cursor uint64, limit uint32) (revs []interest.Revision, err error) {
+	err = s.checkOwn(ctx, id, groupId, userId, internal)
+	if err == nil {
+		q := bson.M{
+			attrRevisionInterestId: id,
+			attrRevisionNumber: bson.M{
+				"$gt": cursor,
+			},
+		}
+		opts := options.
+			Find().
+			SetLimit(int64(limit)).
+			SetShowRecordID(false).
+			SetSort(bson.D{
+				{
+					Key:   attrRevisionNumber,
+					Value: 1,
+				},
+			})
+		var cur *mongo.Cursor
+		cur, err = s.collRevisions.Find(ctx, q, opts)
+		if err == nil {
+			defer cur.Close(ctx)
+			var recs []revisionRec
+			err = cur.All(ctx, &recs)
+			for _, rec := range recs {
+				if err != nil {
+					break
+				}
+				var rev interest.Revision
+				err = rec.decodeRevision(&rev)
+				revs = append(revs, rev)
+			}
+		}
+		if err != nil {
+			err = fmt.Errorf("%w: failed to list revisions, id: %s, err: %s", storage.ErrInternal, id, err)
+		}
+	}
+	return
+}
+
+func (s storageImpl) ReadRevision(ctx context.Context, id, groupId, userId string, internal bool, number uint64) (rev interest.Revision, err error) {
+	err = s.checkOwn(ctx, id, groupId, userId, internal)
+	if err == nil {
+		q := bson.M{
+			attrRevisionInterestId: id,
+			attrRevisionNumber:     number,
+		}
+		var rec revisionRec
+		err = s.collRevisions.FindOne(ctx, q).Decode(&rec)
+		if err == nil {
+			err = rec.decodeRevision(&rev)
+		}
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			err = fmt.Errorf("%w: revision not found, id: %s, number: %d, acc: %s/%s", storage.ErrNotFound, id, number, groupId, userId)
+		case err != nil:
+			err = fmt.Errorf("%w: failed to read revision, id: %s, number: %d, err: %s", storage.ErrInternal, id, number, err)
+		}
+	}
+	return
+}
+
+func (s storageImpl) RestoreRevision(ctx context.Context, id, groupId, userId string, internal bool, number uint64, updated time.Time) (rev interest.Revision, prev interest.Data, err error) {
+	err = s.inTx(ctx, func(ctx mongo.SessionContext) (err error) {
+		q := bson.M{
+			attrRevisionInterestId: id,
+			attrRevisionNumber:     number,
+		}
+		var rec revisionRec
+		err = s.collRevisions.FindOne(ctx, q).Decode(&rec)
+		if err == nil {
+			err = rec.decodeRevision(&rev)
+		}
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			err = fmt.Errorf("%w: revision not found, id: %s, number: %d, acc: %s/%s", storage.ErrNotFound, id, number, groupId, userId)
+		case err == nil:
+			prev, err = s.update(ctx, id, groupId, userId, internal, rev.RestoreData(updated))
+		}
+		return
+	})
+	if err != nil && !errors.Is(err, storage.ErrNotFound) && !errors.Is(err, storage.ErrInternal) && !errors.Is(err, storage.ErrQuotaExceeded) {
+		err = fmt.Errorf("%w: failed to restore revision, id: %s, number: %d, err: %s", storage.ErrInternal, id, number, err)
+	}
+	return
+}
+
+// checkOwn returns storage.ErrNotFound when the interest is missing or not owned by the specified account.
+func (s storageImpl) checkOwn(ctx context.Context, id, groupId, userId string, internal bool) (err error) {
+	q := bson.M{
+		attrId: id,
+		attrDeletedAt: bson.M{
+			"$exists": false,
+		},
+	}
+	if !internal {
+		q[attrGroupId] = groupId
+		q[attrUserId] = userId
+	}
+	err = s.coll.FindOne(ctx, q, optsCheckOwn).Err()
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		err = fmt.Errorf("%w: not found, id: %s, acc: %s/%s", storage.ErrNotFound, id, groupId, userId)
+	case err != nil:
+		err = fmt.Errorf("%w: failed to find by id: %s, acc: %s/%s, %s", storage.ErrInternal, id, groupId, userId, err)
+	}
+	return
+}
+
+func (s storageImpl) ReadChanges(ctx context.Context, after uint64, limit uint32) (changes []interest.Change, err error) {
+	q := bson.M{
+		attrChangePosition: bson.M{
+			"$gt": after,
+		},
+	}
+	opts := options.
+		Find().
+		SetLimit(int64(limit)).
+		SetShowRecordID(false).
+		SetSort(projChanges)
+	var cur *mongo.Cursor
+	cur, err = s.collChanges.Find(ctx, q, opts)
+	if err == nil {
+		defer cur.Close(ctx)
+		var recs []changeRec
+		err = cur.All(ctx, &recs)
+		for _, rec := range recs {
+			if err != nil {
+				break
+			}
+			var c interest.Change
+			err = rec.decodeChange(&c)
+			changes = append(changes, c)
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: failed to read changes after %d: %s", storage.ErrInternal, after, err)
+	}
+	return
+}
+
+// inTx runs the specified func in the transaction, the func may be retried on a transient error.
+func (s storageImpl) inTx(ctx context.Context, f func(ctx mongo.SessionContext) error) (err error) {
+	var sess mongo.Session
+	sess, err = s.conn.StartSession()
+	if err == nil {
+		defer sess.EndSession(ctx)
+		_, err = sess.WithTransaction(ctx, func(ctx mongo.SessionContext) (any, error) {
+			return nil, f(ctx)
+		})
+	}
+	return
+}
+
+// updateManyChanges updates the interests matching the query and records the change for every one of them.
+// The query should select only the interests those are going to be modified. The enforce func, when set, is invoked
+// with the usage and the conditions of the modified interests after the update. Returns the count and the unique sorted
+// condition ids of the modified interests.
+func (s storageImpl) updateManyChanges(
+	ctx context.Context, q, u bson.M,
+	enforce func(ctx mongo.SessionContext, u interest.Usage, conds []condition.Condition) (err error),
+	change func(rec interestRec) (c interest.Change, err error),
+) (n int64, condIds []string, err error) {
+	err = s.inTx(ctx, func(ctx mongo.SessionContext) (err error) {
+		n = 0
+		condIds = nil
+		var changes []interest.Change
+		var usage interest.Usage
+		var conds []condition.Condition
+		var cur *mongo.Cursor
+		cur, err = s.coll.Find(ctx, q, optsFindChanged)
+		if err == nil {
+			var recs []interestRec
+			err = cur.All(ctx, &recs)
+			var ids []string
+			for _, rec := range recs {
+				if err != nil {
+					break
+				}
+				var c interest.Change
+				c, err = change(rec)
+				changes = append(changes, c)
+				ids = append(ids, rec.Id)
+				usage = usage.Add(interest.UsageAdded(nil, interest.Data{Public: rec.Public}))
+				conds = append(conds, c.After)
+			}
+			if err == nil && len(ids) > 0 {
+				var result *mongo.UpdateResult
+				result, err = s.coll.UpdateMany(ctx, bson.M{attrId: bson.M{"$in": ids}}, u)
+				if err == nil {
+					n = result.ModifiedCount
+				}
+			}
+		}
+		if err == nil && enforce != nil {
+			err = enforce(ctx, usage, conds)
+		}
+		if err == nil {
+			err = s.insertChanges(ctx, changes...)
+		}
+		if err == nil {
+			condIds = interest.CondIdsAfter(changes)
+		}
+		return
+	})
+	return
+}
+
+// insertRevision writes the next revision of the interest. The concurrent writes of the same interest conflict on the
+// interest document, so the revision numbers don't clash.
+func (s storageImpl) insertRevision(ctx mongo.SessionContext, id, groupId, userId string, sd interest.Data) (err error) {
+	var last revisionRec
+	err = s.collRevisions.FindOne(ctx, bson.M{attrRevisionInterestId: id}, optsRevisionLast).Decode(&last)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = nil
+	}
+	if err == nil {
+		rev := interest.Revision{
+			Number:  last.Number + 1,
+			GroupId: groupId,
+			UserId:  userId,
+			Time:    time.Now().UTC(),
+			Data:    sd,
+		}
+		_, err = s.collRevisions.InsertOne(ctx, encodeRevision(rev, id))
+	}
+	return
+}
+
+// insertChanges reserves the positions for the changes and inserts them. Every transaction inserting the changes
+// modifies the same position document, so the concurrent ones conflict and commit in the order of the positions.
+// This is deliberate: the consumer resumes after the last position seen, so a change committed later should never get
+// the lower position. The cost is the write throughput limited by the single document, the conflicting transactions
+// are retried by WithTransaction.
+func (s storageImpl) insertChanges(ctx mongo.SessionContext, changes ...interest.Change) (err error) {
+	if len(changes) == 0 {
+		return
+	}
+	q := bson.M{
+		"_id": positionRecId,
+	}
+	u := bson.M{
+		"$inc": bson.M{
+			attrPositionValue: len(changes),
+		},
+	}
+	var pos positionRec
+	err = s.collPositions.FindOneAndUpdate(ctx, q, u, optsPositionInc).Decode(&pos)
+	if err == nil {
+		recs := make([]any, len(changes))
+		first := pos.Value - uint64(len(changes)) + 1
+		for i, c := range changes {
+			c.Position = first + uint64(i)
+			recs[i] = encodeChange(c)
+		}
+		_, err = s.collChanges.InsertMany(ctx, recs)
+	}
+	return
+}
@@ -7,8 +7,11 @@ import (
 	"github.com/awakari/interests/model/condition"
 	"github.com/awakari/interests/model/interest"
 	"github.com/awakari/interests/storage"
+	"github.com/awakari/interests/storage/storagetest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"math"
 	"os"
 	"sort"
@@ -39,9 +42,34 @@ func TestNewStorage(t *testing.T) {
 
 func clear(ctx context.Context, t *testing.T, s storageImpl) {
 	require.Nil(t, s.coll.Drop(ctx))
+	require.Nil(t, s.collChanges.Drop(ctx))
+	require.Nil(t, s.collPositions.Drop(ctx))
+	require.Nil(t, s.collRevisions.Drop(ctx))
 	require.Nil(t, s.Close())
 }
 
+func TestStorageImpl_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, resultTtl time.Duration) storage.Storage {
+		collName := fmt.Sprintf("interests-test-%d", time.Now().UnixMicro())
+		dbCfg := config.DbConfig{
+			Uri:       dbUri,
+			Name:      "interests",
+			ResultTtl: resultTtl,
+		}
+		dbCfg.Table.Name = collName
+		dbCfg.Tls.Enabled = true
+		dbCfg.Tls.Insecure = true
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+		t.Cleanup(cancel)
+		s, err := NewStorage(ctx, dbCfg)
+		require.Nil(t, err)
+		t.Cleanup(func() {
+			clear(ctx, t, s.(storageImpl))
+		})
+		return s
+	})
+}
+
 func TestStorageImpl_Create(t *testing.T) {
 	//
 	collName := fmt.Sprintf("interests-test-%d", time.Now().UnixMicro())
@@ -969,7 +997,7 @@ func TestStorageImpl_SearchByCondition_WithExpiration(t *testing.T) {
 	}
 	err = s.Create(ctx, "interest3", "acc0", "user0", sub3)
 	require.Nil(t, err)
-	_, err = s.SetEnabledBatch(ctx, []string{"interest3"}, true, time.Date(2022, 2, 22, 22, 22, 22, 0, time.UTC))
+	_, _, err = s.SetEnabledBatch(ctx, []string{"interest3"}, true, time.Date(2022, 2, 22, 22, 22, 22, 0, time.UTC))
 	require.Nil(t, err)
 	// not yet
 	sub4 := interest.Data{
@@ -978,7 +1006,7 @@ func TestStorageImpl_SearchByCondition_WithExpiration(t *testing.T) {
 	}
 	err = s.Create(ctx, "interest4", "acc0", "user0", sub4)
 	require.Nil(t, err)
-	_, err = s.SetEnabledBatch(ctx, []string{"interest4"}, true, time.Now().Add(1*time.Hour).UTC())
+	_, _, err = s.SetEnabledBatch(ctx, []string{"interest4"}, true, time.Now().Add(1*time.Hour).UTC())
 	require.Nil(t, err)
 	//
 	cases := map[string]struct {
@@ -1377,7 +1405,7 @@ func TestStorageImpl_SetEnabledBatch(t *testing.T) {
 	for name, c := range cases {
 		t.Run(name, func(t *testing.T) {
 			var n int64
-			n, err = s.SetEnabledBatch(context.TODO(), c.ids, c.enabled, c.enabledSince)
+			n, _, err = s.SetEnabledBatch(context.TODO(), c.ids, c.enabled, c.enabledSince)
 			assert.Equal(t, c.n, n)
 			assert.ErrorIs(t, err, c.err)
 		})
@@ -1451,3 +1479,50 @@ func TestStorageImpl_ChangeOwner(t *testing.T) {
 		})
 	}
 }
+
+// the ascending page following the zero followers cursor used to match every interest having the followers count,
+// so the interests before the cursor id were returned again.
+func TestPageQuerySortByFollowers_AscZeroFollowersCursorRepeats(t *testing.T) {
+	q := interest.Query{
+		Sort: interest.SortFollowers,
+	}
+	cursor := interest.Cursor{
+		Id: "interest1",
+	}
+	dbQuery, _ := pageQuerySortByFollowers(q, cursor, bson.M{}, options.Find())
+	assert.Equal(t, bson.M{
+		"$and": []bson.M{
+			{},
+			{
+				"$or": []bson.M{
+					{
+						attrFollowers: bson.M{
+							"$gt": int64(0),
+						},
+					},
+					{
+						"$and": []bson.M{
+							{
+								"$or": []bson.M{
+									{
+										attrFollowers: int64(0),
+									},
+									{
+										attrFollowers: bson.M{
+											"$exists": false,
+										},
+									},
+								},
+							},
+							{
+								attrId: bson.M{
+									"$gt": "interest1",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, dbQuery)
+}
@@ -0,0 +1,2 @@
+// Package postgres contains the PostgreSQL interests storage implementation.
+package postgres
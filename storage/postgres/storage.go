@@ -0,0 +1,1085 @@
+package postgres
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/awakari/interests/config"
+	"github.com/awakari/interests/model/condition"
+	"github.com/awakari/interests/model/interest"
+	"github.com/awakari/interests/storage"
+	"github.com/awakari/interests/storage/jsoncond"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"slices"
+	"strings"
+	"time"
+)
+
+type storageImpl struct {
+	pool             *pgxpool.Pool
+	tbl              string
+	tblChanges       string
+	tblRevisions     string
+	tblPurges        string
+	resultTtlDefault time.Duration
+	retentionPeriod  time.Duration
+	stop             chan struct{}
+}
+
+// the time columns are not nullable, the zero time value means "not set" the same way as in the model.
+// the revisions table group_id and user_id identify the account made the revision, the revisions of the purged
+// interest are removed by the cascade. the purges table keeps the audit records regardless of the retention period.
+const schema = `
+CREATE TABLE IF NOT EXISTS %[1]s (
+	id            TEXT        PRIMARY KEY,
+	group_id      TEXT        NOT NULL,
+	user_id       TEXT        NOT NULL,
+	descr         TEXT        NOT NULL DEFAULT '',
+	enabled       BOOLEAN     NOT NULL DEFAULT FALSE,
+	enabled_since TIMESTAMPTZ NOT NULL DEFAULT '0001-01-01 00:00:00+00',
+	expires       TIMESTAMPTZ NOT NULL DEFAULT '0001-01-01 00:00:00+00',
+	created       TIMESTAMPTZ NOT NULL DEFAULT '0001-01-01 00:00:00+00',
+	updated       TIMESTAMPTZ NOT NULL DEFAULT '0001-01-01 00:00:00+00',
+	result        TIMESTAMPTZ NOT NULL DEFAULT '0001-01-01 00:00:00+00',
+	public        BOOLEAN     NOT NULL DEFAULT FALSE,
+	followers     BIGINT      NOT NULL DEFAULT 0,
+	cond          JSONB       NOT NULL,
+	cond_ids      TEXT[]      NOT NULL,
+	deleted_at    TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS %[2]s ON %[1]s USING GIN (cond_ids);
+CREATE INDEX IF NOT EXISTS %[3]s ON %[1]s (group_id, user_id, id);
+CREATE INDEX IF NOT EXISTS %[4]s ON %[1]s (followers, id);
+CREATE INDEX IF NOT EXISTS %[5]s ON %[1]s (created, id);
+CREATE INDEX IF NOT EXISTS %[6]s ON %[1]s (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE TABLE IF NOT EXISTS %[7]s (
+	position      BIGSERIAL   PRIMARY KEY,
+	type          SMALLINT    NOT NULL,
+	interest_id   TEXT        NOT NULL,
+	group_id      TEXT        NOT NULL,
+	user_id       TEXT        NOT NULL,
+	time          TIMESTAMPTZ NOT NULL,
+	enabled       BOOLEAN     NOT NULL,
+	enabled_since TIMESTAMPTZ NOT NULL,
+	cond_before   JSONB,
+	cond_after    JSONB
+);
+CREATE INDEX IF NOT EXISTS %[8]s ON %[7]s (time);
+CREATE TABLE IF NOT EXISTS %[9]s (
+	interest_id   TEXT        NOT NULL REFERENCES %[1]s (id) ON DELETE CASCADE,
+	number        BIGINT      NOT NULL,
+	group_id      TEXT        NOT NULL,
+	user_id       TEXT        NOT NULL,
+	time          TIMESTAMPTZ NOT NULL,
+	descr         TEXT        NOT NULL,
+	enabled       BOOLEAN     NOT NULL,
+	enabled_since TIMESTAMPTZ NOT NULL,
+	expires       TIMESTAMPTZ NOT NULL,
+	created       TIMESTAMPTZ NOT NULL,
+	updated       TIMESTAMPTZ NOT NULL,
+	result        TIMESTAMPTZ NOT NULL,
+	public        BOOLEAN     NOT NULL,
+	followers     BIGINT      NOT NULL,
+	cond          JSONB       NOT NULL,
+	PRIMARY KEY (interest_id, number)
+);
+CREATE TABLE IF NOT EXISTS %[10]s (
+	id       BIGSERIAL   PRIMARY KEY,
+	group_id TEXT        NOT NULL,
+	user_id  TEXT        NOT NULL,
+	time     TIMESTAMPTZ NOT NULL,
+	actor    TEXT        NOT NULL,
+	count    BIGINT      NOT NULL,
+	deleted  BIGINT      NOT NULL,
+	cond_ids TEXT[]
+);
+CREATE INDEX IF NOT EXISTS %[11]s ON %[10]s (group_id, user_id, id);
+`
+
+const colsData = "descr, enabled, enabled_since, expires, created, updated, result, public, followers, cond, group_id, user_id"
+const colsChanged = "id, group_id, user_id, enabled, enabled_since, cond, public"
+const colsDataPrev = "prev.descr, prev.enabled, prev.enabled_since, prev.expires, prev.created, prev.updated, prev.result, prev.public, prev.followers, prev.cond, prev.group_id, prev.user_id"
+
+const (
+	queryCreate = `INSERT INTO %s (id, group_id, user_id, descr, enabled, expires, created, updated, public, followers, cond, cond_ids)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+	queryRead = `SELECT ` + colsData + ` FROM %s
+WHERE id = $1 AND deleted_at IS NULL AND ($2 OR (group_id = $3 AND user_id = $4) OR public)`
+	queryUpdate = `WITH prev AS (
+	SELECT * FROM %[1]s WHERE id = $1 AND deleted_at IS NULL AND ($2 OR (group_id = $3 AND user_id = $4)) FOR UPDATE
+)
+UPDATE %[1]s AS t
+SET descr = $5, enabled = $6, expires = $7, updated = $8, public = $9, cond = $10, cond_ids = $11
+FROM prev WHERE t.id = prev.id
+RETURNING ` + colsDataPrev
+	queryUpdateFollowers = `UPDATE %s SET followers = $2 WHERE id = $1 AND deleted_at IS NULL`
+	queryUpdateResult    = `UPDATE %s SET result = $2 WHERE id = $1 AND deleted_at IS NULL`
+	// count only the actually modified rows
+	querySetEnabledBatch = `UPDATE %s SET enabled = $2, enabled_since = $3
+WHERE id = ANY($1) AND deleted_at IS NULL AND (enabled <> $2 OR enabled_since <> $3)
+RETURNING ` + colsChanged
+	querySetEnabledBatchKeepSince = `UPDATE %s SET enabled = $2
+WHERE id = ANY($1) AND deleted_at IS NULL AND enabled <> $2
+RETURNING ` + colsChanged
+	queryChangeOwner = `UPDATE %s SET group_id = $3, user_id = $4
+WHERE group_id = $1 AND user_id = $2 AND deleted_at IS NULL AND (group_id <> $3 OR user_id <> $4)
+RETURNING ` + colsChanged
+	queryDelete = `UPDATE %s SET deleted_at = $4
+WHERE id = $1 AND group_id = $2 AND user_id = $3 AND deleted_at IS NULL
+RETURNING ` + colsData
+	queryReadDeletedAt = `SELECT group_id, user_id, deleted_at FROM %s WHERE id = $1 FOR UPDATE`
+	queryRestore       = `UPDATE %s SET deleted_at = NULL WHERE id = $1
+RETURNING ` + colsData
+	queryListOwned = `SELECT id, ` + colsData + ` FROM %s
+WHERE group_id = $1 AND user_id = $2 AND deleted_at IS NULL AND id > $3
+ORDER BY id`
+	queryListDeleted = `SELECT id, deleted_at, ` + colsData + ` FROM %s
+WHERE group_id = $1 AND user_id = $2 AND deleted_at IS NOT NULL AND deleted_at >= $3 AND id > $4
+ORDER BY id`
+	// the revisions are removed by the cascade
+	queryPurgeOwned = `DELETE FROM %s WHERE group_id = $1 AND user_id = $2
+RETURNING id, deleted_at IS NOT NULL, enabled, enabled_since, cond`
+	queryPurgeChangesByIds = `DELETE FROM %s WHERE interest_id = ANY($1)`
+	queryCreatePurge       = `INSERT INTO %s (group_id, user_id, time, actor, count, deleted, cond_ids)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	queryListPurges = `SELECT time, actor, count, deleted, cond_ids FROM %s
+WHERE group_id = $1 AND user_id = $2
+ORDER BY id`
+	querySearchByCondition = `SELECT id, cond, expires FROM %s
+WHERE id > $1 AND cond_ids @> ARRAY[$2::TEXT] AND deleted_at IS NULL AND enabled
+AND enabled_since < $3 AND (expires > $3 OR expires = $4)
+ORDER BY id`
+	querySearchByConditionStream = `SELECT id, cond, expires, enabled_since FROM %s
+WHERE cond_ids @> ARRAY[$1::TEXT] AND deleted_at IS NULL AND enabled AND (expires > $2 OR expires = $3)
+ORDER BY id`
+	querySearchByConditionBatch = `SELECT id, cond, expires, cond_ids FROM %s
+WHERE id > $1 AND cond_ids && $2::TEXT[] AND deleted_at IS NULL AND enabled
+AND enabled_since < $3 AND (expires > $3 OR expires = $4)
+ORDER BY id`
+	queryUsage = `SELECT COUNT(*), COUNT(*) FILTER (WHERE public) FROM %s
+WHERE group_id = $1 AND user_id = $2 AND deleted_at IS NULL`
+	queryCount            = `SELECT COUNT(*) FROM %s WHERE deleted_at IS NULL`
+	queryCountUsersUnique = `SELECT COUNT(DISTINCT user_id) FROM %s WHERE deleted_at IS NULL`
+	queryPurgeDeleted     = `DELETE FROM %s WHERE deleted_at < $1`
+	// the changes writers are serialized to make the positions visible in the ascending order only
+	queryLockChanges = `SELECT pg_advisory_xact_lock(hashtext($1))`
+	// the quota checks of the same account are serialized to count the usage including the concurrent writes
+	queryLockQuota    = `SELECT pg_advisory_xact_lock(hashtext($1))`
+	queryCreateChange = `INSERT INTO %s (type, interest_id, group_id, user_id, time, enabled, enabled_since, cond_before, cond_after)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	queryReadChanges = `SELECT position, type, interest_id, group_id, user_id, time, enabled, enabled_since, cond_before, cond_after
+FROM %s
+WHERE position > $1
+ORDER BY position`
+	queryPurgeChanges = `DELETE FROM %s WHERE time < $1`
+	// snapshot the interest data as is after the write
+	queryCreateRevision = `INSERT INTO %[1]s (interest_id, number, group_id, user_id, time, descr, enabled, enabled_since, expires, created, updated, result, public, followers, cond)
+SELECT id, COALESCE((SELECT MAX(number) FROM %[1]s WHERE interest_id = $1), 0) + 1, $2, $3, $4, descr, enabled, enabled_since, expires, created, updated, result, public, followers, cond
+FROM %[2]s
+WHERE id = $1`
+	queryCheckOwn = `SELECT 1 FROM %s
+WHERE id = $1 AND deleted_at IS NULL AND ($2 OR (group_id = $3 AND user_id = $4))`
+	queryListRevisions = `SELECT number, time, ` + colsData + ` FROM %s
+WHERE interest_id = $1 AND number > $2
+ORDER BY number`
+	queryReadRevision = `SELECT number, time, ` + colsData + ` FROM %s
+WHERE interest_id = $1 AND number = $2`
+)
+
+// codeUniqueViolation is the PostgreSQL error code for the unique constraint violation
+const codeUniqueViolation = "23505"
+
+// purgeIntervalMax is the max period between the removals of the deleted interests those retention period is over.
+const purgeIntervalMax = 1 * time.Hour
+
+var timeZero = time.Time{}.UTC()
+
+func NewStorage(ctx context.Context, cfgDb config.DbConfig) (s storage.Storage, err error) {
+	var poolCfg *pgxpool.Config
+	poolCfg, err = pgxpool.ParseConfig(cfgDb.Uri)
+	if err == nil {
+		if cfgDb.Name != "" {
+			poolCfg.ConnConfig.Database = cfgDb.Name
+		}
+		if len(cfgDb.UserName) > 0 {
+			poolCfg.ConnConfig.User = cfgDb.UserName
+			poolCfg.ConnConfig.Password = cfgDb.Password
+		}
+		if cfgDb.Tls.Enabled {
+			poolCfg.ConnConfig.TLSConfig = &tls.Config{
+				InsecureSkipVerify: cfgDb.Tls.Insecure,
+				ServerName:         poolCfg.ConnConfig.Host,
+			}
+		}
+	}
+	var pool *pgxpool.Pool
+	if err == nil {
+		pool, err = pgxpool.NewWithConfig(ctx, poolCfg)
+	}
+	var stor storageImpl
+	if err == nil {
+		stor.pool = pool
+		stor.tbl = pgx.Identifier{cfgDb.Table.Name}.Sanitize()
+		stor.tblChanges = pgx.Identifier{cfgDb.Table.Name + "_changes"}.Sanitize()
+		stor.tblRevisions = pgx.Identifier{cfgDb.Table.Name + "_revisions"}.Sanitize()
+		stor.tblPurges = pgx.Identifier{cfgDb.Table.Name + "_purges"}.Sanitize()
+		stor.resultTtlDefault = cfgDb.ResultTtl
+		stor.retentionPeriod = cfgDb.Table.Retention
+		stor.stop = make(chan struct{})
+		err = stor.ensureSchema(ctx, cfgDb.Table.Name)
+		if err != nil {
+			pool.Close()
+		}
+	}
+	if err == nil {
+		if stor.retentionPeriod > 0 {
+			go stor.purgeDeletedPeriodically()
+		}
+		s = stor
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %s", storage.ErrInternal, err)
+	}
+	return
+}
+
+func (s storageImpl) ensureSchema(ctx context.Context, tblName string) (err error) {
+	idx := func(suffix string) string {
+		return pgx.Identifier{tblName + "_" + suffix}.Sanitize()
+	}
+	_, err = s.pool.Exec(
+		ctx,
+		fmt.Sprintf(
+			schema,
+			s.tbl, idx("cond_ids"), idx("owner"), idx("followers"), idx("created"), idx("deleted_at"),
+			s.tblChanges, idx("changes_time"), s.tblRevisions, s.tblPurges, idx("purges_owner"),
+		),
+	)
+	return
+}
+
+func (s storageImpl) purgeDeletedPeriodically() {
+	interval := min(s.retentionPeriod, purgeIntervalMax)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		deletedBefore := time.Now().UTC().Add(-s.retentionPeriod)
+		_, _ = s.pool.Exec(context.TODO(), fmt.Sprintf(queryPurgeDeleted, s.tbl), deletedBefore)
+		_, _ = s.pool.Exec(context.TODO(), fmt.Sprintf(queryPurgeChanges, s.tblChanges), deletedBefore)
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s storageImpl) Close() error {
+	close(s.stop)
+	s.pool.Close()
+	return nil
+}
+
+func (s storageImpl) Create(ctx context.Context, id, groupId, userId string, sd interest.Data) (err error) {
+	var cond []byte
+	cond, err = jsoncond.Encode(sd.Condition)
+	if err == nil {
+		err = pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) (err error) {
+			_, err = tx.Exec(
+				ctx, fmt.Sprintf(queryCreate, s.tbl),
+				id, groupId, userId, sd.Description, sd.Enabled, sd.Expires.UTC(), sd.Created.UTC(), sd.Updated.UTC(),
+				sd.Public, sd.Followers, cond, condIds(sd.Condition),
+			)
+			if err == nil {
+				err = s.enforceQuotas(ctx, tx, groupId, userId, []condition.Condition{sd.Condition}, interest.UsageAdded(nil, sd))
+			}
+			if err == nil {
+				err = s.insertRevision(ctx, tx, id, groupId, userId)
+			}
+			if err == nil {
+				c := interest.NewChange(interest.ChangeCreated, id, groupId, userId, nil, sd.Condition)
+				c.Enabled = sd.Enabled
+				err = s.insertChanges(ctx, tx, c)
+			}
+			return
+		})
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.As(err, &pgErr) && pgErr.Code == codeUniqueViolation:
+			err = fmt.Errorf("%w: id already in use: %s", storage.ErrConflict, id)
+		case errors.Is(err, storage.ErrQuotaExceeded):
+		case err != nil:
+			err = fmt.Errorf("%w: failed to insert: %s", storage.ErrInternal, err)
+		}
+	}
+	return
+}
+
+func (s storageImpl) Read(ctx context.Context, id, groupId, userId string, internal bool) (sd interest.Data, ownerGroupId, ownerUserId string, err error) {
+	row := s.pool.QueryRow(ctx, fmt.Sprintf(queryRead, s.tbl), id, internal, groupId, userId)
+	sd, ownerGroupId, ownerUserId, err = scanData(row)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		err = fmt.Errorf("%w: id=%s, acc=%s/%s", storage.ErrNotFound, id, groupId, userId)
+	case err != nil:
+		err = fmt.Errorf("%w: failed to find by id: %s, acc: %s/%s, %s", storage.ErrInternal, id, groupId, userId, err)
+	}
+	return
+}
+
+func (s storageImpl) Update(ctx context.Context, id, groupId, userId string, internal bool, d interest.Data) (prev interest.Data, err error) {
+	err = pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) (err error) {
+		prev, err = s.update(ctx, tx, id, groupId, userId, internal, d)
+		return
+	})
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		err = fmt.Errorf("%w: not found, id: %s, acc: %s/%s", storage.ErrNotFound, id, groupId, userId)
+	case errors.Is(err, storage.ErrQuotaExceeded):
+	case err != nil:
+		err = fmt.Errorf("%w: failed to update interest, id: %s, err: %s", storage.ErrInternal, id, err)
+	}
+	return
+}
+
+func (s storageImpl) update(ctx context.Context, tx pgx.Tx, id, groupId, userId string, internal bool, d interest.Data) (prev interest.Data, err error) {
+	var cond []byte
+	cond, err = jsoncond.Encode(d.Condition)
+	var ownerGroupId, ownerUserId string
+	if err == nil {
+		row := tx.QueryRow(
+			ctx, fmt.Sprintf(queryUpdate, s.tbl),
+			id, internal, groupId, userId,
+			d.Description, d.Enabled, d.Expires.UTC(), d.Updated.UTC(), d.Public, cond, condIds(d.Condition),
+		)
+		prev, ownerGroupId, ownerUserId, err = scanData(row)
+	}
+	if err == nil {
+		conds := []condition.Condition{d.Condition}
+		err = s.enforceQuotas(ctx, tx, ownerGroupId, ownerUserId, conds, interest.UsageAdded(&prev, d))
+	}
+	if err == nil {
+		err = s.insertRevision(ctx, tx, id, groupId, userId)
+	}
+	if err == nil {
+		c := interest.NewChange(interest.ChangeUpdated, id, ownerGroupId, ownerUserId, prev.Condition, d.Condition)
+		c.Enabled = d.Enabled
+		c.EnabledSince = prev.EnabledSince
+		err = s.insertChanges(ctx, tx, c)
+	}
+	return
+}
+
+func (s storageImpl) UpdateFollowers(ctx context.Context, id string, count int64) (err error) {
+	var tag pgconn.CommandTag
+	tag, err = s.pool.Exec(ctx, fmt.Sprintf(queryUpdateFollowers, s.tbl), id, count)
+	switch {
+	case err == nil && tag.RowsAffected() < 1:
+		err = fmt.Errorf("%w: not found, id: %s", storage.ErrNotFound, id)
+	case err != nil:
+		err = fmt.Errorf("%w: failed to update interest, id: %s, err: %s", storage.ErrInternal, id, err)
+	}
+	return
+}
+
+func (s storageImpl) UpdateResultTime(ctx context.Context, id string, last time.Time) (err error) {
+	var tag pgconn.CommandTag
+	tag, err = s.pool.Exec(ctx, fmt.Sprintf(queryUpdateResult, s.tbl), id, last.UTC())
+	switch {
+	case err == nil && tag.RowsAffected() < 1:
+		err = fmt.Errorf("%w: not found, id: %s", storage.ErrNotFound, id)
+	case err != nil:
+		err = fmt.Errorf("%w: failed to update interest, id: %s, err: %s", storage.ErrInternal, id, err)
+	}
+	return
+}
+
+func (s storageImpl) SetEnabledBatch(ctx context.Context, ids []string, enabled bool, enabledSince time.Time) (n int64, condIds []string, err error) {
+	switch enabledSince.IsZero() {
+	case true:
+		n, condIds, err = s.execChanges(ctx, interest.ChangeEnabled, nil, fmt.Sprintf(querySetEnabledBatchKeepSince, s.tbl), ids, enabled)
+	default:
+		n, condIds, err = s.execChanges(ctx, interest.ChangeEnabled, nil, fmt.Sprintf(querySetEnabledBatch, s.tbl), ids, enabled, enabledSince.UTC())
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: failed to update interest, ids: %s, err: %s", storage.ErrInternal, ids, err)
+	}
+	return
+}
+
+func (s storageImpl) ChangeOwner(ctx context.Context, oldGroupId, oldUserId, newGroupId, newUserId string) (n int64, err error) {
+	enforce := func(tx pgx.Tx, moved interest.Usage, conds []condition.Condition) (err error) {
+		return s.enforceQuotas(ctx, tx, newGroupId, newUserId, conds, moved)
+	}
+	n, _, err = s.execChanges(
+		ctx, interest.ChangeOwner, enforce, fmt.Sprintf(queryChangeOwner, s.tbl),
+		oldGroupId, oldUserId, newGroupId, newUserId,
+	)
+	if err != nil && !errors.Is(err, storage.ErrQuotaExceeded) {
+		err = fmt.Errorf("%w: failed to change owner: %s", storage.ErrInternal, err)
+	}
+	return
+}
+
+func (s storageImpl) Delete(ctx context.Context, id, groupId, userId string) (sd interest.Data, err error) {
+	err = pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) (err error) {
+		row := tx.QueryRow(ctx, fmt.Sprintf(queryDelete, s.tbl), id, groupId, userId, time.Now().UTC())
+		sd, _, _, err = scanData(row)
+		if err == nil {
+			c := interest.NewChange(interest.ChangeDeleted, id, groupId, userId, sd.Condition, nil)
+			c.Enabled = sd.Enabled
+			c.EnabledSince = sd.EnabledSince
+			err = s.insertChanges(ctx, tx, c)
+		}
+		return
+	})
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		err = fmt.Errorf("%w: id=%s, acc=%s/%s", storage.ErrNotFound, id, groupId, userId)
+	case err != nil:
+		err = fmt.Errorf("%w: failed to delete by id: %s, acc: %s/%s, %s", storage.ErrInternal, id, groupId, userId, err)
+	}
+	return
+}
+
+func (s storageImpl) Restore(ctx context.Context, id, groupId, userId string) (sd interest.Data, err error) {
+	err = pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) (err error) {
+		var ownerGroupId, ownerUserId string
+		var deletedAt *time.Time
+		row := tx.QueryRow(ctx, fmt.Sprintf(queryReadDeletedAt, s.tbl), id)
+		err = row.Scan(&ownerGroupId, &ownerUserId, &deletedAt)
+		switch {
+		case err != nil:
+		case deletedAt == nil:
+			err = fmt.Errorf("%w: id already in use: %s", storage.ErrConflict, id)
+		case ownerGroupId != groupId, ownerUserId != userId, deletedAt.Before(s.deletedSince()):
+			err = pgx.ErrNoRows
+		default:
+			row = tx.QueryRow(ctx, fmt.Sprintf(queryRestore, s.tbl), id)
+			sd, _, _, err = scanData(row)
+		}
+		if err == nil {
+			err = s.enforceQuotas(ctx, tx, groupId, userId, []condition.Condition{sd.Condition}, interest.UsageAdded(nil, sd))
+		}
+		if err == nil {
+			c := interest.NewChange(interest.ChangeRestored, id, groupId, userId, nil, sd.Condition)
+			c.Enabled = sd.Enabled
+			c.EnabledSince = sd.EnabledSince
+			err = s.insertChanges(ctx, tx, c)
+		}
+		return
+	})
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		err = fmt.Errorf("%w: id=%s, acc=%s/%s", storage.ErrNotFound, id, groupId, userId)
+	case errors.Is(err, storage.ErrConflict), errors.Is(err, storage.ErrQuotaExceeded):
+	case err != nil:
+		err = fmt.Errorf("%w: failed to restore by id: %s, acc: %s/%s, %s", storage.ErrInternal, id, groupId, userId, err)
+	}
+	return
+}
+
+func (s storageImpl) ListOwned(ctx context.Context, groupId, userId, cursor string, limit uint32) (page []interest.Interest, err error) {
+	dbQuery := fmt.Sprintf(queryListOwned, s.tbl)
+	args := []any{groupId, userId, cursor}
+	if limit > 0 {
+		dbQuery += " LIMIT $4"
+		args = append(args, limit)
+	}
+	var rows pgx.Rows
+	rows, err = s.pool.Query(ctx, dbQuery, args...)
+	if err == nil {
+		page, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (i interest.Interest, err error) {
+			i.Data, i.GroupId, i.UserId, err = scanData(prefixRow{
+				row: row,
+				prefix: []any{
+					&i.Id,
+				},
+			})
+			return
+		})
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: failed to list owned, acc: %s/%s, %s", storage.ErrInternal, groupId, userId, err)
+	}
+	return
+}
+
+func (s storageImpl) ListDeleted(ctx context.Context, groupId, userId, cursor string, limit uint32) (page []interest.Deleted, err error) {
+	dbQuery := fmt.Sprintf(queryListDeleted, s.tbl)
+	args := []any{groupId, userId, s.deletedSince(), cursor}
+	if limit > 0 {
+		dbQuery += " LIMIT $5"
+		args = append(args, limit)
+	}
+	var rows pgx.Rows
+	rows, err = s.pool.Query(ctx, dbQuery, args...)
+	if err == nil {
+		page, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (d interest.Deleted, err error) {
+			d.Data, _, _, err = scanData(prefixRow{
+				row: row,
+				prefix: []any{
+					&d.Id, &d.DeletedAt,
+				},
+			})
+			d.DeletedAt = d.DeletedAt.UTC()
+			return
+		})
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: failed to list deleted, acc: %s/%s, %s", storage.ErrInternal, groupId, userId, err)
+	}
+	return
+}
+
+// deletedSince returns the earliest deletion time of the interest those retention period is not over yet.
+func (s storageImpl) deletedSince() (t time.Time) {
+	if s.retentionPeriod > 0 {
+		t = time.Now().UTC().Add(-s.retentionPeriod)
+	}
+	return
+}
+
+func (s storageImpl) Purge(ctx context.Context, groupId, userId, actor string) (p interest.Purged, err error) {
+	err = pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) (err error) {
+		p = interest.Purged{}
+		var changes []interest.Change
+		var rows pgx.Rows
+		rows, err = tx.Query(ctx, fmt.Sprintf(queryPurgeOwned, s.tbl), groupId, userId)
+		if err == nil {
+			changes, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (c interest.Change, err error) {
+				var id string
+				var deleted, enabled bool
+				var enabledSince time.Time
+				var rawCond []byte
+				err = row.Scan(&id, &deleted, &enabled, &enabledSince, &rawCond)
+				var cond condition.Condition
+				if err == nil {
+					cond, err = jsoncond.Decode(rawCond)
+				}
+				if err == nil {
+					p.Add(cond, deleted)
+					var before condition.Condition
+					if !deleted {
+						before = cond
+					}
+					c = interest.NewChange(interest.ChangePurged, id, groupId, userId, before, nil)
+					c.Enabled = enabled
+					c.EnabledSince = enabledSince.UTC()
+				}
+				return
+			})
+		}
+		var ids []string
+		for _, c := range changes {
+			ids = append(ids, c.InterestId)
+		}
+		if err == nil && len(ids) > 0 {
+			_, err = tx.Exec(ctx, fmt.Sprintf(queryPurgeChangesByIds, s.tblChanges), ids)
+		}
+		if err == nil {
+			slices.SortFunc(changes, func(a, b interest.Change) int {
+				return cmp.Compare(a.InterestId, b.InterestId)
+			})
+			err = s.insertChanges(ctx, tx, changes...)
+		}
+		if err == nil {
+			_, err = tx.Exec(
+				ctx, fmt.Sprintf(queryCreatePurge, s.tblPurges),
+				groupId, userId, time.Now().UTC(), actor, p.Count, p.Deleted, p.CondIds,
+			)
+		}
+		return
+	})
+	if err != nil {
+		err = fmt.Errorf("%w: failed to purge, acc: %s/%s, %s", storage.ErrInternal, groupId, userId, err)
+	}
+	return
+}
+
+func (s storageImpl) ListPurges(ctx context.Context, groupId, userId string) (audits []interest.PurgeAudit, err error) {
+	var rows pgx.Rows
+	rows, err = s.pool.Query(ctx, fmt.Sprintf(queryListPurges, s.tblPurges), groupId, userId)
+	if err == nil {
+		audits, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (a interest.PurgeAudit, err error) {
+			a.GroupId = groupId
+			a.UserId = userId
+			err = row.Scan(&a.Time, &a.Actor, &a.Count, &a.Deleted, &a.CondIds)
+			a.Time = a.Time.UTC()
+			return
+		})
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: failed to list purges, acc: %s/%s, %s", storage.ErrInternal, groupId, userId, err)
+	}
+	return
+}
+
+func (s storageImpl) Search(ctx context.Context, q interest.Query, cursor interest.Cursor) (ids []string, err error) {
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	where = append(where, "deleted_at IS NULL")
+	switch {
+	case q.All:
+	case q.PrivateOnly:
+		where = append(where, fmt.Sprintf("group_id = %s AND user_id = %s AND NOT public", arg(q.GroupId), arg(q.UserId)))
+	case q.IncludePublic:
+		where = append(where, fmt.Sprintf("((group_id = %s AND user_id = %s) OR public)", arg(q.GroupId), arg(q.UserId)))
+	default:
+		where = append(where, fmt.Sprintf("group_id = %s AND user_id = %s", arg(q.GroupId), arg(q.UserId)))
+	}
+	if q.Pattern != "" {
+		where = append(where, fmt.Sprintf("descr ~ %s", arg(q.Pattern)))
+	}
+	cmpOp, order := ">", "ASC"
+	if q.Order == interest.OrderDesc {
+		cmpOp, order = "<", "DESC"
+	}
+	var orderBy string
+	switch q.Sort {
+	case interest.SortFollowers:
+		where = append(where, fmt.Sprintf("(followers, id) %s (%s, %s)", cmpOp, arg(cursor.Followers), arg(cursor.Id)))
+		orderBy = fmt.Sprintf("followers %[1]s, id %[1]s", order)
+	case interest.SortTimeCreated:
+		where = append(where, fmt.Sprintf("(created, id) %s (%s, %s)", cmpOp, arg(cursor.CreatedAt.UTC()), arg(cursor.Id)))
+		orderBy = fmt.Sprintf("created %[1]s, id %[1]s", order)
+	default:
+		where = append(where, fmt.Sprintf("id %s %s", cmpOp, arg(cursor.Id)))
+		orderBy = fmt.Sprintf("id %s", order)
+	}
+	dbQuery := fmt.Sprintf("SELECT id FROM %s WHERE %s ORDER BY %s", s.tbl, strings.Join(where, " AND "), orderBy)
+	if q.Limit > 0 {
+		dbQuery += fmt.Sprintf(" LIMIT %s", arg(q.Limit))
+	}
+	var rows pgx.Rows
+	rows, err = s.pool.Query(ctx, dbQuery, args...)
+	if err == nil {
+		ids, err = pgx.CollectRows(rows, pgx.RowTo[string])
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: failed to find: query=%v, cursor=%v, %s", storage.ErrInternal, q, cursor, err)
+	}
+	return
+}
+
+func (s storageImpl) SearchByCondition(ctx context.Context, q interest.QueryByCondition, cursor string) (page interest.ConditionMatchPage, err error) {
+	tNow := time.Now().UTC()
+	dbQuery := fmt.Sprintf(querySearchByCondition, s.tbl)
+	args := []any{cursor, q.CondId, tNow, timeZero}
+	if q.Limit > 0 {
+		dbQuery += " LIMIT $5"
+		args = append(args, q.Limit)
+	}
+	var rows pgx.Rows
+	rows, err = s.pool.Query(ctx, dbQuery, args...)
+	if err != nil {
+		err = fmt.Errorf("%w: failed to find: query=%+v, %s", storage.ErrInternal, q, err)
+	} else {
+		defer rows.Close()
+		page.Expires = tNow.Add(s.resultTtlDefault)
+		for rows.Next() {
+			var cm interest.ConditionMatch
+			var rawCond []byte
+			var expires time.Time
+			err = rows.Scan(&cm.InterestId, &rawCond, &expires)
+			if err == nil {
+				cm.Condition, err = jsoncond.Decode(rawCond)
+			}
+			if err != nil {
+				err = fmt.Errorf("%w: failed to decode interest record %s: %s", storage.ErrInternal, cm.InterestId, err)
+				break
+			}
+			page.ConditionMatches = append(page.ConditionMatches, cm)
+			if !expires.IsZero() && page.Expires.After(expires) {
+				page.Expires = expires.UTC()
+			}
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+	}
+	return
+}
+
+func (s storageImpl) SearchByConditionStream(ctx context.Context, condId string, consume func(cm interest.ConditionMatch) (err error)) (expires time.Time, err error) {
+	tNow := time.Now().UTC()
+	var rows pgx.Rows
+	rows, err = s.pool.Query(ctx, fmt.Sprintf(querySearchByConditionStream, s.tbl), condId, tNow, timeZero)
+	if err != nil {
+		err = fmt.Errorf("%w: failed to find: condId=%s, %s", storage.ErrInternal, condId, err)
+	} else {
+		defer rows.Close()
+		expires = tNow.Add(s.resultTtlDefault)
+		for rows.Next() {
+			var cm interest.ConditionMatch
+			var rawCond []byte
+			var recExpires, recEnabledSince time.Time
+			err = rows.Scan(&cm.InterestId, &rawCond, &recExpires, &recEnabledSince)
+			if err != nil {
+				err = fmt.Errorf("%w: failed to decode interest record %s: %s", storage.ErrInternal, cm.InterestId, err)
+				break
+			}
+			if !recEnabledSince.Before(tNow) {
+				// not enabled yet, the result becomes outdated when it is
+				if expires.After(recEnabledSince) {
+					expires = recEnabledSince.UTC()
+				}
+				continue
+			}
+			cm.Condition, err = jsoncond.Decode(rawCond)
+			if err != nil {
+				err = fmt.Errorf("%w: failed to decode interest record %s: %s", storage.ErrInternal, cm.InterestId, err)
+				break
+			}
+			if !recExpires.IsZero() && expires.After(recExpires) {
+				expires = recExpires.UTC()
+			}
+			err = consume(cm)
+			if err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = rows.Err()
+			if err != nil {
+				err = fmt.Errorf("%w: failed to iterate: condId=%s, %s", storage.ErrInternal, condId, err)
+			}
+		}
+	}
+	return
+}
+
+func (s storageImpl) SearchByConditionBatch(ctx context.Context, qs []interest.QueryByConditionCursor) (batch interest.ConditionMatchBatch, err error) {
+	tNow := time.Now().UTC()
+	c := storage.NewConditionMatchBatchCollector(qs, tNow.Add(s.resultTtlDefault))
+	var rows pgx.Rows
+	rows, err = s.pool.Query(
+		ctx, fmt.Sprintf(querySearchByConditionBatch, s.tbl),
+		c.CursorMin(), c.CondIds(), tNow, timeZero,
+	)
+	if err != nil {
+		err = fmt.Errorf("%w: failed to find: queries=%+v, %s", storage.ErrInternal, qs, err)
+	} else {
+		defer rows.Close()
+		for !c.Full() && rows.Next() {
+			var id string
+			var rawCond []byte
+			var expires time.Time
+			var condIds []string
+			err = rows.Scan(&id, &rawCond, &expires, &condIds)
+			if err == nil && c.Add(id, condIds, expires) {
+				var cond condition.Condition
+				cond, err = jsoncond.Decode(rawCond)
+				c.SetCondition(id, cond)
+			}
+			if err != nil {
+				err = fmt.Errorf("%w: failed to decode interest record %s: %s", storage.ErrInternal, id, err)
+				break
+			}
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+	}
+	batch = c.Batch()
+	return
+}
+
+func (s storageImpl) ListRevisions(ctx context.Context, id, groupId, userId string, internal bool, cursor uint64, limit uint32) (revs []interest.Revision, err error) {
+	err = s.checkOwn(ctx, id, groupId, userId, internal)
+	if err == nil {
+		dbQuery := fmt.Sprintf(queryListRevisions, s.tblRevisions)
+		args := []any{id, int64(cursor)}
+		if limit > 0 {
+			dbQuery += " LIMIT $3"
+			args = append(args, limit)
+		}
+		var rows pgx.Rows
+		rows, err = s.pool.Query(ctx, dbQuery, args...)
+		if err == nil {
+			revs, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (interest.Revision, error) {
+				return scanRevision(row)
+			})
+		}
+	}
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		err = fmt.Errorf("%w: not found, id: %s, acc: %s/%s", storage.ErrNotFound, id, groupId, userId)
+	case err != nil:
+		err = fmt.Errorf("%w: failed to list revisions, id: %s, err: %s", storage.ErrInternal, id, err)
+	}
+	return
+}
+
+func (s storageImpl) ReadRevision(ctx context.Context, id, groupId, userId string, internal bool, number uint64) (rev interest.Revision, err error) {
+	err = s.checkOwn(ctx, id, groupId, userId, internal)
+	if err == nil {
+		rev, err = scanRevision(s.pool.QueryRow(ctx, fmt.Sprintf(queryReadRevision, s.tblRevisions), id, int64(number)))
+	}
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		err = fmt.Errorf("%w: revision not found, id: %s, number: %d, acc: %s/%s", storage.ErrNotFound, id, number, groupId, userId)
+	case err != nil:
+		err = fmt.Errorf("%w: failed to read revision, id: %s, number: %d, err: %s", storage.ErrInternal, id, number, err)
+	}
+	return
+}
+
+func (s storageImpl) RestoreRevision(ctx context.Context, id, groupId, userId string, internal bool, number uint64, updated time.Time) (rev interest.Revision, prev interest.Data, err error) {
+	err = pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) (err error) {
+		rev, err = scanRevision(tx.QueryRow(ctx, fmt.Sprintf(queryReadRevision, s.tblRevisions), id, int64(number)))
+		if err == nil {
+			prev, err = s.update(ctx, tx, id, groupId, userId, internal, rev.RestoreData(updated))
+		}
+		return
+	})
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		err = fmt.Errorf("%w: revision not found, id: %s, number: %d, acc: %s/%s", storage.ErrNotFound, id, number, groupId, userId)
+	case errors.Is(err, storage.ErrQuotaExceeded):
+	case err != nil:
+		err = fmt.Errorf("%w: failed to restore revision, id: %s, number: %d, err: %s", storage.ErrInternal, id, number, err)
+	}
+	return
+}
+
+// checkOwn returns pgx.ErrNoRows when the interest is missing or not owned by the specified account.
+func (s storageImpl) checkOwn(ctx context.Context, id, groupId, userId string, internal bool) (err error) {
+	var found int
+	err = s.pool.QueryRow(ctx, fmt.Sprintf(queryCheckOwn, s.tbl), id, internal, groupId, userId).Scan(&found)
+	return
+}
+
+func (s storageImpl) ReadChanges(ctx context.Context, after uint64, limit uint32) (changes []interest.Change, err error) {
+	dbQuery := fmt.Sprintf(queryReadChanges, s.tblChanges)
+	args := []any{int64(after)}
+	if limit > 0 {
+		dbQuery += " LIMIT $2"
+		args = append(args, limit)
+	}
+	var rows pgx.Rows
+	rows, err = s.pool.Query(ctx, dbQuery, args...)
+	if err == nil {
+		changes, err = pgx.CollectRows(rows, scanChange)
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: failed to read changes after %d: %s", storage.ErrInternal, after, err)
+	}
+	return
+}
+
+func (s storageImpl) Usage(ctx context.Context, groupId, userId string) (u interest.Usage, err error) {
+	err = s.pool.QueryRow(ctx, fmt.Sprintf(queryUsage, s.tbl), groupId, userId).Scan(&u.Interests, &u.Public)
+	if err != nil {
+		err = fmt.Errorf("%w: failed to count the usage, acc: %s/%s, %s", storage.ErrInternal, groupId, userId, err)
+	}
+	return
+}
+
+func (s storageImpl) Count(ctx context.Context) (count int64, err error) {
+	err = s.pool.QueryRow(ctx, fmt.Sprintf(queryCount, s.tbl)).Scan(&count)
+	if err != nil {
+		err = fmt.Errorf("%w: failed to count: %s", storage.ErrInternal, err)
+	}
+	return
+}
+
+func (s storageImpl) CountUsersUnique(ctx context.Context) (count int64, err error) {
+	err = s.pool.QueryRow(ctx, fmt.Sprintf(queryCountUsersUnique, s.tbl)).Scan(&count)
+	if err != nil {
+		err = fmt.Errorf("%w: failed to count unique users: %s", storage.ErrInternal, err)
+	}
+	return
+}
+
+// execChanges runs the update query returning the modified interests and records the change for every one of them.
+// Returns the count and the unique sorted condition ids of the modified interests.
+// The enforce func, when set, is invoked with the usage and the conditions of the modified interests before that.
+func (s storageImpl) execChanges(
+	ctx context.Context, t interest.ChangeType,
+	enforce func(tx pgx.Tx, u interest.Usage, conds []condition.Condition) (err error),
+	query string, args ...any,
+) (n int64, condIds []string, err error) {
+	err = pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) (err error) {
+		var changes []interest.Change
+		var u interest.Usage
+		var conds []condition.Condition
+		var rows pgx.Rows
+		rows, err = tx.Query(ctx, query, args...)
+		if err == nil {
+			changes, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (c interest.Change, err error) {
+				var id, groupId, userId string
+				var enabled, public bool
+				var enabledSince time.Time
+				var rawCond []byte
+				err = row.Scan(&id, &groupId, &userId, &enabled, &enabledSince, &rawCond, &public)
+				var cond condition.Condition
+				if err == nil {
+					cond, err = jsoncond.Decode(rawCond)
+				}
+				if err == nil {
+					c = interest.NewChange(t, id, groupId, userId, cond, cond)
+					c.Enabled = enabled
+					c.EnabledSince = enabledSince.UTC()
+					u = u.Add(interest.UsageAdded(nil, interest.Data{Public: public}))
+					conds = append(conds, cond)
+				}
+				return
+			})
+		}
+		if err == nil && enforce != nil {
+			err = enforce(tx, u, conds)
+		}
+		if err == nil {
+			err = s.insertChanges(ctx, tx, changes...)
+		}
+		n = int64(len(changes))
+		condIds = interest.CondIdsAfter(changes)
+		return
+	})
+	if err != nil {
+		n = 0
+		condIds = nil
+	}
+	return
+}
+
+// enforceQuotas locks the account quota until the transaction end and counts the usage after the write, so the
+// concurrent writes of the same account see each other.
+func (s storageImpl) enforceQuotas(ctx context.Context, tx pgx.Tx, groupId, userId string, conds []condition.Condition, added interest.Usage) (err error) {
+	return storage.EnforceQuotas(ctx, groupId, userId, conds, added, func() (u interest.Usage, err error) {
+		_, err = tx.Exec(ctx, queryLockQuota, fmt.Sprintf("%s/quota/%s/%s", s.tbl, groupId, userId))
+		if err == nil {
+			err = tx.QueryRow(ctx, fmt.Sprintf(queryUsage, s.tbl), groupId, userId).Scan(&u.Interests, &u.Public)
+		}
+		return
+	})
+}
+
+func (s storageImpl) insertRevision(ctx context.Context, tx pgx.Tx, id, groupId, userId string) (err error) {
+	_, err = tx.Exec(
+		ctx, fmt.Sprintf(queryCreateRevision, s.tblRevisions, s.tbl),
+		id, groupId, userId, time.Now().UTC(),
+	)
+	return
+}
+
+func (s storageImpl) insertChanges(ctx context.Context, tx pgx.Tx, changes ...interest.Change) (err error) {
+	if len(changes) > 0 {
+		_, err = tx.Exec(ctx, queryLockChanges, s.tblChanges)
+	}
+	for _, c := range changes {
+		if err != nil {
+			break
+		}
+		var before, after []byte
+		before, err = encodeNullCondition(c.Before)
+		if err == nil {
+			after, err = encodeNullCondition(c.After)
+		}
+		if err == nil {
+			_, err = tx.Exec(
+				ctx, fmt.Sprintf(queryCreateChange, s.tblChanges),
+				c.Type, c.InterestId, c.GroupId, c.UserId, c.Time.UTC(), c.Enabled, c.EnabledSince.UTC(), before, after,
+			)
+		}
+	}
+	return
+}
+
+func scanRevision(row pgx.Row) (rev interest.Revision, err error) {
+	var number int64
+	rev.Data, rev.GroupId, rev.UserId, err = scanData(prefixRow{
+		row: row,
+		prefix: []any{
+			&number, &rev.Time,
+		},
+	})
+	rev.Number = uint64(number)
+	rev.Time = rev.Time.UTC()
+	return
+}
+
+// prefixRow scans the leading columns into the prefix destinations, the rest are left for the caller.
+type prefixRow struct {
+	row    pgx.Row
+	prefix []any
+}
+
+func (pr prefixRow) Scan(dest ...any) error {
+	return pr.row.Scan(append(pr.prefix, dest...)...)
+}
+
+func scanChange(row pgx.CollectableRow) (c interest.Change, err error) {
+	var t interest.ChangeType
+	var id, groupId, userId string
+	var position int64
+	var tChange, enabledSince time.Time
+	var enabled bool
+	var rawBefore, rawAfter []byte
+	err = row.Scan(&position, &t, &id, &groupId, &userId, &tChange, &enabled, &enabledSince, &rawBefore, &rawAfter)
+	var before, after condition.Condition
+	if err == nil {
+		before, err = decodeNullCondition(rawBefore)
+	}
+	if err == nil {
+		after, err = decodeNullCondition(rawAfter)
+	}
+	if err == nil {
+		c = interest.NewChange(t, id, groupId, userId, before, after)
+		c.Position = uint64(position)
+		c.Time = tChange.UTC()
+		c.Enabled = enabled
+		c.EnabledSince = enabledSince.UTC()
+	}
+	return
+}
+
+// encodeNullCondition returns nil, i.e. NULL, for the missing condition, e.g. the one before the interest is created.
+func encodeNullCondition(cond condition.Condition) (data []byte, err error) {
+	if cond != nil {
+		data, err = jsoncond.Encode(cond)
+	}
+	return
+}
+
+func decodeNullCondition(data []byte) (cond condition.Condition, err error) {
+	if data != nil {
+		cond, err = jsoncond.Decode(data)
+	}
+	return
+}
+
+func scanData(row pgx.Row) (sd interest.Data, groupId, userId string, err error) {
+	var rawCond []byte
+	err = row.Scan(
+		&sd.Description, &sd.Enabled, &sd.EnabledSince, &sd.Expires, &sd.Created, &sd.Updated, &sd.Result,
+		&sd.Public, &sd.Followers, &rawCond, &groupId, &userId,
+	)
+	if err == nil {
+		sd.EnabledSince = sd.EnabledSince.UTC()
+		sd.Expires = sd.Expires.UTC()
+		sd.Created = sd.Created.UTC()
+		sd.Updated = sd.Updated.UTC()
+		sd.Result = sd.Result.UTC()
+		sd.Condition, err = jsoncond.Decode(rawCond)
+	}
+	return
+}
+
+// condIds returns the flat list of all condition ids, the list is never nil to fit the non-nullable column.
+func condIds(c condition.Condition) (ids []string) {
+	ids = condition.LeafIds(c)
+	if ids == nil {
+		ids = []string{}
+	}
+	return
+}
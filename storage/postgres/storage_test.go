@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/awakari/interests/config"
+	"github.com/awakari/interests/storage"
+	"github.com/awakari/interests/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+	"time"
+)
+
+var dbUri = os.Getenv("DB_URI_TEST_POSTGRES")
+
+func newTestStorage(t *testing.T, resultTtl time.Duration) storage.Storage {
+	tblName := fmt.Sprintf("interests_test_%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Type:      config.DbTypePostgres,
+		Uri:       dbUri,
+		ResultTtl: resultTtl,
+	}
+	dbCfg.Table.Name = tblName
+	dbCfg.Table.Retention = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	t.Cleanup(cancel)
+	s, err := NewStorage(ctx, dbCfg)
+	require.Nil(t, err)
+	t.Cleanup(func() {
+		_, err := s.(storageImpl).pool.Exec(ctx, fmt.Sprintf("DROP TABLE %s", s.(storageImpl).tbl))
+		assert.Nil(t, err)
+		assert.Nil(t, s.Close())
+	})
+	return s
+}
+
+func TestNewStorage_Fail(t *testing.T) {
+	dbCfg := config.DbConfig{
+		Uri: "not a postgres uri: %",
+	}
+	s, err := NewStorage(context.TODO(), dbCfg)
+	assert.Nil(t, s)
+	assert.ErrorIs(t, err, storage.ErrInternal)
+}
+
+func TestStorageImpl_Conformance(t *testing.T) {
+	storagetest.Run(t, newTestStorage)
+}
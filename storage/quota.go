@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/awakari/interests/model/condition"
+	"github.com/awakari/interests/model/interest"
+	"os"
+	"time"
+)
+
+// Quotas defines the interest.Limits of the accounts: the user limits override the group limits which override the
+// default ones. The overriding limits replace the overridden ones as a whole.
+type Quotas struct {
+	Default interest.Limits        `json:"default"`
+	Groups  map[string]GroupQuotas `json:"groups"`
+}
+
+type GroupQuotas struct {
+
+	// Limits of every group user, the Quotas.Default is used when not set.
+	Limits *interest.Limits `json:"limits"`
+
+	// Users contains the limits by the user id.
+	Users map[string]interest.Limits `json:"users"`
+}
+
+type quotaMiddleware struct {
+	stor   Storage
+	quotas Quotas
+}
+
+type ctxKeyQuotas struct{}
+
+// LoadQuotas reads the quotas from the JSON file, returns no limits when the path is empty.
+func LoadQuotas(path string) (q Quotas, err error) {
+	if path != "" {
+		var data []byte
+		data, err = os.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(data, &q)
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to load the quotas from %s: %w", path, err)
+		}
+	}
+	return
+}
+
+// Limits returns the limits of the specified account.
+func (q Quotas) Limits(groupId, userId string) (l interest.Limits) {
+	l = q.Default
+	if gq, found := q.Groups[groupId]; found {
+		if gq.Limits != nil {
+			l = *gq.Limits
+		}
+		if ul, found := gq.Users[userId]; found {
+			l = ul
+		}
+	}
+	return
+}
+
+// Empty is true when no account is limited.
+func (q Quotas) Empty() bool {
+	return q.Default == interest.Limits{} && len(q.Groups) == 0
+}
+
+// NewQuotaMiddleware returns the Storage failing with ErrQuotaExceeded when the Create, Update, ChangeOwner, Restore or
+// RestoreRevision would exceed the limits of the resulting interest owner. The quotas are passed to the underlying
+// storage with the context, the storage enforces them in the same transaction as the write, see EnforceQuotas.
+func NewQuotaMiddleware(stor Storage, quotas Quotas) Storage {
+	return quotaMiddleware{
+		stor:   stor,
+		quotas: quotas,
+	}
+}
+
+// WithQuotas returns the context making the storage enforce the quotas on the write, see EnforceQuotas.
+func WithQuotas(ctx context.Context, q Quotas) context.Context {
+	return context.WithValue(ctx, ctxKeyQuotas{}, q)
+}
+
+// EnforceQuotas fails with ErrQuotaExceeded when the write exceeds the limits of the written interests owner set by
+// WithQuotas, does nothing when not set. Every written condition is checked. The usage func is invoked only when the
+// write adds the limited interests, it should return the owner usage after the write counted in the same transaction.
+// The storage should serialize the counting with the concurrent writes of the same owner and roll back the write on
+// the error, so the limits hold under the concurrent writes too.
+func EnforceQuotas(
+	ctx context.Context, groupId, userId string, conds []condition.Condition, added interest.Usage,
+	usage func() (u interest.Usage, err error),
+) (err error) {
+	q, ok := ctx.Value(ctxKeyQuotas{}).(Quotas)
+	if ok {
+		limits := q.Limits(groupId, userId)
+		for _, cond := range conds {
+			err = checkCondition(limits, cond)
+			if err != nil {
+				break
+			}
+		}
+		checkInterests := limits.Interests > 0 && added.Interests > 0
+		checkPublic := limits.Public > 0 && added.Public > 0
+		if err == nil && (checkInterests || checkPublic) {
+			var u interest.Usage
+			u, err = usage()
+			switch {
+			case err != nil:
+			case checkInterests && u.Interests > limits.Interests:
+				err = fmt.Errorf(
+					"%w: acc %s/%s interests count %d, limit %d", ErrQuotaExceeded, groupId, userId, u.Interests,
+					limits.Interests,
+				)
+			case checkPublic && u.Public > limits.Public:
+				err = fmt.Errorf(
+					"%w: acc %s/%s public interests count %d, limit %d", ErrQuotaExceeded, groupId, userId, u.Public,
+					limits.Public,
+				)
+			}
+		}
+	}
+	return
+}
+
+func (qm quotaMiddleware) Close() error {
+	return qm.stor.Close()
+}
+
+func (qm quotaMiddleware) Create(ctx context.Context, id, groupId, userId string, sd interest.Data) (err error) {
+	return qm.stor.Create(WithQuotas(ctx, qm.quotas), id, groupId, userId, sd)
+}
+
+func (qm quotaMiddleware) Read(ctx context.Context, id, groupId, userId string, internal bool) (sd interest.Data, ownerGroupId, ownerUserId string, err error) {
+	return qm.stor.Read(ctx, id, groupId, userId, internal)
+}
+
+func (qm quotaMiddleware) Update(ctx context.Context, id, groupId, userId string, internal bool, sd interest.Data) (prev interest.Data, err error) {
+	return qm.stor.Update(WithQuotas(ctx, qm.quotas), id, groupId, userId, internal, sd)
+}
+
+func (qm quotaMiddleware) UpdateFollowers(ctx context.Context, id string, count int64) (err error) {
+	return qm.stor.UpdateFollowers(ctx, id, count)
+}
+
+func (qm quotaMiddleware) UpdateResultTime(ctx context.Context, id string, last time.Time) (err error) {
+	return qm.stor.UpdateResultTime(ctx, id, last)
+}
+
+func (qm quotaMiddleware) SetEnabledBatch(ctx context.Context, ids []string, enabled bool, enabledSince time.Time) (n int64, condIds []string, err error) {
+	return qm.stor.SetEnabledBatch(ctx, ids, enabled, enabledSince)
+}
+
+func (qm quotaMiddleware) ChangeOwner(ctx context.Context, oldGroupId, oldUserId, newGroupId, newUserId string) (n int64, err error) {
+	return qm.stor.ChangeOwner(WithQuotas(ctx, qm.quotas), oldGroupId, oldUserId, newGroupId, newUserId)
+}
+
+func (qm quotaMiddleware) Delete(ctx context.Context, id, groupId, userId string) (sd interest.Data, err error) {
+	return qm.stor.Delete(ctx, id, groupId, userId)
+}
+
+func (qm quotaMiddleware) Restore(ctx context.Context, id, groupId, userId string) (sd interest.Data, err error) {
+	return qm.stor.Restore(WithQuotas(ctx, qm.quotas), id, groupId, userId)
+}
+
+func (qm quotaMiddleware) ListOwned(ctx context.Context, groupId, userId, cursor string, limit uint32) (page []interest.Interest, err error) {
+	return qm.stor.ListOwned(ctx, groupId, userId, cursor, limit)
+}
+
+func (qm quotaMiddleware) ListDeleted(ctx context.Context, groupId, userId, cursor string, limit uint32) (page []interest.Deleted, err error) {
+	return qm.stor.ListDeleted(ctx, groupId, userId, cursor, limit)
+}
+
+func (qm quotaMiddleware) Purge(ctx context.Context, groupId, userId, actor string) (p interest.Purged, err error) {
+	return qm.stor.Purge(ctx, groupId, userId, actor)
+}
+
+func (qm quotaMiddleware) ListPurges(ctx context.Context, groupId, userId string) (audits []interest.PurgeAudit, err error) {
+	return qm.stor.ListPurges(ctx, groupId, userId)
+}
+
+func (qm quotaMiddleware) Search(ctx context.Context, q interest.Query, cursor interest.Cursor) (ids []string, err error) {
+	return qm.stor.Search(ctx, q, cursor)
+}
+
+func (qm quotaMiddleware) SearchByCondition(ctx context.Context, q interest.QueryByCondition, cursor string) (page interest.ConditionMatchPage, err error) {
+	return qm.stor.SearchByCondition(ctx, q, cursor)
+}
+
+func (qm quotaMiddleware) SearchByConditionStream(ctx context.Context, condId string, consume func(cm interest.ConditionMatch) (err error)) (expires time.Time, err error) {
+	return qm.stor.SearchByConditionStream(ctx, condId, consume)
+}
+
+func (qm quotaMiddleware) SearchByConditionBatch(ctx context.Context, qs []interest.QueryByConditionCursor) (batch interest.ConditionMatchBatch, err error) {
+	return qm.stor.SearchByConditionBatch(ctx, qs)
+}
+
+func (qm quotaMiddleware) ListRevisions(ctx context.Context, id, groupId, userId string, internal bool, cursor uint64, limit uint32) (revs []interest.Revision, err error) {
+	return qm.stor.ListRevisions(ctx, id, groupId, userId, internal, cursor, limit)
+}
+
+func (qm quotaMiddleware) ReadRevision(ctx context.Context, id, groupId, userId string, internal bool, number uint64) (rev interest.Revision, err error) {
+	return qm.stor.ReadRevision(ctx, id, groupId, userId, internal, number)
+}
+
+func (qm quotaMiddleware) RestoreRevision(ctx context.Context, id, groupId, userId string, internal bool, number uint64, updated time.Time) (rev interest.Revision, prev interest.Data, err error) {
+	return qm.stor.RestoreRevision(WithQuotas(ctx, qm.quotas), id, groupId, userId, internal, number, updated)
+}
+
+func (qm quotaMiddleware) ReadChanges(ctx context.Context, after uint64, limit uint32) (changes []interest.Change, err error) {
+	return qm.stor.ReadChanges(ctx, after, limit)
+}
+
+func (qm quotaMiddleware) Usage(ctx context.Context, groupId, userId string) (u interest.Usage, err error) {
+	return qm.stor.Usage(ctx, groupId, userId)
+}
+
+func (qm quotaMiddleware) Count(ctx context.Context) (count int64, err error) {
+	return qm.stor.Count(ctx)
+}
+
+func (qm quotaMiddleware) CountUsersUnique(ctx context.Context) (count int64, err error) {
+	return qm.stor.CountUsersUnique(ctx)
+}
+
+func checkCondition(limits interest.Limits, cond condition.Condition) (err error) {
+	if limits.ConditionDepth > 0 {
+		if depth := condition.Depth(cond); depth > limits.ConditionDepth {
+			err = fmt.Errorf("%w: condition depth %d, limit %d", ErrQuotaExceeded, depth, limits.ConditionDepth)
+		}
+	}
+	if err == nil && limits.ConditionLeaves > 0 {
+		if leaves := condition.LeafCount(cond); leaves > limits.ConditionLeaves {
+			err = fmt.Errorf("%w: condition leaves count %d, limit %d", ErrQuotaExceeded, leaves, limits.ConditionLeaves)
+		}
+	}
+	return
+}
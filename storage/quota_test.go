@@ -0,0 +1,313 @@
+package storage
+
+import (
+	"context"
+	"github.com/awakari/interests/model/condition"
+	"github.com/awakari/interests/model/interest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestQuotas_Limits(t *testing.T) {
+	q := Quotas{
+		Default: interest.Limits{
+			Interests: 10,
+		},
+		Groups: map[string]GroupQuotas{
+			"group1": {
+				Limits: &interest.Limits{
+					Interests: 20,
+					Public:    2,
+				},
+				Users: map[string]interest.Limits{
+					"user1": {
+						Public: 3,
+					},
+				},
+			},
+			"group2": {
+				Users: map[string]interest.Limits{
+					"user2": {
+						Interests: 30,
+					},
+				},
+			},
+		},
+	}
+	cases := map[string]struct {
+		groupId string
+		userId  string
+		limits  interest.Limits
+	}{
+		"default": {
+			groupId: "group0",
+			userId:  "user0",
+			limits: interest.Limits{
+				Interests: 10,
+			},
+		},
+		"group": {
+			groupId: "group1",
+			userId:  "user0",
+			limits: interest.Limits{
+				Interests: 20,
+				Public:    2,
+			},
+		},
+		"user replaces group": {
+			groupId: "group1",
+			userId:  "user1",
+			limits: interest.Limits{
+				Public: 3,
+			},
+		},
+		"group without own limits": {
+			groupId: "group2",
+			userId:  "user0",
+			limits: interest.Limits{
+				Interests: 10,
+			},
+		},
+		"user in group without own limits": {
+			groupId: "group2",
+			userId:  "user2",
+			limits: interest.Limits{
+				Interests: 30,
+			},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, c.limits, q.Limits(c.groupId, c.userId))
+		})
+	}
+	assert.False(t, q.Empty())
+	assert.True(t, Quotas{}.Empty())
+}
+
+func TestLoadQuotas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotas.json")
+	err := os.WriteFile(path, []byte(`{
+		"default": {"interests": 100, "conditionDepth": 3},
+		"groups": {"group0": {"users": {"user0": {"public": 5}}}}
+	}`), 0600)
+	require.Nil(t, err)
+	q, err := LoadQuotas(path)
+	require.Nil(t, err)
+	assert.Equal(t, interest.Limits{Interests: 100, ConditionDepth: 3}, q.Limits("group1", "user0"))
+	assert.Equal(t, interest.Limits{Public: 5}, q.Limits("group0", "user0"))
+	q, err = LoadQuotas("")
+	assert.Nil(t, err)
+	assert.True(t, q.Empty())
+	_, err = LoadQuotas(filepath.Join(t.TempDir(), "missing.json"))
+	assert.NotNil(t, err)
+}
+
+func TestEnforceQuotas(t *testing.T) {
+	nested := condition.NewGroupCondition(condition.NewCondition(false), condition.GroupLogicAnd, []condition.Condition{
+		newTextCondition("cond0"),
+		condition.NewGroupCondition(condition.NewCondition(false), condition.GroupLogicOr, []condition.Condition{
+			newTextCondition("cond1"),
+			newTextCondition("cond2"),
+		}),
+	})
+	cases := map[string]struct {
+		noQuotas bool
+		limits   interest.Limits
+		conds    []condition.Condition
+		added    interest.Usage
+		usage    interest.Usage
+		usageErr error
+		counted  bool
+		err      error
+	}{
+		"no quotas": {
+			noQuotas: true,
+			limits: interest.Limits{
+				Interests:      1,
+				ConditionDepth: 1,
+			},
+			conds: []condition.Condition{
+				nested,
+			},
+			added: interest.Usage{
+				Interests: 1,
+			},
+			usage: interest.Usage{
+				Interests: 2,
+			},
+		},
+		"unlimited": {
+			conds: []condition.Condition{
+				nested,
+			},
+			added: interest.Usage{
+				Interests: 1,
+				Public:    1,
+			},
+		},
+		"within limits": {
+			limits: interest.Limits{
+				Interests:       4,
+				Public:          2,
+				ConditionDepth:  3,
+				ConditionLeaves: 3,
+			},
+			conds: []condition.Condition{
+				nested,
+			},
+			added: interest.Usage{
+				Interests: 1,
+				Public:    1,
+			},
+			usage: interest.Usage{
+				Interests: 4,
+				Public:    2,
+			},
+			counted: true,
+		},
+		"interests exceeded": {
+			limits: interest.Limits{
+				Interests: 3,
+			},
+			added: interest.Usage{
+				Interests: 1,
+			},
+			usage: interest.Usage{
+				Interests: 4,
+			},
+			counted: true,
+			err:     ErrQuotaExceeded,
+		},
+		"public exceeded": {
+			limits: interest.Limits{
+				Public: 1,
+			},
+			added: interest.Usage{
+				Public: 1,
+			},
+			usage: interest.Usage{
+				Interests: 3,
+				Public:    2,
+			},
+			counted: true,
+			err:     ErrQuotaExceeded,
+		},
+		"nothing added is not counted": {
+			limits: interest.Limits{
+				Interests: 1,
+				Public:    1,
+			},
+			usage: interest.Usage{
+				Interests: 3,
+				Public:    2,
+			},
+		},
+		"depth exceeded": {
+			limits: interest.Limits{
+				ConditionDepth: 2,
+			},
+			conds: []condition.Condition{
+				newTextCondition("cond0"),
+				nested,
+			},
+			err: ErrQuotaExceeded,
+		},
+		"leaves exceeded": {
+			limits: interest.Limits{
+				ConditionLeaves: 2,
+			},
+			conds: []condition.Condition{
+				nested,
+			},
+			err: ErrQuotaExceeded,
+		},
+		"usage fails": {
+			limits: interest.Limits{
+				Interests: 10,
+			},
+			added: interest.Usage{
+				Interests: 1,
+			},
+			usageErr: ErrInternal,
+			counted:  true,
+			err:      ErrInternal,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx := context.TODO()
+			if !c.noQuotas {
+				ctx = WithQuotas(ctx, Quotas{
+					Default: c.limits,
+				})
+			}
+			var counted bool
+			err := EnforceQuotas(ctx, "group0", "user0", c.conds, c.added, func() (u interest.Usage, err error) {
+				counted = true
+				return c.usage, c.usageErr
+			})
+			assert.ErrorIs(t, err, c.err)
+			assert.Equal(t, c.counted, counted)
+		})
+	}
+}
+
+// quotasCapturingStorage fails with ErrQuotaExceeded on the write when the quotas are passed with the context.
+type quotasCapturingStorage struct {
+	Storage
+}
+
+func (s quotasCapturingStorage) Create(ctx context.Context, id, groupId, userId string, sd interest.Data) (err error) {
+	return s.enforce(ctx, groupId, userId)
+}
+
+func (s quotasCapturingStorage) Update(ctx context.Context, id, groupId, userId string, internal bool, sd interest.Data) (prev interest.Data, err error) {
+	err = s.enforce(ctx, groupId, userId)
+	return
+}
+
+func (s quotasCapturingStorage) ChangeOwner(ctx context.Context, oldGroupId, oldUserId, newGroupId, newUserId string) (n int64, err error) {
+	err = s.enforce(ctx, newGroupId, newUserId)
+	return
+}
+
+func (s quotasCapturingStorage) Restore(ctx context.Context, id, groupId, userId string) (sd interest.Data, err error) {
+	err = s.enforce(ctx, groupId, userId)
+	return
+}
+
+func (s quotasCapturingStorage) Delete(ctx context.Context, id, groupId, userId string) (sd interest.Data, err error) {
+	err = s.enforce(ctx, groupId, userId)
+	return
+}
+
+func (s quotasCapturingStorage) enforce(ctx context.Context, groupId, userId string) (err error) {
+	return EnforceQuotas(ctx, groupId, userId, nil, interest.Usage{Interests: 1}, func() (u interest.Usage, err error) {
+		u.Interests = 2
+		return
+	})
+}
+
+func TestQuotaMiddleware_WithQuotas(t *testing.T) {
+	ctx := context.TODO()
+	s := NewQuotaMiddleware(quotasCapturingStorage{}, Quotas{
+		Default: interest.Limits{
+			Interests: 1,
+		},
+	})
+	err := s.Create(ctx, "interest0", "group0", "user0", interest.Data{})
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	_, err = s.Update(ctx, "interest0", "group0", "user0", false, interest.Data{})
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	_, err = s.ChangeOwner(ctx, "group0", "user0", "group1", "user1")
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	_, err = s.Restore(ctx, "interest0", "group0", "user0")
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	// not limited
+	_, err = s.Delete(ctx, "interest0", "group0", "user0")
+	assert.Nil(t, err)
+}
@@ -0,0 +1,3 @@
+// Package sqlite contains the embedded SQLite interests storage implementation for the single node deployments.
+// The pure Go driver is used, so the service may still be built with CGO_ENABLED=0.
+package sqlite
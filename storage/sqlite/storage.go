@@ -0,0 +1,1240 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/awakari/interests/config"
+	"github.com/awakari/interests/model/condition"
+	"github.com/awakari/interests/model/interest"
+	"github.com/awakari/interests/storage"
+	"github.com/awakari/interests/storage/jsoncond"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type storageImpl struct {
+	db               *sql.DB
+	tbl              string
+	tblCondIds       string
+	tblChanges       string
+	tblRevisions     string
+	tblPurges        string
+	resultTtlDefault time.Duration
+	retentionPeriod  time.Duration
+}
+
+// the condition ids are kept in the separate table to have them indexed for the search by condition.
+// the revisions table group_id and user_id identify the account made the revision.
+// the purges table keeps the audit records regardless of the retention period, the condition ids are the JSON array.
+// the time values are stored as unix microseconds, 0 means "not set" the same way as the zero time in the model.
+const schema = `
+CREATE TABLE IF NOT EXISTS %[1]s (
+	id            TEXT    NOT NULL PRIMARY KEY,
+	group_id      TEXT    NOT NULL,
+	user_id       TEXT    NOT NULL,
+	descr         TEXT    NOT NULL DEFAULT '',
+	enabled       INTEGER NOT NULL DEFAULT 0,
+	enabled_since INTEGER NOT NULL DEFAULT 0,
+	expires       INTEGER NOT NULL DEFAULT 0,
+	created       INTEGER NOT NULL DEFAULT 0,
+	updated       INTEGER NOT NULL DEFAULT 0,
+	result        INTEGER NOT NULL DEFAULT 0,
+	public        INTEGER NOT NULL DEFAULT 0,
+	followers     INTEGER NOT NULL DEFAULT 0,
+	cond          TEXT    NOT NULL,
+	deleted_at    INTEGER
+);
+CREATE TABLE IF NOT EXISTS %[2]s (
+	cond_id     TEXT NOT NULL,
+	interest_id TEXT NOT NULL,
+	PRIMARY KEY (cond_id, interest_id)
+);
+CREATE INDEX IF NOT EXISTS %[3]s ON %[2]s (interest_id);
+CREATE INDEX IF NOT EXISTS %[4]s ON %[1]s (group_id, user_id, id);
+CREATE INDEX IF NOT EXISTS %[5]s ON %[1]s (followers, id);
+CREATE INDEX IF NOT EXISTS %[6]s ON %[1]s (created, id);
+CREATE INDEX IF NOT EXISTS %[7]s ON %[1]s (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE TABLE IF NOT EXISTS %[8]s (
+	position      INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	type          INTEGER NOT NULL,
+	interest_id   TEXT    NOT NULL,
+	group_id      TEXT    NOT NULL,
+	user_id       TEXT    NOT NULL,
+	time          INTEGER NOT NULL,
+	enabled       INTEGER NOT NULL,
+	enabled_since INTEGER NOT NULL,
+	cond_before   TEXT,
+	cond_after    TEXT
+);
+CREATE INDEX IF NOT EXISTS %[9]s ON %[8]s (time);
+CREATE TABLE IF NOT EXISTS %[10]s (
+	interest_id   TEXT    NOT NULL,
+	number        INTEGER NOT NULL,
+	group_id      TEXT    NOT NULL,
+	user_id       TEXT    NOT NULL,
+	time          INTEGER NOT NULL,
+	descr         TEXT    NOT NULL,
+	enabled       INTEGER NOT NULL,
+	enabled_since INTEGER NOT NULL,
+	expires       INTEGER NOT NULL,
+	created       INTEGER NOT NULL,
+	updated       INTEGER NOT NULL,
+	result        INTEGER NOT NULL,
+	public        INTEGER NOT NULL,
+	followers     INTEGER NOT NULL,
+	cond          TEXT    NOT NULL,
+	PRIMARY KEY (interest_id, number)
+);
+CREATE TABLE IF NOT EXISTS %[11]s (
+	group_id TEXT    NOT NULL,
+	user_id  TEXT    NOT NULL,
+	time     INTEGER NOT NULL,
+	actor    TEXT    NOT NULL,
+	count    INTEGER NOT NULL,
+	deleted  INTEGER NOT NULL,
+	cond_ids TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS %[12]s ON %[11]s (group_id, user_id, time);
+`
+
+const colsData = "descr, enabled, enabled_since, expires, created, updated, result, public, followers, cond, group_id, user_id"
+
+const (
+	queryCreate = `INSERT INTO %s (id, group_id, user_id, descr, enabled, expires, created, updated, public, followers, cond)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	queryCreateCondId  = `INSERT INTO %s (cond_id, interest_id) VALUES (?, ?)`
+	queryDeleteCondIds = `DELETE FROM %s WHERE interest_id = ?`
+	queryRead          = `SELECT ` + colsData + ` FROM %s
+WHERE id = ? AND deleted_at IS NULL AND (? OR (group_id = ? AND user_id = ?) OR public)`
+	queryReadOwn = `SELECT ` + colsData + ` FROM %s
+WHERE id = ? AND deleted_at IS NULL AND (? OR (group_id = ? AND user_id = ?))`
+	queryUpdate = `UPDATE %s SET descr = ?, enabled = ?, expires = ?, updated = ?, public = ?, cond = ?
+WHERE id = ?`
+	queryUpdateFollowers = `UPDATE %s SET followers = ? WHERE id = ? AND deleted_at IS NULL`
+	queryUpdateResult    = `UPDATE %s SET result = ? WHERE id = ? AND deleted_at IS NULL`
+	// count only the actually modified rows
+	querySetEnabledBatch = `UPDATE %s SET enabled = ?, enabled_since = ?
+WHERE id IN (%s) AND deleted_at IS NULL AND (enabled <> ? OR enabled_since <> ?)
+RETURNING id, group_id, user_id, enabled, enabled_since, cond, public`
+	querySetEnabledBatchKeepSince = `UPDATE %s SET enabled = ?
+WHERE id IN (%s) AND deleted_at IS NULL AND enabled <> ?
+RETURNING id, group_id, user_id, enabled, enabled_since, cond, public`
+	queryChangeOwner = `UPDATE %s SET group_id = ?, user_id = ?
+WHERE group_id = ? AND user_id = ? AND deleted_at IS NULL AND (group_id <> ? OR user_id <> ?)
+RETURNING id, group_id, user_id, enabled, enabled_since, cond, public`
+	queryDelete        = `UPDATE %s SET deleted_at = ? WHERE id = ?`
+	queryReadDeletedAt = `SELECT group_id, user_id, deleted_at FROM %s WHERE id = ?`
+	queryRestore       = `UPDATE %s SET deleted_at = NULL WHERE id = ?
+RETURNING ` + colsData
+	queryListOwned = `SELECT id, ` + colsData + ` FROM %s
+WHERE group_id = ? AND user_id = ? AND deleted_at IS NULL AND id > ?
+ORDER BY id
+LIMIT ?`
+	queryListDeleted = `SELECT id, deleted_at, ` + colsData + ` FROM %s
+WHERE group_id = ? AND user_id = ? AND deleted_at IS NOT NULL AND deleted_at >= ? AND id > ?
+ORDER BY id
+LIMIT ?`
+	queryReadOwned = `SELECT id, deleted_at IS NOT NULL, enabled, enabled_since, cond FROM %s
+WHERE group_id = ? AND user_id = ?
+ORDER BY id`
+	// removes the condition ids, the revisions or the changes of the account interests
+	queryPurgeOwnedRefs = `DELETE FROM %s WHERE interest_id IN (SELECT id FROM %s WHERE group_id = ? AND user_id = ?)`
+	queryPurgeOwned     = `DELETE FROM %s WHERE group_id = ? AND user_id = ?`
+	queryCreatePurge    = `INSERT INTO %s (group_id, user_id, time, actor, count, deleted, cond_ids)
+VALUES (?, ?, ?, ?, ?, ?, ?)`
+	queryListPurges = `SELECT time, actor, count, deleted, cond_ids FROM %s
+WHERE group_id = ? AND user_id = ?
+ORDER BY time, rowid`
+	querySearchByCondition = `SELECT i.id, i.cond, i.expires FROM %s AS i
+JOIN %s AS c ON c.interest_id = i.id
+WHERE c.cond_id = ? AND i.id > ? AND i.deleted_at IS NULL AND i.enabled
+AND i.enabled_since < ? AND (i.expires = 0 OR i.expires > ?)
+ORDER BY i.id
+LIMIT ?`
+	querySearchByConditionStream = `SELECT i.id, i.cond, i.expires, i.enabled_since FROM %s AS i
+JOIN %s AS c ON c.interest_id = i.id
+WHERE c.cond_id = ? AND i.deleted_at IS NULL AND i.enabled AND (i.expires = 0 OR i.expires > ?)
+ORDER BY i.id`
+	querySearchByConditionBatch = `SELECT i.id, c.cond_id, i.cond, i.expires FROM %s AS i
+JOIN %s AS c ON c.interest_id = i.id
+WHERE c.cond_id IN (%s) AND i.id > ? AND i.deleted_at IS NULL AND i.enabled
+AND i.enabled_since < ? AND (i.expires = 0 OR i.expires > ?)
+ORDER BY i.id`
+	queryUsage = `SELECT COUNT(*), COUNT(*) FILTER (WHERE public) FROM %s
+WHERE group_id = ? AND user_id = ? AND deleted_at IS NULL`
+	queryCount            = `SELECT COUNT(*) FROM %s WHERE deleted_at IS NULL`
+	queryCountUsersUnique = `SELECT COUNT(DISTINCT user_id) FROM %s WHERE deleted_at IS NULL`
+	// removes the condition ids or the revisions of the purged interests
+	queryPurgeDeletedRefs = `DELETE FROM %s WHERE interest_id IN (SELECT id FROM %s WHERE deleted_at < ?)`
+	queryPurgeDeleted     = `DELETE FROM %s WHERE deleted_at < ?`
+	queryCreateChange     = `INSERT INTO %s (type, interest_id, group_id, user_id, time, enabled, enabled_since, cond_before, cond_after)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	queryReadChanges = `SELECT position, type, interest_id, group_id, user_id, time, enabled, enabled_since, cond_before, cond_after
+FROM %s
+WHERE position > ?
+ORDER BY position
+LIMIT ?`
+	queryPurgeChanges = `DELETE FROM %s WHERE time < ?`
+	// snapshot the interest data as is after the write
+	queryCreateRevision = `INSERT INTO %[1]s (interest_id, number, group_id, user_id, time, descr, enabled, enabled_since, expires, created, updated, result, public, followers, cond)
+SELECT id, COALESCE((SELECT MAX(number) FROM %[1]s WHERE interest_id = ?), 0) + 1, ?, ?, ?, descr, enabled, enabled_since, expires, created, updated, result, public, followers, cond
+FROM %[2]s
+WHERE id = ?`
+	queryCheckOwn = `SELECT 1 FROM %s
+WHERE id = ? AND deleted_at IS NULL AND (? OR (group_id = ? AND user_id = ?))`
+	queryListRevisions = `SELECT number, time, ` + colsData + ` FROM %s
+WHERE interest_id = ? AND number > ?
+ORDER BY number
+LIMIT ?`
+	queryReadRevision = `SELECT number, time, ` + colsData + ` FROM %s
+WHERE interest_id = ? AND number = ?`
+)
+
+const driverName = "sqlite"
+
+// funcRegexp is the name of the function SQLite uses to evaluate the "X REGEXP Y" operator.
+const funcRegexp = "regexp"
+
+func init() {
+	err := sqlite.RegisterDeterministicScalarFunction(funcRegexp, 2, matchRegexp)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func matchRegexp(_ *sqlite.FunctionContext, args []driver.Value) (v driver.Value, err error) {
+	pattern, _ := args[0].(string)
+	txt, _ := args[1].(string)
+	var re *regexp.Regexp
+	re, err = regexp.Compile(pattern)
+	if err == nil {
+		v = re.MatchString(txt)
+	}
+	return
+}
+
+func NewStorage(ctx context.Context, cfgDb config.DbConfig) (s storage.Storage, err error) {
+	var db *sql.DB
+	db, err = sql.Open(driverName, cfgDb.Uri)
+	if err == nil {
+		// SQLite allows a single writer only, in addition every connection to ":memory:" opens a separate database
+		db.SetMaxOpenConns(1)
+		stor := storageImpl{
+			db:               db,
+			tbl:              quoteIdent(cfgDb.Table.Name),
+			tblCondIds:       quoteIdent(cfgDb.Table.Name + "_cond_ids"),
+			tblChanges:       quoteIdent(cfgDb.Table.Name + "_changes"),
+			tblRevisions:     quoteIdent(cfgDb.Table.Name + "_revisions"),
+			tblPurges:        quoteIdent(cfgDb.Table.Name + "_purges"),
+			resultTtlDefault: cfgDb.ResultTtl,
+			retentionPeriod:  cfgDb.Table.Retention,
+		}
+		err = stor.ensureSchema(ctx, cfgDb.Table.Name)
+		switch err {
+		case nil:
+			s = stor
+		default:
+			_ = db.Close()
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %s", storage.ErrInternal, err)
+	}
+	return
+}
+
+func (s storageImpl) ensureSchema(ctx context.Context, tblName string) (err error) {
+	idx := func(suffix string) string {
+		return quoteIdent(tblName + "_" + suffix)
+	}
+	_, err = s.db.ExecContext(
+		ctx,
+		fmt.Sprintf(
+			schema,
+			s.tbl, s.tblCondIds, idx("cond_ids_interest_id"), idx("owner"), idx("followers"), idx("created"),
+			idx("deleted_at"), s.tblChanges, idx("changes_time"), s.tblRevisions, s.tblPurges, idx("purges_owner"),
+		),
+	)
+	return
+}
+
+func (s storageImpl) Close() error {
+	return s.db.Close()
+}
+
+func (s storageImpl) Create(ctx context.Context, id, groupId, userId string, sd interest.Data) (err error) {
+	var cond []byte
+	cond, err = jsoncond.Encode(sd.Condition)
+	if err == nil {
+		err = s.inTx(ctx, func(tx *sql.Tx) (err error) {
+			err = s.purgeDeleted(ctx, tx)
+			if err == nil {
+				_, err = tx.ExecContext(
+					ctx, fmt.Sprintf(queryCreate, s.tbl),
+					id, groupId, userId, sd.Description, sd.Enabled, timeToDb(sd.Expires), timeToDb(sd.Created),
+					timeToDb(sd.Updated), sd.Public, sd.Followers, string(cond),
+				)
+				var sqliteErr *sqlite.Error
+				switch {
+				case errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+					err = fmt.Errorf("%w: id already in use: %s", storage.ErrConflict, id)
+				case err == nil:
+					err = s.enforceQuotas(ctx, tx, groupId, userId, []condition.Condition{sd.Condition}, interest.UsageAdded(nil, sd))
+				}
+				if err == nil {
+					err = s.insertCondIds(ctx, tx, id, sd.Condition)
+				}
+				if err == nil {
+					err = s.insertRevision(ctx, tx, id, groupId, userId)
+				}
+				if err == nil {
+					c := interest.NewChange(interest.ChangeCreated, id, groupId, userId, nil, sd.Condition)
+					c.Enabled = sd.Enabled
+					err = s.insertChange(ctx, tx, c)
+				}
+			}
+			return
+		})
+		if err != nil && !errors.Is(err, storage.ErrConflict) && !errors.Is(err, storage.ErrQuotaExceeded) {
+			err = fmt.Errorf("%w: failed to insert: %s", storage.ErrInternal, err)
+		}
+	}
+	return
+}
+
+func (s storageImpl) Read(ctx context.Context, id, groupId, userId string, internal bool) (sd interest.Data, ownerGroupId, ownerUserId string, err error) {
+	row := s.db.QueryRowContext(ctx, fmt.Sprintf(queryRead, s.tbl), id, internal, groupId, userId)
+	sd, ownerGroupId, ownerUserId, err = scanData(row)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = fmt.Errorf("%w: id=%s, acc=%s/%s", storage.ErrNotFound, id, groupId, userId)
+	case err != nil:
+		err = fmt.Errorf("%w: failed to find by id: %s, acc: %s/%s, %s", storage.ErrInternal, id, groupId, userId, err)
+	}
+	return
+}
+
+func (s storageImpl) Update(ctx context.Context, id, groupId, userId string, internal bool, d interest.Data) (prev interest.Data, err error) {
+	err = s.inTx(ctx, func(tx *sql.Tx) (err error) {
+		prev, err = s.update(ctx, tx, id, groupId, userId, internal, d)
+		return
+	})
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = fmt.Errorf("%w: not found, id: %s, acc: %s/%s", storage.ErrNotFound, id, groupId, userId)
+	case errors.Is(err, storage.ErrQuotaExceeded):
+	case err != nil:
+		err = fmt.Errorf("%w: failed to update interest, id: %s, err: %s", storage.ErrInternal, id, err)
+	}
+	return
+}
+
+func (s storageImpl) update(ctx context.Context, tx *sql.Tx, id, groupId, userId string, internal bool, d interest.Data) (prev interest.Data, err error) {
+	var cond []byte
+	cond, err = jsoncond.Encode(d.Condition)
+	var ownerGroupId, ownerUserId string
+	if err == nil {
+		row := tx.QueryRowContext(ctx, fmt.Sprintf(queryReadOwn, s.tbl), id, internal, groupId, userId)
+		prev, ownerGroupId, ownerUserId, err = scanData(row)
+	}
+	if err == nil {
+		_, err = tx.ExecContext(
+			ctx, fmt.Sprintf(queryUpdate, s.tbl),
+			d.Description, d.Enabled, timeToDb(d.Expires), timeToDb(d.Updated), d.Public, string(cond), id,
+		)
+	}
+	if err == nil {
+		conds := []condition.Condition{d.Condition}
+		err = s.enforceQuotas(ctx, tx, ownerGroupId, ownerUserId, conds, interest.UsageAdded(&prev, d))
+	}
+	if err == nil {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(queryDeleteCondIds, s.tblCondIds), id)
+	}
+	if err == nil {
+		err = s.insertCondIds(ctx, tx, id, d.Condition)
+	}
+	if err == nil {
+		err = s.insertRevision(ctx, tx, id, groupId, userId)
+	}
+	if err == nil {
+		c := interest.NewChange(interest.ChangeUpdated, id, ownerGroupId, ownerUserId, prev.Condition, d.Condition)
+		c.Enabled = d.Enabled
+		c.EnabledSince = prev.EnabledSince
+		err = s.insertChange(ctx, tx, c)
+	}
+	return
+}
+
+func (s storageImpl) UpdateFollowers(ctx context.Context, id string, count int64) (err error) {
+	var n int64
+	n, err = s.exec(ctx, fmt.Sprintf(queryUpdateFollowers, s.tbl), count, id)
+	switch {
+	case err == nil && n < 1:
+		err = fmt.Errorf("%w: not found, id: %s", storage.ErrNotFound, id)
+	case err != nil:
+		err = fmt.Errorf("%w: failed to update interest, id: %s, err: %s", storage.ErrInternal, id, err)
+	}
+	return
+}
+
+func (s storageImpl) UpdateResultTime(ctx context.Context, id string, last time.Time) (err error) {
+	var n int64
+	n, err = s.exec(ctx, fmt.Sprintf(queryUpdateResult, s.tbl), timeToDb(last), id)
+	switch {
+	case err == nil && n < 1:
+		err = fmt.Errorf("%w: not found, id: %s", storage.ErrNotFound, id)
+	case err != nil:
+		err = fmt.Errorf("%w: failed to update interest, id: %s, err: %s", storage.ErrInternal, id, err)
+	}
+	return
+}
+
+func (s storageImpl) SetEnabledBatch(ctx context.Context, ids []string, enabled bool, enabledSince time.Time) (n int64, condIds []string, err error) {
+	if len(ids) == 0 {
+		return
+	}
+	placeholders, idArgs := inArgs(ids)
+	var query string
+	var args []any
+	switch enabledSince.IsZero() {
+	case true:
+		query = fmt.Sprintf(querySetEnabledBatchKeepSince, s.tbl, placeholders)
+		args = append([]any{enabled}, idArgs...)
+		args = append(args, enabled)
+	default:
+		since := timeToDb(enabledSince)
+		query = fmt.Sprintf(querySetEnabledBatch, s.tbl, placeholders)
+		args = append([]any{enabled, since}, idArgs...)
+		args = append(args, enabled, since)
+	}
+	n, condIds, err = s.execChanges(ctx, interest.ChangeEnabled, nil, query, args...)
+	if err != nil {
+		err = fmt.Errorf("%w: failed to update interest, ids: %s, err: %s", storage.ErrInternal, ids, err)
+	}
+	return
+}
+
+func (s storageImpl) ChangeOwner(ctx context.Context, oldGroupId, oldUserId, newGroupId, newUserId string) (n int64, err error) {
+	enforce := func(tx *sql.Tx, moved interest.Usage, conds []condition.Condition) (err error) {
+		return s.enforceQuotas(ctx, tx, newGroupId, newUserId, conds, moved)
+	}
+	n, _, err = s.execChanges(
+		ctx, interest.ChangeOwner, enforce, fmt.Sprintf(queryChangeOwner, s.tbl),
+		newGroupId, newUserId, oldGroupId, oldUserId, newGroupId, newUserId,
+	)
+	if err != nil && !errors.Is(err, storage.ErrQuotaExceeded) {
+		err = fmt.Errorf("%w: failed to change owner: %s", storage.ErrInternal, err)
+	}
+	return
+}
+
+func (s storageImpl) Delete(ctx context.Context, id, groupId, userId string) (sd interest.Data, err error) {
+	err = s.inTx(ctx, func(tx *sql.Tx) (err error) {
+		row := tx.QueryRowContext(ctx, fmt.Sprintf(queryReadOwn, s.tbl), id, false, groupId, userId)
+		sd, _, _, err = scanData(row)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, fmt.Sprintf(queryDelete, s.tbl), timeToDb(time.Now()), id)
+		}
+		if err == nil {
+			c := interest.NewChange(interest.ChangeDeleted, id, groupId, userId, sd.Condition, nil)
+			c.Enabled = sd.Enabled
+			c.EnabledSince = sd.EnabledSince
+			err = s.insertChange(ctx, tx, c)
+		}
+		return
+	})
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = fmt.Errorf("%w: id=%s, acc=%s/%s", storage.ErrNotFound, id, groupId, userId)
+	case err != nil:
+		err = fmt.Errorf("%w: failed to delete by id: %s, acc: %s/%s, %s", storage.ErrInternal, id, groupId, userId, err)
+	}
+	return
+}
+
+func (s storageImpl) Restore(ctx context.Context, id, groupId, userId string) (sd interest.Data, err error) {
+	err = s.inTx(ctx, func(tx *sql.Tx) (err error) {
+		var ownerGroupId, ownerUserId string
+		var deletedAt sql.NullInt64
+		row := tx.QueryRowContext(ctx, fmt.Sprintf(queryReadDeletedAt, s.tbl), id)
+		err = row.Scan(&ownerGroupId, &ownerUserId, &deletedAt)
+		switch {
+		case err != nil:
+		case !deletedAt.Valid:
+			err = fmt.Errorf("%w: id already in use: %s", storage.ErrConflict, id)
+		case ownerGroupId != groupId, ownerUserId != userId, deletedAt.Int64 < s.deletedSince():
+			err = sql.ErrNoRows
+		default:
+			row = tx.QueryRowContext(ctx, fmt.Sprintf(queryRestore, s.tbl), id)
+			sd, _, _, err = scanData(row)
+		}
+		if err == nil {
+			err = s.enforceQuotas(ctx, tx, groupId, userId, []condition.Condition{sd.Condition}, interest.UsageAdded(nil, sd))
+		}
+		if err == nil {
+			c := interest.NewChange(interest.ChangeRestored, id, groupId, userId, nil, sd.Condition)
+			c.Enabled = sd.Enabled
+			c.EnabledSince = sd.EnabledSince
+			err = s.insertChange(ctx, tx, c)
+		}
+		return
+	})
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = fmt.Errorf("%w: id=%s, acc=%s/%s", storage.ErrNotFound, id, groupId, userId)
+	case errors.Is(err, storage.ErrConflict), errors.Is(err, storage.ErrQuotaExceeded):
+	case err != nil:
+		err = fmt.Errorf("%w: failed to restore by id: %s, acc: %s/%s, %s", storage.ErrInternal, id, groupId, userId, err)
+	}
+	return
+}
+
+func (s storageImpl) ListOwned(ctx context.Context, groupId, userId, cursor string, limit uint32) (page []interest.Interest, err error) {
+	var rows *sql.Rows
+	rows, err = s.db.QueryContext(ctx, fmt.Sprintf(queryListOwned, s.tbl), groupId, userId, cursor, limitToDb(limit))
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var i interest.Interest
+			i.Data, i.GroupId, i.UserId, err = scanData(prefixScanner{
+				row: rows,
+				prefix: []any{
+					&i.Id,
+				},
+			})
+			if err != nil {
+				break
+			}
+			page = append(page, i)
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: failed to list owned, acc: %s/%s, %s", storage.ErrInternal, groupId, userId, err)
+	}
+	return
+}
+
+func (s storageImpl) ListDeleted(ctx context.Context, groupId, userId, cursor string, limit uint32) (page []interest.Deleted, err error) {
+	var rows *sql.Rows
+	rows, err = s.db.QueryContext(
+		ctx, fmt.Sprintf(queryListDeleted, s.tbl), groupId, userId, s.deletedSince(), cursor, limitToDb(limit),
+	)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var d interest.Deleted
+			var deletedAt int64
+			d.Data, _, _, err = scanData(prefixScanner{
+				row: rows,
+				prefix: []any{
+					&d.Id, &deletedAt,
+				},
+			})
+			if err != nil {
+				break
+			}
+			d.DeletedAt = timeFromDb(deletedAt)
+			page = append(page, d)
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: failed to list deleted, acc: %s/%s, %s", storage.ErrInternal, groupId, userId, err)
+	}
+	return
+}
+
+// deletedSince returns the earliest deletion time of the interest those retention period is not over yet.
+func (s storageImpl) deletedSince() (t int64) {
+	if s.retentionPeriod > 0 {
+		t = timeToDb(time.Now().Add(-s.retentionPeriod))
+	}
+	return
+}
+
+func (s storageImpl) Purge(ctx context.Context, groupId, userId, actor string) (p interest.Purged, err error) {
+	err = s.inTx(ctx, func(tx *sql.Tx) (err error) {
+		p = interest.Purged{}
+		var changes []interest.Change
+		var rows *sql.Rows
+		rows, err = tx.QueryContext(ctx, fmt.Sprintf(queryReadOwned, s.tbl), groupId, userId)
+		if err == nil {
+			defer rows.Close()
+			for rows.Next() {
+				var id, rawCond string
+				var deleted, enabled bool
+				var enabledSince int64
+				err = rows.Scan(&id, &deleted, &enabled, &enabledSince, &rawCond)
+				var cond condition.Condition
+				if err == nil {
+					cond, err = jsoncond.Decode([]byte(rawCond))
+				}
+				if err != nil {
+					break
+				}
+				p.Add(cond, deleted)
+				var before condition.Condition
+				if !deleted {
+					before = cond
+				}
+				c := interest.NewChange(interest.ChangePurged, id, groupId, userId, before, nil)
+				c.Enabled = enabled
+				c.EnabledSince = timeFromDb(enabledSince)
+				changes = append(changes, c)
+			}
+			if err == nil {
+				err = rows.Err()
+			}
+		}
+		for _, tblRefs := range []string{s.tblCondIds, s.tblRevisions, s.tblChanges} {
+			if err != nil {
+				break
+			}
+			_, err = tx.ExecContext(ctx, fmt.Sprintf(queryPurgeOwnedRefs, tblRefs, s.tbl), groupId, userId)
+		}
+		if err == nil {
+			_, err = tx.ExecContext(ctx, fmt.Sprintf(queryPurgeOwned, s.tbl), groupId, userId)
+		}
+		for _, c := range changes {
+			if err != nil {
+				break
+			}
+			err = s.insertChange(ctx, tx, c)
+		}
+		var condIds []byte
+		if err == nil {
+			condIds, err = json.Marshal(p.CondIds)
+		}
+		if err == nil {
+			_, err = tx.ExecContext(
+				ctx, fmt.Sprintf(queryCreatePurge, s.tblPurges),
+				groupId, userId, timeToDb(time.Now()), actor, p.Count, p.Deleted, string(condIds),
+			)
+		}
+		return
+	})
+	if err != nil {
+		err = fmt.Errorf("%w: failed to purge, acc: %s/%s, %s", storage.ErrInternal, groupId, userId, err)
+	}
+	return
+}
+
+func (s storageImpl) ListPurges(ctx context.Context, groupId, userId string) (audits []interest.PurgeAudit, err error) {
+	var rows *sql.Rows
+	rows, err = s.db.QueryContext(ctx, fmt.Sprintf(queryListPurges, s.tblPurges), groupId, userId)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			a := interest.PurgeAudit{
+				GroupId: groupId,
+				UserId:  userId,
+			}
+			var t int64
+			var condIds string
+			err = rows.Scan(&t, &a.Actor, &a.Count, &a.Deleted, &condIds)
+			if err == nil {
+				a.Time = timeFromDb(t)
+				err = json.Unmarshal([]byte(condIds), &a.CondIds)
+			}
+			if err != nil {
+				break
+			}
+			audits = append(audits, a)
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: failed to list purges, acc: %s/%s, %s", storage.ErrInternal, groupId, userId, err)
+	}
+	return
+}
+
+func (s storageImpl) Search(ctx context.Context, q interest.Query, cursor interest.Cursor) (ids []string, err error) {
+	where := []string{"deleted_at IS NULL"}
+	var args []any
+	switch {
+	case q.All:
+	case q.PrivateOnly:
+		where = append(where, "group_id = ? AND user_id = ? AND NOT public")
+		args = append(args, q.GroupId, q.UserId)
+	case q.IncludePublic:
+		where = append(where, "((group_id = ? AND user_id = ?) OR public)")
+		args = append(args, q.GroupId, q.UserId)
+	default:
+		where = append(where, "group_id = ? AND user_id = ?")
+		args = append(args, q.GroupId, q.UserId)
+	}
+	if q.Pattern != "" {
+		where = append(where, "descr REGEXP ?")
+		args = append(args, q.Pattern)
+	}
+	cmpOp, order := ">", "ASC"
+	if q.Order == interest.OrderDesc {
+		cmpOp, order = "<", "DESC"
+	}
+	var orderBy string
+	switch q.Sort {
+	case interest.SortFollowers:
+		where = append(where, fmt.Sprintf("(followers, id) %s (?, ?)", cmpOp))
+		args = append(args, cursor.Followers, cursor.Id)
+		orderBy = fmt.Sprintf("followers %[1]s, id %[1]s", order)
+	case interest.SortTimeCreated:
+		where = append(where, fmt.Sprintf("(created, id) %s (?, ?)", cmpOp))
+		args = append(args, timeToDb(cursor.CreatedAt), cursor.Id)
+		orderBy = fmt.Sprintf("created %[1]s, id %[1]s", order)
+	default:
+		where = append(where, fmt.Sprintf("id %s ?", cmpOp))
+		args = append(args, cursor.Id)
+		orderBy = fmt.Sprintf("id %s", order)
+	}
+	dbQuery := fmt.Sprintf(
+		"SELECT id FROM %s WHERE %s ORDER BY %s LIMIT ?", s.tbl, strings.Join(where, " AND "), orderBy,
+	)
+	args = append(args, limitToDb(q.Limit))
+	var rows *sql.Rows
+	rows, err = s.db.QueryContext(ctx, dbQuery, args...)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var id string
+			err = rows.Scan(&id)
+			if err != nil {
+				break
+			}
+			ids = append(ids, id)
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: failed to find: query=%v, cursor=%v, %s", storage.ErrInternal, q, cursor, err)
+	}
+	return
+}
+
+func (s storageImpl) SearchByCondition(ctx context.Context, q interest.QueryByCondition, cursor string) (page interest.ConditionMatchPage, err error) {
+	tNow := time.Now().UTC()
+	var rows *sql.Rows
+	rows, err = s.db.QueryContext(
+		ctx, fmt.Sprintf(querySearchByCondition, s.tbl, s.tblCondIds),
+		q.CondId, cursor, timeToDb(tNow), timeToDb(tNow), limitToDb(q.Limit),
+	)
+	if err != nil {
+		err = fmt.Errorf("%w: failed to find: query=%+v, %s", storage.ErrInternal, q, err)
+	} else {
+		defer rows.Close()
+		page.Expires = tNow.Add(s.resultTtlDefault)
+		for rows.Next() {
+			var cm interest.ConditionMatch
+			var rawCond string
+			var expires int64
+			err = rows.Scan(&cm.InterestId, &rawCond, &expires)
+			if err == nil {
+				cm.Condition, err = jsoncond.Decode([]byte(rawCond))
+			}
+			if err != nil {
+				err = fmt.Errorf("%w: failed to decode interest record %s: %s", storage.ErrInternal, cm.InterestId, err)
+				break
+			}
+			page.ConditionMatches = append(page.ConditionMatches, cm)
+			if expiresTime := timeFromDb(expires); !expiresTime.IsZero() && page.Expires.After(expiresTime) {
+				page.Expires = expiresTime
+			}
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+	}
+	return
+}
+
+// SearchByConditionStream reads all the matches before consuming: the single database connection should not be held
+// by a slow consumer.
+func (s storageImpl) SearchByConditionStream(ctx context.Context, condId string, consume func(cm interest.ConditionMatch) (err error)) (expires time.Time, err error) {
+	tNow := time.Now().UTC()
+	expires = tNow.Add(s.resultTtlDefault)
+	var cms []interest.ConditionMatch
+	var rows *sql.Rows
+	rows, err = s.db.QueryContext(
+		ctx, fmt.Sprintf(querySearchByConditionStream, s.tbl, s.tblCondIds),
+		condId, timeToDb(tNow),
+	)
+	if err != nil {
+		err = fmt.Errorf("%w: failed to find: condId=%s, %s", storage.ErrInternal, condId, err)
+	} else {
+		for rows.Next() {
+			var cm interest.ConditionMatch
+			var rawCond string
+			var recExpires, recEnabledSince int64
+			err = rows.Scan(&cm.InterestId, &rawCond, &recExpires, &recEnabledSince)
+			if err != nil {
+				err = fmt.Errorf("%w: failed to decode interest record %s: %s", storage.ErrInternal, cm.InterestId, err)
+				break
+			}
+			if enabledSince := timeFromDb(recEnabledSince); !enabledSince.Before(tNow) {
+				// not enabled yet, the result becomes outdated when it is
+				if expires.After(enabledSince) {
+					expires = enabledSince
+				}
+				continue
+			}
+			cm.Condition, err = jsoncond.Decode([]byte(rawCond))
+			if err != nil {
+				err = fmt.Errorf("%w: failed to decode interest record %s: %s", storage.ErrInternal, cm.InterestId, err)
+				break
+			}
+			cms = append(cms, cm)
+			if expiresTime := timeFromDb(recExpires); !expiresTime.IsZero() && expires.After(expiresTime) {
+				expires = expiresTime
+			}
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+		// release the connection before consuming
+		_ = rows.Close()
+	}
+	for _, cm := range cms {
+		if err != nil {
+			break
+		}
+		err = consume(cm)
+	}
+	return
+}
+
+// SearchByConditionBatch reads a row per every matching condition id of an interest, the rows of the same interest
+// are adjacent due to the ordering.
+func (s storageImpl) SearchByConditionBatch(ctx context.Context, qs []interest.QueryByConditionCursor) (batch interest.ConditionMatchBatch, err error) {
+	tNow := time.Now().UTC()
+	c := storage.NewConditionMatchBatchCollector(qs, tNow.Add(s.resultTtlDefault))
+	batch = c.Batch()
+	if len(qs) == 0 {
+		return
+	}
+	placeholders, args := inArgs(c.CondIds())
+	args = append(args, c.CursorMin(), timeToDb(tNow), timeToDb(tNow))
+	var rows *sql.Rows
+	rows, err = s.db.QueryContext(ctx, fmt.Sprintf(querySearchByConditionBatch, s.tbl, s.tblCondIds, placeholders), args...)
+	if err != nil {
+		err = fmt.Errorf("%w: failed to find: queries=%+v, %s", storage.ErrInternal, qs, err)
+	} else {
+		defer rows.Close()
+		for !c.Full() && rows.Next() {
+			var id, condId, rawCond string
+			var expires int64
+			err = rows.Scan(&id, &condId, &rawCond, &expires)
+			if err == nil && c.Add(id, []string{condId}, timeFromDb(expires)) {
+				var cond condition.Condition
+				cond, err = jsoncond.Decode([]byte(rawCond))
+				c.SetCondition(id, cond)
+			}
+			if err != nil {
+				err = fmt.Errorf("%w: failed to decode interest record %s: %s", storage.ErrInternal, id, err)
+				break
+			}
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+	}
+	return
+}
+
+func (s storageImpl) ListRevisions(ctx context.Context, id, groupId, userId string, internal bool, cursor uint64, limit uint32) (revs []interest.Revision, err error) {
+	err = s.checkOwn(ctx, id, groupId, userId, internal)
+	var rows *sql.Rows
+	if err == nil {
+		rows, err = s.db.QueryContext(ctx, fmt.Sprintf(queryListRevisions, s.tblRevisions), id, cursor, limitToDb(limit))
+	}
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var rev interest.Revision
+			rev, err = scanRevision(rows)
+			if err != nil {
+				break
+			}
+			revs = append(revs, rev)
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+	}
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = fmt.Errorf("%w: not found, id: %s, acc: %s/%s", storage.ErrNotFound, id, groupId, userId)
+	case err != nil:
+		err = fmt.Errorf("%w: failed to list revisions, id: %s, err: %s", storage.ErrInternal, id, err)
+	}
+	return
+}
+
+func (s storageImpl) ReadRevision(ctx context.Context, id, groupId, userId string, internal bool, number uint64) (rev interest.Revision, err error) {
+	err = s.checkOwn(ctx, id, groupId, userId, internal)
+	if err == nil {
+		rev, err = scanRevision(s.db.QueryRowContext(ctx, fmt.Sprintf(queryReadRevision, s.tblRevisions), id, number))
+	}
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = fmt.Errorf("%w: revision not found, id: %s, number: %d, acc: %s/%s", storage.ErrNotFound, id, number, groupId, userId)
+	case err != nil:
+		err = fmt.Errorf("%w: failed to read revision, id: %s, number: %d, err: %s", storage.ErrInternal, id, number, err)
+	}
+	return
+}
+
+func (s storageImpl) RestoreRevision(ctx context.Context, id, groupId, userId string, internal bool, number uint64, updated time.Time) (rev interest.Revision, prev interest.Data, err error) {
+	err = s.inTx(ctx, func(tx *sql.Tx) (err error) {
+		rev, err = scanRevision(tx.QueryRowContext(ctx, fmt.Sprintf(queryReadRevision, s.tblRevisions), id, number))
+		if err == nil {
+			prev, err = s.update(ctx, tx, id, groupId, userId, internal, rev.RestoreData(updated))
+		}
+		return
+	})
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = fmt.Errorf("%w: revision not found, id: %s, number: %d, acc: %s/%s", storage.ErrNotFound, id, number, groupId, userId)
+	case errors.Is(err, storage.ErrQuotaExceeded):
+	case err != nil:
+		err = fmt.Errorf("%w: failed to restore revision, id: %s, number: %d, err: %s", storage.ErrInternal, id, number, err)
+	}
+	return
+}
+
+// checkOwn returns sql.ErrNoRows when the interest is missing or not owned by the specified account.
+func (s storageImpl) checkOwn(ctx context.Context, id, groupId, userId string, internal bool) (err error) {
+	var found int
+	err = s.db.QueryRowContext(ctx, fmt.Sprintf(queryCheckOwn, s.tbl), id, internal, groupId, userId).Scan(&found)
+	return
+}
+
+func (s storageImpl) ReadChanges(ctx context.Context, after uint64, limit uint32) (changes []interest.Change, err error) {
+	var rows *sql.Rows
+	rows, err = s.db.QueryContext(ctx, fmt.Sprintf(queryReadChanges, s.tblChanges), after, limitToDb(limit))
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var c interest.Change
+			c, err = scanChange(rows)
+			if err != nil {
+				break
+			}
+			changes = append(changes, c)
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: failed to read changes after %d: %s", storage.ErrInternal, after, err)
+	}
+	return
+}
+
+func (s storageImpl) Usage(ctx context.Context, groupId, userId string) (u interest.Usage, err error) {
+	err = s.db.QueryRowContext(ctx, fmt.Sprintf(queryUsage, s.tbl), groupId, userId).Scan(&u.Interests, &u.Public)
+	if err != nil {
+		err = fmt.Errorf("%w: failed to count the usage, acc: %s/%s, %s", storage.ErrInternal, groupId, userId, err)
+	}
+	return
+}
+
+func (s storageImpl) Count(ctx context.Context) (count int64, err error) {
+	err = s.db.QueryRowContext(ctx, fmt.Sprintf(queryCount, s.tbl)).Scan(&count)
+	if err != nil {
+		err = fmt.Errorf("%w: failed to count: %s", storage.ErrInternal, err)
+	}
+	return
+}
+
+func (s storageImpl) CountUsersUnique(ctx context.Context) (count int64, err error) {
+	err = s.db.QueryRowContext(ctx, fmt.Sprintf(queryCountUsersUnique, s.tbl)).Scan(&count)
+	if err != nil {
+		err = fmt.Errorf("%w: failed to count unique users: %s", storage.ErrInternal, err)
+	}
+	return
+}
+
+func (s storageImpl) inTx(ctx context.Context, f func(tx *sql.Tx) error) (err error) {
+	var tx *sql.Tx
+	tx, err = s.db.BeginTx(ctx, nil)
+	if err == nil {
+		err = f(tx)
+		switch err {
+		case nil:
+			err = tx.Commit()
+		default:
+			_ = tx.Rollback()
+		}
+	}
+	return
+}
+
+func (s storageImpl) exec(ctx context.Context, query string, args ...any) (n int64, err error) {
+	var result sql.Result
+	result, err = s.db.ExecContext(ctx, query, args...)
+	if err == nil {
+		n, err = result.RowsAffected()
+	}
+	return
+}
+
+// execChanges runs the update query returning the modified interests and records the change for every one of them.
+// Returns the count and the unique sorted condition ids of the modified interests.
+// The enforce func, when set, is invoked with the usage and the conditions of the modified interests before that.
+func (s storageImpl) execChanges(
+	ctx context.Context, t interest.ChangeType,
+	enforce func(tx *sql.Tx, u interest.Usage, conds []condition.Condition) (err error),
+	query string, args ...any,
+) (n int64, condIds []string, err error) {
+	err = s.inTx(ctx, func(tx *sql.Tx) (err error) {
+		var changes []interest.Change
+		var u interest.Usage
+		var conds []condition.Condition
+		var rows *sql.Rows
+		rows, err = tx.QueryContext(ctx, query, args...)
+		if err == nil {
+			for rows.Next() {
+				var id, groupId, userId, rawCond string
+				var enabled, public bool
+				var enabledSince int64
+				var cond condition.Condition
+				err = rows.Scan(&id, &groupId, &userId, &enabled, &enabledSince, &rawCond, &public)
+				if err == nil {
+					cond, err = jsoncond.Decode([]byte(rawCond))
+				}
+				if err != nil {
+					break
+				}
+				c := interest.NewChange(t, id, groupId, userId, cond, cond)
+				c.Enabled = enabled
+				c.EnabledSince = timeFromDb(enabledSince)
+				changes = append(changes, c)
+				u = u.Add(interest.UsageAdded(nil, interest.Data{Public: public}))
+				conds = append(conds, cond)
+			}
+			if err == nil {
+				err = rows.Err()
+			}
+			_ = rows.Close()
+		}
+		if err == nil && enforce != nil {
+			err = enforce(tx, u, conds)
+		}
+		for _, c := range changes {
+			if err != nil {
+				break
+			}
+			err = s.insertChange(ctx, tx, c)
+		}
+		n = int64(len(changes))
+		condIds = interest.CondIdsAfter(changes)
+		return
+	})
+	if err != nil {
+		n = 0
+		condIds = nil
+	}
+	return
+}
+
+// enforceQuotas counts the usage after the write in the same transaction, the transactions are serialized by the
+// single connection.
+func (s storageImpl) enforceQuotas(ctx context.Context, tx *sql.Tx, groupId, userId string, conds []condition.Condition, added interest.Usage) (err error) {
+	return storage.EnforceQuotas(ctx, groupId, userId, conds, added, func() (u interest.Usage, err error) {
+		err = tx.QueryRowContext(ctx, fmt.Sprintf(queryUsage, s.tbl), groupId, userId).Scan(&u.Interests, &u.Public)
+		return
+	})
+}
+
+func (s storageImpl) insertChange(ctx context.Context, tx *sql.Tx, c interest.Change) (err error) {
+	var before, after sql.NullString
+	before, err = encodeNullCondition(c.Before)
+	if err == nil {
+		after, err = encodeNullCondition(c.After)
+	}
+	if err == nil {
+		_, err = tx.ExecContext(
+			ctx, fmt.Sprintf(queryCreateChange, s.tblChanges),
+			c.Type, c.InterestId, c.GroupId, c.UserId, timeToDb(c.Time), c.Enabled, timeToDb(c.EnabledSince),
+			before, after,
+		)
+	}
+	return
+}
+
+func (s storageImpl) insertRevision(ctx context.Context, tx *sql.Tx, id, groupId, userId string) (err error) {
+	_, err = tx.ExecContext(
+		ctx, fmt.Sprintf(queryCreateRevision, s.tblRevisions, s.tbl),
+		id, groupId, userId, timeToDb(time.Now()), id,
+	)
+	return
+}
+
+func (s storageImpl) insertCondIds(ctx context.Context, tx *sql.Tx, id string, c condition.Condition) (err error) {
+	q := fmt.Sprintf(queryCreateCondId, s.tblCondIds)
+	for _, condId := range condition.LeafIds(c) {
+		_, err = tx.ExecContext(ctx, q, condId, id)
+		if err != nil {
+			break
+		}
+	}
+	return
+}
+
+// purgeDeleted removes the deleted interests those retention period is over, so their ids may be reused.
+func (s storageImpl) purgeDeleted(ctx context.Context, tx *sql.Tx) (err error) {
+	deletedBefore := timeToDb(time.Now().Add(-s.retentionPeriod))
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(queryPurgeDeletedRefs, s.tblCondIds, s.tbl), deletedBefore)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(queryPurgeDeletedRefs, s.tblRevisions, s.tbl), deletedBefore)
+	}
+	if err == nil {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(queryPurgeDeleted, s.tbl), deletedBefore)
+	}
+	if err == nil && s.retentionPeriod > 0 {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(queryPurgeChanges, s.tblChanges), deletedBefore)
+	}
+	return
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanData(row rowScanner) (sd interest.Data, groupId, userId string, err error) {
+	var enabledSince, expires, created, updated, result int64
+	var rawCond string
+	err = row.Scan(
+		&sd.Description, &sd.Enabled, &enabledSince, &expires, &created, &updated, &result,
+		&sd.Public, &sd.Followers, &rawCond, &groupId, &userId,
+	)
+	if err == nil {
+		sd.EnabledSince = timeFromDb(enabledSince)
+		sd.Expires = timeFromDb(expires)
+		sd.Created = timeFromDb(created)
+		sd.Updated = timeFromDb(updated)
+		sd.Result = timeFromDb(result)
+		sd.Condition, err = jsoncond.Decode([]byte(rawCond))
+	}
+	return
+}
+
+func scanRevision(row rowScanner) (rev interest.Revision, err error) {
+	var t int64
+	rev.Data, rev.GroupId, rev.UserId, err = scanData(prefixScanner{
+		row: row,
+		prefix: []any{
+			&rev.Number, &t,
+		},
+	})
+	rev.Time = timeFromDb(t)
+	return
+}
+
+// prefixScanner scans the leading columns into the prefix destinations, the rest are left for the caller.
+type prefixScanner struct {
+	row    rowScanner
+	prefix []any
+}
+
+func (ps prefixScanner) Scan(dest ...any) error {
+	return ps.row.Scan(append(ps.prefix, dest...)...)
+}
+
+func scanChange(row rowScanner) (c interest.Change, err error) {
+	var t interest.ChangeType
+	var id, groupId, userId string
+	var position uint64
+	var tChange, enabledSince int64
+	var enabled bool
+	var rawBefore, rawAfter sql.NullString
+	err = row.Scan(&position, &t, &id, &groupId, &userId, &tChange, &enabled, &enabledSince, &rawBefore, &rawAfter)
+	var before, after condition.Condition
+	if err == nil {
+		before, err = decodeNullCondition(rawBefore)
+	}
+	if err == nil {
+		after, err = decodeNullCondition(rawAfter)
+	}
+	if err == nil {
+		c = interest.NewChange(t, id, groupId, userId, before, after)
+		c.Position = position
+		c.Time = timeFromDb(tChange)
+		c.Enabled = enabled
+		c.EnabledSince = timeFromDb(enabledSince)
+	}
+	return
+}
+
+// encodeNullCondition returns NULL for the missing condition, e.g. the one before the interest is created.
+func encodeNullCondition(cond condition.Condition) (v sql.NullString, err error) {
+	if cond != nil {
+		var data []byte
+		data, err = jsoncond.Encode(cond)
+		v = sql.NullString{
+			String: string(data),
+			Valid:  err == nil,
+		}
+	}
+	return
+}
+
+func decodeNullCondition(v sql.NullString) (cond condition.Condition, err error) {
+	if v.Valid {
+		cond, err = jsoncond.Decode([]byte(v.String))
+	}
+	return
+}
+
+func timeToDb(t time.Time) (v int64) {
+	if !t.IsZero() {
+		v = t.UnixMicro()
+	}
+	return
+}
+
+func timeFromDb(v int64) (t time.Time) {
+	if v != 0 {
+		t = time.UnixMicro(v).UTC()
+	}
+	return
+}
+
+// limitToDb converts the query limit to the SQLite one, where a negative value means no limit.
+func limitToDb(limit uint32) (v int64) {
+	v = int64(limit)
+	if v == 0 {
+		v = -1
+	}
+	return
+}
+
+func inArgs(vals []string) (placeholders string, args []any) {
+	args = make([]any, len(vals))
+	for i, v := range vals {
+		args[i] = v
+	}
+	placeholders = strings.TrimSuffix(strings.Repeat("?, ", len(vals)), ", ")
+	return
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
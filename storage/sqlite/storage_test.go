@@ -0,0 +1,103 @@
+package sqlite
+
+import (
+	"context"
+	"github.com/awakari/interests/config"
+	"github.com/awakari/interests/model/condition"
+	"github.com/awakari/interests/model/interest"
+	"github.com/awakari/interests/storage"
+	"github.com/awakari/interests/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestStorage(t *testing.T, resultTtl, retention time.Duration) storage.Storage {
+	dbCfg := config.DbConfig{
+		Type:      config.DbTypeSqlite,
+		Uri:       filepath.Join(t.TempDir(), "interests.db"),
+		ResultTtl: resultTtl,
+	}
+	dbCfg.Table.Name = "interests"
+	dbCfg.Table.Retention = retention
+	s, err := NewStorage(context.TODO(), dbCfg)
+	require.Nil(t, err)
+	t.Cleanup(func() {
+		assert.Nil(t, s.Close())
+	})
+	return s
+}
+
+func TestStorageImpl_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, resultTtl time.Duration) storage.Storage {
+		return newTestStorage(t, resultTtl, time.Hour)
+	})
+}
+
+func TestNewStorage_Memory(t *testing.T) {
+	dbCfg := config.DbConfig{
+		Uri: ":memory:",
+	}
+	dbCfg.Table.Name = "interests"
+	s, err := NewStorage(context.TODO(), dbCfg)
+	require.Nil(t, err)
+	defer s.Close()
+	err = s.Create(context.TODO(), "interest0", "group0", "user0", interest.Data{
+		Condition: condition.NewSemanticCondition(condition.NewCondition(false), "cond0", "lorem ipsum", 0.5),
+	})
+	require.Nil(t, err)
+	count, err := s.Count(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestStorageImpl_Create_RetentionExpired(t *testing.T) {
+	s := newTestStorage(t, time.Minute, time.Nanosecond)
+	ctx := context.TODO()
+	cond0 := condition.NewSemanticCondition(condition.NewCondition(false), "cond0", "lorem ipsum", 0.5)
+	err := s.Create(ctx, "interest0", "group0", "user0", interest.Data{
+		Condition: cond0,
+	})
+	require.Nil(t, err)
+	_, err = s.Delete(ctx, "interest0", "group0", "user0")
+	require.Nil(t, err)
+	time.Sleep(time.Millisecond)
+	err = s.Create(ctx, "interest0", "group1", "user1", interest.Data{
+		Condition: cond0,
+		Enabled:   true,
+	})
+	assert.Nil(t, err)
+	page, err := s.SearchByCondition(ctx, interest.QueryByCondition{CondId: "cond0"}, "")
+	assert.Nil(t, err)
+	assert.Len(t, page.ConditionMatches, 1)
+}
+
+func TestStorageImpl_Purge_RetentionExpired(t *testing.T) {
+	s := newTestStorage(t, time.Minute, time.Nanosecond)
+	ctx := context.TODO()
+	cond0 := condition.NewSemanticCondition(condition.NewCondition(false), "cond0", "lorem ipsum", 0.5)
+	err := s.Create(ctx, "interest0", "group0", "user0", interest.Data{
+		Condition: cond0,
+	})
+	require.Nil(t, err)
+	p, err := s.Purge(ctx, "group0", "user0", "service0")
+	require.Nil(t, err)
+	time.Sleep(time.Millisecond)
+	// the next write removes the changes those retention period is over, including the purge ones
+	err = s.Create(ctx, "interest1", "group1", "user1", interest.Data{
+		Condition: cond0,
+	})
+	require.Nil(t, err)
+	changes, err := s.ReadChanges(ctx, 0, 0)
+	require.Nil(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, interest.ChangeCreated, changes[0].Type)
+	// the purge audit survives
+	audits, err := s.ListPurges(ctx, "group0", "user0")
+	require.Nil(t, err)
+	require.Len(t, audits, 1)
+	assert.Equal(t, "service0", audits[0].Actor)
+	assert.Equal(t, p, audits[0].Purged)
+}
@@ -29,7 +29,8 @@ type (
 
 		UpdateResultTime(ctx context.Context, id string, last time.Time) (err error)
 
-		SetEnabledBatch(ctx context.Context, ids []string, enabled bool, enabledSince time.Time) (n int64, err error)
+		// SetEnabledBatch returns the count of the modified interests and their unique sorted condition ids.
+		SetEnabledBatch(ctx context.Context, ids []string, enabled bool, enabledSince time.Time) (n int64, condIds []string, err error)
 
 		ChangeOwner(ctx context.Context, oldGroupId, oldUserId, newGroupId, newUserId string) (n int64, err error)
 
@@ -37,6 +38,27 @@ type (
 		// Returns the interest.Data if deleted, error otherwise.
 		Delete(ctx context.Context, id, groupId, userId string) (sd interest.Data, err error)
 
+		// Restore reverts the Delete of the interest owned by the account unless the retention period is over.
+		// Returns ErrConflict when the id is already used by another interest, the interest.Data if restored.
+		Restore(ctx context.Context, id, groupId, userId string) (sd interest.Data, err error)
+
+		// ListOwned returns up to the limit of the account interests those are not deleted, ordered by id following the
+		// cursor id. Every interest contains the complete interest.Data. The zero limit means no limit, so the whole
+		// account is read by the single query.
+		ListOwned(ctx context.Context, groupId, userId, cursor string, limit uint32) (page []interest.Interest, err error)
+
+		// ListDeleted returns up to the limit of the account interests deleted but not purged yet, ordered by id
+		// following the cursor id.
+		ListDeleted(ctx context.Context, groupId, userId, cursor string, limit uint32) (page []interest.Deleted, err error)
+
+		// Purge permanently removes all interests of the account including the deleted ones together with their
+		// revisions and previous changes. Appends the ChangePurged change for every removed interest and records the
+		// interest.PurgeAudit of the specified actor in the same transaction.
+		Purge(ctx context.Context, groupId, userId, actor string) (p interest.Purged, err error)
+
+		// ListPurges returns all interest.PurgeAudit records of the account ordered by time.
+		ListPurges(ctx context.Context, groupId, userId string) (audits []interest.PurgeAudit, err error)
+
 		// Search returns all interest ids matching the query.
 		Search(ctx context.Context, q interest.Query, cursor interest.Cursor) (ids []string, err error)
 
@@ -44,6 +66,38 @@ type (
 		// specified consumer func.
 		SearchByCondition(ctx context.Context, q interest.QueryByCondition, cursor string) (page interest.ConditionMatchPage, err error)
 
+		// SearchByConditionStream feeds all interests matching the specified condition id to the consume func ordered
+		// by id. Returns the time until the result may be cached: the min of the default result TTL, the earliest
+		// matching interest expiration time and the earliest matching interest enabling time in the future.
+		// Stops and returns the consume func error as is.
+		SearchByConditionStream(ctx context.Context, condId string, consume func(cm interest.ConditionMatch) (err error)) (expires time.Time, err error)
+
+		// SearchByConditionBatch is the SearchByCondition for multiple condition ids at once, every query has own
+		// cursor and limit. An interest containing several of the condition ids is present in every corresponding
+		// page while its condition is returned once.
+		SearchByConditionBatch(ctx context.Context, qs []interest.QueryByConditionCursor) (batch interest.ConditionMatchBatch, err error)
+
+		// ListRevisions returns up to the limit of the interest revisions following the cursor revision number, ordered
+		// by the number. Every successful Create and Update writes a new revision. The ownership check is the same as
+		// for the Update.
+		ListRevisions(ctx context.Context, id, groupId, userId string, internal bool, cursor uint64, limit uint32) (revs []interest.Revision, err error)
+
+		// ReadRevision returns the interest revision by its number.
+		ReadRevision(ctx context.Context, id, groupId, userId string, internal bool, number uint64) (rev interest.Revision, err error)
+
+		// RestoreRevision updates the interest with the interest.Revision RestoreData in the same transaction as the
+		// revision is read, so the concurrent update can't get in between. Returns the restored revision and the
+		// replaced data. The ownership check is the same as for the Update.
+		RestoreRevision(ctx context.Context, id, groupId, userId string, internal bool, number uint64, updated time.Time) (rev interest.Revision, prev interest.Data, err error)
+
+		// ReadChanges returns up to the limit of the interest changes following the specified position, ordered by
+		// the position. Every successful mutation above appends the change for every affected interest atomically.
+		// The changes older than the retention period may be purged.
+		ReadChanges(ctx context.Context, after uint64, limit uint32) (changes []interest.Change, err error)
+
+		// Usage returns the count of the account interests those are not deleted, see the ErrQuotaExceeded.
+		Usage(ctx context.Context, groupId, userId string) (u interest.Usage, err error)
+
 		Count(ctx context.Context) (count int64, err error)
 		CountUsersUnique(ctx context.Context) (count int64, err error)
 	}
@@ -57,6 +111,9 @@ var (
 	// ErrConflict indicates the interest id is already in use.
 	ErrConflict = errors.New("interest id is already in use")
 
+	// ErrQuotaExceeded indicates the operation would exceed the interest.Limits of the account.
+	ErrQuotaExceeded = errors.New("interest quota exceeded")
+
 	// ErrInternal indicates the internal storage failure happened.
 	ErrInternal = errors.New("internal interest storage failure")
 )
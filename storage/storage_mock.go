@@ -25,6 +25,8 @@ func (s storageMock) Create(ctx context.Context, id, groupId, userId string, sd
 		err = ErrInternal
 	case "conflict":
 		err = ErrConflict
+	case "quota":
+		err = ErrQuotaExceeded
 	}
 	return
 }
@@ -63,6 +65,18 @@ func (s storageMock) Read(ctx context.Context, id, groupId, userId string, inter
 				},
 			),
 		}
+		if id == "unprintable" {
+			sd.Condition = condition.NewGroupCondition(
+				condition.NewCondition(false),
+				condition.GroupLogic(3),
+				[]condition.Condition{
+					condition.NewNumberCondition(
+						condition.NewKeyCondition(condition.NewCondition(false), "", "key2"),
+						condition.NumOpUndefined, 42,
+					),
+				},
+			)
+		}
 		ownerGroupId = groupId
 		ownerUserId = userId
 	}
@@ -116,11 +130,14 @@ func (s storageMock) UpdateResultTime(ctx context.Context, id string, last time.
 	return
 }
 
-func (s storageMock) SetEnabledBatch(ctx context.Context, ids []string, enabled bool, enabledSince time.Time) (n int64, err error) {
+func (s storageMock) SetEnabledBatch(ctx context.Context, ids []string, enabled bool, enabledSince time.Time) (n int64, condIds []string, err error) {
 	if len(ids) > 0 && ids[0] == "fail" {
 		err = ErrInternal
 	} else {
 		n = int64(len(ids))
+		if n > 0 {
+			condIds = []string{"cond0"}
+		}
 	}
 	return
 }
@@ -152,6 +169,106 @@ func (s storageMock) Delete(ctx context.Context, id, groupId, userId string) (sd
 	return
 }
 
+func (s storageMock) Restore(ctx context.Context, id, groupId, userId string) (sd interest.Data, err error) {
+	switch id {
+	case "fail":
+		err = ErrInternal
+	case "missing":
+		err = ErrNotFound
+	case "conflict":
+		err = ErrConflict
+	default:
+		sd = interest.Data{
+			Description: "description",
+			Condition: condition.NewTextCondition(
+				condition.NewKeyCondition(condition.NewCondition(false), "cond0", "key0"),
+				"pattern0", false,
+			),
+		}
+	}
+	return
+}
+
+func (s storageMock) ListOwned(ctx context.Context, groupId, userId, cursor string, limit uint32) (page []interest.Interest, err error) {
+	switch groupId {
+	case "fail":
+		err = ErrInternal
+	default:
+		for _, id := range []string{"sub0", "sub1"} {
+			if id > cursor && (limit == 0 || len(page) < int(limit)) {
+				sd, _, _, _ := s.Read(ctx, id, groupId, userId, false)
+				page = append(page, interest.Interest{
+					Id:      id,
+					GroupId: groupId,
+					UserId:  userId,
+					Data:    sd,
+				})
+			}
+		}
+	}
+	return
+}
+
+func (s storageMock) ListDeleted(ctx context.Context, groupId, userId, cursor string, limit uint32) (page []interest.Deleted, err error) {
+	switch cursor {
+	case "fail":
+		err = ErrInternal
+	default:
+		for i := 0; i < 3 && (limit == 0 || len(page) < int(limit)); i++ {
+			page = append(page, interest.Deleted{
+				Id:        fmt.Sprintf("%sinterest%d", cursor, i),
+				DeletedAt: time.Date(2024, 4, 9, 7, 3, i, 0, time.UTC),
+				Data: interest.Data{
+					Description: fmt.Sprintf("description%d", i),
+					Condition: condition.NewTextCondition(
+						condition.NewKeyCondition(condition.NewCondition(false), "cond0", "key0"),
+						"pattern0", false,
+					),
+				},
+			})
+		}
+	}
+	return
+}
+
+func (s storageMock) Purge(ctx context.Context, groupId, userId, actor string) (p interest.Purged, err error) {
+	switch groupId {
+	case "fail":
+		err = ErrInternal
+	default:
+		p = interest.Purged{
+			Count:   2,
+			Deleted: 1,
+			CondIds: []string{
+				"cond0",
+				"cond1",
+			},
+		}
+	}
+	return
+}
+
+func (s storageMock) ListPurges(ctx context.Context, groupId, userId string) (audits []interest.PurgeAudit, err error) {
+	switch groupId {
+	case "fail":
+		err = ErrInternal
+	default:
+		audits = append(audits, interest.PurgeAudit{
+			Purged: interest.Purged{
+				Count: 1,
+				CondIds: []string{
+					"cond0",
+				},
+			},
+			Actor:   "service0",
+			GroupId: groupId,
+			UserId:  userId,
+			Time:    time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		})
+	}
+	return
+}
+
 func (s storageMock) Search(ctx context.Context, q interest.Query, cursor interest.Cursor) (ids []string, err error) {
 	if cursor.Id == "" {
 		switch q.Order {
@@ -195,6 +312,144 @@ func (s storageMock) SearchByCondition(ctx context.Context, q interest.QueryByCo
 	return
 }
 
+func (s storageMock) SearchByConditionStream(ctx context.Context, condId string, consume func(cm interest.ConditionMatch) (err error)) (expires time.Time, err error) {
+	switch condId {
+	case "fail":
+		err = ErrInternal
+	default:
+		for i := 0; i < 3; i++ {
+			cm := interest.ConditionMatch{
+				InterestId: fmt.Sprintf("sub%d", i),
+				Condition: condition.NewTextCondition(
+					condition.NewKeyCondition(condition.NewCondition(false), condId, "key0"),
+					"pattern0", false,
+				),
+			}
+			err = consume(cm)
+			if err != nil {
+				break
+			}
+		}
+		expires = time.Date(2025, 3, 1, 13, 4, 55, 0, time.UTC)
+	}
+	return
+}
+
+func (s storageMock) SearchByConditionBatch(ctx context.Context, qs []interest.QueryByConditionCursor) (batch interest.ConditionMatchBatch, err error) {
+	c := NewConditionMatchBatchCollector(qs, time.Date(2025, 3, 1, 13, 4, 55, 0, time.UTC))
+	for _, q := range qs {
+		if q.CondId == "fail" {
+			err = ErrInternal
+			break
+		}
+	}
+	// every mock interest contains every condition id
+	for i := 0; err == nil && i < 3; i++ {
+		id := fmt.Sprintf("sub%d", i)
+		if c.Add(id, c.CondIds(), time.Time{}) {
+			c.SetCondition(id, condition.NewTextCondition(
+				condition.NewKeyCondition(condition.NewCondition(false), "cond0", "key0"),
+				"pattern0", false,
+			))
+		}
+	}
+	batch = c.Batch()
+	return
+}
+
+func (s storageMock) ListRevisions(ctx context.Context, id, groupId, userId string, internal bool, cursor uint64, limit uint32) (revs []interest.Revision, err error) {
+	switch id {
+	case "fail":
+		err = ErrInternal
+	case "missing":
+		err = ErrNotFound
+	default:
+		for number := cursor + 1; number <= 3 && (limit == 0 || len(revs) < int(limit)); number++ {
+			revs = append(revs, revisionMock(number, groupId, userId))
+		}
+	}
+	return
+}
+
+func (s storageMock) ReadRevision(ctx context.Context, id, groupId, userId string, internal bool, number uint64) (rev interest.Revision, err error) {
+	switch {
+	case id == "fail":
+		err = ErrInternal
+	case id == "missing", number < 1, number > 3:
+		err = ErrNotFound
+	default:
+		rev = revisionMock(number, groupId, userId)
+	}
+	return
+}
+
+func (s storageMock) RestoreRevision(ctx context.Context, id, groupId, userId string, internal bool, number uint64, updated time.Time) (rev interest.Revision, prev interest.Data, err error) {
+	rev, err = s.ReadRevision(ctx, id, groupId, userId, internal, number)
+	if err == nil {
+		prev, err = s.Update(ctx, id, groupId, userId, internal, rev.RestoreData(updated))
+	}
+	return
+}
+
+func revisionMock(number uint64, groupId, userId string) interest.Revision {
+	return interest.Revision{
+		Number:  number,
+		GroupId: groupId,
+		UserId:  userId,
+		Time:    time.Date(2024, 4, 9, 7, 3, int(number), 0, time.UTC),
+		Data: interest.Data{
+			Description: fmt.Sprintf("description%d", number),
+			Enabled:     true,
+			Created:     time.Date(2024, 4, 9, 7, 3, 1, 0, time.UTC),
+			Updated:     time.Date(2024, 4, 9, 7, 3, int(number), 0, time.UTC),
+			Condition: condition.NewTextCondition(
+				condition.NewKeyCondition(condition.NewCondition(false), "cond0", "key0"),
+				fmt.Sprintf("pattern%d", number), false,
+			),
+		},
+	}
+}
+
+func (s storageMock) ReadChanges(ctx context.Context, after uint64, limit uint32) (changes []interest.Change, err error) {
+	switch {
+	case after == 42:
+		err = ErrInternal
+	default:
+		for pos := after + 1; pos <= 3 && (limit == 0 || len(changes) < int(limit)); pos++ {
+			changes = append(changes, interest.Change{
+				Position:   pos,
+				Type:       interest.ChangeCreated,
+				InterestId: fmt.Sprintf("sub%d", pos),
+				GroupId:    "group0",
+				UserId:     "user0",
+				Time:       time.Date(2025, 3, 1, 13, 4, 55, 0, time.UTC),
+				Enabled:    true,
+				After: condition.NewTextCondition(
+					condition.NewKeyCondition(condition.NewCondition(false), "cond0", "key0"),
+					"pattern0", false,
+				),
+				CondIdsAdded: []string{
+					"cond0",
+				},
+			})
+		}
+	}
+	return
+}
+
+func (s storageMock) Usage(ctx context.Context, groupId, userId string) (u interest.Usage, err error) {
+	switch groupId {
+	case "fail":
+		err = ErrInternal
+	default:
+		u = interest.Usage{
+			Interests: 3,
+			Public:    1,
+		}
+	}
+	return
+}
+
 func (s storageMock) Count(ctx context.Context) (count int64, err error) {
 	count = 42
 	return
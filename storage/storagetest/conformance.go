@@ -0,0 +1,2424 @@
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"github.com/awakari/interests/model/condition"
+	"github.com/awakari/interests/model/interest"
+	"github.com/awakari/interests/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// NewStorageFunc should return a new empty storage instance using the specified default condition match result TTL.
+// The function is responsible for releasing the storage resources after the test, e.g. using t.Cleanup.
+type NewStorageFunc func(t *testing.T, resultTtl time.Duration) storage.Storage
+
+// timePrecision is the least time precision every storage implementation should preserve.
+const timePrecision = time.Millisecond
+
+// Run runs the complete conformance test suite against the storage.Storage implementation.
+func Run(t *testing.T, newStorage NewStorageFunc) {
+	t.Run("Create", func(t *testing.T) {
+		testCreate(t, newStorage)
+	})
+	t.Run("Read", func(t *testing.T) {
+		testRead(t, newStorage)
+	})
+	t.Run("Update", func(t *testing.T) {
+		testUpdate(t, newStorage)
+	})
+	t.Run("UpdateFollowers", func(t *testing.T) {
+		testUpdateFollowers(t, newStorage)
+	})
+	t.Run("UpdateResultTime", func(t *testing.T) {
+		testUpdateResultTime(t, newStorage)
+	})
+	t.Run("SetEnabledBatch", func(t *testing.T) {
+		testSetEnabledBatch(t, newStorage)
+	})
+	t.Run("ChangeOwner", func(t *testing.T) {
+		testChangeOwner(t, newStorage)
+	})
+	t.Run("Delete", func(t *testing.T) {
+		testDelete(t, newStorage)
+	})
+	t.Run("Restore", func(t *testing.T) {
+		testRestore(t, newStorage)
+	})
+	t.Run("ListOwned", func(t *testing.T) {
+		testListOwned(t, newStorage)
+	})
+	t.Run("ListDeleted", func(t *testing.T) {
+		testListDeleted(t, newStorage)
+	})
+	t.Run("Purge", func(t *testing.T) {
+		testPurge(t, newStorage)
+	})
+	t.Run("Search", func(t *testing.T) {
+		testSearch(t, newStorage)
+	})
+	t.Run("SearchPages", func(t *testing.T) {
+		testSearchPages(t, newStorage)
+	})
+	t.Run("SearchByCondition", func(t *testing.T) {
+		testSearchByCondition(t, newStorage)
+	})
+	t.Run("SearchByConditionStream", func(t *testing.T) {
+		testSearchByConditionStream(t, newStorage)
+	})
+	t.Run("SearchByConditionBatch", func(t *testing.T) {
+		testSearchByConditionBatch(t, newStorage)
+	})
+	t.Run("Revisions", func(t *testing.T) {
+		testRevisions(t, newStorage)
+	})
+	t.Run("ReadChanges", func(t *testing.T) {
+		testReadChanges(t, newStorage)
+	})
+	t.Run("ConcurrentChanges", func(t *testing.T) {
+		testConcurrentChanges(t, newStorage)
+	})
+	t.Run("Usage", func(t *testing.T) {
+		testUsage(t, newStorage)
+	})
+	t.Run("Quotas", func(t *testing.T) {
+		testQuotas(t, newStorage)
+	})
+	t.Run("ConcurrentQuotas", func(t *testing.T) {
+		testConcurrentQuotas(t, newStorage)
+	})
+	t.Run("Count", func(t *testing.T) {
+		testCount(t, newStorage)
+	})
+}
+
+func newTextCondition(id, key, term string) condition.Condition {
+	return condition.NewTextCondition(
+		condition.NewKeyCondition(condition.NewCondition(false), id, key),
+		term, false,
+	)
+}
+
+func testCreate(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	ctx := context.TODO()
+	cond0 := newTextCondition("cond0", "key0", "pattern0")
+	err := s.Create(ctx, "interest0", "group0", "user0", interest.Data{
+		Condition: cond0,
+	})
+	require.Nil(t, err)
+	err = s.Create(ctx, "interest1", "group0", "user0", interest.Data{
+		Condition: cond0,
+	})
+	require.Nil(t, err)
+	_, err = s.Delete(ctx, "interest1", "group0", "user0")
+	require.Nil(t, err)
+	//
+	cases := map[string]struct {
+		id  string
+		sd  interest.Data
+		err error
+	}{
+		"ok": {
+			id: "interest2",
+			sd: interest.Data{
+				Description: "test interest 2",
+				Expires:     time.Now().Add(1 * time.Hour),
+				Public:      true,
+				Condition: condition.NewGroupCondition(
+					condition.NewCondition(false),
+					condition.GroupLogicOr,
+					[]condition.Condition{
+						condition.NewTextCondition(
+							condition.NewKeyCondition(condition.NewCondition(true), "cond0", "key0"),
+							"pattern0", true,
+						),
+						condition.NewNumberCondition(
+							condition.NewKeyCondition(condition.NewCondition(false), "cond1", "key1"),
+							condition.NumOpEq, 42,
+						),
+						condition.NewSemanticCondition(
+							condition.NewCondition(false), "cond2", "lorem ipsum...", 0.5,
+						),
+					},
+				),
+			},
+		},
+		"conflict": {
+			id: "interest0",
+			sd: interest.Data{
+				Condition: cond0,
+			},
+			err: storage.ErrConflict,
+		},
+		"id of the deleted interest is still in use": {
+			id: "interest1",
+			sd: interest.Data{
+				Condition: cond0,
+			},
+			err: storage.ErrConflict,
+		},
+	}
+	//
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			err = s.Create(ctx, c.id, "group0", "user0", c.sd)
+			if c.err == nil {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+}
+
+func testRead(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	ctx := context.TODO()
+	cond0 := condition.NewGroupCondition(
+		condition.NewCondition(false),
+		condition.GroupLogicOr,
+		[]condition.Condition{
+			condition.NewTextCondition(
+				condition.NewKeyCondition(condition.NewCondition(false), "cond0", "key0"),
+				"pattern0", true,
+			),
+			condition.NewNumberCondition(
+				condition.NewKeyCondition(condition.NewCondition(true), "cond1", "key1"),
+				condition.NumOpLt, -1.2e-3,
+			),
+			condition.NewSemanticCondition(
+				condition.NewCondition(false), "cond2", "lorem ipsum...", 0.5,
+			),
+			condition.NewRegexCondition(
+				condition.NewKeyCondition(condition.NewCondition(true), "cond3", "key3"),
+				`^pattern\d+$`,
+			),
+			condition.NewRangeCondition(
+				condition.NewKeyCondition(condition.NewCondition(false), "cond4", "key4"),
+				condition.Bound{Value: -1.5},
+				condition.Bound{Value: 1.5, Inclusive: true},
+			),
+			condition.NewSetCondition(
+				condition.NewKeyCondition(condition.NewCondition(true), "cond5", "key5"),
+				[]string{"a", "b"},
+				[]float64{1, 2.5},
+			),
+			condition.NewExistsCondition(condition.NewKeyCondition(condition.NewCondition(true), "cond6", "key6")),
+		},
+	)
+	sdPrivate := interest.Data{
+		Description: "private",
+		Enabled:     true,
+		Expires:     time.Date(2023, 10, 4, 10, 20, 45, 0, time.UTC),
+		Created:     time.Date(2023, 10, 3, 10, 20, 45, 0, time.UTC),
+		Condition:   cond0,
+	}
+	err := s.Create(ctx, "interest0", "group0", "user0", sdPrivate)
+	require.Nil(t, err)
+	sdPublic := interest.Data{
+		Description: "public",
+		Created:     time.Date(2023, 10, 3, 10, 20, 45, 0, time.UTC),
+		Public:      true,
+		Condition:   cond0,
+	}
+	err = s.Create(ctx, "interest1", "group0", "user0", sdPublic)
+	require.Nil(t, err)
+	err = s.Create(ctx, "interest2", "group0", "user0", sdPrivate)
+	require.Nil(t, err)
+	_, err = s.Delete(ctx, "interest2", "group0", "user0")
+	require.Nil(t, err)
+	//
+	cases := map[string]struct {
+		id       string
+		groupId  string
+		userId   string
+		internal bool
+		sd       interest.Data
+		err      error
+	}{
+		"own": {
+			id:      "interest0",
+			groupId: "group0",
+			userId:  "user0",
+			sd:      sdPrivate,
+		},
+		"non-own private": {
+			id:      "interest0",
+			groupId: "group0",
+			userId:  "user1",
+			err:     storage.ErrNotFound,
+		},
+		"non-own private internal": {
+			id:       "interest0",
+			internal: true,
+			sd:       sdPrivate,
+		},
+		"non-own public": {
+			id:      "interest1",
+			groupId: "group1",
+			userId:  "user1",
+			sd:      sdPublic,
+		},
+		"deleted": {
+			id:      "interest2",
+			groupId: "group0",
+			userId:  "user0",
+			err:     storage.ErrNotFound,
+		},
+		"deleted internal": {
+			id:       "interest2",
+			internal: true,
+			err:      storage.ErrNotFound,
+		},
+		"missing": {
+			id:      "interest3",
+			groupId: "group0",
+			userId:  "user0",
+			err:     storage.ErrNotFound,
+		},
+	}
+	//
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			sd, ownerGroupId, ownerUserId, err := s.Read(ctx, c.id, c.groupId, c.userId, c.internal)
+			if c.err == nil {
+				require.Nil(t, err)
+				assert.Equal(t, c.sd.Description, sd.Description)
+				assert.Equal(t, c.sd.Enabled, sd.Enabled)
+				assert.Equal(t, c.sd.Public, sd.Public)
+				assert.WithinDuration(t, c.sd.Expires, sd.Expires, timePrecision)
+				assert.WithinDuration(t, c.sd.Created, sd.Created, timePrecision)
+				assert.True(t, c.sd.Condition.Equal(sd.Condition))
+				assert.Equal(t, "group0", ownerGroupId)
+				assert.Equal(t, "user0", ownerUserId)
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+}
+
+func testUpdate(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	ctx := context.TODO()
+	cond0 := newTextCondition("cond0", "key0", "pattern0")
+	sd0 := interest.Data{
+		Description: "description0",
+		Enabled:     true,
+		Expires:     time.Date(2023, 10, 4, 6, 44, 55, 0, time.UTC),
+		Created:     time.Date(2023, 10, 3, 6, 44, 55, 0, time.UTC),
+		Followers:   42,
+		Condition:   cond0,
+	}
+	err := s.Create(ctx, "interest0", "group0", "user0", sd0)
+	require.Nil(t, err)
+	cond1 := newTextCondition("cond1", "key1", "pattern1")
+	sd1 := interest.Data{
+		Description: "description1",
+		Expires:     time.Date(2023, 10, 4, 6, 44, 55, 0, time.UTC),
+		Condition:   cond1,
+	}
+	err = s.Create(ctx, "interest1", "group0", "user0", sd1)
+	require.Nil(t, err)
+	//
+	cases := map[string]struct {
+		id       string
+		groupId  string
+		userId   string
+		internal bool
+		sd       interest.Data
+		prev     interest.Data
+		err      error
+	}{
+		"ok": {
+			id:      "interest0",
+			groupId: "group0",
+			userId:  "user0",
+			sd: interest.Data{
+				Description: "new description",
+				Enabled:     true,
+				Updated:     time.Date(2023, 10, 5, 6, 44, 55, 0, time.UTC),
+				Condition:   newTextCondition("cond2", "key2", "pattern2"),
+				Public:      true,
+			},
+			prev: sd0,
+		},
+		"ok internal": {
+			id:       "interest1",
+			internal: true,
+			sd: interest.Data{
+				Description: "new description",
+				Expires:     time.Now().Add(1 * time.Hour),
+				Condition:   newTextCondition("cond3", "key3", "pattern3"),
+			},
+			prev: sd1,
+		},
+		"id mismatch": {
+			id:      "interest2",
+			groupId: "group0",
+			userId:  "user0",
+			sd: interest.Data{
+				Description: "new description",
+				Condition:   cond0,
+			},
+			err: storage.ErrNotFound,
+		},
+		"acc mismatch": {
+			id:      "interest0",
+			groupId: "group1",
+			userId:  "user0",
+			sd: interest.Data{
+				Description: "new description",
+				Condition:   cond0,
+			},
+			err: storage.ErrNotFound,
+		},
+	}
+	//
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			prev, err := s.Update(ctx, c.id, c.groupId, c.userId, c.internal, c.sd)
+			if c.err == nil {
+				require.Nil(t, err)
+				assert.Equal(t, c.prev.Description, prev.Description)
+				assert.WithinDuration(t, c.prev.Expires, prev.Expires, timePrecision)
+				assert.True(t, c.prev.Condition.Equal(prev.Condition))
+				var sd interest.Data
+				sd, _, _, err = s.Read(ctx, c.id, "", "", true)
+				require.Nil(t, err)
+				assert.Equal(t, c.sd.Description, sd.Description)
+				assert.Equal(t, c.sd.Enabled, sd.Enabled)
+				assert.Equal(t, c.sd.Public, sd.Public)
+				assert.WithinDuration(t, c.sd.Expires, sd.Expires, timePrecision)
+				assert.WithinDuration(t, c.sd.Updated, sd.Updated, timePrecision)
+				assert.True(t, c.sd.Condition.Equal(sd.Condition))
+				// not updatable
+				assert.Equal(t, c.prev.Followers, sd.Followers)
+				assert.WithinDuration(t, c.prev.Created, sd.Created, timePrecision)
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+	// condition ids should be updated too
+	page, err := s.SearchByCondition(ctx, interest.QueryByCondition{CondId: "cond2", Limit: 10}, "")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(page.ConditionMatches))
+	assert.Equal(t, "interest0", page.ConditionMatches[0].InterestId)
+	page, err = s.SearchByCondition(ctx, interest.QueryByCondition{CondId: "cond0", Limit: 10}, "")
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(page.ConditionMatches))
+}
+
+func testUpdateFollowers(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	ctx := context.TODO()
+	err := s.Create(ctx, "interest0", "group0", "user0", interest.Data{
+		Condition: newTextCondition("cond0", "key0", "pattern0"),
+	})
+	require.Nil(t, err)
+	//
+	cases := map[string]struct {
+		id    string
+		count int64
+		err   error
+	}{
+		"ok": {
+			id:    "interest0",
+			count: 42,
+		},
+		"missing": {
+			id:  "interest1",
+			err: storage.ErrNotFound,
+		},
+	}
+	//
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			err = s.UpdateFollowers(ctx, c.id, c.count)
+			if c.err == nil {
+				require.Nil(t, err)
+				var sd interest.Data
+				sd, _, _, err = s.Read(ctx, c.id, "group0", "user0", false)
+				require.Nil(t, err)
+				assert.Equal(t, c.count, sd.Followers)
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+}
+
+func testUpdateResultTime(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	ctx := context.TODO()
+	err := s.Create(ctx, "interest0", "group0", "user0", interest.Data{
+		Condition: newTextCondition("cond0", "key0", "pattern0"),
+	})
+	require.Nil(t, err)
+	//
+	cases := map[string]struct {
+		id   string
+		last time.Time
+		err  error
+	}{
+		"ok": {
+			id:   "interest0",
+			last: time.Date(2024, 8, 12, 18, 7, 0, 0, time.UTC),
+		},
+		"missing": {
+			id:  "interest1",
+			err: storage.ErrNotFound,
+		},
+	}
+	//
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			err = s.UpdateResultTime(ctx, c.id, c.last)
+			if c.err == nil {
+				require.Nil(t, err)
+				var sd interest.Data
+				sd, _, _, err = s.Read(ctx, c.id, "group0", "user0", false)
+				require.Nil(t, err)
+				assert.WithinDuration(t, c.last, sd.Result, timePrecision)
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+}
+
+func testSetEnabledBatch(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	ctx := context.TODO()
+	cond0 := newTextCondition("cond0", "key0", "pattern0")
+	cond1 := newTextCondition("cond1", "key1", "pattern1")
+	for _, id := range []string{"interest0", "interest1", "interest7"} {
+		cond := cond0
+		if id == "interest1" {
+			cond = cond1
+		}
+		err := s.Create(ctx, id, "group0", "user0", interest.Data{
+			Condition: cond,
+			Enabled:   true,
+		})
+		require.Nil(t, err)
+	}
+	for _, id := range []string{"interest2", "interest3", "interest4", "interest5"} {
+		err := s.Create(ctx, id, "group0", "user0", interest.Data{
+			Condition: cond0,
+		})
+		require.Nil(t, err)
+	}
+	//
+	cases := []struct {
+		name         string
+		ids          []string
+		enabled      bool
+		enabledSince time.Time
+		n            int64
+		condIds      []string
+	}{
+		{
+			name:    "disable",
+			ids:     []string{"interest0", "interest1", "interest2"},
+			n:       2,
+			condIds: []string{"cond0", "cond1"},
+		},
+		{
+			name:         "enable",
+			ids:          []string{"interest3", "interest4", "interest5"},
+			enabled:      true,
+			enabledSince: time.Now(),
+			n:            3,
+			condIds:      []string{"cond0"},
+		},
+		{
+			name:    "some missing",
+			ids:     []string{"interest6", "interest7"},
+			n:       1,
+			condIds: []string{"cond0"},
+		},
+		{
+			name: "none",
+			ids:  []string{"interest8", "interest9"},
+		},
+	}
+	//
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			n, condIds, err := s.SetEnabledBatch(ctx, c.ids, c.enabled, c.enabledSince)
+			assert.Nil(t, err)
+			assert.Equal(t, c.n, n)
+			assert.ElementsMatch(t, c.condIds, condIds)
+		})
+	}
+	sd, _, _, err := s.Read(ctx, "interest4", "", "", true)
+	require.Nil(t, err)
+	assert.True(t, sd.Enabled)
+	assert.WithinDuration(t, cases[1].enabledSince, sd.EnabledSince, timePrecision)
+}
+
+func testChangeOwner(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	ctx := context.TODO()
+	cond0 := newTextCondition("cond0", "key0", "pattern0")
+	owners := [][2]string{
+		{"group0", "user0"},
+		{"group0", "user1"},
+		{"group0", "user1"},
+		{"group1", "user0"},
+		{"group0", "user1"},
+	}
+	for i, owner := range owners {
+		err := s.Create(ctx, fmt.Sprintf("interest%d", i), owner[0], owner[1], interest.Data{
+			Condition: cond0,
+		})
+		require.Nil(t, err)
+	}
+	_, err := s.Delete(ctx, "interest4", "group0", "user1")
+	require.Nil(t, err)
+	//
+	cases := []struct {
+		name       string
+		oldGroupId string
+		oldUserId  string
+		newGroupId string
+		newUserId  string
+		n          int64
+	}{
+		{
+			name:       "none",
+			oldGroupId: "group1",
+			oldUserId:  "user1",
+			newGroupId: "group2",
+			newUserId:  "user2",
+		},
+		{
+			name:       "one",
+			oldGroupId: "group0",
+			oldUserId:  "user0",
+			newGroupId: "group2",
+			newUserId:  "user0",
+			n:          1,
+		},
+		{
+			name:       "two, deleted skipped",
+			oldGroupId: "group0",
+			oldUserId:  "user1",
+			newGroupId: "group2",
+			newUserId:  "user2",
+			n:          2,
+		},
+	}
+	//
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			n, err := s.ChangeOwner(ctx, c.oldGroupId, c.oldUserId, c.newGroupId, c.newUserId)
+			assert.Nil(t, err)
+			assert.Equal(t, c.n, n)
+		})
+	}
+	_, ownerGroupId, ownerUserId, err := s.Read(ctx, "interest2", "group2", "user2", false)
+	require.Nil(t, err)
+	assert.Equal(t, "group2", ownerGroupId)
+	assert.Equal(t, "user2", ownerUserId)
+	_, _, _, err = s.Read(ctx, "interest2", "group0", "user1", false)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testDelete(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	ctx := context.TODO()
+	cond0 := newTextCondition("cond0", "key0", "pattern0")
+	sd0 := interest.Data{
+		Description: "description0",
+		Enabled:     true,
+		Expires:     time.Now().Add(1 * time.Hour),
+		Condition:   cond0,
+	}
+	err := s.Create(ctx, "interest0", "acc0", "user0", sd0)
+	require.Nil(t, err)
+	err = s.Create(ctx, "interest1", "acc0", "user1", interest.Data{
+		Condition: cond0,
+		Public:    true,
+	})
+	require.Nil(t, err)
+	//
+	cases := map[string]struct {
+		id      string
+		groupId string
+		userId  string
+		sd      interest.Data
+		err     error
+	}{
+		"ok": {
+			id:      "interest0",
+			groupId: "acc0",
+			userId:  "user0",
+			sd:      sd0,
+		},
+		"not found by id": {
+			id:      "interest2",
+			groupId: "acc0",
+			userId:  "user0",
+			err:     storage.ErrNotFound,
+		},
+		"not found by acc": {
+			id:      "interest0",
+			groupId: "acc1",
+			userId:  "user0",
+			err:     storage.ErrNotFound,
+		},
+		"can not delete non-own public": {
+			id:      "interest1",
+			groupId: "acc0",
+			userId:  "user0",
+			err:     storage.ErrNotFound,
+		},
+	}
+	//
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			sd, err := s.Delete(ctx, c.id, c.groupId, c.userId)
+			if c.err == nil {
+				require.Nil(t, err)
+				assert.Equal(t, c.sd.Description, sd.Description)
+				assert.Equal(t, c.sd.Enabled, sd.Enabled)
+				assert.True(t, c.sd.Condition.Equal(sd.Condition))
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+	// deleted interest should be invisible
+	_, err = s.Delete(ctx, "interest0", "acc0", "user0")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, _, _, err = s.Read(ctx, "interest0", "", "", true)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = s.Update(ctx, "interest0", "", "", true, sd0)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	err = s.UpdateFollowers(ctx, "interest0", 1)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	err = s.UpdateResultTime(ctx, "interest0", time.Now())
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	n, _, err := s.SetEnabledBatch(ctx, []string{"interest0"}, false, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+	ids, err := s.Search(ctx, interest.Query{Limit: 10, All: true}, interest.Cursor{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"interest1"}, ids)
+	page, err := s.SearchByCondition(ctx, interest.QueryByCondition{CondId: "cond0", Limit: 10}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(page.ConditionMatches))
+}
+
+// createSearchData creates 10 interests: even ones are owned by acc0/user0, odd ones by acc1/user1,
+// interest4 and interest9 are public, the followers count decreases and the creation time increases with the index.
+func testRestore(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	ctx := context.TODO()
+	cond0 := newTextCondition("cond0", "key0", "pattern0")
+	for _, id := range []string{"interest0", "interest1", "interest2"} {
+		err := s.Create(ctx, id, "group0", "user0", interest.Data{
+			Description: id,
+			Enabled:     true,
+			Condition:   cond0,
+		})
+		require.Nil(t, err)
+	}
+	_, err := s.Delete(ctx, "interest0", "group0", "user0")
+	require.Nil(t, err)
+	_, err = s.Delete(ctx, "interest2", "group0", "user0")
+	require.Nil(t, err)
+	//
+	cases := map[string]struct {
+		id      string
+		groupId string
+		userId  string
+		err     error
+	}{
+		"ok": {
+			id:      "interest0",
+			groupId: "group0",
+			userId:  "user0",
+		},
+		"not deleted": {
+			id:      "interest1",
+			groupId: "group0",
+			userId:  "user0",
+			err:     storage.ErrConflict,
+		},
+		"not owned": {
+			id:      "interest2",
+			groupId: "group0",
+			userId:  "user1",
+			err:     storage.ErrNotFound,
+		},
+		"missing": {
+			id:      "interest3",
+			groupId: "group0",
+			userId:  "user0",
+			err:     storage.ErrNotFound,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			sd, err := s.Restore(ctx, c.id, c.groupId, c.userId)
+			assert.ErrorIs(t, err, c.err)
+			if c.err == nil {
+				assert.Equal(t, c.id, sd.Description)
+				assert.Equal(t, cond0, sd.Condition)
+				_, _, _, err = s.Read(ctx, c.id, c.groupId, c.userId, false)
+				assert.Nil(t, err)
+				// the condition id is searchable again
+				page, err := s.SearchByCondition(ctx, interest.QueryByCondition{CondId: "cond0", Limit: 10}, "")
+				assert.Nil(t, err)
+				var ids []string
+				for _, cm := range page.ConditionMatches {
+					ids = append(ids, cm.InterestId)
+				}
+				assert.Contains(t, ids, c.id)
+				// restored twice
+				_, err = s.Restore(ctx, c.id, c.groupId, c.userId)
+				assert.ErrorIs(t, err, storage.ErrConflict)
+			}
+		})
+	}
+	//
+	changes, err := s.ReadChanges(ctx, 0, 0)
+	require.Nil(t, err)
+	last := changes[len(changes)-1]
+	assert.Equal(t, interest.ChangeRestored, last.Type)
+	assert.Equal(t, "interest0", last.InterestId)
+	assert.Nil(t, last.Before)
+	assert.Equal(t, cond0, last.After)
+	assert.Equal(t, []string{"cond0"}, last.CondIdsAdded)
+}
+
+func testListOwned(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	ctx := context.TODO()
+	conds := map[string]condition.Condition{}
+	for i, id := range []string{"interest2", "interest0", "interest1", "interest3"} {
+		conds[id] = newTextCondition(fmt.Sprintf("cond%d", i), "key0", "pattern0")
+		err := s.Create(ctx, id, "group0", "user0", interest.Data{
+			Description: id,
+			Enabled:     true,
+			Public:      id == "interest2",
+			Condition:   conds[id],
+		})
+		require.Nil(t, err)
+	}
+	err := s.Create(ctx, "interest4", "group0", "user1", interest.Data{
+		Condition: newTextCondition("cond4", "key0", "pattern0"),
+	})
+	require.Nil(t, err)
+	_, err = s.Delete(ctx, "interest3", "group0", "user0")
+	require.Nil(t, err)
+	//
+	cases := map[string]struct {
+		userId string
+		cursor string
+		limit  uint32
+		ids    []string
+	}{
+		"all": {
+			userId: "user0",
+			ids: []string{
+				"interest0",
+				"interest1",
+				"interest2",
+			},
+		},
+		"page": {
+			userId: "user0",
+			cursor: "interest0",
+			limit:  1,
+			ids: []string{
+				"interest1",
+			},
+		},
+		"none": {
+			userId: "user2",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			page, err := s.ListOwned(ctx, "group0", c.userId, c.cursor, c.limit)
+			assert.Nil(t, err)
+			var ids []string
+			for _, i := range page {
+				ids = append(ids, i.Id)
+				assert.Equal(t, "group0", i.GroupId)
+				assert.Equal(t, c.userId, i.UserId)
+				assert.Equal(t, i.Id, i.Data.Description)
+				assert.True(t, i.Data.Enabled)
+				assert.Equal(t, i.Id == "interest2", i.Data.Public)
+				assert.True(t, conds[i.Id].Equal(i.Data.Condition), i.Id)
+			}
+			assert.Equal(t, c.ids, ids)
+		})
+	}
+}
+
+func testListDeleted(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	ctx := context.TODO()
+	for _, id := range []string{"interest0", "interest1", "interest2", "interest3"} {
+		err := s.Create(ctx, id, "group0", "user0", interest.Data{
+			Description: id,
+			Condition:   newTextCondition("cond0", "key0", "pattern0"),
+		})
+		require.Nil(t, err)
+	}
+	for _, id := range []string{"interest3", "interest0", "interest2"} {
+		_, err := s.Delete(ctx, id, "group0", "user0")
+		require.Nil(t, err)
+	}
+	_, err := s.Restore(ctx, "interest2", "group0", "user0")
+	require.Nil(t, err)
+	//
+	cases := map[string]struct {
+		userId string
+		cursor string
+		limit  uint32
+		ids    []string
+	}{
+		"all": {
+			userId: "user0",
+			ids: []string{
+				"interest0",
+				"interest3",
+			},
+		},
+		"page": {
+			userId: "user0",
+			cursor: "interest0",
+			limit:  1,
+			ids: []string{
+				"interest3",
+			},
+		},
+		"not owned": {
+			userId: "user1",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			page, err := s.ListDeleted(ctx, "group0", c.userId, c.cursor, c.limit)
+			assert.Nil(t, err)
+			var ids []string
+			for _, d := range page {
+				ids = append(ids, d.Id)
+				assert.Equal(t, d.Id, d.Data.Description)
+				assert.WithinDuration(t, time.Now(), d.DeletedAt, time.Minute)
+			}
+			assert.Equal(t, c.ids, ids)
+		})
+	}
+}
+
+func testPurge(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	ctx := context.TODO()
+	cond0 := newTextCondition("cond0", "key0", "pattern0")
+	cond1 := condition.NewGroupCondition(
+		condition.NewCondition(false),
+		condition.GroupLogicOr,
+		[]condition.Condition{
+			newTextCondition("cond2", "key2", "pattern2"),
+			newTextCondition("cond1", "key1", "pattern1"),
+		},
+	)
+	require.Nil(t, s.Create(ctx, "interest0", "group0", "user0", interest.Data{Condition: cond0, Enabled: true}))
+	require.Nil(t, s.Create(ctx, "interest1", "group0", "user0", interest.Data{Condition: cond1}))
+	require.Nil(t, s.Create(ctx, "interest2", "group0", "user0", interest.Data{Condition: cond0}))
+	require.Nil(t, s.Create(ctx, "interest3", "group0", "user1", interest.Data{Condition: cond0}))
+	_, err := s.Update(ctx, "interest1", "group0", "user0", false, interest.Data{Condition: cond1, Enabled: true})
+	require.Nil(t, err)
+	_, err = s.Delete(ctx, "interest2", "group0", "user0")
+	require.Nil(t, err)
+	//
+	p, err := s.Purge(ctx, "group0", "user0", "service0")
+	require.Nil(t, err)
+	assert.Equal(t, interest.Purged{
+		Count:   3,
+		Deleted: 1,
+		CondIds: []string{
+			"cond0",
+			"cond1",
+			"cond2",
+		},
+	}, p)
+	audits, err := s.ListPurges(ctx, "group0", "user0")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(audits))
+	assert.WithinDuration(t, time.Now(), audits[0].Time, time.Minute)
+	audits[0].Time = time.Time{}
+	assert.Equal(t, interest.PurgeAudit{
+		Purged:  p,
+		Actor:   "service0",
+		GroupId: "group0",
+		UserId:  "user0",
+	}, audits[0])
+	for _, id := range []string{"interest0", "interest1"} {
+		_, _, _, err = s.Read(ctx, id, "", "", true)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	}
+	_, err = s.Restore(ctx, "interest2", "group0", "user0")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	deleted, err := s.ListDeleted(ctx, "group0", "user0", "", 0)
+	assert.Nil(t, err)
+	assert.Empty(t, deleted)
+	// the other account interest is intact
+	_, _, _, err = s.Read(ctx, "interest3", "group0", "user1", false)
+	assert.Nil(t, err)
+	// the ids are free
+	require.Nil(t, s.Create(ctx, "interest2", "group0", "user1", interest.Data{Condition: cond1}))
+	revs, err := s.ListRevisions(ctx, "interest2", "", "", true, 0, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(revs))
+	// only the purge changes remain for the purged interests
+	changes, err := s.ReadChanges(ctx, 0, 0)
+	require.Nil(t, err)
+	var purged []interest.Change
+	for _, c := range changes {
+		switch c.InterestId {
+		case "interest0", "interest1":
+			c.Position = 0
+			c.Time = time.Time{}
+			purged = append(purged, c)
+		}
+	}
+	assert.Equal(t, []interest.Change{
+		{
+			Type:           interest.ChangePurged,
+			InterestId:     "interest0",
+			GroupId:        "group0",
+			UserId:         "user0",
+			Enabled:        true,
+			Before:         cond0,
+			CondIdsRemoved: []string{"cond0"},
+		},
+		{
+			Type:           interest.ChangePurged,
+			InterestId:     "interest1",
+			GroupId:        "group0",
+			UserId:         "user0",
+			Enabled:        true,
+			Before:         cond1,
+			CondIdsRemoved: []string{"cond2", "cond1"},
+		},
+	}, purged)
+	// nothing left to purge
+	p, err = s.Purge(ctx, "group0", "user0", "service1")
+	assert.Nil(t, err)
+	assert.Equal(t, interest.Purged{}, p)
+	// every purge is audited
+	audits, err = s.ListPurges(ctx, "group0", "user0")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(audits))
+	assert.Equal(t, "service0", audits[0].Actor)
+	assert.Equal(t, "service1", audits[1].Actor)
+	assert.Zero(t, audits[1].Count)
+	assert.Empty(t, audits[1].CondIds)
+	// the other account has no purges
+	audits, err = s.ListPurges(ctx, "group0", "user1")
+	assert.Nil(t, err)
+	assert.Empty(t, audits)
+}
+
+func createSearchData(t *testing.T, s storage.Storage) (ids []string) {
+	ctx := context.TODO()
+	for i := 0; i < 10; i++ {
+		cond := condition.NewTextCondition(
+			condition.NewKeyCondition(
+				condition.NewCondition(i%4 == 0), fmt.Sprintf("cond%d", i), fmt.Sprintf("key%d", i%3),
+			),
+			fmt.Sprintf("pattern%d", i%3), i%2 == 0,
+		)
+		sd := interest.Data{
+			Description: fmt.Sprintf("description%d", i%3),
+			Expires:     time.Now().Add(time.Duration(i-2) * time.Hour),
+			Condition:   cond,
+			Public:      i%5 == 4,
+			Followers:   (10 - int64(i)) / 2,
+			Created:     time.Date(2024, 2, i+1, 1, 2, 4, 0, time.UTC),
+		}
+		id := fmt.Sprintf("interest%d", i)
+		err := s.Create(ctx, id, fmt.Sprintf("acc%d", i%2), fmt.Sprintf("user%d", i%2), sd)
+		require.Nil(t, err)
+		ids = append(ids, id)
+	}
+	return
+}
+
+func testSearch(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	ctx := context.TODO()
+	ids := createSearchData(t, s)
+	//
+	cases := map[string]struct {
+		q      interest.Query
+		cursor interest.Cursor
+		ids    []string
+	}{
+		"acc0": {
+			q: interest.Query{
+				Limit:   100,
+				GroupId: "acc0",
+				UserId:  "user0",
+			},
+			ids: []string{ids[0], ids[2], ids[4], ids[6], ids[8]},
+		},
+		"acc1 limit": {
+			q: interest.Query{
+				Limit:   3,
+				GroupId: "acc1",
+				UserId:  "user1",
+			},
+			ids: []string{ids[1], ids[3], ids[5]},
+		},
+		"pattern filter": {
+			q: interest.Query{
+				Limit:   100,
+				GroupId: "acc0",
+				UserId:  "user0",
+				Pattern: "description1",
+			},
+			ids: []string{ids[4]},
+		},
+		"id asc w/ cursor": {
+			q: interest.Query{
+				Limit:   2,
+				GroupId: "acc0",
+				UserId:  "user0",
+			},
+			cursor: interest.Cursor{
+				Id: ids[2],
+			},
+			ids: []string{ids[4], ids[6]},
+		},
+		"id desc w/ cursor": {
+			q: interest.Query{
+				Limit:   2,
+				GroupId: "acc0",
+				UserId:  "user0",
+				Order:   interest.OrderDesc,
+			},
+			cursor: interest.Cursor{
+				Id: ids[6],
+			},
+			ids: []string{ids[4], ids[2]},
+		},
+		"include public": {
+			q: interest.Query{
+				Limit:         100,
+				GroupId:       "acc0",
+				UserId:        "user0",
+				IncludePublic: true,
+			},
+			ids: []string{ids[0], ids[2], ids[4], ids[6], ids[8], ids[9]},
+		},
+		"private only": {
+			q: interest.Query{
+				Limit:       100,
+				GroupId:     "acc0",
+				UserId:      "user0",
+				PrivateOnly: true,
+			},
+			ids: []string{ids[0], ids[2], ids[6], ids[8]},
+		},
+		"all": {
+			q: interest.Query{
+				Limit: 10,
+				All:   true,
+			},
+			ids: ids,
+		},
+		"followers asc": {
+			q: interest.Query{
+				Limit:         100,
+				GroupId:       "acc0",
+				UserId:        "user0",
+				IncludePublic: true,
+				Sort:          interest.SortFollowers,
+			},
+			ids: []string{ids[9], ids[8], ids[6], ids[4], ids[2], ids[0]},
+		},
+		"followers asc w/ cursor": {
+			q: interest.Query{
+				Limit:         100,
+				GroupId:       "acc0",
+				UserId:        "user0",
+				IncludePublic: true,
+				Sort:          interest.SortFollowers,
+			},
+			cursor: interest.Cursor{
+				Id:        ids[6],
+				Followers: 2,
+			},
+			ids: []string{ids[4], ids[2], ids[0]},
+		},
+		"followers asc zero followers cursor repeats": {
+			q: interest.Query{
+				Limit:         100,
+				GroupId:       "acc0",
+				UserId:        "user0",
+				IncludePublic: true,
+				Sort:          interest.SortFollowers,
+			},
+			cursor: interest.Cursor{
+				Id: ids[9],
+			},
+			ids: []string{ids[8], ids[6], ids[4], ids[2], ids[0]},
+		},
+		"followers desc": {
+			q: interest.Query{
+				Limit:         4,
+				GroupId:       "acc0",
+				UserId:        "user0",
+				IncludePublic: true,
+				Sort:          interest.SortFollowers,
+				Order:         interest.OrderDesc,
+			},
+			cursor: interest.Cursor{
+				Followers: math.MaxInt64,
+			},
+			ids: []string{ids[0], ids[2], ids[4], ids[6]},
+		},
+		"followers desc w/ cursor": {
+			q: interest.Query{
+				Limit:         4,
+				GroupId:       "acc0",
+				UserId:        "user0",
+				IncludePublic: true,
+				Sort:          interest.SortFollowers,
+				Order:         interest.OrderDesc,
+			},
+			cursor: interest.Cursor{
+				Id:        ids[8],
+				Followers: 3,
+			},
+			ids: []string{ids[4], ids[6], ids[8], ids[9]},
+		},
+		"time created asc": {
+			q: interest.Query{
+				Limit:         10,
+				GroupId:       "acc1",
+				UserId:        "user1",
+				IncludePublic: true,
+				Sort:          interest.SortTimeCreated,
+			},
+			ids: []string{ids[1], ids[3], ids[4], ids[5], ids[7], ids[9]},
+		},
+		"time created asc w/ cursor": {
+			q: interest.Query{
+				Limit:         2,
+				GroupId:       "acc1",
+				UserId:        "user1",
+				IncludePublic: true,
+				Sort:          interest.SortTimeCreated,
+			},
+			cursor: interest.Cursor{
+				Id:        ids[4],
+				CreatedAt: time.Date(2024, 2, 5, 1, 2, 4, 0, time.UTC),
+			},
+			ids: []string{ids[5], ids[7]},
+		},
+		"time created desc": {
+			q: interest.Query{
+				Limit:         3,
+				GroupId:       "acc0",
+				UserId:        "user0",
+				IncludePublic: true,
+				Sort:          interest.SortTimeCreated,
+				Order:         interest.OrderDesc,
+			},
+			cursor: interest.Cursor{
+				CreatedAt: time.Date(2025, 2, 15, 0, 0, 0, 0, time.UTC),
+			},
+			ids: []string{ids[9], ids[8], ids[6]},
+		},
+		"time created desc w/ cursor": {
+			q: interest.Query{
+				Limit:         3,
+				GroupId:       "acc0",
+				UserId:        "user0",
+				IncludePublic: true,
+				Sort:          interest.SortTimeCreated,
+				Order:         interest.OrderDesc,
+			},
+			cursor: interest.Cursor{
+				Id:        ids[8],
+				CreatedAt: time.Date(2024, 2, 9, 1, 2, 4, 0, time.UTC),
+			},
+			ids: []string{ids[6], ids[4], ids[2]},
+		},
+	}
+	//
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			result, err := s.Search(ctx, c.q, c.cursor)
+			assert.Nil(t, err)
+			assert.Equal(t, c.ids, result)
+		})
+	}
+}
+
+// testSearchPages verifies the consecutive pages built using the last result as a next cursor don't overlap or skip.
+func testSearchPages(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	ctx := context.TODO()
+	ids := createSearchData(t, s)
+	//
+	cases := map[string]struct {
+		sort   interest.Sort
+		order  interest.Order
+		cursor interest.Cursor
+		ids    []string
+	}{
+		"id asc": {
+			ids: []string{ids[0], ids[2], ids[4], ids[6], ids[8], ids[9]},
+		},
+		"id desc": {
+			order: interest.OrderDesc,
+			cursor: interest.Cursor{
+				Id: "~",
+			},
+			ids: []string{ids[9], ids[8], ids[6], ids[4], ids[2], ids[0]},
+		},
+		"followers asc": {
+			sort: interest.SortFollowers,
+			ids:  []string{ids[9], ids[8], ids[6], ids[4], ids[2], ids[0]},
+		},
+		"followers desc": {
+			sort:  interest.SortFollowers,
+			order: interest.OrderDesc,
+			cursor: interest.Cursor{
+				Followers: math.MaxInt64,
+			},
+			ids: []string{ids[0], ids[2], ids[4], ids[6], ids[8], ids[9]},
+		},
+		"time created asc": {
+			sort: interest.SortTimeCreated,
+			ids:  []string{ids[0], ids[2], ids[4], ids[6], ids[8], ids[9]},
+		},
+		"time created desc": {
+			sort:  interest.SortTimeCreated,
+			order: interest.OrderDesc,
+			cursor: interest.Cursor{
+				CreatedAt: time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			ids: []string{ids[9], ids[8], ids[6], ids[4], ids[2], ids[0]},
+		},
+	}
+	//
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			q := interest.Query{
+				Limit:         4,
+				GroupId:       "acc0",
+				UserId:        "user0",
+				IncludePublic: true,
+				Sort:          c.sort,
+				Order:         c.order,
+			}
+			cursor := c.cursor
+			var result []string
+			for {
+				page, err := s.Search(ctx, q, cursor)
+				require.Nil(t, err)
+				if len(page) == 0 {
+					break
+				}
+				require.LessOrEqual(t, len(page), int(q.Limit))
+				require.LessOrEqual(t, len(result), len(c.ids))
+				result = append(result, page...)
+				cursor.Id = page[len(page)-1]
+				var sd interest.Data
+				sd, _, _, err = s.Read(ctx, cursor.Id, "", "", true)
+				require.Nil(t, err)
+				cursor.Followers = sd.Followers
+				cursor.CreatedAt = sd.Created
+			}
+			assert.Equal(t, c.ids, result)
+		})
+	}
+}
+
+func testSearchByCondition(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, 24*time.Hour)
+	ctx := context.TODO()
+	cond0 := newTextCondition("cond0", "key0", "term0")
+	cond1 := condition.NewGroupCondition(
+		condition.NewCondition(false),
+		condition.GroupLogicAnd,
+		[]condition.Condition{
+			newTextCondition("cond1", "key1", "term1"),
+			condition.NewNumberCondition(
+				condition.NewKeyCondition(condition.NewCondition(true), "cond0", "key0"),
+				condition.NumOpGte, 42,
+			),
+			condition.NewRegexCondition(
+				condition.NewKeyCondition(condition.NewCondition(false), "cond3", "key3"),
+				"^term3$",
+			),
+			condition.NewRangeCondition(
+				condition.NewKeyCondition(condition.NewCondition(false), "cond4", "key4"),
+				condition.Bound{Value: 10, Inclusive: true},
+				condition.Bound{Value: 100},
+			),
+			condition.NewSetCondition(
+				condition.NewKeyCondition(condition.NewCondition(false), "cond5", "key5"),
+				[]string{"term5"},
+				nil,
+			),
+			condition.NewExistsCondition(condition.NewKeyCondition(condition.NewCondition(false), "cond6", "key6")),
+		},
+	)
+	// expiration not set
+	err := s.Create(ctx, "interest0", "acc0", "user0", interest.Data{
+		Enabled:   true,
+		Condition: cond0,
+	})
+	require.Nil(t, err)
+	// already expired
+	err = s.Create(ctx, "interest1", "acc0", "user0", interest.Data{
+		Enabled:   true,
+		Condition: cond0,
+		Expires:   time.Date(2022, 2, 22, 22, 22, 22, 0, time.UTC),
+	})
+	require.Nil(t, err)
+	// not expired
+	t2 := time.Now().Add(1 * time.Hour).UTC()
+	err = s.Create(ctx, "interest2", "acc1", "user1", interest.Data{
+		Enabled:   true,
+		Condition: cond0,
+		Expires:   t2,
+	})
+	require.Nil(t, err)
+	// temporarily disabled in past but active now
+	err = s.Create(ctx, "interest3", "acc0", "user0", interest.Data{
+		Enabled:   true,
+		Condition: cond1,
+	})
+	require.Nil(t, err)
+	_, _, err = s.SetEnabledBatch(ctx, []string{"interest3"}, true, time.Date(2022, 2, 22, 22, 22, 22, 0, time.UTC))
+	require.Nil(t, err)
+	// not yet
+	err = s.Create(ctx, "interest4", "acc0", "user0", interest.Data{
+		Enabled:   true,
+		Condition: cond0,
+	})
+	require.Nil(t, err)
+	_, _, err = s.SetEnabledBatch(ctx, []string{"interest4"}, true, time.Now().Add(1*time.Hour).UTC())
+	require.Nil(t, err)
+	// disabled
+	err = s.Create(ctx, "interest5", "acc0", "user0", interest.Data{
+		Condition: cond0,
+	})
+	require.Nil(t, err)
+	// enabled, not expired, the condition id is nested
+	err = s.Create(ctx, "interest6", "acc0", "user0", interest.Data{
+		Enabled:   true,
+		Condition: cond1,
+		Expires:   time.Now().Add(48 * time.Hour),
+	})
+	require.Nil(t, err)
+	conds := map[string]condition.Condition{
+		"interest0": cond0,
+		"interest2": cond0,
+		"interest3": cond1,
+		"interest6": cond1,
+	}
+	//
+	cases := map[string]struct {
+		q       interest.QueryByCondition
+		cursor  string
+		ids     []string
+		expires time.Time
+	}{
+		"all": {
+			q: interest.QueryByCondition{
+				CondId: "cond0",
+				Limit:  10,
+			},
+			ids:     []string{"interest0", "interest2", "interest3", "interest6"},
+			expires: t2,
+		},
+		"limit=1": {
+			q: interest.QueryByCondition{
+				CondId: "cond0",
+				Limit:  1,
+			},
+			ids:     []string{"interest0"},
+			expires: time.Now().Add(24 * time.Hour),
+		},
+		"with cursor": {
+			q: interest.QueryByCondition{
+				CondId: "cond0",
+				Limit:  10,
+			},
+			cursor:  "interest2",
+			ids:     []string{"interest3", "interest6"},
+			expires: time.Now().Add(24 * time.Hour),
+		},
+		"nested condition id": {
+			q: interest.QueryByCondition{
+				CondId: "cond1",
+				Limit:  10,
+			},
+			ids:     []string{"interest3", "interest6"},
+			expires: time.Now().Add(24 * time.Hour),
+		},
+		"regex condition id": {
+			q: interest.QueryByCondition{
+				CondId: "cond3",
+				Limit:  10,
+			},
+			ids:     []string{"interest3", "interest6"},
+			expires: time.Now().Add(24 * time.Hour),
+		},
+		"range condition id": {
+			q: interest.QueryByCondition{
+				CondId: "cond4",
+				Limit:  10,
+			},
+			ids:     []string{"interest3", "interest6"},
+			expires: time.Now().Add(24 * time.Hour),
+		},
+		"set condition id": {
+			q: interest.QueryByCondition{
+				CondId: "cond5",
+				Limit:  10,
+			},
+			ids:     []string{"interest3", "interest6"},
+			expires: time.Now().Add(24 * time.Hour),
+		},
+		"exists condition id": {
+			q: interest.QueryByCondition{
+				CondId: "cond6",
+				Limit:  10,
+			},
+			ids:     []string{"interest3", "interest6"},
+			expires: time.Now().Add(24 * time.Hour),
+		},
+		"no matches": {
+			q: interest.QueryByCondition{
+				CondId: "cond2",
+				Limit:  10,
+			},
+			expires: time.Now().Add(24 * time.Hour),
+		},
+	}
+	//
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			page, err := s.SearchByCondition(ctx, c.q, c.cursor)
+			require.Nil(t, err)
+			require.Equal(t, len(c.ids), len(page.ConditionMatches))
+			for i, id := range c.ids {
+				assert.Equal(t, id, page.ConditionMatches[i].InterestId)
+				assert.True(t, conds[id].Equal(page.ConditionMatches[i].Condition))
+			}
+			assert.WithinDuration(t, c.expires, page.Expires, time.Second)
+		})
+	}
+}
+
+func testSearchByConditionStream(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, 24*time.Hour)
+	ctx := context.TODO()
+	cond0 := newTextCondition("cond0", "key0", "term0")
+	cond1 := condition.NewGroupCondition(
+		condition.NewCondition(false),
+		condition.GroupLogicOr,
+		[]condition.Condition{
+			newTextCondition("cond1", "key1", "term1"),
+			newTextCondition("cond0", "key0", "term0"),
+		},
+	)
+	// expiration not set
+	err := s.Create(ctx, "interest0", "acc0", "user0", interest.Data{
+		Enabled:   true,
+		Condition: cond0,
+	})
+	require.Nil(t, err)
+	// already expired
+	err = s.Create(ctx, "interest1", "acc0", "user0", interest.Data{
+		Enabled:   true,
+		Condition: cond0,
+		Expires:   time.Date(2022, 2, 22, 22, 22, 22, 0, time.UTC),
+	})
+	require.Nil(t, err)
+	// not expired
+	err = s.Create(ctx, "interest2", "acc1", "user1", interest.Data{
+		Enabled:   true,
+		Condition: cond1,
+		Expires:   time.Now().Add(2 * time.Hour).UTC(),
+	})
+	require.Nil(t, err)
+	// not enabled yet, limits the result expiration time
+	t3 := time.Now().Add(30 * time.Minute).UTC()
+	err = s.Create(ctx, "interest3", "acc0", "user0", interest.Data{
+		Enabled:   true,
+		Condition: cond0,
+	})
+	require.Nil(t, err)
+	_, _, err = s.SetEnabledBatch(ctx, []string{"interest3"}, true, t3)
+	require.Nil(t, err)
+	// disabled
+	err = s.Create(ctx, "interest4", "acc0", "user0", interest.Data{
+		Condition: cond0,
+	})
+	require.Nil(t, err)
+	// deleted
+	err = s.Create(ctx, "interest5", "acc0", "user0", interest.Data{
+		Enabled:   true,
+		Condition: cond0,
+	})
+	require.Nil(t, err)
+	_, err = s.Delete(ctx, "interest5", "acc0", "user0")
+	require.Nil(t, err)
+	conds := map[string]condition.Condition{
+		"interest0": cond0,
+		"interest2": cond1,
+	}
+	//
+	cases := map[string]struct {
+		condId  string
+		stopErr error
+		ids     []string
+		expires time.Time
+		err     error
+	}{
+		"all": {
+			condId:  "cond0",
+			ids:     []string{"interest0", "interest2"},
+			expires: t3,
+		},
+		"nested condition id": {
+			condId:  "cond1",
+			ids:     []string{"interest2"},
+			expires: time.Now().Add(2 * time.Hour),
+		},
+		"no matches": {
+			condId:  "cond2",
+			expires: time.Now().Add(24 * time.Hour),
+		},
+		"consumer fails": {
+			condId:  "cond0",
+			stopErr: storage.ErrConflict,
+			ids:     []string{"interest0"},
+			err:     storage.ErrConflict,
+		},
+	}
+	//
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var cms []interest.ConditionMatch
+			expires, err := s.SearchByConditionStream(ctx, c.condId, func(cm interest.ConditionMatch) error {
+				cms = append(cms, cm)
+				return c.stopErr
+			})
+			assert.ErrorIs(t, err, c.err)
+			require.Equal(t, len(c.ids), len(cms))
+			for i, id := range c.ids {
+				assert.Equal(t, id, cms[i].InterestId)
+				assert.True(t, conds[id].Equal(cms[i].Condition))
+			}
+			if c.err == nil {
+				assert.WithinDuration(t, c.expires, expires, time.Second)
+			}
+		})
+	}
+}
+
+func testSearchByConditionBatch(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, 24*time.Hour)
+	ctx := context.TODO()
+	cond0 := newTextCondition("cond0", "key0", "term0")
+	cond1 := newTextCondition("cond1", "key1", "term1")
+	cond01 := condition.NewGroupCondition(
+		condition.NewCondition(false),
+		condition.GroupLogicAnd,
+		[]condition.Condition{
+			newTextCondition("cond0", "key0", "term0"),
+			newTextCondition("cond1", "key1", "term1"),
+		},
+	)
+	t3 := time.Now().Add(time.Hour).UTC()
+	for _, c := range []struct {
+		id      string
+		cond    condition.Condition
+		enabled bool
+		expires time.Time
+	}{
+		{id: "interest0", cond: cond0, enabled: true},
+		{id: "interest1", cond: cond01, enabled: true},
+		{id: "interest2", cond: cond1, enabled: true},
+		{id: "interest3", cond: cond01, enabled: true, expires: t3},
+		{id: "interest4", cond: cond0},
+		{id: "interest5", cond: cond1, enabled: true, expires: time.Date(2022, 2, 22, 22, 22, 22, 0, time.UTC)},
+		{id: "interest6", cond: cond1, enabled: true},
+	} {
+		err := s.Create(ctx, c.id, "acc0", "user0", interest.Data{
+			Enabled:   c.enabled,
+			Condition: c.cond,
+			Expires:   c.expires,
+		})
+		require.Nil(t, err)
+	}
+	conds := map[string]condition.Condition{
+		"interest0": cond0,
+		"interest1": cond01,
+		"interest2": cond1,
+		"interest3": cond01,
+		"interest6": cond1,
+	}
+	qByCond := func(condId string, limit uint32, cursor string) interest.QueryByConditionCursor {
+		return interest.QueryByConditionCursor{
+			QueryByCondition: interest.QueryByCondition{
+				CondId: condId,
+				Limit:  limit,
+			},
+			Cursor: cursor,
+		}
+	}
+	//
+	cases := map[string]struct {
+		qs      []interest.QueryByConditionCursor
+		pages   map[string][]string
+		expires map[string]time.Time
+	}{
+		"all": {
+			qs: []interest.QueryByConditionCursor{
+				qByCond("cond0", 10, ""),
+				qByCond("cond1", 10, ""),
+			},
+			pages: map[string][]string{
+				"cond0": {"interest0", "interest1", "interest3"},
+				"cond1": {"interest1", "interest2", "interest3", "interest6"},
+			},
+			expires: map[string]time.Time{
+				"cond0": t3,
+				"cond1": t3,
+			},
+		},
+		"own limits and cursors": {
+			qs: []interest.QueryByConditionCursor{
+				qByCond("cond0", 2, ""),
+				qByCond("cond1", 1, "interest2"),
+			},
+			pages: map[string][]string{
+				"cond0": {"interest0", "interest1"},
+				"cond1": {"interest3"},
+			},
+			expires: map[string]time.Time{
+				"cond0": time.Now().Add(24 * time.Hour),
+				"cond1": t3,
+			},
+		},
+		"no limit": {
+			qs: []interest.QueryByConditionCursor{
+				qByCond("cond1", 0, "interest3"),
+			},
+			pages: map[string][]string{
+				"cond1": {"interest6"},
+			},
+			expires: map[string]time.Time{
+				"cond1": time.Now().Add(24 * time.Hour),
+			},
+		},
+		"no matches": {
+			qs: []interest.QueryByConditionCursor{
+				qByCond("cond0", 10, ""),
+				qByCond("cond2", 10, ""),
+			},
+			pages: map[string][]string{
+				"cond0": {"interest0", "interest1", "interest3"},
+				"cond2": nil,
+			},
+			expires: map[string]time.Time{
+				"cond0": t3,
+				"cond2": time.Now().Add(24 * time.Hour),
+			},
+		},
+		"empty": {
+			pages:   map[string][]string{},
+			expires: map[string]time.Time{},
+		},
+	}
+	//
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			batch, err := s.SearchByConditionBatch(ctx, c.qs)
+			require.Nil(t, err)
+			require.Equal(t, len(c.pages), len(batch.Pages))
+			ids := map[string]bool{}
+			for condId, expectedIds := range c.pages {
+				page, found := batch.Pages[condId]
+				require.True(t, found, condId)
+				assert.Equal(t, expectedIds, page.InterestIds, condId)
+				assert.WithinDuration(t, c.expires[condId], page.Expires, time.Second, condId)
+				for _, id := range expectedIds {
+					ids[id] = true
+				}
+			}
+			// every interest condition is returned once
+			require.Equal(t, len(ids), len(batch.Conditions))
+			for id := range ids {
+				assert.True(t, conds[id].Equal(batch.Conditions[id]), id)
+			}
+		})
+	}
+}
+
+func testRevisions(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	ctx := context.TODO()
+	cond0 := newTextCondition("cond0", "key0", "pattern0")
+	cond1 := newTextCondition("cond1", "key1", "pattern1")
+	err := s.Create(ctx, "interest0", "group0", "user0", interest.Data{
+		Description: "revision 1",
+		Condition:   cond0,
+	})
+	require.Nil(t, err)
+	_, err = s.Update(ctx, "interest0", "group0", "user0", false, interest.Data{
+		Description: "revision 2",
+		Enabled:     true,
+		Condition:   cond1,
+	})
+	require.Nil(t, err)
+	_, err = s.Update(ctx, "interest0", "", "", true, interest.Data{
+		Description: "revision 3",
+		Condition:   cond0,
+	})
+	require.Nil(t, err)
+	err = s.Create(ctx, "interest1", "group0", "user0", interest.Data{
+		Condition: cond1,
+	})
+	require.Nil(t, err)
+	_, err = s.Delete(ctx, "interest1", "group0", "user0")
+	require.Nil(t, err)
+	//
+	revs, err := s.ListRevisions(ctx, "interest0", "group0", "user0", false, 0, 0)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(revs))
+	expected := []struct {
+		groupId string
+		userId  string
+		descr   string
+		enabled bool
+		cond    condition.Condition
+	}{
+		{"group0", "user0", "revision 1", false, cond0},
+		{"group0", "user0", "revision 2", true, cond1},
+		{"", "", "revision 3", false, cond0},
+	}
+	for i, rev := range revs {
+		assert.Equal(t, uint64(i+1), rev.Number)
+		assert.Equal(t, expected[i].groupId, rev.GroupId)
+		assert.Equal(t, expected[i].userId, rev.UserId)
+		assert.WithinDuration(t, time.Now(), rev.Time, time.Minute)
+		assert.Equal(t, expected[i].descr, rev.Data.Description)
+		assert.Equal(t, expected[i].enabled, rev.Data.Enabled)
+		assert.Equal(t, expected[i].cond, rev.Data.Condition)
+	}
+	//
+	listCases := map[string]struct {
+		id       string
+		groupId  string
+		userId   string
+		internal bool
+		cursor   uint64
+		limit    uint32
+		numbers  []uint64
+		err      error
+	}{
+		"page": {
+			id:      "interest0",
+			groupId: "group0",
+			userId:  "user0",
+			cursor:  1,
+			limit:   1,
+			numbers: []uint64{2},
+		},
+		"end": {
+			id:      "interest0",
+			groupId: "group0",
+			userId:  "user0",
+			cursor:  3,
+		},
+		"internal": {
+			id:       "interest0",
+			internal: true,
+			numbers:  []uint64{1, 2, 3},
+		},
+		"not owned": {
+			id:      "interest0",
+			groupId: "group0",
+			userId:  "user1",
+			err:     storage.ErrNotFound,
+		},
+		"deleted": {
+			id:      "interest1",
+			groupId: "group0",
+			userId:  "user0",
+			err:     storage.ErrNotFound,
+		},
+		"missing": {
+			id:       "interest2",
+			internal: true,
+			err:      storage.ErrNotFound,
+		},
+	}
+	for k, c := range listCases {
+		t.Run("list "+k, func(t *testing.T) {
+			page, err := s.ListRevisions(ctx, c.id, c.groupId, c.userId, c.internal, c.cursor, c.limit)
+			assert.ErrorIs(t, err, c.err)
+			var numbers []uint64
+			for _, rev := range page {
+				numbers = append(numbers, rev.Number)
+			}
+			assert.Equal(t, c.numbers, numbers)
+		})
+	}
+	//
+	readCases := map[string]struct {
+		id      string
+		groupId string
+		userId  string
+		number  uint64
+		descr   string
+		err     error
+	}{
+		"ok": {
+			id:      "interest0",
+			groupId: "group0",
+			userId:  "user0",
+			number:  2,
+			descr:   "revision 2",
+		},
+		"missing number": {
+			id:      "interest0",
+			groupId: "group0",
+			userId:  "user0",
+			number:  4,
+			err:     storage.ErrNotFound,
+		},
+		"not owned": {
+			id:      "interest0",
+			groupId: "group1",
+			userId:  "user0",
+			number:  1,
+			err:     storage.ErrNotFound,
+		},
+		"deleted": {
+			id:      "interest1",
+			groupId: "group0",
+			userId:  "user0",
+			number:  1,
+			err:     storage.ErrNotFound,
+		},
+	}
+	for k, c := range readCases {
+		t.Run("read "+k, func(t *testing.T) {
+			rev, err := s.ReadRevision(ctx, c.id, c.groupId, c.userId, false, c.number)
+			assert.ErrorIs(t, err, c.err)
+			if c.err == nil {
+				assert.Equal(t, c.number, rev.Number)
+				assert.Equal(t, c.descr, rev.Data.Description)
+			}
+		})
+	}
+	//
+	restoreCases := map[string]struct {
+		id      string
+		groupId string
+		userId  string
+		number  uint64
+	}{
+		"missing number": {
+			id:      "interest0",
+			groupId: "group0",
+			userId:  "user0",
+			number:  4,
+		},
+		"not owned": {
+			id:      "interest0",
+			groupId: "group1",
+			userId:  "user0",
+			number:  1,
+		},
+		"deleted": {
+			id:      "interest1",
+			groupId: "group0",
+			userId:  "user0",
+			number:  1,
+		},
+	}
+	for k, c := range restoreCases {
+		t.Run("restore "+k, func(t *testing.T) {
+			_, _, err := s.RestoreRevision(ctx, c.id, c.groupId, c.userId, false, c.number, time.Now())
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+		})
+	}
+	t.Run("restore", func(t *testing.T) {
+		updated := time.Now().UTC()
+		rev, prev, err := s.RestoreRevision(ctx, "interest0", "group0", "user0", false, 2, updated)
+		require.Nil(t, err)
+		assert.Equal(t, uint64(2), rev.Number)
+		assert.Equal(t, "revision 3", prev.Description)
+		assert.Equal(t, cond0, prev.Condition)
+		sd, _, _, err := s.Read(ctx, "interest0", "group0", "user0", false)
+		require.Nil(t, err)
+		assert.Equal(t, "revision 2", sd.Description)
+		assert.True(t, sd.Enabled)
+		assert.Equal(t, cond1, sd.Condition)
+		assert.WithinDuration(t, updated, sd.Updated, timePrecision)
+		revs, err = s.ListRevisions(ctx, "interest0", "group0", "user0", false, 3, 0)
+		require.Nil(t, err)
+		require.Equal(t, 1, len(revs))
+		assert.Equal(t, uint64(4), revs[0].Number)
+		assert.Equal(t, "group0", revs[0].GroupId)
+		assert.Equal(t, "revision 2", revs[0].Data.Description)
+	})
+}
+
+func testReadChanges(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	ctx := context.TODO()
+	cond0 := newTextCondition("cond0", "key0", "pattern0")
+	cond1 := newTextCondition("cond1", "key1", "pattern1")
+	cond2 := condition.NewGroupCondition(
+		condition.NewCondition(false),
+		condition.GroupLogicAnd,
+		[]condition.Condition{
+			newTextCondition("cond1", "key1", "pattern1"),
+			newTextCondition("cond2", "key2", "pattern2"),
+		},
+	)
+	changes, err := s.ReadChanges(ctx, 0, 0)
+	require.Nil(t, err)
+	assert.Empty(t, changes)
+	//
+	err = s.Create(ctx, "interest0", "group0", "user0", interest.Data{
+		Condition: cond0,
+	})
+	require.Nil(t, err)
+	err = s.Create(ctx, "interest1", "group0", "user1", interest.Data{
+		Condition: cond2,
+		Enabled:   true,
+	})
+	require.Nil(t, err)
+	// failed mutations should not produce any change
+	err = s.Create(ctx, "interest0", "group0", "user0", interest.Data{
+		Condition: cond1,
+	})
+	require.ErrorIs(t, err, storage.ErrConflict)
+	_, err = s.Update(ctx, "interest2", "group0", "user0", false, interest.Data{
+		Condition: cond1,
+	})
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = s.Update(ctx, "interest0", "group0", "user0", false, interest.Data{
+		Condition: cond1,
+		Enabled:   true,
+	})
+	require.Nil(t, err)
+	n, _, err := s.SetEnabledBatch(ctx, []string{"interest1", "interest2"}, false, time.Time{})
+	require.Nil(t, err)
+	require.Equal(t, int64(1), n)
+	n, err = s.ChangeOwner(ctx, "group0", "user0", "group1", "user1")
+	require.Nil(t, err)
+	require.Equal(t, int64(1), n)
+	_, err = s.Delete(ctx, "interest0", "group1", "user1")
+	require.Nil(t, err)
+	//
+	expected := []interest.Change{
+		{
+			Type:         interest.ChangeCreated,
+			InterestId:   "interest0",
+			GroupId:      "group0",
+			UserId:       "user0",
+			After:        cond0,
+			CondIdsAdded: []string{"cond0"},
+		},
+		{
+			Type:         interest.ChangeCreated,
+			InterestId:   "interest1",
+			GroupId:      "group0",
+			UserId:       "user1",
+			Enabled:      true,
+			After:        cond2,
+			CondIdsAdded: []string{"cond1", "cond2"},
+		},
+		{
+			Type:           interest.ChangeUpdated,
+			InterestId:     "interest0",
+			GroupId:        "group0",
+			UserId:         "user0",
+			Enabled:        true,
+			Before:         cond0,
+			After:          cond1,
+			CondIdsAdded:   []string{"cond1"},
+			CondIdsRemoved: []string{"cond0"},
+		},
+		{
+			Type:       interest.ChangeEnabled,
+			InterestId: "interest1",
+			GroupId:    "group0",
+			UserId:     "user1",
+			Before:     cond2,
+			After:      cond2,
+		},
+		{
+			Type:       interest.ChangeOwner,
+			InterestId: "interest0",
+			GroupId:    "group1",
+			UserId:     "user1",
+			Enabled:    true,
+			Before:     cond1,
+			After:      cond1,
+		},
+		{
+			Type:           interest.ChangeDeleted,
+			InterestId:     "interest0",
+			GroupId:        "group1",
+			UserId:         "user1",
+			Enabled:        true,
+			Before:         cond1,
+			CondIdsRemoved: []string{"cond1"},
+		},
+	}
+	changes, err = s.ReadChanges(ctx, 0, 0)
+	require.Nil(t, err)
+	require.Equal(t, len(expected), len(changes))
+	for i, c := range changes {
+		if i > 0 {
+			assert.Greater(t, c.Position, changes[i-1].Position)
+		}
+		assert.WithinDuration(t, time.Now(), c.Time, time.Minute)
+		c.Position = 0
+		c.Time = time.Time{}
+		assert.Equal(t, expected[i], c, fmt.Sprintf("change #%d", i))
+	}
+	//
+	cases := map[string]struct {
+		after     uint64
+		limit     uint32
+		positions []uint64
+	}{
+		"all": {
+			positions: []uint64{
+				changes[0].Position, changes[1].Position, changes[2].Position,
+				changes[3].Position, changes[4].Position, changes[5].Position,
+			},
+		},
+		"resume": {
+			after: changes[1].Position,
+			limit: 2,
+			positions: []uint64{
+				changes[2].Position, changes[3].Position,
+			},
+		},
+		"end": {
+			after: changes[5].Position,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			page, err := s.ReadChanges(ctx, c.after, c.limit)
+			assert.Nil(t, err)
+			var positions []uint64
+			for _, pc := range page {
+				positions = append(positions, pc.Position)
+			}
+			assert.Equal(t, c.positions, positions)
+		})
+	}
+}
+
+// testConcurrentChanges checks the concurrent mutations don't fail and don't lose their changes, e.g. when the storage
+// serializes the change positions and retries the conflicting transactions.
+func testConcurrentChanges(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	ctx := context.TODO()
+	const count = 16
+	var wg sync.WaitGroup
+	errs := make([]error, count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.Create(ctx, fmt.Sprintf("interest%d", i), "group0", fmt.Sprintf("user%d", i), interest.Data{
+				Condition: newTextCondition(fmt.Sprintf("cond%d", i), "key0", "pattern0"),
+			})
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		require.Nil(t, err, fmt.Sprintf("interest%d", i))
+	}
+	changes, err := s.ReadChanges(ctx, 0, 0)
+	require.Nil(t, err)
+	require.Equal(t, count, len(changes))
+	ids := make(map[string]bool)
+	for i, c := range changes {
+		if i > 0 {
+			assert.Greater(t, c.Position, changes[i-1].Position)
+		}
+		assert.Equal(t, interest.ChangeCreated, c.Type)
+		ids[c.InterestId] = true
+	}
+	assert.Equal(t, count, len(ids))
+}
+
+func testUsage(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	ctx := context.TODO()
+	cond0 := newTextCondition("cond0", "key0", "pattern0")
+	for i := 0; i < 5; i++ {
+		err := s.Create(ctx, fmt.Sprintf("interest%d", i), "group0", "user0", interest.Data{
+			Condition: cond0,
+			Public:    i%2 == 0,
+		})
+		require.Nil(t, err)
+	}
+	err := s.Create(ctx, "interest5", "group0", "user1", interest.Data{
+		Condition: cond0,
+		Public:    true,
+	})
+	require.Nil(t, err)
+	_, err = s.Delete(ctx, "interest0", "group0", "user0")
+	require.Nil(t, err)
+	//
+	cases := map[string]struct {
+		groupId string
+		userId  string
+		u       interest.Usage
+	}{
+		"deleted excluded": {
+			groupId: "group0",
+			userId:  "user0",
+			u: interest.Usage{
+				Interests: 4,
+				Public:    2,
+			},
+		},
+		"another user": {
+			groupId: "group0",
+			userId:  "user1",
+			u: interest.Usage{
+				Interests: 1,
+				Public:    1,
+			},
+		},
+		"none": {
+			groupId: "group1",
+			userId:  "user0",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			u, err := s.Usage(ctx, c.groupId, c.userId)
+			assert.Nil(t, err)
+			assert.Equal(t, c.u, u)
+		})
+	}
+}
+
+func testQuotas(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	ctx := storage.WithQuotas(context.TODO(), storage.Quotas{
+		Default: interest.Limits{
+			Interests:      3,
+			Public:         1,
+			ConditionDepth: 1,
+		},
+	})
+	cond0 := newTextCondition("cond0", "key0", "pattern0")
+	deep := condition.NewGroupCondition(condition.NewCondition(false), condition.GroupLogicAnd, []condition.Condition{
+		cond0,
+		newTextCondition("cond1", "key1", "pattern1"),
+	})
+	assertUsage := func(t *testing.T, userId string, expected interest.Usage) {
+		u, err := s.Usage(context.TODO(), "group0", userId)
+		require.Nil(t, err)
+		assert.Equal(t, expected, u)
+	}
+	//
+	err := s.Create(ctx, "interest0", "group0", "user0", interest.Data{
+		Condition: cond0,
+		Public:    true,
+	})
+	require.Nil(t, err)
+	err = s.Create(ctx, "interest1", "group0", "user0", interest.Data{
+		Condition: cond0,
+	})
+	require.Nil(t, err)
+	t.Run("create public exceeded", func(t *testing.T) {
+		err = s.Create(ctx, "interest2", "group0", "user0", interest.Data{
+			Condition: cond0,
+			Public:    true,
+		})
+		assert.ErrorIs(t, err, storage.ErrQuotaExceeded)
+		_, _, _, err = s.Read(ctx, "interest2", "group0", "user0", false)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+		assertUsage(t, "user0", interest.Usage{Interests: 2, Public: 1})
+	})
+	t.Run("create depth exceeded", func(t *testing.T) {
+		err = s.Create(ctx, "interest2", "group0", "user0", interest.Data{
+			Condition: deep,
+		})
+		assert.ErrorIs(t, err, storage.ErrQuotaExceeded)
+		assertUsage(t, "user0", interest.Usage{Interests: 2, Public: 1})
+	})
+	err = s.Create(ctx, "interest2", "group0", "user0", interest.Data{
+		Condition: cond0,
+	})
+	require.Nil(t, err)
+	t.Run("create interests exceeded", func(t *testing.T) {
+		err = s.Create(ctx, "interest3", "group0", "user0", interest.Data{
+			Condition: cond0,
+		})
+		assert.ErrorIs(t, err, storage.ErrQuotaExceeded)
+		assertUsage(t, "user0", interest.Usage{Interests: 3, Public: 1})
+	})
+	t.Run("update public exceeded", func(t *testing.T) {
+		_, err = s.Update(ctx, "interest1", "group0", "user0", false, interest.Data{
+			Condition: cond0,
+			Public:    true,
+		})
+		assert.ErrorIs(t, err, storage.ErrQuotaExceeded)
+		var sd interest.Data
+		sd, _, _, err = s.Read(ctx, "interest1", "group0", "user0", false)
+		require.Nil(t, err)
+		assert.False(t, sd.Public)
+	})
+	t.Run("update already public", func(t *testing.T) {
+		_, err = s.Update(ctx, "interest0", "group0", "user0", false, interest.Data{
+			Condition: cond0,
+			Public:    true,
+		})
+		assert.Nil(t, err)
+	})
+	t.Run("update depth exceeded", func(t *testing.T) {
+		_, err = s.Update(ctx, "interest1", "group0", "user0", false, interest.Data{
+			Condition: deep,
+		})
+		assert.ErrorIs(t, err, storage.ErrQuotaExceeded)
+	})
+	t.Run("restore interests exceeded", func(t *testing.T) {
+		_, err = s.Delete(ctx, "interest2", "group0", "user0")
+		require.Nil(t, err)
+		err = s.Create(ctx, "interest3", "group0", "user0", interest.Data{
+			Condition: cond0,
+		})
+		require.Nil(t, err)
+		_, err = s.Restore(ctx, "interest2", "group0", "user0")
+		assert.ErrorIs(t, err, storage.ErrQuotaExceeded)
+		_, err = s.Delete(ctx, "interest3", "group0", "user0")
+		require.Nil(t, err)
+		_, err = s.Restore(ctx, "interest2", "group0", "user0")
+		assert.Nil(t, err)
+		assertUsage(t, "user0", interest.Usage{Interests: 3, Public: 1})
+	})
+	// the interests created without the quotas
+	for i, userId := range []string{"user1", "user1", "user2"} {
+		sd := interest.Data{
+			Condition: cond0,
+			Public:    i == 0,
+		}
+		if userId == "user2" {
+			sd.Condition = deep
+		}
+		err = s.Create(context.TODO(), fmt.Sprintf("interest%d", 4+i), "group0", userId, sd)
+		require.Nil(t, err)
+	}
+	t.Run("change owner interests exceeded", func(t *testing.T) {
+		var n int64
+		n, err = s.ChangeOwner(ctx, "group0", "user1", "group0", "user0")
+		assert.ErrorIs(t, err, storage.ErrQuotaExceeded)
+		assert.Equal(t, int64(0), n)
+		assertUsage(t, "user0", interest.Usage{Interests: 3, Public: 1})
+		assertUsage(t, "user1", interest.Usage{Interests: 2, Public: 1})
+	})
+	t.Run("change owner depth exceeded", func(t *testing.T) {
+		_, err = s.ChangeOwner(ctx, "group0", "user2", "group0", "user3")
+		assert.ErrorIs(t, err, storage.ErrQuotaExceeded)
+		assertUsage(t, "user2", interest.Usage{Interests: 1})
+	})
+	t.Run("change owner within limits", func(t *testing.T) {
+		var n int64
+		n, err = s.ChangeOwner(ctx, "group0", "user1", "group0", "user3")
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), n)
+		assertUsage(t, "user3", interest.Usage{Interests: 2, Public: 1})
+	})
+}
+
+func testConcurrentQuotas(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	const limit = 5
+	ctx := storage.WithQuotas(context.TODO(), storage.Quotas{
+		Default: interest.Limits{
+			Interests: limit,
+		},
+	})
+	const count = 16
+	var wg sync.WaitGroup
+	errs := make([]error, count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.Create(ctx, fmt.Sprintf("interest%d", i), "group0", "user0", interest.Data{
+				Condition: newTextCondition(fmt.Sprintf("cond%d", i), "key0", "pattern0"),
+			})
+		}()
+	}
+	wg.Wait()
+	var created int
+	for i, err := range errs {
+		switch err {
+		case nil:
+			created++
+		default:
+			assert.ErrorIs(t, err, storage.ErrQuotaExceeded, fmt.Sprintf("interest%d", i))
+		}
+	}
+	assert.Equal(t, limit, created)
+	u, err := s.Usage(ctx, "group0", "user0")
+	require.Nil(t, err)
+	assert.Equal(t, interest.Usage{Interests: limit}, u)
+	changes, err := s.ReadChanges(ctx, 0, 0)
+	require.Nil(t, err)
+	assert.Equal(t, limit, len(changes))
+}
+
+func testCount(t *testing.T, newStorage NewStorageFunc) {
+	s := newStorage(t, time.Minute)
+	ctx := context.TODO()
+	cond0 := newTextCondition("cond0", "key0", "pattern0")
+	for i := 0; i < 3; i++ {
+		err := s.Create(ctx, fmt.Sprintf("interest%d", i), "group0", fmt.Sprintf("user%d", i%2), interest.Data{
+			Condition: cond0,
+		})
+		require.Nil(t, err)
+	}
+	count, err := s.Count(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), count)
+	count, err = s.CountUsersUnique(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+}
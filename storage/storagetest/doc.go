@@ -0,0 +1,3 @@
+// Package storagetest contains the backend-agnostic conformance test suite for the storage.Storage implementations.
+// Every storage backend should run the suite from its own tests to be verified against the same expectations.
+package storagetest